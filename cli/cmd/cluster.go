@@ -265,6 +265,54 @@ Examples:
 	},
 }
 
+var clusterUpgradeK8sCmd = &cobra.Command{
+	Use:   "upgrade-k8s",
+	Short: "Upgrade Kubernetes on the cluster",
+	Long: `Upgrade Kubernetes with talosctl upgrade-k8s.
+
+Before upgrading, the daemon checks the version skew rules (one minor version
+at a time, kubelets at most three minor versions behind) and scans the
+manifests of the cluster services and configured apps for APIs the target
+version no longer serves. The upgrade refuses to start when a check fails.
+
+A dry run prints the checks and what talosctl would change, even when checks
+fail, without changing anything. After a successful upgrade the version is
+recorded as cluster.kubernetes.version.
+
+Examples:
+  # See what upgrading would do
+  wild cluster upgrade-k8s --version v1.34.1 --dry-run
+
+  # Upgrade
+  wild cluster upgrade-k8s --version v1.34.1`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		inst, err := getInstanceName()
+		if err != nil {
+			return err
+		}
+
+		version, _ := cmd.Flags().GetString("version")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+
+		resp, err := apiClient.Post(fmt.Sprintf("/api/v1/instances/%s/cluster/kubernetes/upgrade", inst), map[string]interface{}{
+			"version": version,
+			"dry_run": dryRun,
+		})
+		if err != nil {
+			if !dryRun {
+				return fmt.Errorf("%w\nRun with --dry-run for the full preflight report", err)
+			}
+			return err
+		}
+
+		fmt.Println(resp.GetString("message"))
+		fmt.Println()
+
+		return followOperation(resp.GetString("operation_id"))
+	},
+}
+
 func init() {
 	clusterCmd.AddCommand(clusterBootstrapCmd)
 	clusterCmd.AddCommand(clusterStatusCmd)
@@ -273,9 +321,13 @@ func init() {
 	clusterCmd.AddCommand(clusterConfigGenerateCmd)
 	clusterCmd.AddCommand(clusterTalosconfigCmd)
 	clusterCmd.AddCommand(clusterEndpointsCmd)
+	clusterCmd.AddCommand(clusterUpgradeK8sCmd)
 
 	clusterEndpointsCmd.Flags().Bool("nodes", false, "Include all control node IPs as fallback endpoints")
 
+	clusterUpgradeK8sCmd.Flags().String("version", "", "Kubernetes version to upgrade to (e.g. v1.34.1)")
+	clusterUpgradeK8sCmd.Flags().Bool("dry-run", false, "Run the checks and show what would change without upgrading")
+
 	// Add --persist flags to config commands
 	clusterTalosconfigCmd.Flags().Bool("persist", false, "Save talosconfig to instance directory")
 	clusterKubeconfigCmd.Flags().Bool("persist", false, "Save kubeconfig to instance directory")
@@ -1,12 +1,18 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
+	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 
 	"github.com/spf13/cobra"
 
+	"github.com/wild-cloud/wild-central/wild/internal/client"
 	"github.com/wild-cloud/wild-central/wild/internal/config"
 )
 
@@ -19,13 +25,55 @@ var instanceCmd = &cobra.Command{
 var instanceCreateCmd = &cobra.Command{
 	Use:   "create <name>",
 	Short: "Create a new instance",
-	Args:  cobra.ExactArgs(1),
+	Long: `Create a new instance, optionally from a template.
+
+Templates provide a starting configuration. Values passed with --set
+override template values and are validated before the instance is created.
+
+Examples:
+  wild instance create home
+  wild instance create home --template home-lab-single-node
+  wild instance create prod --template ha-3-control-plane --set cluster.nodes.control.vip=192.168.8.20
+  wild instance create lab --description "Basement lab" --label env=dev`,
+	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		name := args[0]
+		template, _ := cmd.Flags().GetString("template")
+		sets, _ := cmd.Flags().GetStringArray("set")
+		description, _ := cmd.Flags().GetString("description")
+		labelArgs, _ := cmd.Flags().GetStringArray("label")
 
-		resp, err := apiClient.Post("/api/v1/instances", map[string]string{
+		labels, err := parseLabels(labelArgs)
+		if err != nil {
+			return err
+		}
+
+		values := make(map[string]string)
+		for _, set := range sets {
+			key, value, ok := strings.Cut(set, "=")
+			if !ok || key == "" {
+				return fmt.Errorf("invalid --set value %q (expected key=value)", set)
+			}
+			values[key] = value
+		}
+
+		body := map[string]interface{}{
 			"name": name,
-		})
+		}
+		if template != "" {
+			body["template"] = template
+		}
+		if len(values) > 0 {
+			body["config"] = values
+		}
+		if description != "" {
+			body["description"] = description
+		}
+		if len(labels) > 0 {
+			body["labels"] = labels
+		}
+
+		resp, err := apiClient.Post("/api/v1/instances", body)
 		if err != nil {
 			return err
 		}
@@ -38,11 +86,62 @@ var instanceCreateCmd = &cobra.Command{
 	},
 }
 
+var instanceTemplatesCmd = &cobra.Command{
+	Use:   "templates",
+	Short: "List available instance templates",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		resp, err := apiClient.Get("/api/v1/templates")
+		if err != nil {
+			return err
+		}
+
+		if outputFormat == "json" {
+			return printJSON(resp.Data)
+		}
+
+		if outputFormat == "yaml" {
+			return printYAML(resp.Data)
+		}
+
+		templates := resp.GetArray("templates")
+		if len(templates) == 0 {
+			fmt.Println("No templates found")
+			return nil
+		}
+
+		fmt.Printf("%-25s  %s\n", "NAME", "DESCRIPTION")
+		for _, t := range templates {
+			if tmpl, ok := t.(map[string]interface{}); ok {
+				fmt.Printf("%-25s  %s\n", tmpl["name"], tmpl["description"])
+			}
+		}
+		return nil
+	},
+}
+
 var instanceListCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List all instances",
+	Long: `List instances with their node count, bootstrap state, deployed services
+and last operation.
+
+Examples:
+  wild instance list
+  wild instance list --label env=prod
+  wild instance list --label env=prod,site`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		resp, err := apiClient.Get("/api/v1/instances")
+		selectors, _ := cmd.Flags().GetStringArray("label")
+
+		path := "/api/v1/instances"
+		if len(selectors) > 0 {
+			query := url.Values{}
+			for _, selector := range selectors {
+				query.Add("label", selector)
+			}
+			path += "?" + query.Encode()
+		}
+
+		resp, err := apiClient.Get(path)
 		if err != nil {
 			return err
 		}
@@ -55,22 +154,118 @@ var instanceListCmd = &cobra.Command{
 			return printYAML(resp.Data)
 		}
 
-		instances := resp.GetArray("instances")
-		if len(instances) == 0 {
+		summaries := resp.GetArray("summaries")
+		if len(summaries) == 0 {
 			fmt.Println("No instances found")
 			return nil
 		}
 
-		fmt.Println("INSTANCES:")
-		for _, inst := range instances {
-			if name, ok := inst.(string); ok {
-				fmt.Printf("  - %s\n", name)
+		fmt.Printf("%-20s  %-5s  %-12s  %-8s  %-30s  %s\n", "NAME", "NODES", "BOOTSTRAPPED", "SERVICES", "LAST OPERATION", "LABELS")
+		for _, s := range summaries {
+			summary, ok := s.(map[string]interface{})
+			if !ok {
+				continue
 			}
+
+			nodes, _ := summary["node_count"].(float64)
+			bootstrapped, _ := summary["bootstrapped"].(bool)
+			services, _ := summary["services_deployed"].([]interface{})
+
+			lastOp := "-"
+			if op, ok := summary["last_operation"].(map[string]interface{}); ok {
+				lastOp = fmt.Sprintf("%v %v (%v)", op["type"], op["target"], op["status"])
+			}
+
+			var labels map[string]interface{}
+			if meta, ok := summary["metadata"].(map[string]interface{}); ok {
+				labels, _ = meta["labels"].(map[string]interface{})
+			}
+
+			fmt.Printf("%-20s  %-5d  %-12v  %-8d  %-30s  %s\n",
+				summary["name"], int(nodes), bootstrapped, len(services), lastOp, formatLabels(labels))
+		}
+		return nil
+	},
+}
+
+var instanceUpdateCmd = &cobra.Command{
+	Use:   "update <name>",
+	Short: "Update an instance's description and labels",
+	Long: `Update the description and labels recorded for an instance.
+
+Examples:
+  wild instance update home --description "Home lab"
+  wild instance update home --label env=prod --remove-label staging`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+		labelArgs, _ := cmd.Flags().GetStringArray("label")
+		remove, _ := cmd.Flags().GetStringArray("remove-label")
+
+		labels, err := parseLabels(labelArgs)
+		if err != nil {
+			return err
+		}
+		for _, key := range remove {
+			labels[key] = ""
+		}
+
+		body := map[string]interface{}{}
+		if cmd.Flags().Changed("description") {
+			description, _ := cmd.Flags().GetString("description")
+			body["description"] = description
+		}
+		if len(labels) > 0 {
+			body["labels"] = labels
+		}
+		if len(body) == 0 {
+			return fmt.Errorf("nothing to update: use --description, --label or --remove-label")
+		}
+
+		resp, err := apiClient.Patch(fmt.Sprintf("/api/v1/instances/%s", name), body)
+		if err != nil {
+			return err
+		}
+
+		if outputFormat == "json" {
+			return printJSON(resp.Data)
+		}
+
+		if outputFormat == "yaml" {
+			return printYAML(resp.Data)
 		}
+
+		fmt.Printf("Instance '%s' updated\n", name)
 		return nil
 	},
 }
 
+// parseLabels parses key=value label arguments
+func parseLabels(args []string) (map[string]string, error) {
+	labels := make(map[string]string)
+	for _, arg := range args {
+		key, value, ok := strings.Cut(arg, "=")
+		if !ok || key == "" || value == "" {
+			return nil, fmt.Errorf("invalid label %q (expected key=value)", arg)
+		}
+		labels[key] = value
+	}
+	return labels, nil
+}
+
+// formatLabels renders labels as sorted key=value pairs
+func formatLabels(labels map[string]interface{}) string {
+	if len(labels) == 0 {
+		return "-"
+	}
+	pairs := make([]string, 0, len(labels))
+	for key, value := range labels {
+		pairs = append(pairs, fmt.Sprintf("%s=%v", key, value))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
 var instanceShowCmd = &cobra.Command{
 	Use:   "show <name>",
 	Short: "Show instance details",
@@ -97,6 +292,35 @@ var instanceShowCmd = &cobra.Command{
 			fmt.Printf("Instance: %s\n", instanceName)
 		}
 
+		if summary := resp.GetMap("summary"); summary != nil {
+			if meta, ok := summary["metadata"].(map[string]interface{}); ok {
+				if description, ok := meta["description"].(string); ok && description != "" {
+					fmt.Printf("Description: %s\n", description)
+				}
+				labels, _ := meta["labels"].(map[string]interface{})
+				if len(labels) > 0 {
+					fmt.Printf("Labels: %s\n", formatLabels(labels))
+				}
+				if created, ok := meta["created_at"].(string); ok {
+					fmt.Printf("Created: %s\n", created)
+				}
+				if revision, ok := meta["directory_revision"].(string); ok && revision != "" {
+					fmt.Printf("Directory Revision: %s\n", revision)
+				}
+			}
+			nodes, _ := summary["node_count"].(float64)
+			bootstrapped, _ := summary["bootstrapped"].(bool)
+			fmt.Printf("Nodes: %d\n", int(nodes))
+			fmt.Printf("Bootstrapped: %v\n", bootstrapped)
+			if services, ok := summary["services_deployed"].([]interface{}); ok && len(services) > 0 {
+				names := make([]string, 0, len(services))
+				for _, svc := range services {
+					names = append(names, fmt.Sprintf("%v", svc))
+				}
+				fmt.Printf("Services: %s\n", strings.Join(names, ", "))
+			}
+		}
+
 		// Show key config values
 		if config := resp.GetMap("config"); config != nil {
 			// Check for cloud config (test-cloud structure)
@@ -133,9 +357,29 @@ var instanceShowCmd = &cobra.Command{
 var instanceDeleteCmd = &cobra.Command{
 	Use:   "delete <name>",
 	Short: "Delete an instance",
-	Args:  cobra.ExactArgs(1),
+	Long: `Delete an instance by moving it to the trash.
+
+Deleted instances are kept for the daemon's retention period (30 days by
+default) and can be restored with 'wild instance trash restore'. Use
+--permanent to remove an instance immediately; this destroys its Talos
+secrets, without which an existing cluster can no longer be managed.`,
+	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		name := args[0]
+		permanent, _ := cmd.Flags().GetBool("permanent")
+
+		warnIfClusterReachable(name)
+
+		path := fmt.Sprintf("/api/v1/instances/%s", name)
+		if permanent {
+			// The daemon asks for confirmation; the user types the name
+			resp, err := deleteWithConfirmation(path+"?permanent=true", name)
+			if err != nil || resp == nil {
+				return err
+			}
+			fmt.Printf("Instance '%s' permanently deleted\n", name)
+			return nil
+		}
 
 		// Confirm deletion
 		fmt.Printf("Are you sure you want to delete instance '%s'? (yes/no): ", name)
@@ -146,19 +390,238 @@ var instanceDeleteCmd = &cobra.Command{
 			return nil
 		}
 
-		resp, err := apiClient.Delete(fmt.Sprintf("/api/v1/instances/%s", name))
+		resp, err := apiClient.Delete(path)
 		if err != nil {
 			return err
 		}
 
-		fmt.Printf("Instance '%s' deleted successfully\n", name)
-		if msg, ok := resp.Data["message"].(string); ok && msg != "" {
-			fmt.Println(msg)
+		fmt.Printf("Instance '%s' moved to trash\n", name)
+		if trash := resp.GetMap("trash"); trash != nil {
+			fmt.Printf("Restore it with: wild instance trash restore %v (until %v)\n", trash["id"], trash["expires_at"])
+		}
+		return nil
+	},
+}
+
+var instanceDoctorCmd = &cobra.Command{
+	Use:   "doctor [name]",
+	Short: "Check an instance for problems and optionally repair them",
+	Long: `Check an instance's files for wrong permissions, missing directories,
+stale lock files, mismatched talosconfig locations, orphaned operation logs
+and config that does not parse.
+
+Only reports by default. Use --fix to repair what can be fixed safely;
+config files are never changed.
+
+Examples:
+  wild instance doctor
+  wild instance doctor home --fix`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		fix, _ := cmd.Flags().GetBool("fix")
+
+		var name string
+		if len(args) > 0 {
+			name = args[0]
+		} else {
+			inst, err := getInstanceName()
+			if err != nil {
+				return err
+			}
+			name = inst
+		}
+
+		resp, err := apiClient.Post(fmt.Sprintf("/api/v1/instances/%s/repair", name), map[string]interface{}{
+			"dryRun": !fix,
+		})
+		if err != nil {
+			return err
+		}
+
+		if outputFormat == "json" {
+			return printJSON(resp.Data)
+		}
+
+		if outputFormat == "yaml" {
+			return printYAML(resp.Data)
+		}
+
+		findings := resp.GetArray("findings")
+		if len(findings) == 0 {
+			fmt.Printf("Instance '%s': no problems found\n", name)
+			return nil
+		}
+
+		fixable := 0
+		for _, f := range findings {
+			finding, ok := f.(map[string]interface{})
+			if !ok {
+				continue
+			}
+
+			status := "PROBLEM"
+			switch {
+			case finding["fixed"] == true:
+				status = "FIXED"
+			case finding["fix_error"] != nil:
+				status = "FAILED"
+			case finding["fixable"] == true:
+				status = "FIXABLE"
+				fixable++
+			}
+
+			fmt.Printf("%-8s  %-12v  %v: %v\n", status, finding["check"], finding["path"], finding["problem"])
+			if hint, ok := finding["hint"].(string); ok && hint != "" && finding["fixed"] != true {
+				fmt.Printf("          %s\n", hint)
+			}
+			if fixErr, ok := finding["fix_error"].(string); ok {
+				fmt.Printf("          %s\n", fixErr)
+			}
+		}
+
+		if fixable > 0 && !fix {
+			fmt.Printf("\nRun 'wild instance doctor %s --fix' to repair %d problem(s)\n", name, fixable)
+		}
+		if healthy, _ := resp.Data["healthy"].(bool); !healthy && fix {
+			return fmt.Errorf("some problems could not be repaired")
+		}
+		return nil
+	},
+}
+
+var instanceTrashCmd = &cobra.Command{
+	Use:   "trash",
+	Short: "Manage deleted instances",
+}
+
+var instanceTrashListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List deleted instances",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		resp, err := apiClient.Get("/api/v1/trash")
+		if err != nil {
+			return err
+		}
+
+		if outputFormat == "json" {
+			return printJSON(resp.Data)
 		}
+
+		if outputFormat == "yaml" {
+			return printYAML(resp.Data)
+		}
+
+		entries := resp.GetArray("trash")
+		if len(entries) == 0 {
+			fmt.Println("Trash is empty")
+			return nil
+		}
+
+		fmt.Printf("%-40s  %-20s  %-25s  %s\n", "ID", "NAME", "DELETED", "EXPIRES")
+		for _, e := range entries {
+			if entry, ok := e.(map[string]interface{}); ok {
+				fmt.Printf("%-40v  %-20v  %-25v  %v\n", entry["id"], entry["name"], entry["deleted_at"], entry["expires_at"])
+			}
+		}
+		return nil
+	},
+}
+
+var instanceTrashRestoreCmd = &cobra.Command{
+	Use:   "restore <id|name>",
+	Short: "Restore a deleted instance",
+	Long: `Restore a deleted instance by trash ID, or the most recently deleted
+instance with the given name.
+
+Examples:
+  wild instance trash restore home
+  wild instance trash restore home-20250102030405 --as home-old`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		as, _ := cmd.Flags().GetString("as")
+
+		body := map[string]interface{}{}
+		if as != "" {
+			body["name"] = as
+		}
+
+		resp, err := apiClient.Post(fmt.Sprintf("/api/v1/trash/%s/restore", args[0]), body)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Instance '%s' restored\n", resp.GetString("name"))
+		return nil
+	},
+}
+
+var instanceTrashPurgeCmd = &cobra.Command{
+	Use:   "purge <id|name>",
+	Short: "Permanently remove a deleted instance",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		resp, err := deleteWithConfirmation(fmt.Sprintf("/api/v1/trash/%s", args[0]), args[0])
+		if err != nil || resp == nil {
+			return err
+		}
+
+		fmt.Printf("Purged %s\n", resp.GetString("id"))
 		return nil
 	},
 }
 
+// warnIfClusterReachable prints a warning when the instance's cluster still answers
+func warnIfClusterReachable(name string) {
+	resp, err := apiClient.Get(fmt.Sprintf("/api/v1/instances/%s/utilities/reachable", name))
+	if err != nil {
+		return
+	}
+	if data := resp.GetMap("data"); data != nil {
+		if reachable, _ := data["reachable"].(bool); reachable {
+			fmt.Printf("WARNING: the cluster of instance '%s' is still reachable.\n", name)
+			fmt.Println("Without this instance's Talos secrets the cluster can no longer be managed.")
+		}
+	}
+}
+
+// deleteWithConfirmation sends a DELETE the daemon guards with a confirmation
+// token. The user must type expected to confirm. Returns a nil response if
+// the user cancels.
+func deleteWithConfirmation(path, expected string) (*client.APIResponse, error) {
+	return sendWithConfirmation(path, expected, apiClient.Delete)
+}
+
+// sendWithConfirmation sends a request the daemon guards with a
+// confirmation token, asking the user to type expected and repeating it
+// with the token when the daemon asks for one
+func sendWithConfirmation(path, expected string, send func(path string) (*client.APIResponse, error)) (*client.APIResponse, error) {
+	resp, err := send(path)
+
+	var apiErr *client.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusPreconditionRequired {
+		return resp, err
+	}
+	token, _ := apiErr.Data["confirm_token"].(string)
+	if token == "" {
+		return nil, err
+	}
+
+	fmt.Println(apiErr.Message)
+	fmt.Printf("Type '%s' to confirm: ", expected)
+	var confirm string
+	fmt.Scanln(&confirm)
+	if confirm != expected {
+		fmt.Println("Cancelled")
+		return nil, nil
+	}
+
+	separator := "?"
+	if strings.Contains(path, "?") {
+		separator = "&"
+	}
+	return send(path + separator + "confirm=" + url.QueryEscape(token))
+}
+
 var instanceCurrentCmd = &cobra.Command{
 	Use:   "current",
 	Short: "Show current instance",
@@ -166,26 +629,39 @@ var instanceCurrentCmd = &cobra.Command{
 
 Resolution order:
   1. --instance flag
-  2. ~/.wildcloud/current_instance file
-  3. Auto-select first available instance`,
+  2. This client's context on the daemon (set with 'wild instance use')
+  3. ~/.wildcloud/current_instance file
+  4. The daemon-wide default (set with 'wild instance use --default')
+  5. Auto-select first available instance
+
+With --source, also shows which of these the instance came from.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		inst, err := getInstanceName()
+		inst, source, err := resolveInstance()
 		if err != nil {
 			fmt.Printf("Error: %v\n", err)
 			os.Exit(1)
 		}
 
+		if showSource, _ := cmd.Flags().GetBool("source"); showSource {
+			fmt.Printf("%s (%s)\n", inst, source)
+			return
+		}
 		fmt.Println(inst)
 	},
 }
 
 var instanceUseCmd = &cobra.Command{
 	Use:   "use <name>",
-	Short: "Set the default instance",
-	Long: `Set the default instance to use for all commands.
+	Short: "Set the current instance",
+	Long: `Set the instance to use for all commands.
 
-This persists the instance selection to ~/.wildcloud/current_instance.
-The instance can still be overridden with the --instance flag.`,
+The selection is stored on the daemon for this client only, so other
+clients of the same daemon keep their own current instance. It is also
+written to ~/.wildcloud/current_instance for daemons without per-client
+contexts. The instance can still be overridden with the --instance flag.
+
+With --default, sets the daemon-wide default used by clients that have
+not selected an instance themselves.`,
 	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		instanceToSet := args[0]
@@ -201,7 +677,26 @@ The instance can still be overridden with the --instance flag.`,
 			return fmt.Errorf("instance '%s' not found", instanceToSet)
 		}
 
-		// Persist the selection
+		setDefault, _ := cmd.Flags().GetBool("default")
+		scope := "client"
+		if setDefault {
+			scope = "default"
+		}
+
+		// Persist the selection on the daemon
+		_, err = apiClient.Post("/api/v1/context", map[string]string{
+			"context": instanceToSet,
+			"scope":   scope,
+		})
+		if err != nil && setDefault {
+			return fmt.Errorf("failed to set default instance: %w", err)
+		}
+
+		if setDefault {
+			fmt.Printf("Default instance set to: %s\n", instanceToSet)
+			return nil
+		}
+
 		if err := config.SetCurrentInstance(instanceToSet); err != nil {
 			return fmt.Errorf("failed to set current instance: %w", err)
 		}
@@ -273,4 +768,29 @@ func init() {
 	instanceCmd.AddCommand(instanceCurrentCmd)
 	instanceCmd.AddCommand(instanceUseCmd)
 	instanceCmd.AddCommand(instanceEnvCmd)
+	instanceCmd.AddCommand(instanceTemplatesCmd)
+	instanceCmd.AddCommand(instanceUpdateCmd)
+	instanceCmd.AddCommand(instanceTrashCmd)
+	instanceCmd.AddCommand(instanceDoctorCmd)
+
+	instanceTrashCmd.AddCommand(instanceTrashListCmd)
+	instanceTrashCmd.AddCommand(instanceTrashRestoreCmd)
+	instanceTrashCmd.AddCommand(instanceTrashPurgeCmd)
+
+	instanceCurrentCmd.Flags().Bool("source", false, "Show where the current instance was resolved from")
+	instanceUseCmd.Flags().Bool("default", false, "Set the daemon-wide default instead of this client's instance")
+	instanceDeleteCmd.Flags().Bool("permanent", false, "Delete immediately instead of moving to the trash")
+	instanceDoctorCmd.Flags().Bool("fix", false, "Repair the problems that can be fixed safely")
+	instanceTrashRestoreCmd.Flags().String("as", "", "Restore under a different instance name")
+
+	instanceCreateCmd.Flags().String("template", "", "Template to create the instance from")
+	instanceCreateCmd.Flags().StringArray("set", nil, "Initial config value as key=value (repeatable)")
+	instanceCreateCmd.Flags().String("description", "", "Description of the instance")
+	instanceCreateCmd.Flags().StringArray("label", nil, "Label as key=value (repeatable)")
+
+	instanceListCmd.Flags().StringArray("label", nil, "Only list instances matching a label selector, e.g. env=prod (repeatable)")
+
+	instanceUpdateCmd.Flags().String("description", "", "New description")
+	instanceUpdateCmd.Flags().StringArray("label", nil, "Label to set as key=value (repeatable)")
+	instanceUpdateCmd.Flags().StringArray("remove-label", nil, "Label key to remove (repeatable)")
 }
@@ -2,9 +2,13 @@ package cmd
 
 import (
 	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
+
+	"github.com/wild-cloud/wild-central/wild/internal/client"
 )
 
 // Node commands
@@ -125,6 +129,9 @@ Output shows:
 
 				path, _ := diskMap["path"].(string)
 				size, _ := diskMap["size"].(float64) // JSON numbers are float64
+				diskType, _ := diskMap["type"].(string)
+				model, _ := diskMap["model"].(string)
+				unsuitable, _ := diskMap["unsuitable"].(string)
 
 				// Format size in GB/TB
 				sizeGB := size / (1024 * 1024 * 1024)
@@ -135,13 +142,27 @@ Output shows:
 					sizeStr = fmt.Sprintf("%.1f GB", sizeGB)
 				}
 
-				fmt.Printf("  - %s (%s)\n", path, sizeStr)
+				details := []string{sizeStr}
+				if diskType != "" {
+					details = append(details, diskType)
+				}
+				if model != "" {
+					details = append(details, model)
+				}
+				line := fmt.Sprintf("  - %s (%s)", path, strings.Join(details, ", "))
+				if unsuitable != "" {
+					line += " - not for install: " + unsuitable
+				}
+				fmt.Println(line)
 			}
 		}
 
 		if selected := resp.GetString("selected_disk"); selected != "" {
 			fmt.Printf("\nRecommended Disk: %s\n", selected)
 		}
+		if reason := resp.GetString("selection_reason"); reason != "" {
+			fmt.Printf("  %s\n", reason)
+		}
 
 		fmt.Printf("\nTo add this node:\n")
 		fmt.Printf("  wild node add <hostname> <role> --current-ip %s --target-ip <target-ip> --disk <disk> --interface <interface>\n", nodeIP)
@@ -223,6 +244,14 @@ var nodeShowCmd = &cobra.Command{
 		fmt.Printf("Schematic ID: %s\n", resp.GetString("schematic_id"))
 		fmt.Printf("Configured:   %v\n", resp.Data["configured"])
 		fmt.Printf("Deployed:     %v\n", resp.Data["deployed"])
+		printNodeMetadata("Labels", resp.GetMap("labels"))
+		printNodeMetadata("Taints", resp.GetMap("taints"))
+		printNodeMetadata("Annotations", resp.GetMap("annotations"))
+		printNodeStorage(resp.GetMap("storage"))
+		if inventory := resp.GetMap("inventory"); inventory != nil {
+			fmt.Printf("Hardware:     (gathered %v)\n", inventory["gathered_at"])
+			printInventorySummary(inventory)
+		}
 
 		return nil
 	},
@@ -242,7 +271,15 @@ Examples:
   wild node add control-1 controlplane --current-ip 192.168.1.100 --target-ip 192.168.1.31 --disk /dev/sda
 
   # Node already applied (unusual, only if config was removed manually)
-  wild node add worker-1 worker --target-ip 192.168.1.32 --disk /dev/nvme0n1`,
+  wild node add worker-1 worker --target-ip 192.168.1.32 --disk /dev/nvme0n1
+
+  # GPU worker that only runs pods tolerating the GPU taint
+  wild node add gpu-1 worker --current-ip 192.168.1.101 --target-ip 192.168.1.41 --disk /dev/nvme0n1 \
+    --label nvidia.com/gpu.present=true --taint nvidia.com/gpu=present:NoSchedule
+
+  # Worker with a second disk for Longhorn
+  wild node add worker-2 worker --current-ip 192.168.1.102 --target-ip 192.168.1.42 --disk /dev/nvme0n1 \
+    --storage name=longhorn,role=longhorn,disk=/dev/sda`,
 	Args: cobra.ExactArgs(2),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		inst, err := getInstanceName()
@@ -282,6 +319,12 @@ Examples:
 		if maintenance {
 			body["maintenance"] = true
 		}
+		if err := addNodeMetadataFlags(cmd, body, false); err != nil {
+			return err
+		}
+		if err := addNodeStorageFlags(cmd, body); err != nil {
+			return err
+		}
 
 		_, err = apiClient.Post(fmt.Sprintf("/api/v1/instances/%s/nodes", inst), body)
 		if err != nil {
@@ -311,12 +354,19 @@ This command:
 4. Applies configuration to node (using --insecure if in maintenance mode)
 5. Updates node state after successful application
 
+With --diff nothing is applied. The configuration is rendered, validated
+and diffed against the last applied and the running configuration, and the
+command says whether applying it would reboot the node.
+
 Examples:
   # Apply to node in maintenance mode (PXE booted)
   wild node apply control-1
 
   # Re-apply to production node (updates configuration)
-  wild node apply worker-1`,
+  wild node apply worker-1
+
+  # Show what re-applying would change
+  wild node apply worker-1 --diff`,
 	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		inst, err := getInstanceName()
@@ -324,6 +374,10 @@ Examples:
 			return err
 		}
 
+		if diff, _ := cmd.Flags().GetBool("diff"); diff {
+			return nodeApplyDiff(inst, args[0])
+		}
+
 		resp, err := apiClient.Post(fmt.Sprintf("/api/v1/instances/%s/nodes/%s/apply", inst, args[0]), nil)
 		if err != nil {
 			return err
@@ -337,6 +391,111 @@ Examples:
 	},
 }
 
+// nodeApplyDiff prints what applying a node's configuration would change
+func nodeApplyDiff(inst, hostname string) error {
+	resp, err := apiClient.Post(fmt.Sprintf("/api/v1/instances/%s/nodes/%s/apply?dryRun=true", inst, hostname), nil)
+	if err != nil {
+		return err
+	}
+
+	if outputFormat == "json" {
+		return printJSON(resp.Data)
+	}
+
+	if outputFormat == "yaml" {
+		return printYAML(resp.Data)
+	}
+
+	for _, key := range []string{"last_applied", "live"} {
+		diff := resp.GetMap(key)
+		if diff == nil {
+			continue
+		}
+		if text, _ := diff["diff"].(string); text != "" {
+			fmt.Print(text)
+		} else {
+			fmt.Printf("No changes against %v\n", diff["source"])
+		}
+		fmt.Println()
+	}
+	if resp.GetMap("last_applied") == nil {
+		fmt.Println("No configuration has been applied to this node from here")
+	}
+	if liveErr := resp.GetString("live_error"); liveErr != "" {
+		fmt.Printf("Running configuration not compared: %s\n", liveErr)
+	}
+
+	if valid, _ := resp.GetData("valid").(bool); valid {
+		fmt.Println("Validation: passed")
+	} else {
+		fmt.Printf("Validation: FAILED\n%s\n", resp.GetString("validation"))
+	}
+
+	reboot := "no"
+	if required, _ := resp.GetData("reboot_required").(bool); required {
+		reboot = "yes"
+	}
+	if reason := resp.GetString("reboot_reason"); reason != "" {
+		reboot += " (" + reason + ")"
+	}
+	fmt.Printf("Reboot required: %s\n", reboot)
+	return nil
+}
+
+var nodeUpgradeCmd = &cobra.Command{
+	Use:   "upgrade",
+	Short: "Upgrade Talos on nodes one at a time",
+	Long: `Upgrade Talos on the instance's nodes in a rolling fashion.
+
+Workers are upgraded first, then control planes, one node at a time. After
+each node the upgrade waits for it to be Ready and for etcd to be healthy,
+and it stops at the first failure. A cluster with fewer than three control
+planes loses etcd quorum while one is upgraded, so upgrading its control
+planes needs --allow-quorum-loss.
+
+Examples:
+  # Upgrade every node
+  wild node upgrade --version v1.11.2
+
+  # Upgrade two workers to a new schematic
+  wild node upgrade --version v1.11.2 --schematic-id 376567988ad3... --node worker-1 --node worker-2`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		inst, err := getInstanceName()
+		if err != nil {
+			return err
+		}
+
+		version, _ := cmd.Flags().GetString("version")
+		schematicID, _ := cmd.Flags().GetString("schematic-id")
+		nodes, _ := cmd.Flags().GetStringSlice("node")
+		allowQuorumLoss, _ := cmd.Flags().GetBool("allow-quorum-loss")
+
+		resp, err := apiClient.Post(fmt.Sprintf("/api/v1/instances/%s/nodes/upgrade", inst), map[string]interface{}{
+			"version":           version,
+			"schematic_id":      schematicID,
+			"nodes":             nodes,
+			"allow_quorum_loss": allowQuorumLoss,
+		})
+		if err != nil {
+			return err
+		}
+
+		plan := resp.GetMap("plan")
+		if steps, ok := plan["nodes"].([]interface{}); ok {
+			fmt.Printf("Upgrading %d node(s) to Talos %v:\n", len(steps), plan["version"])
+			for _, s := range steps {
+				if step, ok := s.(map[string]interface{}); ok {
+					fmt.Printf("  %-20v  %-12v  %v\n", step["hostname"], step["role"], step["ip"])
+				}
+			}
+		}
+		fmt.Println()
+
+		return followOperation(resp.GetString("operation_id"))
+	},
+}
+
 var nodeUpdateCmd = &cobra.Command{
 	Use:   "update <hostname>",
 	Short: "Update node configuration",
@@ -352,7 +511,29 @@ Examples:
   wild node update control-1 --current-ip 192.168.1.100 --maintenance
 
   # Clear maintenance after successful apply
-  wild node update control-1 --no-maintenance`,
+  wild node update control-1 --no-maintenance
+
+Labels, taints and annotations are set with key=value, taints as
+key=value:Effect, and removed with key-. Other keys are kept. A node that has
+joined the cluster is changed right away with kubectl; the next apply
+renders them into its machine configuration.
+
+  # Label a storage node for Longhorn
+  wild node update worker-2 --label node.longhorn.io/create-default-disk=true
+
+  # Keep general workloads off a GPU node, and drop an old label
+  wild node update gpu-1 --taint nvidia.com/gpu=present:NoSchedule --label zone-
+
+Additional disks are set with --storage as comma-separated fields: name,
+role (longhorn or volume), disk, and optionally mount-path, filesystem (xfs
+or ext4) and size. A volume without a mount path is mounted at
+/var/mnt/<name>. They take effect when the node is applied.
+
+  # Add an ext4 data volume on a second NVMe disk
+  wild node update worker-2 --storage name=data,role=volume,disk=/dev/nvme1n1,filesystem=ext4
+
+  # Stop using it
+  wild node update worker-2 --remove-storage data`,
 	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		inst, err := getInstanceName()
@@ -393,17 +574,108 @@ Examples:
 		if noMaintenance {
 			body["maintenance"] = false
 		}
+		if err := addNodeMetadataFlags(cmd, body, true); err != nil {
+			return err
+		}
+		if err := addNodeStorageFlags(cmd, body); err != nil {
+			return err
+		}
 
 		if len(body) == 0 {
 			return fmt.Errorf("no updates specified")
 		}
 
-		_, err = apiClient.Put(fmt.Sprintf("/api/v1/instances/%s/nodes/%s", inst, args[0]), body)
+		resp, err := apiClient.Put(fmt.Sprintf("/api/v1/instances/%s/nodes/%s", inst, args[0]), body)
 		if err != nil {
 			return err
 		}
 
 		fmt.Printf("Node updated: %s\n", args[0])
+		if warning := resp.GetString("warning"); warning != "" {
+			fmt.Printf("⚠️  %s\n", warning)
+		}
+		return nil
+	},
+}
+
+var nodeInventoryCmd = &cobra.Command{
+	Use:   "inventory <hostname>",
+	Short: "Show the hardware inventory of a node",
+	Long: `Show a node's CPUs, memory, network interfaces, disks and GPUs.
+
+The inventory is gathered from the node with talosctl and kept in the
+instance, so it can be shown when the node is down. --refresh gathers it
+again; the first time it is needed.
+
+Examples:
+  # Show the inventory kept for a node
+  wild node inventory worker-1
+
+  # Gather it from the node again
+  wild node inventory worker-1 --refresh`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		inst, err := getInstanceName()
+		if err != nil {
+			return err
+		}
+
+		path := fmt.Sprintf("/api/v1/instances/%s/nodes/%s/inventory", inst, args[0])
+		refresh, _ := cmd.Flags().GetBool("refresh")
+		var resp *client.APIResponse
+		if refresh {
+			resp, err = apiClient.Post(path, nil)
+		} else {
+			resp, err = apiClient.Get(path)
+		}
+		if err != nil {
+			return err
+		}
+
+		if outputFormat == "json" {
+			return printJSON(resp.Data)
+		}
+
+		if outputFormat == "yaml" {
+			return printYAML(resp.Data)
+		}
+
+		fmt.Printf("Hardware of %s, gathered %v:\n\n", args[0], resp.Data["gathered_at"])
+		printInventorySummary(resp.Data)
+
+		if nics := resp.GetArray("nics"); len(nics) > 0 {
+			fmt.Printf("\nNetwork Interfaces:\n")
+			for _, item := range nics {
+				nic, _ := item.(map[string]interface{})
+				line := fmt.Sprintf("  - %v %v", nic["name"], nic["mac"])
+				if speed, ok := nic["speed_mbit"].(float64); ok && speed > 0 {
+					line += fmt.Sprintf(", %.0f Mbit/s", speed)
+				} else if up, _ := nic["up"].(bool); !up {
+					line += ", down"
+				}
+				if product, ok := nic["product"].(string); ok {
+					line += " (" + product + ")"
+				}
+				fmt.Println(line)
+			}
+		}
+
+		if disks := resp.GetArray("disks"); len(disks) > 0 {
+			fmt.Printf("\nDisks:\n")
+			for _, item := range disks {
+				disk, _ := item.(map[string]interface{})
+				size, _ := disk["size"].(float64)
+				line := fmt.Sprintf("  - %v (%.1f GB, %v", disk["path"], size/(1024*1024*1024), disk["type"])
+				if model, ok := disk["model"].(string); ok {
+					line += ", " + model
+				}
+				fmt.Println(line + ")")
+			}
+		}
+
+		for _, warning := range resp.GetArray("warnings") {
+			fmt.Printf("⚠️  Not read: %v\n", warning)
+		}
 		return nil
 	},
 }
@@ -433,24 +705,110 @@ You can use it manually to update templates.`,
 
 var nodeDeleteCmd = &cobra.Command{
 	Use:   "delete <hostname>",
-	Short: "Delete a node",
-	Args:  cobra.ExactArgs(1),
+	Short: "Remove a node from the cluster",
+	Long: `Remove a node from the cluster and from the instance.
+
+A node that has joined the cluster is cordoned and drained, leaves etcd if it
+is a control plane, and is deleted from Kubernetes. Its generated patch and
+machine configuration files are deleted, and then it is removed from
+config.yaml. Removing the last control plane is refused.
+
+Examples:
+  # Remove a worker
+  wild node delete worker-2
+
+  # Remove a node and wipe it (asks for confirmation)
+  wild node delete worker-2 --reset
+
+  # Remove a dead node that can no longer be drained
+  wild node delete worker-2 --force`,
+	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		inst, err := getInstanceName()
 		if err != nil {
 			return err
 		}
 
-		_, err = apiClient.Delete(fmt.Sprintf("/api/v1/instances/%s/nodes/%s", inst, args[0]))
-		if err != nil {
+		hostname := args[0]
+		reset, _ := cmd.Flags().GetBool("reset")
+		force, _ := cmd.Flags().GetBool("force")
+
+		path := fmt.Sprintf("/api/v1/instances/%s/nodes/%s?reset=%t&force=%t", inst, hostname, reset, force)
+		resp, err := deleteWithConfirmation(path, hostname)
+		if err != nil || resp == nil {
 			return err
 		}
 
-		fmt.Printf("Node deleted: %s\n", args[0])
-		return nil
+		fmt.Println(resp.GetString("message"))
+		fmt.Println()
+
+		return followOperation(resp.GetString("operation_id"))
 	},
 }
 
+// newNodeActionCmd creates the command running a lifecycle action on a node
+func newNodeActionCmd(action, short, long string) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   action + " <hostname>",
+		Short: short,
+		Long:  long,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			inst, err := getInstanceName()
+			if err != nil {
+				return err
+			}
+
+			hostname := args[0]
+			force, _ := cmd.Flags().GetBool("force")
+
+			// Shutdown, reset and --force ask for confirmation; the user types the hostname
+			path := fmt.Sprintf("/api/v1/instances/%s/nodes/%s/actions/%s", inst, hostname, action)
+			resp, err := sendWithConfirmation(path, hostname, func(path string) (*client.APIResponse, error) {
+				return apiClient.Post(path, map[string]bool{"force": force})
+			})
+			if err != nil || resp == nil {
+				return err
+			}
+
+			fmt.Println(resp.GetString("message"))
+			fmt.Println()
+
+			return followOperation(resp.GetString("operation_id"))
+		},
+	}
+	cmd.Flags().Bool("force", false, "Skip the etcd quorum and workload capacity checks")
+	return cmd
+}
+
+var nodeRebootCmd = newNodeActionCmd("reboot", "Reboot a node",
+	`Reboot a node with talosctl and wait for it to be Ready again.
+
+Rebooting a control plane is refused when the other etcd members could not
+keep quorum meanwhile, and rebooting the only node accepting workloads is
+refused too. --force skips both checks and asks for confirmation. Only one
+reboot, shutdown, reset, drain or removal runs at a time per instance.`)
+
+var nodeShutdownCmd = newNodeActionCmd("shutdown", "Shut down a node",
+	`Shut down a node with talosctl. It stays off until powered on again.
+
+Asks for confirmation. The same quorum and capacity checks as reboot apply.`)
+
+var nodeResetCmd = newNodeActionCmd("reset", "Reset a node to maintenance mode",
+	`Reset a node with talosctl, wiping its configuration and data. A control
+plane leaves etcd first. The node reboots into maintenance mode and is marked
+as such; apply its configuration again to rejoin the cluster.
+
+Asks for confirmation. Resetting the only control plane is refused.`)
+
+var nodeDrainCmd = newNodeActionCmd("drain", "Cordon a node and evict its pods",
+	`Cordon a node and evict its pods with kubectl drain, ignoring DaemonSets.
+
+Draining the only node accepting workloads is refused unless --force is set.`)
+
+var nodeUncordonCmd = newNodeActionCmd("uncordon", "Let pods be scheduled on a node again",
+	`Mark a drained or cordoned node schedulable again with kubectl uncordon.`)
+
 func init() {
 	nodeCmd.AddCommand(nodeDiscoverCmd)
 	nodeCmd.AddCommand(nodeDetectCmd)
@@ -459,8 +817,15 @@ func init() {
 	nodeCmd.AddCommand(nodeAddCmd)
 	nodeCmd.AddCommand(nodeApplyCmd)
 	nodeCmd.AddCommand(nodeUpdateCmd)
+	nodeCmd.AddCommand(nodeInventoryCmd)
+	nodeCmd.AddCommand(nodeUpgradeCmd)
 	nodeCmd.AddCommand(nodeFetchTemplatesCmd)
 	nodeCmd.AddCommand(nodeDeleteCmd)
+	nodeCmd.AddCommand(nodeRebootCmd)
+	nodeCmd.AddCommand(nodeShutdownCmd)
+	nodeCmd.AddCommand(nodeResetCmd)
+	nodeCmd.AddCommand(nodeDrainCmd)
+	nodeCmd.AddCommand(nodeUncordonCmd)
 
 	// Add flags to node add command
 	nodeAddCmd.Flags().String("target-ip", "", "Target IP address for production")
@@ -469,6 +834,26 @@ func init() {
 	nodeAddCmd.Flags().String("interface", "", "Network interface (optional, e.g., eth0)")
 	nodeAddCmd.Flags().String("schematic-id", "", "Talos schematic ID (optional, uses instance default)")
 	nodeAddCmd.Flags().Bool("maintenance", false, "Mark node as in maintenance mode")
+	nodeAddCmd.Flags().StringArray("label", nil, "Kubernetes label key=value (repeatable)")
+	nodeAddCmd.Flags().StringArray("taint", nil, "Kubernetes taint key=value:Effect (repeatable)")
+	nodeAddCmd.Flags().StringArray("annotation", nil, "Kubernetes annotation key=value (repeatable)")
+	nodeAddCmd.Flags().StringArray("storage", nil, "Additional disk name=NAME,role=ROLE,disk=DEVICE[,mount-path=PATH][,filesystem=FS][,size=SIZE] (repeatable)")
+
+	// Add flags to node apply command
+	nodeApplyCmd.Flags().Bool("diff", false, "Show what would change without applying")
+
+	// Add flags to node upgrade command
+	nodeUpgradeCmd.Flags().String("version", "", "Target Talos version (required, e.g., v1.11.2)")
+	nodeUpgradeCmd.Flags().String("schematic-id", "", "Talos schematic ID (optional, uses instance default)")
+	nodeUpgradeCmd.Flags().StringSlice("node", nil, "Node to upgrade (repeatable, default all nodes)")
+	nodeUpgradeCmd.Flags().Bool("allow-quorum-loss", false, "Upgrade control planes even if etcd loses quorum meanwhile")
+
+	// Add flags to node inventory command
+	nodeInventoryCmd.Flags().Bool("refresh", false, "Gather the inventory from the node again")
+
+	// Add flags to node delete command
+	nodeDeleteCmd.Flags().Bool("reset", false, "Wipe the machine with talosctl reset after it leaves the cluster")
+	nodeDeleteCmd.Flags().Bool("force", false, "Keep going when the node cannot be drained or reset")
 
 	// Add flags to node update command
 	nodeUpdateCmd.Flags().String("target-ip", "", "Update target IP address")
@@ -478,5 +863,176 @@ func init() {
 	nodeUpdateCmd.Flags().String("schematic-id", "", "Update Talos schematic ID")
 	nodeUpdateCmd.Flags().Bool("maintenance", false, "Set maintenance mode")
 	nodeUpdateCmd.Flags().Bool("no-maintenance", false, "Clear maintenance mode")
+	nodeUpdateCmd.Flags().StringArray("label", nil, "Set label key=value, or remove it with key- (repeatable)")
+	nodeUpdateCmd.Flags().StringArray("taint", nil, "Set taint key=value:Effect, or remove it with key- (repeatable)")
+	nodeUpdateCmd.Flags().StringArray("annotation", nil, "Set annotation key=value, or remove it with key- (repeatable)")
+	nodeUpdateCmd.Flags().StringArray("storage", nil, "Set additional disk name=NAME,role=ROLE,disk=DEVICE[,mount-path=PATH][,filesystem=FS][,size=SIZE] (repeatable)")
+	nodeUpdateCmd.Flags().StringArray("remove-storage", nil, "Remove the additional disk with this name (repeatable)")
+}
+
+// addNodeMetadataFlags adds the --label, --taint and --annotation flags to a
+// node request body. Each is key=value, a taint key=value:Effect or
+// key:Effect, and key- removes the key when removal is allowed.
+func addNodeMetadataFlags(cmd *cobra.Command, body map[string]interface{}, allowRemoval bool) error {
+	for _, field := range []struct{ flag, key string }{
+		{"label", "labels"},
+		{"taint", "taints"},
+		{"annotation", "annotations"},
+	} {
+		values, _ := cmd.Flags().GetStringArray(field.flag)
+		if len(values) == 0 {
+			continue
+		}
+
+		entries := map[string]interface{}{}
+		for _, value := range values {
+			switch {
+			case strings.HasSuffix(value, "-") && !strings.Contains(value, "="):
+				if !allowRemoval {
+					return fmt.Errorf("--%s %s: nothing to remove from a new node", field.flag, value)
+				}
+				entries[strings.TrimSuffix(value, "-")] = nil
+			case strings.Contains(value, "="):
+				key, val, _ := strings.Cut(value, "=")
+				entries[key] = val
+			case field.flag == "taint" && strings.Contains(value, ":"):
+				// A taint without a value: key:Effect
+				key, effect, _ := strings.Cut(value, ":")
+				entries[key] = effect
+			default:
+				return fmt.Errorf("--%s %s: expected key=value", field.flag, value)
+			}
+		}
+		body[field.key] = entries
+	}
+	return nil
+}
+
+// printNodeMetadata prints a node's labels, taints or annotations sorted by
+// key, one per line
+func printNodeMetadata(title string, values map[string]interface{}) {
+	if len(values) == 0 {
+		return
+	}
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	fmt.Printf("%s:\n", title)
+	for _, key := range keys {
+		fmt.Printf("  %s=%v\n", key, values[key])
+	}
 }
 
+// storageFields maps --storage fields to the API's volume fields
+var storageFields = map[string]string{
+	"role":       "role",
+	"disk":       "disk",
+	"mount-path": "mount_path",
+	"filesystem": "filesystem",
+	"size":       "size",
+}
+
+// addNodeStorageFlags adds the --storage and --remove-storage flags to a
+// node request body
+func addNodeStorageFlags(cmd *cobra.Command, body map[string]interface{}) error {
+	volumes := map[string]interface{}{}
+
+	specs, _ := cmd.Flags().GetStringArray("storage")
+	for _, spec := range specs {
+		volume := map[string]interface{}{}
+		var name string
+		for _, field := range strings.Split(spec, ",") {
+			key, value, ok := strings.Cut(field, "=")
+			if !ok {
+				return fmt.Errorf("--storage %s: expected key=value fields", spec)
+			}
+			if key == "name" {
+				name = value
+				continue
+			}
+			apiKey, known := storageFields[key]
+			if !known {
+				return fmt.Errorf("--storage %s: unknown field %q", spec, key)
+			}
+			volume[apiKey] = value
+		}
+		if name == "" {
+			return fmt.Errorf("--storage %s: name is required", spec)
+		}
+		volumes[name] = volume
+	}
+
+	if cmd.Flags().Lookup("remove-storage") != nil {
+		names, _ := cmd.Flags().GetStringArray("remove-storage")
+		for _, name := range names {
+			volumes[name] = nil
+		}
+	}
+
+	if len(volumes) > 0 {
+		body["storage"] = volumes
+	}
+	return nil
+}
+
+// printNodeStorage prints a node's additional disks sorted by name
+func printNodeStorage(volumes map[string]interface{}) {
+	if len(volumes) == 0 {
+		return
+	}
+	names := make([]string, 0, len(volumes))
+	for name := range volumes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Printf("Storage:\n")
+	for _, name := range names {
+		volume, _ := volumes[name].(map[string]interface{})
+		line := fmt.Sprintf("  %s: %v %v", name, volume["role"], volume["disk"])
+		if mountPath, ok := volume["mount_path"].(string); ok {
+			line += " at " + mountPath
+		}
+		if filesystem, ok := volume["filesystem"].(string); ok {
+			line += " (" + filesystem + ")"
+		}
+		if size, ok := volume["size"].(string); ok {
+			line += ", " + size
+		}
+		fmt.Println(line)
+	}
+}
+
+// printInventorySummary prints the system, CPUs, memory and GPUs of a
+// hardware inventory
+func printInventorySummary(inventory map[string]interface{}) {
+	if system, ok := inventory["system"].(map[string]interface{}); ok && len(system) > 0 {
+		fmt.Printf("  System:  %v %v\n", system["manufacturer"], system["product_name"])
+	}
+
+	cpus, _ := inventory["cpus"].([]interface{})
+	for _, item := range cpus {
+		cpu, _ := item.(map[string]interface{})
+		fmt.Printf("  CPU:     %v, %v cores, %v threads\n", cpu["model"], cpu["cores"], cpu["threads"])
+	}
+
+	if memory, ok := inventory["memory"].(map[string]interface{}); ok {
+		total, _ := memory["total_mb"].(float64)
+		modules, _ := memory["modules"].([]interface{})
+		if total > 0 {
+			fmt.Printf("  Memory:  %.0f GB in %d module(s)\n", total/1024, len(modules))
+		}
+	}
+
+	gpus, _ := inventory["gpus"].([]interface{})
+	for _, item := range gpus {
+		gpu, _ := item.(map[string]interface{})
+		fmt.Printf("  GPU:     %v %v\n", gpu["vendor"], gpu["product"])
+	}
+	if len(gpus) == 0 {
+		fmt.Printf("  GPU:     none\n")
+	}
+}
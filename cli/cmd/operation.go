@@ -140,3 +140,31 @@ func streamOperationOutput(opID string) error {
 		}
 	}
 }
+
+// followOperation streams an operation until it finishes and returns an
+// error if it failed
+func followOperation(opID string) error {
+	if opID == "" {
+		return nil
+	}
+	if err := streamOperationOutput(opID); err != nil {
+		fmt.Printf("\nCouldn't stream output: %v\n", err)
+		fmt.Printf("Operation ID: %s\n", opID)
+		fmt.Printf("Monitor with: wild operation get %s\n", opID)
+		return nil
+	}
+
+	inst, err := getInstanceName()
+	if err != nil {
+		return err
+	}
+	resp, err := apiClient.Get(fmt.Sprintf("/api/v1/operations/%s?instance=%s", opID, inst))
+	if err != nil {
+		return err
+	}
+	if resp.GetString("status") == "failed" {
+		return fmt.Errorf("%s", resp.GetString("message"))
+	}
+	fmt.Printf("\n✓ %s\n", resp.GetString("message"))
+	return nil
+}
@@ -44,6 +44,11 @@ services, and applications through the Wild Central daemon.`,
 		// Create API client
 		apiClient = client.NewClient(url)
 
+		// Identify this CLI so the daemon keeps a separate current context for it
+		if clientID, err := config.GetClientID(); err == nil {
+			apiClient.SetHeader(client.ClientIDHeader, clientID)
+		}
+
 		return nil
 	},
 }
@@ -82,14 +87,19 @@ func init() {
 
 // getInstanceName returns the current instance name using the priority cascade
 func getInstanceName() (string, error) {
+	instance, _, err := resolveInstance()
+	return instance, err
+}
+
+// resolveInstance returns the current instance and where it was resolved from
+func resolveInstance() (string, string, error) {
 	// Create instance lister adapter for API client
 	var lister config.InstanceLister
 	if apiClient != nil {
 		lister = &instanceListerAdapter{client: apiClient}
 	}
 
-	instance, _, err := config.GetCurrentInstance(instanceName, lister)
-	return instance, err
+	return config.GetCurrentInstance(instanceName, lister)
 }
 
 // instanceListerAdapter adapts the API client to the InstanceLister interface
@@ -114,6 +124,15 @@ func (a *instanceListerAdapter) ListInstances() ([]string, error) {
 	return result, nil
 }
 
+// CurrentContext returns the context the daemon resolves for this client
+func (a *instanceListerAdapter) CurrentContext() (string, string, error) {
+	resp, err := a.client.Get("/api/v1/context")
+	if err != nil {
+		return "", "", err
+	}
+	return resp.GetString("context"), resp.GetString("source"), nil
+}
+
 // printJSON prints data as JSON
 func printJSON(data interface{}) error {
 	encoder := json.NewEncoder(os.Stdout)
@@ -2,10 +2,10 @@ package cmd
 
 import (
 	"fmt"
+	"net/url"
+	"sort"
 
 	"github.com/spf13/cobra"
-
-	"github.com/wild-cloud/wild-central/wild/internal/config"
 )
 
 // Secret commands
@@ -16,8 +16,13 @@ var secretCmd = &cobra.Command{
 
 var secretGetCmd = &cobra.Command{
 	Use:   "get <key>",
-	Short: "Get secret value (redacted)",
-	Args:  cobra.ExactArgs(1),
+	Short: "Get a secret value",
+	Long: `Print the raw value of one secret.
+
+The daemon refuses this unless it was started with
+WILD_CENTRAL_SECRETS_REVEAL=allow, and asks for confirmation each time;
+type the key to confirm.`,
+	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		inst, err := getInstanceName()
 		if err != nil {
@@ -25,24 +30,108 @@ var secretGetCmd = &cobra.Command{
 		}
 		key := args[0]
 
-		// Request raw secrets (not redacted)
-		resp, err := apiClient.Get(fmt.Sprintf("/api/v1/instances/%s/secrets?raw=true", inst))
+		path := fmt.Sprintf("/api/v1/instances/%s/secrets/%s", inst, url.PathEscape(key))
+		resp, err := sendWithConfirmation(path, key, apiClient.Get)
+		if err != nil || resp == nil {
+			return err
+		}
+
+		fmt.Println(resp.GetString("value"))
+		return nil
+	},
+}
+
+var secretListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List secrets without their values",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		inst, err := getInstanceName()
 		if err != nil {
 			return err
 		}
 
-		// Secrets are returned directly at top level, not nested under "secrets" key
-		// Use nested path lookup for dot notation (e.g., cloudflare.token)
-		val := config.GetValue(resp.Data, key)
-		if val != nil {
-			fmt.Println(val)
-		} else {
-			return fmt.Errorf("secret '%s' not found", key)
+		resp, err := apiClient.Get(fmt.Sprintf("/api/v1/instances/%s/secrets", inst))
+		if err != nil {
+			return err
+		}
+
+		if outputFormat == "json" {
+			return printJSON(resp.Data)
+		}
+
+		if outputFormat == "yaml" {
+			return printYAML(resp.Data)
+		}
+
+		rows := flattenSecretInfo(resp.Data, "")
+		if len(rows) == 0 {
+			fmt.Println("No secrets found")
+			return nil
+		}
+
+		sort.Slice(rows, func(i, j int) bool { return rows[i][0] < rows[j][0] })
+		fmt.Printf("%-40s  %-6s  %-6s  %s\n", "KEY", "SET", "LENGTH", "MODIFIED")
+		for _, row := range rows {
+			fmt.Printf("%-40s  %-6s  %-6s  %s\n", row[0], row[1], row[2], row[3])
 		}
 		return nil
 	},
 }
 
+var secretDeleteCmd = &cobra.Command{
+	Use:   "delete <key>",
+	Short: "Delete a secret",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		inst, err := getInstanceName()
+		if err != nil {
+			return err
+		}
+
+		_, err = apiClient.Delete(fmt.Sprintf("/api/v1/instances/%s/secrets/%s", inst, url.PathEscape(args[0])))
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Secret deleted: %s\n", args[0])
+		return nil
+	},
+}
+
+// flattenSecretInfo turns the redacted secrets tree into key, set, length and
+// modified columns. Leaves are the objects carrying a "set" flag.
+func flattenSecretInfo(tree map[string]interface{}, prefix string) [][4]string {
+	var rows [][4]string
+	for key, value := range tree {
+		path := key
+		if prefix != "" {
+			path = prefix + "." + key
+		}
+
+		node, ok := value.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		set, isLeaf := node["set"].(bool)
+		if !isLeaf {
+			rows = append(rows, flattenSecretInfo(node, path)...)
+			continue
+		}
+
+		status := "no"
+		if set {
+			status = "yes"
+		}
+		length := ""
+		if l, ok := node["length"].(float64); ok {
+			length = fmt.Sprintf("%d", int(l))
+		}
+		modified, _ := node["modified"].(string)
+		rows = append(rows, [4]string{path, status, length, modified})
+	}
+	return rows
+}
+
 var secretSetCmd = &cobra.Command{
 	Use:   "set <key> <value>",
 	Short: "Set secret value",
@@ -66,7 +155,277 @@ var secretSetCmd = &cobra.Command{
 	},
 }
 
+var secretEncryptCmd = &cobra.Command{
+	Use:   "encrypt",
+	Short: "Encrypt instance secrets at rest",
+	Long: `Migrate an instance's plaintext secrets to encrypted storage.
+
+If the daemon has no encryption key yet, a key file is created in its data
+directory. Use --all to encrypt every instance.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		all, _ := cmd.Flags().GetBool("all")
+
+		var instances []string
+		if all {
+			resp, err := apiClient.Get("/api/v1/instances")
+			if err != nil {
+				return err
+			}
+			for _, inst := range resp.GetArray("instances") {
+				if name, ok := inst.(string); ok {
+					instances = append(instances, name)
+				}
+			}
+		} else {
+			inst, err := getInstanceName()
+			if err != nil {
+				return err
+			}
+			instances = []string{inst}
+		}
+
+		for _, inst := range instances {
+			resp, err := apiClient.Post(fmt.Sprintf("/api/v1/instances/%s/secrets/encrypt", inst), nil)
+			if err != nil {
+				return fmt.Errorf("encrypting %s: %w", inst, err)
+			}
+			fmt.Printf("%s: %s (key %s)\n", inst, resp.GetString("message"), resp.GetString("key_id"))
+		}
+		return nil
+	},
+}
+
+var secretEncryptionCmd = &cobra.Command{
+	Use:   "encryption",
+	Short: "Show secrets encryption status",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		resp, err := apiClient.Get("/api/v1/secrets/encryption")
+		if err != nil {
+			return err
+		}
+
+		if outputFormat == "json" {
+			return printJSON(resp.Data)
+		}
+
+		if outputFormat == "yaml" {
+			return printYAML(resp.Data)
+		}
+
+		if enabled, _ := resp.Data["enabled"].(bool); enabled {
+			fmt.Printf("Encryption: enabled (%s, key %s)\n", resp.GetString("mode"), resp.GetString("key_id"))
+		} else {
+			fmt.Println("Encryption: disabled")
+		}
+
+		for name, v := range resp.GetMap("instances") {
+			status := "plaintext"
+			if info, ok := v.(map[string]interface{}); ok {
+				if encrypted, _ := info["encrypted"].(bool); encrypted {
+					status = "encrypted"
+				}
+			}
+			fmt.Printf("  %-20s  %s\n", name, status)
+		}
+		return nil
+	},
+}
+
+var secretRotateKeyCmd = &cobra.Command{
+	Use:   "rotate-key",
+	Short: "Rotate the secrets encryption key",
+	Long: `Replace the master key and re-wrap every encrypted secrets file.
+
+When the daemon is unlocked with a passphrase, pass the new one with
+--passphrase and restart the daemon with it afterwards.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		passphrase, _ := cmd.Flags().GetString("passphrase")
+
+		body := map[string]string{}
+		if passphrase != "" {
+			body["passphrase"] = passphrase
+		}
+
+		resp, err := apiClient.Post("/api/v1/secrets/rotate-key", body)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(resp.GetString("message"))
+		fmt.Printf("New key: %s\n", resp.GetString("key_id"))
+		return nil
+	},
+}
+
+var secretRotateCmd = &cobra.Command{
+	Use:   "rotate <key>",
+	Short: "Rotate a secret and propagate it to the cluster",
+	Long: `Generate a new value for a secret, run any rotation hooks declared for it,
+and update the services and apps that reference it. The previous value is
+kept so the rotation can be undone with 'wild secret rollback'.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		inst, err := getInstanceName()
+		if err != nil {
+			return err
+		}
+
+		length, _ := cmd.Flags().GetInt("length")
+		noDeploy, _ := cmd.Flags().GetBool("no-deploy")
+
+		resp, err := apiClient.Post(fmt.Sprintf("/api/v1/instances/%s/secrets/rotate", inst), map[string]interface{}{
+			"key":    args[0],
+			"length": length,
+			"deploy": !noDeploy,
+		})
+		if err != nil {
+			return err
+		}
+
+		return followOperation(resp.GetString("operation_id"))
+	},
+}
+
+var secretRollbackCmd = &cobra.Command{
+	Use:   "rollback <key>",
+	Short: "Restore the previous value of a rotated secret",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		inst, err := getInstanceName()
+		if err != nil {
+			return err
+		}
+
+		noDeploy, _ := cmd.Flags().GetBool("no-deploy")
+
+		resp, err := apiClient.Post(fmt.Sprintf("/api/v1/instances/%s/secrets/rollback", inst), map[string]interface{}{
+			"key":    args[0],
+			"deploy": !noDeploy,
+		})
+		if err != nil {
+			return err
+		}
+
+		return followOperation(resp.GetString("operation_id"))
+	},
+}
+
+var secretReferencesCmd = &cobra.Command{
+	Use:   "references <key>",
+	Short: "List services and apps that use a secret",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		inst, err := getInstanceName()
+		if err != nil {
+			return err
+		}
+
+		resp, err := apiClient.Get(fmt.Sprintf("/api/v1/instances/%s/secrets/references?key=%s", inst, url.QueryEscape(args[0])))
+		if err != nil {
+			return err
+		}
+
+		if outputFormat == "json" {
+			return printJSON(resp.Data)
+		}
+
+		if outputFormat == "yaml" {
+			return printYAML(resp.Data)
+		}
+
+		refs := resp.GetArray("references")
+		if len(refs) == 0 {
+			fmt.Printf("No services or apps reference %s\n", args[0])
+			return nil
+		}
+
+		for _, r := range refs {
+			ref, ok := r.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			hooks, _ := ref["hooks"].([]interface{})
+			fmt.Printf("%-20s  %-8s  %d hook(s)\n", ref["name"], ref["kind"], len(hooks))
+		}
+		return nil
+	},
+}
+
+var secretSyncCmd = &cobra.Command{
+	Use:   "sync",
+	Short: "Sync secrets into their Kubernetes Secrets",
+	Long: `Create or update the Kubernetes Secrets declared by installed services and
+configured apps (kubernetesSecrets in their manifests, and <app>-secrets for
+app requiredSecrets). With --check, only report missing or drifted Secrets.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		inst, err := getInstanceName()
+		if err != nil {
+			return err
+		}
+
+		check, _ := cmd.Flags().GetBool("check")
+		if !check {
+			resp, err := apiClient.Post(fmt.Sprintf("/api/v1/instances/%s/secrets/sync", inst), nil)
+			if err != nil {
+				return err
+			}
+			return followOperation(resp.GetString("operation_id"))
+		}
+
+		resp, err := apiClient.Get(fmt.Sprintf("/api/v1/instances/%s/secrets/sync", inst))
+		if err != nil {
+			return err
+		}
+
+		if outputFormat == "json" {
+			return printJSON(resp.Data)
+		}
+
+		if outputFormat == "yaml" {
+			return printYAML(resp.Data)
+		}
+
+		for _, r := range resp.GetArray("secrets") {
+			result, ok := r.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			target := fmt.Sprintf("%v/%v", result["namespace"], result["name"])
+			detail := ""
+			if drift, ok := result["drift"].([]interface{}); ok && len(drift) > 0 {
+				detail = fmt.Sprintf("%v", drift)
+			}
+			if e, ok := result["error"].(string); ok && e != "" {
+				detail = e
+			}
+			fmt.Printf("%-40s  %-24s  %-8s  %s\n", target, result["source"], result["status"], detail)
+		}
+
+		if inSync, _ := resp.Data["in_sync"].(bool); !inSync {
+			return fmt.Errorf("kubernetes secrets are out of sync; run 'wild secret sync'")
+		}
+		fmt.Println("All Kubernetes secrets are in sync")
+		return nil
+	},
+}
+
 func init() {
 	secretCmd.AddCommand(secretGetCmd)
 	secretCmd.AddCommand(secretSetCmd)
+	secretCmd.AddCommand(secretListCmd)
+	secretCmd.AddCommand(secretDeleteCmd)
+	secretCmd.AddCommand(secretEncryptCmd)
+	secretCmd.AddCommand(secretEncryptionCmd)
+	secretCmd.AddCommand(secretRotateKeyCmd)
+	secretCmd.AddCommand(secretRotateCmd)
+	secretCmd.AddCommand(secretRollbackCmd)
+	secretCmd.AddCommand(secretReferencesCmd)
+	secretCmd.AddCommand(secretSyncCmd)
+
+	secretEncryptCmd.Flags().Bool("all", false, "Encrypt secrets for all instances")
+	secretRotateKeyCmd.Flags().String("passphrase", "", "New passphrase (passphrase mode only)")
+	secretRotateCmd.Flags().Int("length", 32, "Length of the generated value")
+	secretRotateCmd.Flags().Bool("no-deploy", false, "Recompile services without redeploying them")
+	secretRollbackCmd.Flags().Bool("no-deploy", false, "Recompile services without redeploying them")
+	secretSyncCmd.Flags().Bool("check", false, "Only report missing or drifted Kubernetes secrets")
 }
@@ -10,10 +10,15 @@ import (
 	"time"
 )
 
+// ClientIDHeader identifies the CLI to the daemon, which keys the current
+// context by it
+const ClientIDHeader = "X-Wild-Client-ID"
+
 // Client is the HTTP client for the Wild Central daemon
 type Client struct {
 	baseURL    string
 	httpClient *http.Client
+	headers    map[string]string // Sent with every request
 }
 
 // NewClient creates a new API client
@@ -23,15 +28,37 @@ func NewClient(baseURL string) *Client {
 		httpClient: &http.Client{
 			Timeout: 30 * time.Second,
 		},
+		headers: make(map[string]string),
 	}
 }
 
+// SetHeader sets a header sent with every request
+func (c *Client) SetHeader(key, value string) {
+	c.headers[key] = value
+}
+
 // APIResponse is the API response format
 type APIResponse struct {
 	Data  map[string]interface{}
 	Error string `json:"error,omitempty"`
 }
 
+// APIError is returned for responses with an error status. Data holds the
+// decoded response body, if it was JSON.
+type APIError struct {
+	StatusCode int
+	Message    string
+	Body       string
+	Data       map[string]interface{}
+}
+
+func (e *APIError) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("API error: %s", e.Message)
+	}
+	return fmt.Sprintf("API request failed with status %d: %s", e.StatusCode, e.Body)
+}
+
 // Get makes a GET request to the API
 func (c *Client) Get(path string) (*APIResponse, error) {
 	return c.doRequest("GET", path, nil)
@@ -78,6 +105,9 @@ func (c *Client) doRequest(method, path string, body interface{}) (*APIResponse,
 	if body != nil {
 		req.Header.Set("Content-Type", "application/json")
 	}
+	for key, value := range c.headers {
+		req.Header.Set(key, value)
+	}
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
@@ -96,10 +126,10 @@ func (c *Client) doRequest(method, path string, body interface{}) (*APIResponse,
 		var errResp map[string]interface{}
 		if err := json.Unmarshal(respBody, &errResp); err == nil {
 			if errMsg, ok := errResp["error"].(string); ok {
-				return nil, fmt.Errorf("API error: %s", errMsg)
+				return nil, &APIError{StatusCode: resp.StatusCode, Message: errMsg, Data: errResp}
 			}
 		}
-		return nil, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(respBody))
+		return nil, &APIError{StatusCode: resp.StatusCode, Body: string(respBody)}
 	}
 
 	// Parse response data directly (daemon doesn't wrap in "data" field)
@@ -1,6 +1,8 @@
 package config
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -19,17 +21,30 @@ func GetWildCLIDataDir() string {
 	return filepath.Join(home, ".wildcloud")
 }
 
-// GetCurrentInstance resolves the current instance using the priority cascade:
+// GetCurrentInstance resolves the current instance with the daemon's context
+// rules (see the daemon context package), first match wins:
 // 1. --instance flag (passed as parameter)
-// 2. $WILD_CLI_DATA/current_instance file
-// 3. Auto-select first instance from API
+// 2. This client's context on the daemon (set by 'wild instance use')
+// 3. $WILD_CLI_DATA/current_instance file, kept for older daemons
+// 4. The daemon-wide default context
+// 5. Auto-select first instance from API
+// Steps 2 and 4 are only used when apiClient also implements ContextResolver.
 func GetCurrentInstance(flagInstance string, apiClient InstanceLister) (string, string, error) {
 	// Priority 1: --instance flag
 	if flagInstance != "" {
 		return flagInstance, "flag", nil
 	}
 
-	// Priority 2: current_instance file
+	// Priority 2: this client's context on the daemon
+	var daemonContext, daemonSource string
+	if resolver, ok := apiClient.(ContextResolver); ok {
+		daemonContext, daemonSource, _ = resolver.CurrentContext()
+		if daemonContext != "" && daemonSource == "client" {
+			return daemonContext, "client", nil
+		}
+	}
+
+	// Priority 3: current_instance file
 	dataDir := GetWildCLIDataDir()
 	currentFile := filepath.Join(dataDir, "current_instance")
 
@@ -40,7 +55,12 @@ func GetCurrentInstance(flagInstance string, apiClient InstanceLister) (string,
 		}
 	}
 
-	// Priority 3: Auto-select first instance from API
+	// Priority 4: daemon-wide default
+	if daemonContext != "" {
+		return daemonContext, "default", nil
+	}
+
+	// Priority 5: Auto-select first instance from API
 	if apiClient != nil {
 		instances, err := apiClient.ListInstances()
 		if err != nil {
@@ -81,3 +101,36 @@ func SetCurrentInstance(instance string) error {
 type InstanceLister interface {
 	ListInstances() ([]string, error)
 }
+
+// ContextResolver is implemented by instance listers that can ask the daemon
+// for the current context. Source is "client" or "default".
+type ContextResolver interface {
+	CurrentContext() (instance string, source string, err error)
+}
+
+// GetClientID returns the ID this CLI identifies itself to the daemon with,
+// creating it in $WILD_CLI_DATA/client_id on first use
+func GetClientID() (string, error) {
+	dataDir := GetWildCLIDataDir()
+	idFile := filepath.Join(dataDir, "client_id")
+
+	if data, err := os.ReadFile(idFile); err == nil {
+		if id := strings.TrimSpace(string(data)); id != "" {
+			return id, nil
+		}
+	}
+
+	buf := make([]byte, 12)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate client ID: %w", err)
+	}
+	id := "cli-" + hex.EncodeToString(buf)
+
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create data directory: %w", err)
+	}
+	if err := os.WriteFile(idFile, []byte(id), 0644); err != nil {
+		return "", fmt.Errorf("failed to write client ID file: %w", err)
+	}
+	return id, nil
+}
@@ -121,6 +121,72 @@ func TestGetCurrentInstance(t *testing.T) {
 	}
 }
 
+// mockContextResolver also reports a daemon context
+type mockContextResolver struct {
+	mockInstanceLister
+	context string
+	source  string
+}
+
+func (m *mockContextResolver) CurrentContext() (string, string, error) {
+	return m.context, m.source, nil
+}
+
+func TestGetCurrentInstance_DaemonContext(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("WILD_CLI_DATA", tmpDir)
+	currentFile := filepath.Join(tmpDir, "current_instance")
+
+	tests := []struct {
+		name         string
+		fileInstance string
+		source       string
+		wantInstance string
+		wantSource   string
+	}{
+		{"client context beats file", "file-instance", "client", "daemon-instance", "client"},
+		{"file beats daemon default", "file-instance", "default", "file-instance", "file"},
+		{"daemon default beats auto-select", "", "default", "daemon-instance", "default"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Remove(currentFile)
+			if tt.fileInstance != "" {
+				if err := os.WriteFile(currentFile, []byte(tt.fileInstance), 0644); err != nil {
+					t.Fatalf("Failed to write test file: %v", err)
+				}
+			}
+
+			lister := &mockContextResolver{
+				mockInstanceLister: mockInstanceLister{instances: []string{"api-instance"}},
+				context:            "daemon-instance",
+				source:             tt.source,
+			}
+			gotInstance, gotSource, err := GetCurrentInstance("", lister)
+			if err != nil {
+				t.Fatalf("GetCurrentInstance() error = %v", err)
+			}
+			if gotInstance != tt.wantInstance || gotSource != tt.wantSource {
+				t.Errorf("GetCurrentInstance() = %v (%v), want %v (%v)", gotInstance, gotSource, tt.wantInstance, tt.wantSource)
+			}
+		})
+	}
+}
+
+func TestGetClientID(t *testing.T) {
+	t.Setenv("WILD_CLI_DATA", t.TempDir())
+
+	id, err := GetClientID()
+	if err != nil {
+		t.Fatalf("GetClientID() error = %v", err)
+	}
+	again, err := GetClientID()
+	if err != nil || again != id {
+		t.Errorf("GetClientID() = %v, %v; want stable ID %v", again, err, id)
+	}
+}
+
 func TestSetCurrentInstance(t *testing.T) {
 	// Save and restore env var
 	oldWildCLIData := os.Getenv("WILD_CLI_DATA")
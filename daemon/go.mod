@@ -4,5 +4,8 @@ go 1.24
 
 require (
 	github.com/gorilla/mux v1.8.1
+	go.etcd.io/bbolt v1.4.3
 	gopkg.in/yaml.v3 v3.0.1
 )
+
+require golang.org/x/sys v0.29.0 // indirect
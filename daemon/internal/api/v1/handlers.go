@@ -2,11 +2,13 @@ package v1
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/gorilla/mux"
@@ -17,6 +19,7 @@ import (
 	"github.com/wild-cloud/wild-central/daemon/internal/instance"
 	"github.com/wild-cloud/wild-central/daemon/internal/operations"
 	"github.com/wild-cloud/wild-central/daemon/internal/secrets"
+	"github.com/wild-cloud/wild-central/daemon/internal/storage"
 )
 
 // API holds all dependencies for API handlers
@@ -60,39 +63,61 @@ func (api *API) RegisterRoutes(r *mux.Router) {
 	r.HandleFunc("/api/v1/instances", api.CreateInstance).Methods("POST")
 	r.HandleFunc("/api/v1/instances", api.ListInstances).Methods("GET")
 	r.HandleFunc("/api/v1/instances/{name}", api.GetInstance).Methods("GET")
+	r.HandleFunc("/api/v1/instances/{name}", api.UpdateInstance).Methods("PATCH")
+	r.HandleFunc("/api/v1/instances/{name}/repair", api.RepairInstance).Methods("POST")
 	r.HandleFunc("/api/v1/instances/{name}", api.DeleteInstance).Methods("DELETE")
+	r.HandleFunc("/api/v1/trash", api.TrashList).Methods("GET")
+	r.HandleFunc("/api/v1/trash/{id}/restore", api.TrashRestore).Methods("POST")
+	r.HandleFunc("/api/v1/trash/{id}", api.TrashPurge).Methods("DELETE")
+	r.HandleFunc("/api/v1/templates", api.ListTemplates).Methods("GET")
 
 	// Phase 1: Config management
 	r.HandleFunc("/api/v1/instances/{name}/config", api.GetConfig).Methods("GET")
-	r.HandleFunc("/api/v1/instances/{name}/config", api.UpdateConfig).Methods("PUT")
-	r.HandleFunc("/api/v1/instances/{name}/config", api.ConfigUpdateBatch).Methods("PATCH")
+	r.HandleFunc("/api/v1/instances/{name}/config", api.touching(api.UpdateConfig)).Methods("PUT")
+	r.HandleFunc("/api/v1/instances/{name}/config", api.touching(api.ConfigUpdateBatch)).Methods("PATCH")
 
 	// Phase 1: Secrets management
 	r.HandleFunc("/api/v1/instances/{name}/secrets", api.GetSecrets).Methods("GET")
-	r.HandleFunc("/api/v1/instances/{name}/secrets", api.UpdateSecrets).Methods("PUT")
+	r.HandleFunc("/api/v1/instances/{name}/secrets", api.touching(api.UpdateSecrets)).Methods("PUT")
+	r.HandleFunc("/api/v1/instances/{name}/secrets/encrypt", api.touching(api.SecretsEncrypt)).Methods("POST")
+	r.HandleFunc("/api/v1/instances/{name}/secrets/rotate", api.touching(api.SecretsRotate)).Methods("POST")
+	r.HandleFunc("/api/v1/instances/{name}/secrets/rollback", api.touching(api.SecretsRollback)).Methods("POST")
+	r.HandleFunc("/api/v1/instances/{name}/secrets/references", api.SecretsReferences).Methods("GET")
+	r.HandleFunc("/api/v1/instances/{name}/secrets/history", api.SecretsHistory).Methods("GET")
+	r.HandleFunc("/api/v1/instances/{name}/secrets/sync", api.SecretsSyncStatus).Methods("GET")
+	r.HandleFunc("/api/v1/instances/{name}/secrets/sync", api.touching(api.SecretsSync)).Methods("POST")
+	r.HandleFunc("/api/v1/instances/{name}/secrets/{path}", api.SecretsGet).Methods("GET")
+	r.HandleFunc("/api/v1/instances/{name}/secrets/{path}", api.touching(api.SecretsDelete)).Methods("DELETE")
+	r.HandleFunc("/api/v1/secrets/encryption", api.SecretsEncryptionStatus).Methods("GET")
+	r.HandleFunc("/api/v1/secrets/rotate-key", api.SecretsRotateKey).Methods("POST")
 
 	// Phase 1: Context management
 	r.HandleFunc("/api/v1/context", api.GetContext).Methods("GET")
 	r.HandleFunc("/api/v1/context", api.SetContext).Methods("POST")
+	r.HandleFunc("/api/v1/context", api.ClearContext).Methods("DELETE")
 
 	// Phase 2: Node management
-	r.HandleFunc("/api/v1/instances/{name}/nodes/discover", api.NodeDiscover).Methods("POST")
-	r.HandleFunc("/api/v1/instances/{name}/nodes/detect", api.NodeDetect).Methods("POST")
+	r.HandleFunc("/api/v1/instances/{name}/nodes/discover", api.touching(api.NodeDiscover)).Methods("POST")
+	r.HandleFunc("/api/v1/instances/{name}/nodes/detect", api.touching(api.NodeDetect)).Methods("POST")
 	r.HandleFunc("/api/v1/instances/{name}/discovery", api.NodeDiscoveryStatus).Methods("GET")
 	r.HandleFunc("/api/v1/instances/{name}/nodes/hardware/{ip}", api.NodeHardware).Methods("GET")
-	r.HandleFunc("/api/v1/instances/{name}/nodes/fetch-templates", api.NodeFetchTemplates).Methods("POST")
-	r.HandleFunc("/api/v1/instances/{name}/nodes", api.NodeAdd).Methods("POST")
+	r.HandleFunc("/api/v1/instances/{name}/nodes/fetch-templates", api.touching(api.NodeFetchTemplates)).Methods("POST")
+	r.HandleFunc("/api/v1/instances/{name}/nodes/upgrade", api.touching(api.NodeUpgrade)).Methods("POST")
+	r.HandleFunc("/api/v1/instances/{name}/nodes", api.touching(api.NodeAdd)).Methods("POST")
 	r.HandleFunc("/api/v1/instances/{name}/nodes", api.NodeList).Methods("GET")
 	r.HandleFunc("/api/v1/instances/{name}/nodes/{node}", api.NodeGet).Methods("GET")
-	r.HandleFunc("/api/v1/instances/{name}/nodes/{node}", api.NodeUpdate).Methods("PUT")
-	r.HandleFunc("/api/v1/instances/{name}/nodes/{node}/apply", api.NodeApply).Methods("POST")
-	r.HandleFunc("/api/v1/instances/{name}/nodes/{node}", api.NodeDelete).Methods("DELETE")
+	r.HandleFunc("/api/v1/instances/{name}/nodes/{node}", api.touching(api.NodeUpdate)).Methods("PUT")
+	r.HandleFunc("/api/v1/instances/{name}/nodes/{node}/apply", api.touching(api.NodeApply)).Methods("POST")
+	r.HandleFunc("/api/v1/instances/{name}/nodes/{node}/inventory", api.NodeInventory).Methods("GET")
+	r.HandleFunc("/api/v1/instances/{name}/nodes/{node}/inventory", api.touching(api.NodeInventoryRefresh)).Methods("POST")
+	r.HandleFunc("/api/v1/instances/{name}/nodes/{node}/actions/{action}", api.touching(api.NodeAction)).Methods("POST")
+	r.HandleFunc("/api/v1/instances/{name}/nodes/{node}", api.touching(api.NodeDelete)).Methods("DELETE")
 
 	// Phase 2: PXE asset management
 	r.HandleFunc("/api/v1/instances/{name}/pxe/assets", api.PXEListAssets).Methods("GET")
-	r.HandleFunc("/api/v1/instances/{name}/pxe/assets/download", api.PXEDownloadAsset).Methods("POST")
+	r.HandleFunc("/api/v1/instances/{name}/pxe/assets/download", api.touching(api.PXEDownloadAsset)).Methods("POST")
 	r.HandleFunc("/api/v1/instances/{name}/pxe/assets/{type}", api.PXEGetAsset).Methods("GET")
-	r.HandleFunc("/api/v1/instances/{name}/pxe/assets/{type}", api.PXEDeleteAsset).Methods("DELETE")
+	r.HandleFunc("/api/v1/instances/{name}/pxe/assets/{type}", api.touching(api.PXEDeleteAsset)).Methods("DELETE")
 
 	// Phase 2: Operations
 	r.HandleFunc("/api/v1/instances/{name}/operations", api.OperationList).Methods("GET")
@@ -101,60 +126,69 @@ func (api *API) RegisterRoutes(r *mux.Router) {
 	r.HandleFunc("/api/v1/operations/{id}/cancel", api.OperationCancel).Methods("POST")
 
 	// Phase 3: Cluster operations
-	r.HandleFunc("/api/v1/instances/{name}/cluster/config/generate", api.ClusterGenerateConfig).Methods("POST")
-	r.HandleFunc("/api/v1/instances/{name}/cluster/bootstrap", api.ClusterBootstrap).Methods("POST")
-	r.HandleFunc("/api/v1/instances/{name}/cluster/endpoints", api.ClusterConfigureEndpoints).Methods("POST")
+	r.HandleFunc("/api/v1/instances/{name}/cluster/config/generate", api.touching(api.ClusterGenerateConfig)).Methods("POST")
+	r.HandleFunc("/api/v1/instances/{name}/cluster/bootstrap", api.touching(api.ClusterBootstrap)).Methods("POST")
+	r.HandleFunc("/api/v1/instances/{name}/cluster/endpoints", api.touching(api.ClusterConfigureEndpoints)).Methods("POST")
 	r.HandleFunc("/api/v1/instances/{name}/cluster/status", api.ClusterGetStatus).Methods("GET")
 	r.HandleFunc("/api/v1/instances/{name}/cluster/health", api.ClusterHealth).Methods("GET")
 	r.HandleFunc("/api/v1/instances/{name}/cluster/kubeconfig", api.ClusterGetKubeconfig).Methods("GET")
-	r.HandleFunc("/api/v1/instances/{name}/cluster/kubeconfig/generate", api.ClusterGenerateKubeconfig).Methods("POST")
+	r.HandleFunc("/api/v1/instances/{name}/cluster/kubeconfig/generate", api.touching(api.ClusterGenerateKubeconfig)).Methods("POST")
 	r.HandleFunc("/api/v1/instances/{name}/cluster/talosconfig", api.ClusterGetTalosconfig).Methods("GET")
-	r.HandleFunc("/api/v1/instances/{name}/cluster/reset", api.ClusterReset).Methods("POST")
+	r.HandleFunc("/api/v1/instances/{name}/cluster/reset", api.touching(api.ClusterReset)).Methods("POST")
+	r.HandleFunc("/api/v1/instances/{name}/cluster/kubernetes/upgrade", api.touching(api.ClusterUpgradeKubernetes)).Methods("POST")
 
 	// Phase 4: Services
 	r.HandleFunc("/api/v1/instances/{name}/services", api.ServicesList).Methods("GET")
-	r.HandleFunc("/api/v1/instances/{name}/services", api.ServicesInstall).Methods("POST")
-	r.HandleFunc("/api/v1/instances/{name}/services/install-all", api.ServicesInstallAll).Methods("POST")
+	r.HandleFunc("/api/v1/instances/{name}/services", api.touching(api.ServicesInstall)).Methods("POST")
+	r.HandleFunc("/api/v1/instances/{name}/services/install-all", api.touching(api.ServicesInstallAll)).Methods("POST")
 	r.HandleFunc("/api/v1/instances/{name}/services/{service}", api.ServicesGet).Methods("GET")
-	r.HandleFunc("/api/v1/instances/{name}/services/{service}", api.ServicesDelete).Methods("DELETE")
+	r.HandleFunc("/api/v1/instances/{name}/services/{service}", api.touching(api.ServicesDelete)).Methods("DELETE")
 	r.HandleFunc("/api/v1/instances/{name}/services/{service}/status", api.ServicesGetStatus).Methods("GET")
 	r.HandleFunc("/api/v1/services/{service}/manifest", api.ServicesGetManifest).Methods("GET")
 	r.HandleFunc("/api/v1/services/{service}/config", api.ServicesGetConfig).Methods("GET")
 	r.HandleFunc("/api/v1/instances/{name}/services/{service}/config", api.ServicesGetInstanceConfig).Methods("GET")
 
 	// Service lifecycle endpoints
-	r.HandleFunc("/api/v1/instances/{name}/services/{service}/fetch", api.ServicesFetch).Methods("POST")
-	r.HandleFunc("/api/v1/instances/{name}/services/{service}/compile", api.ServicesCompile).Methods("POST")
-	r.HandleFunc("/api/v1/instances/{name}/services/{service}/deploy", api.ServicesDeploy).Methods("POST")
+	r.HandleFunc("/api/v1/instances/{name}/services/{service}/fetch", api.touching(api.ServicesFetch)).Methods("POST")
+	r.HandleFunc("/api/v1/instances/{name}/services/{service}/compile", api.touching(api.ServicesCompile)).Methods("POST")
+	r.HandleFunc("/api/v1/instances/{name}/services/{service}/deploy", api.touching(api.ServicesDeploy)).Methods("POST")
 
 	// Phase 4: Apps
 	r.HandleFunc("/api/v1/apps", api.AppsListAvailable).Methods("GET")
 	r.HandleFunc("/api/v1/apps/{app}", api.AppsGetAvailable).Methods("GET")
 	r.HandleFunc("/api/v1/instances/{name}/apps", api.AppsListDeployed).Methods("GET")
-	r.HandleFunc("/api/v1/instances/{name}/apps", api.AppsAdd).Methods("POST")
-	r.HandleFunc("/api/v1/instances/{name}/apps/{app}/deploy", api.AppsDeploy).Methods("POST")
-	r.HandleFunc("/api/v1/instances/{name}/apps/{app}", api.AppsDelete).Methods("DELETE")
+	r.HandleFunc("/api/v1/instances/{name}/apps", api.touching(api.AppsAdd)).Methods("POST")
+	r.HandleFunc("/api/v1/instances/{name}/apps/{app}/deploy", api.touching(api.AppsDeploy)).Methods("POST")
+	r.HandleFunc("/api/v1/instances/{name}/apps/{app}", api.touching(api.AppsDelete)).Methods("DELETE")
 	r.HandleFunc("/api/v1/instances/{name}/apps/{app}/status", api.AppsGetStatus).Methods("GET")
 
 	// Phase 5: Backup & Restore
-	r.HandleFunc("/api/v1/instances/{name}/apps/{app}/backup", api.BackupAppStart).Methods("POST")
+	r.HandleFunc("/api/v1/instances/{name}/apps/{app}/backup", api.touching(api.BackupAppStart)).Methods("POST")
 	r.HandleFunc("/api/v1/instances/{name}/apps/{app}/backup", api.BackupAppList).Methods("GET")
-	r.HandleFunc("/api/v1/instances/{name}/apps/{app}/restore", api.BackupAppRestore).Methods("POST")
+	r.HandleFunc("/api/v1/instances/{name}/apps/{app}/restore", api.touching(api.BackupAppRestore)).Methods("POST")
 
 	// Phase 5: Utilities
 	r.HandleFunc("/api/v1/utilities/health", api.UtilitiesHealth).Methods("GET")
 	r.HandleFunc("/api/v1/instances/{name}/utilities/health", api.InstanceUtilitiesHealth).Methods("GET")
+	r.HandleFunc("/api/v1/instances/{name}/utilities/reachable", api.InstanceUtilitiesReachable).Methods("GET")
 	r.HandleFunc("/api/v1/utilities/dashboard/token", api.UtilitiesDashboardToken).Methods("GET")
 	r.HandleFunc("/api/v1/utilities/nodes/ips", api.UtilitiesNodeIPs).Methods("GET")
 	r.HandleFunc("/api/v1/utilities/controlplane/ip", api.UtilitiesControlPlaneIP).Methods("GET")
 	r.HandleFunc("/api/v1/utilities/secrets/{secret}/copy", api.UtilitiesSecretCopy).Methods("POST")
 	r.HandleFunc("/api/v1/utilities/version", api.UtilitiesVersion).Methods("GET")
+
+	// Administration
+	r.HandleFunc("/api/v1/admin/locks", api.AdminLocks).Methods("GET")
 }
 
-// CreateInstance creates a new instance
+// CreateInstance creates a new instance, optionally from a template with initial config values
 func (api *API) CreateInstance(w http.ResponseWriter, r *http.Request) {
 	var req struct {
-		Name string `json:"name"`
+		Name        string            `json:"name"`
+		Template    string            `json:"template,omitempty"`
+		Config      map[string]string `json:"config,omitempty"`
+		Description string            `json:"description,omitempty"`
+		Labels      map[string]string `json:"labels,omitempty"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -166,28 +200,172 @@ func (api *API) CreateInstance(w http.ResponseWriter, r *http.Request) {
 		respondError(w, http.StatusBadRequest, "Instance name is required")
 		return
 	}
+	if err := instance.ValidateInstanceName(req.Name); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := instance.ValidateLabels(req.Labels); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	opts := instance.CreateOptions{
+		Values:            req.Config,
+		Description:       req.Description,
+		Labels:            req.Labels,
+		DirectoryRevision: instance.DirectoryRevision(api.directoryPath),
+	}
+
+	// Plain creation stays idempotent
+	if req.Template == "" && len(req.Config) == 0 && req.Description == "" && len(req.Labels) == 0 {
+		if !api.instance.InstanceExists(req.Name) {
+			if err := api.instance.CreateInstanceWithOptions(req.Name, opts); err != nil {
+				if respondLocked(w, err) {
+					return
+				}
+				respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to create instance: %v", err))
+				return
+			}
+		}
+
+		respondJSON(w, http.StatusCreated, map[string]string{
+			"name":    req.Name,
+			"message": "Instance created successfully",
+		})
+		return
+	}
+
+	if api.instance.InstanceExists(req.Name) {
+		respondError(w, http.StatusConflict, fmt.Sprintf("Instance %s already exists", req.Name))
+		return
+	}
+
+	if req.Template != "" {
+		tmpl, err := instance.FindTemplate(req.Template, api.templateDirs()...)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, fmt.Sprintf("Invalid template: %v", err))
+			return
+		}
+		opts.Template = tmpl
+	}
+
+	// Validate up front so schema errors are reported as bad requests
+	values, required := opts.InitialValues()
+	if err := instance.ValidateValues(values, required); err != nil {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("Invalid configuration: %v", err))
+		return
+	}
 
-	if err := api.instance.CreateInstance(req.Name); err != nil {
+	if err := api.instance.CreateInstanceWithOptions(req.Name, opts); err != nil {
+		if respondLocked(w, err) {
+			return
+		}
 		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to create instance: %v", err))
 		return
 	}
 
 	respondJSON(w, http.StatusCreated, map[string]string{
-		"name":    req.Name,
-		"message": "Instance created successfully",
+		"name":     req.Name,
+		"template": req.Template,
+		"message":  "Instance created successfully",
 	})
 }
 
-// ListInstances lists all instances
+// ListTemplates lists available instance templates
+func (api *API) ListTemplates(w http.ResponseWriter, r *http.Request) {
+	templates, err := instance.ListTemplates(api.templateDirs()...)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to list templates: %v", err))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"templates": templates,
+	})
+}
+
+// templateDirs returns instance template directories in lookup order.
+// Templates in the data directory override those shipped with the Wild Cloud Directory.
+func (api *API) templateDirs() []string {
+	return []string{
+		api.instance.GetTemplatesDir(),
+		filepath.Join(api.directoryPath, "setup", "instance-templates"),
+	}
+}
+
+// ListInstances lists instances with their metadata and a summary of their
+// state. ?label=key=value (repeatable, or comma-separated) filters by label.
+// "instances" holds just the names, as before.
 func (api *API) ListInstances(w http.ResponseWriter, r *http.Request) {
-	instances, err := api.instance.ListInstances()
+	selector, err := instance.ParseSelector(r.URL.Query()["label"]...)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	summaries, err := api.instance.ListInstanceSummaries(selector)
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to list instances: %v", err))
 		return
 	}
 
+	names := make([]string, 0, len(summaries))
+	for _, summary := range summaries {
+		names = append(names, summary.Name)
+	}
+
 	respondJSON(w, http.StatusOK, map[string]interface{}{
-		"instances": instances,
+		"instances": names,
+		"summaries": summaries,
+	})
+}
+
+// UpdateInstance updates an instance's description and labels. Labels set
+// to "" are removed; labels not mentioned are kept.
+func (api *API) UpdateInstance(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+
+	if !api.instance.InstanceExists(name) {
+		respondError(w, http.StatusNotFound, fmt.Sprintf("Instance %s not found", name))
+		return
+	}
+
+	var req struct {
+		Description *string           `json:"description"`
+		Labels      map[string]string `json:"labels"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	meta, err := api.instance.UpdateMetadata(name, func(meta *instance.Metadata) {
+		if req.Description != nil {
+			meta.Description = *req.Description
+		}
+		for key, value := range req.Labels {
+			if value == "" {
+				delete(meta.Labels, key)
+				continue
+			}
+			if meta.Labels == nil {
+				meta.Labels = make(map[string]string)
+			}
+			meta.Labels[key] = value
+		}
+	})
+	if err != nil {
+		if respondLocked(w, err) {
+			return
+		}
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("Failed to update instance: %v", err))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"name":     name,
+		"metadata": meta,
 	})
 }
 
@@ -215,24 +393,91 @@ func (api *API) GetInstance(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	summary, err := api.instance.GetSummary(name)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to read instance metadata: %v", err))
+		return
+	}
+
 	respondJSON(w, http.StatusOK, map[string]interface{}{
-		"name":   name,
-		"config": configMap,
+		"name":    name,
+		"config":  configMap,
+		"summary": summary,
 	})
 }
 
-// DeleteInstance deletes an instance
+// RepairInstance checks an instance's files and repairs what can be fixed
+// safely. With {"dryRun": true} it only reports.
+func (api *API) RepairInstance(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+
+	if !api.instance.InstanceExists(name) {
+		respondError(w, http.StatusNotFound, fmt.Sprintf("Instance %s not found", name))
+		return
+	}
+
+	var req struct {
+		DryRun bool `json:"dryRun"`
+	}
+	if r.ContentLength > 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+	}
+
+	report, err := api.instance.Doctor(name, !req.DryRun)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to check instance: %v", err))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, report)
+}
+
+// DeleteInstance moves an instance to the trash. With ?permanent=true it is
+// removed outright, which needs a confirmation token (see requireConfirmation).
 func (api *API) DeleteInstance(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	name := vars["name"]
 
-	if err := api.instance.DeleteInstance(name); err != nil {
+	if !api.instance.InstanceExists(name) {
+		respondError(w, http.StatusNotFound, fmt.Sprintf("Instance %s not found", name))
+		return
+	}
+
+	if r.URL.Query().Get("permanent") == "true" {
+		if !api.requireConfirmation(w, r, "delete:"+name,
+			fmt.Sprintf("Permanently deleting %s destroys its Talos secrets; an existing cluster can no longer be managed", name)) {
+			return
+		}
+
+		if err := api.instance.PermanentlyDeleteInstance(name); err != nil {
+			if respondLocked(w, err) {
+				return
+			}
+			respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to delete instance: %v", err))
+			return
+		}
+
+		respondJSON(w, http.StatusOK, map[string]string{
+			"message": "Instance permanently deleted",
+		})
+		return
+	}
+
+	entry, err := api.instance.DeleteInstance(name)
+	if err != nil {
+		if respondLocked(w, err) {
+			return
+		}
 		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to delete instance: %v", err))
 		return
 	}
 
-	respondJSON(w, http.StatusOK, map[string]string{
-		"message": "Instance deleted successfully",
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"message": "Instance moved to trash",
+		"trash":   entry,
 	})
 }
 
@@ -290,6 +535,9 @@ func (api *API) UpdateConfig(w http.ResponseWriter, r *http.Request) {
 	for key, value := range updates {
 		valueStr := fmt.Sprintf("%v", value)
 		if err := api.config.SetConfigValue(configPath, key, valueStr); err != nil {
+			if respondLocked(w, err) {
+				return
+			}
 			respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to update config key %s: %v", key, err))
 			return
 		}
@@ -300,7 +548,9 @@ func (api *API) UpdateConfig(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// GetSecrets retrieves instance secrets (redacted by default)
+// GetSecrets retrieves instance secrets. By default every value is replaced
+// with whether it is set, its length and when it last changed. ?raw=true
+// returns the values, as it always has, when the daemon allows revealing.
 func (api *API) GetSecrets(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	name := vars["name"]
@@ -310,9 +560,28 @@ func (api *API) GetSecrets(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	secretsPath := api.instance.GetInstanceSecretsPath(name)
+	// Check if client wants raw secrets (dangerous!)
+	if r.URL.Query().Get("raw") != "true" {
+		tree, err := api.secrets.List(api.instance.GetInstancePath(name))
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to list secrets: %v", err))
+			return
+		}
+		respondJSON(w, http.StatusOK, tree)
+		return
+	}
+
+	if !api.checkReveal(w, r, name, fmt.Sprintf("This returns every secret of %s in plaintext", name)) {
+		return
+	}
+
+	store, err := api.secrets.Open(api.instance.GetInstancePath(name))
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to open secrets: %v", err))
+		return
+	}
 
-	secretsData, err := os.ReadFile(secretsPath)
+	secretsData, err := store.Read()
 	if err != nil {
 		if os.IsNotExist(err) {
 			respondJSON(w, http.StatusOK, map[string]interface{}{})
@@ -328,16 +597,6 @@ func (api *API) GetSecrets(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Check if client wants raw secrets (dangerous!)
-	showRaw := r.URL.Query().Get("raw") == "true"
-
-	if !showRaw {
-		// Redact secrets
-		for key := range secretsMap {
-			secretsMap[key] = "********"
-		}
-	}
-
 	respondJSON(w, http.StatusOK, secretsMap)
 }
 
@@ -370,6 +629,9 @@ func (api *API) UpdateSecrets(w http.ResponseWriter, r *http.Request) {
 	for key, value := range updates {
 		valueStr := fmt.Sprintf("%v", value)
 		if err := api.secrets.SetSecret(secretsPath, key, valueStr); err != nil {
+			if respondLocked(w, err) {
+				return
+			}
 			respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to update secret %s: %v", key, err))
 			return
 		}
@@ -380,29 +642,38 @@ func (api *API) UpdateSecrets(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// GetContext retrieves current context
+// GetContext resolves the current context for the calling client. See the
+// context package for the resolution rules.
 func (api *API) GetContext(w http.ResponseWriter, r *http.Request) {
-	currentContext, err := api.context.GetCurrentContext()
+	clientKey, err := clientKey(r)
 	if err != nil {
-		if os.IsNotExist(err) {
-			respondJSON(w, http.StatusOK, map[string]interface{}{
-				"context": nil,
-			})
-			return
-		}
-		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to get context: %v", err))
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	currentContext, source, err := api.context.ResolveContext(clientKey)
+	if err != nil {
+		// No context set anywhere
+		respondJSON(w, http.StatusOK, map[string]interface{}{
+			"context":       nil,
+			"client_scoped": clientKey != "",
+		})
 		return
 	}
 
 	respondJSON(w, http.StatusOK, map[string]interface{}{
-		"context": currentContext,
+		"context":       currentContext,
+		"source":        source,
+		"client_scoped": clientKey != "",
 	})
 }
 
-// SetContext sets current context
+// SetContext sets the current context of the calling client. Requests that
+// identify no client, or set "scope": "default", set the daemon-wide default.
 func (api *API) SetContext(w http.ResponseWriter, r *http.Request) {
 	var req struct {
 		Context string `json:"context"`
+		Scope   string `json:"scope,omitempty"` // "client" (default when identified) or "default"
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -415,23 +686,117 @@ func (api *API) SetContext(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := api.context.SetCurrentContext(req.Context); err != nil {
+	key, scope, ok := api.contextScope(w, r, req.Scope)
+	if !ok {
+		return
+	}
+
+	if scope == context.SourceClient {
+		err := api.context.SetClientContext(key, req.Context)
+		if err != nil {
+			if respondLocked(w, err) {
+				return
+			}
+			respondError(w, http.StatusBadRequest, fmt.Sprintf("Failed to set context: %v", err))
+			return
+		}
+	} else if err := api.context.SetCurrentContext(req.Context); err != nil {
+		if respondLocked(w, err) {
+			return
+		}
 		respondError(w, http.StatusBadRequest, fmt.Sprintf("Failed to set context: %v", err))
 		return
 	}
 
 	respondJSON(w, http.StatusOK, map[string]string{
 		"context": req.Context,
+		"scope":   scope,
 		"message": "Context set successfully",
 	})
 }
 
+// ClearContext clears the calling client's context, or with ?scope=default
+// the daemon-wide default
+func (api *API) ClearContext(w http.ResponseWriter, r *http.Request) {
+	key, scope, ok := api.contextScope(w, r, r.URL.Query().Get("scope"))
+	if !ok {
+		return
+	}
+
+	var err error
+	if scope == context.SourceClient {
+		err = api.context.ClearClientContext(key)
+	} else {
+		err = api.context.ClearCurrentContext()
+	}
+	if err != nil {
+		if respondLocked(w, err) {
+			return
+		}
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to clear context: %v", err))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]string{
+		"scope":   scope,
+		"message": "Context cleared",
+	})
+}
+
+// contextScope decides whether a context change applies to the calling
+// client or the daemon-wide default, responding with an error if it cannot
+func (api *API) contextScope(w http.ResponseWriter, r *http.Request, requested string) (string, string, bool) {
+	key, err := clientKey(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return "", "", false
+	}
+
+	switch requested {
+	case "":
+		if key != "" {
+			return key, context.SourceClient, true
+		}
+		return "", context.SourceDefault, true
+	case context.SourceClient:
+		if key == "" {
+			respondError(w, http.StatusBadRequest, "Client scope needs an API token or "+ClientIDHeader+" header")
+			return "", "", false
+		}
+		return key, context.SourceClient, true
+	case context.SourceDefault:
+		return "", context.SourceDefault, true
+	default:
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("Unknown scope %q", requested))
+		return "", "", false
+	}
+}
+
+// ClientIDHeader identifies a client that has no API token
+const ClientIDHeader = "X-Wild-Client-ID"
+
+// clientKey identifies the calling client: by API token if the request
+// carries one, otherwise by client ID. Returns "" for anonymous requests.
+func clientKey(r *http.Request) (string, error) {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok && strings.TrimSpace(token) != "" {
+		return context.TokenClientKey(strings.TrimSpace(token)), nil
+	}
+	if id := r.Header.Get(ClientIDHeader); id != "" {
+		return context.IDClientKey(id)
+	}
+	return "", nil
+}
+
 // StatusHandler returns daemon status information
 func (api *API) StatusHandler(w http.ResponseWriter, r *http.Request, startTime time.Time, dataDir, directoryPath string) {
-	// Get list of instances
-	instances, err := api.instance.ListInstances()
+	// Get instances with their summaries
+	summaries, err := api.instance.ListInstanceSummaries(nil)
 	if err != nil {
-		instances = []string{}
+		summaries = []instance.Summary{}
+	}
+	instances := make([]string, 0, len(summaries))
+	for _, summary := range summaries {
+		instances = append(instances, summary.Name)
 	}
 
 	// Calculate uptime
@@ -445,8 +810,9 @@ func (api *API) StatusHandler(w http.ResponseWriter, r *http.Request, startTime
 		"dataDir":       dataDir,
 		"directoryPath": directoryPath,
 		"instances": map[string]interface{}{
-			"count": len(instances),
-			"names": instances,
+			"count":     len(instances),
+			"names":     instances,
+			"summaries": summaries,
 		},
 	})
 }
@@ -464,3 +830,49 @@ func respondError(w http.ResponseWriter, status int, message string) {
 		"error": message,
 	})
 }
+
+// respondLocked responds when err is a lock that could not be taken in time,
+// including who holds it, and reports whether it did. A lock held by an
+// operation gets 409 Conflict since it lasts until the operation ends; any
+// other holder gets 423 Locked and the request can be retried.
+func respondLocked(w http.ResponseWriter, err error) bool {
+	var lockErr *storage.LockError
+	if !errors.As(err, &lockErr) {
+		return false
+	}
+
+	status := http.StatusLocked
+	if lockErr.Holder != nil && lockErr.Holder.OperationID != "" {
+		status = http.StatusConflict
+	}
+	respondJSON(w, status, map[string]interface{}{
+		"error":  lockErr.Error(),
+		"lock":   lockErr.Path,
+		"holder": lockErr.Holder,
+	})
+	return true
+}
+
+// touching wraps a handler that changes an instance so a successful response
+// records the change in the instance metadata. Metadata is informational; a
+// failure to record it does not fail the request.
+func (api *API) touching(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		h(rec, r)
+		if rec.status < 300 {
+			api.instance.Touch(mux.Vars(r)["name"], "")
+		}
+	}
+}
+
+// statusRecorder remembers the status a handler responded with
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
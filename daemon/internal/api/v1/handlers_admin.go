@@ -0,0 +1,33 @@
+package v1
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/wild-cloud/wild-central/daemon/internal/storage"
+)
+
+// AdminLocks lists the file locks the daemon currently holds
+func (api *API) AdminLocks(w http.ResponseWriter, r *http.Request) {
+	timeout, err := storage.LockTimeout()
+	if err != nil {
+		timeout = storage.DefaultLockTimeout
+	}
+
+	now := time.Now()
+	locks := []map[string]interface{}{}
+	for _, holder := range storage.HeldLocks() {
+		locks = append(locks, map[string]interface{}{
+			"path":         holder.Path,
+			"pid":          holder.PID,
+			"operation_id": holder.OperationID,
+			"acquired_at":  holder.AcquiredAt,
+			"held_for":     now.Sub(holder.AcquiredAt).Round(time.Millisecond).String(),
+		})
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"locks":   locks,
+		"timeout": timeout.String(),
+	})
+}
@@ -2,6 +2,7 @@ package v1
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 
@@ -96,6 +97,14 @@ func (api *API) AppsAdd(w http.ResponseWriter, r *http.Request) {
 	// Add app
 	appsMgr := apps.NewManager(api.dataDir, api.appsDir)
 	if err := appsMgr.Add(instanceName, req.Name, req.Config); err != nil {
+		var secretsErr *apps.SecretsError
+		if errors.As(err, &secretsErr) {
+			respondJSON(w, http.StatusConflict, map[string]interface{}{
+				"error":    err.Error(),
+				"problems": secretsErr.Problems,
+			})
+			return
+		}
 		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to add app: %v", err))
 		return
 	}
@@ -118,6 +127,20 @@ func (api *API) AppsDeploy(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Report missing or invalid secrets before starting
+	problems, err := apps.NewManager(api.dataDir, api.appsDir).CheckSecrets(instanceName, appName)
+	if err != nil {
+		respondError(w, http.StatusNotFound, fmt.Sprintf("Failed to check app secrets: %v", err))
+		return
+	}
+	if len(problems) > 0 {
+		respondJSON(w, http.StatusConflict, map[string]interface{}{
+			"error":    (&apps.SecretsError{App: appName, Problems: problems}).Error(),
+			"problems": problems,
+		})
+		return
+	}
+
 	// Start deploy operation
 	opsMgr := operations.NewManager(api.dataDir)
 	opID, err := opsMgr.Start(instanceName, "deploy_app", appName)
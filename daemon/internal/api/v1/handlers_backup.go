@@ -36,7 +36,7 @@ func (api *API) BackupAppStart(w http.ResponseWriter, r *http.Request) {
 		}
 
 		opMgr.Update(instanceName, opID, "completed", "Backup completed", 100)
-		_ = info // Metadata saved in backup.json
+		_ = info // Metadata saved in the instance state store
 	}()
 
 	respondJSON(w, http.StatusAccepted, map[string]interface{}{
@@ -4,11 +4,16 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"path/filepath"
+	"strings"
 
 	"github.com/gorilla/mux"
 
+	"github.com/wild-cloud/wild-central/daemon/internal/apps"
 	"github.com/wild-cloud/wild-central/daemon/internal/cluster"
+	"github.com/wild-cloud/wild-central/daemon/internal/node"
 	"github.com/wild-cloud/wild-central/daemon/internal/operations"
+	"github.com/wild-cloud/wild-central/daemon/internal/storage"
 )
 
 // ClusterGenerateConfig generates cluster configuration
@@ -45,11 +50,18 @@ func (api *API) ClusterGenerateConfig(w http.ResponseWriter, r *http.Request) {
 		version = "v1.11.0"
 	}
 
+	// Get cluster.kubernetes.version (optional, set by Kubernetes upgrades)
+	kubernetesVersion, err := api.config.GetConfigValue(configPath, "cluster.kubernetes.version")
+	if err != nil || kubernetesVersion == "null" {
+		kubernetesVersion = ""
+	}
+
 	// Create cluster config
 	config := cluster.ClusterConfig{
-		ClusterName: clusterName,
-		VIP:         vip,
-		Version:     version,
+		ClusterName:       clusterName,
+		VIP:               vip,
+		Version:           version,
+		KubernetesVersion: kubernetesVersion,
 	}
 
 	// Generate configuration
@@ -329,3 +341,116 @@ func (api *API) ClusterReset(w http.ResponseWriter, r *http.Request) {
 		"message":      "Cluster reset initiated",
 	})
 }
+
+// ClusterUpgradeKubernetes upgrades Kubernetes with talosctl upgrade-k8s,
+// after checking version skew and the APIs used by deployed services and
+// apps. A dry run reports what would change even when checks fail.
+func (api *API) ClusterUpgradeKubernetes(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	instanceName := vars["name"]
+
+	// Validate instance exists
+	if err := api.instance.ValidateInstance(instanceName); err != nil {
+		respondError(w, http.StatusNotFound, fmt.Sprintf("Instance not found: %v", err))
+		return
+	}
+
+	var opts cluster.KubernetesUpgradeOptions
+	if err := json.NewDecoder(r.Body).Decode(&opts); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if opts.Version == "" {
+		respondError(w, http.StatusBadRequest, "version is required")
+		return
+	}
+
+	// Scan the manifests of the apps configured on this instance
+	appsMgr := apps.NewManager(api.dataDir, api.appsDir)
+	appNames, err := appsMgr.ListConfigured(instanceName)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to list apps: %v", err))
+		return
+	}
+	var appManifestDirs []string
+	for _, appName := range appNames {
+		appManifestDirs = append(appManifestDirs, filepath.Join(appsMgr.GetAppDir(appName), "manifests"))
+	}
+
+	clusterMgr := cluster.NewManager(api.dataDir)
+	preflight, err := clusterMgr.PreflightKubernetesUpgrade(instanceName, opts.Version, appManifestDirs)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("Cannot upgrade: %v", err))
+		return
+	}
+	if !preflight.Ready && !opts.DryRun {
+		var failures []string
+		for _, check := range preflight.Checks {
+			if check.Status == "failing" {
+				failures = append(failures, check.Message)
+			}
+		}
+		respondJSON(w, http.StatusBadRequest, map[string]interface{}{
+			"error":     fmt.Sprintf("Preflight checks failed: %s", strings.Join(failures, "; ")),
+			"preflight": preflight,
+		})
+		return
+	}
+
+	// Kubernetes and Talos upgrades both restart nodes, so an upgrade waits
+	// for other upgrades and for disruptive node operations. Check and start
+	// under the node operations lock, so a node action cannot start between
+	// the check and the upgrade being recorded.
+	opsMgr := operations.NewManager(api.dataDir)
+	if !opts.DryRun {
+		lock, err := storage.AcquireLock(node.NewManager(api.dataDir).GetOperationsLockPath(instanceName))
+		if err != nil {
+			if !respondLocked(w, err) {
+				respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to lock node operations: %v", err))
+			}
+			return
+		}
+		defer lock.Release()
+
+		if op, err := activeNodeOperation(opsMgr, instanceName, "", true); err != nil {
+			respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to check operations: %v", err))
+			return
+		} else if op != nil {
+			respondError(w, http.StatusConflict, fmt.Sprintf("Operation %s is in progress", op.ID))
+			return
+		}
+	}
+
+	opType, message := "upgrade_kubernetes", fmt.Sprintf("Kubernetes upgrade to %s", preflight.TargetVersion)
+	if opts.DryRun {
+		opType, message = "upgrade_kubernetes_dry_run", fmt.Sprintf("Kubernetes upgrade dry run for %s", preflight.TargetVersion)
+	}
+	opID, err := opsMgr.Start(instanceName, opType, preflight.TargetVersion)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to start operation: %v", err))
+		return
+	}
+
+	go func() {
+		out := opsMgr.Output(instanceName, opID, api.broadcaster)
+		defer out.Close()
+		defer func() {
+			if r := recover(); r != nil {
+				opsMgr.Update(instanceName, opID, "failed", fmt.Sprintf("Internal error: %v", r), 0)
+			}
+		}()
+
+		opsMgr.UpdateStatus(instanceName, opID, "running")
+		if err := clusterMgr.UpgradeKubernetes(instanceName, opts, preflight, out); err != nil {
+			opsMgr.Update(instanceName, opID, "failed", err.Error(), 0)
+		} else {
+			opsMgr.Update(instanceName, opID, "completed", message+" completed", 100)
+		}
+	}()
+
+	respondJSON(w, http.StatusAccepted, map[string]interface{}{
+		"operation_id": opID,
+		"message":      message + " initiated",
+		"preflight":    preflight,
+	})
+}
@@ -62,6 +62,9 @@ func (api *API) ConfigUpdateBatch(w http.ResponseWriter, r *http.Request) {
 	for _, update := range req.Updates {
 		valueStr := fmt.Sprintf("%v", update.Value)
 		if err := api.config.SetConfigValue(configPath, update.Path, valueStr); err != nil {
+			if respondLocked(w, err) {
+				return
+			}
 			respondError(w, http.StatusInternalServerError,
 				fmt.Sprintf("Failed to update config path %s: %v", update.Path, err))
 			return
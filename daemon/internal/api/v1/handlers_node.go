@@ -2,13 +2,18 @@ package v1
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"strings"
 
 	"github.com/gorilla/mux"
 
 	"github.com/wild-cloud/wild-central/daemon/internal/discovery"
 	"github.com/wild-cloud/wild-central/daemon/internal/node"
+	"github.com/wild-cloud/wild-central/daemon/internal/operations"
+	"github.com/wild-cloud/wild-central/daemon/internal/storage"
 )
 
 // NodeDiscover initiates node discovery
@@ -152,6 +157,13 @@ func (api *API) NodeAdd(w http.ResponseWriter, r *http.Request) {
 	// Add node
 	nodeMgr := node.NewManager(api.dataDir)
 	if err := nodeMgr.Add(instanceName, &nodeData); err != nil {
+		if respondLocked(w, err) {
+			return
+		}
+		if invalidNode(err) {
+			respondError(w, http.StatusBadRequest, fmt.Sprintf("Invalid node: %v", err))
+			return
+		}
 		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to add node: %v", err))
 		return
 	}
@@ -206,10 +218,80 @@ func (api *API) NodeGet(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	respondJSON(w, http.StatusOK, nodeData)
+	// With the hardware inventory last gathered, if any
+	inventory, err := nodeMgr.Inventory(instanceName, nodeIdentifier)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to read inventory: %v", err))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, struct {
+		*node.Node
+		Inventory *node.Inventory `json:"inventory,omitempty"`
+	}{nodeData, inventory})
+}
+
+// NodeInventory returns the hardware inventory last gathered for a node
+func (api *API) NodeInventory(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	instanceName := vars["name"]
+	nodeIdentifier := vars["node"]
+
+	// Validate instance exists
+	if err := api.instance.ValidateInstance(instanceName); err != nil {
+		respondError(w, http.StatusNotFound, fmt.Sprintf("Instance not found: %v", err))
+		return
+	}
+
+	nodeMgr := node.NewManager(api.dataDir)
+	if _, err := nodeMgr.Get(instanceName, nodeIdentifier); err != nil {
+		respondError(w, http.StatusNotFound, fmt.Sprintf("Node not found: %v", err))
+		return
+	}
+
+	inventory, err := nodeMgr.Inventory(instanceName, nodeIdentifier)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to read inventory: %v", err))
+		return
+	}
+	if inventory == nil {
+		respondError(w, http.StatusNotFound, "No inventory has been gathered for this node")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, inventory)
 }
 
-// NodeApply generates configuration and applies it to node
+// NodeInventoryRefresh gathers a node's hardware inventory through talosctl
+// and keeps it in the instance
+func (api *API) NodeInventoryRefresh(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	instanceName := vars["name"]
+	nodeIdentifier := vars["node"]
+
+	// Validate instance exists
+	if err := api.instance.ValidateInstance(instanceName); err != nil {
+		respondError(w, http.StatusNotFound, fmt.Sprintf("Instance not found: %v", err))
+		return
+	}
+
+	nodeMgr := node.NewManager(api.dataDir)
+	if _, err := nodeMgr.Get(instanceName, nodeIdentifier); err != nil {
+		respondError(w, http.StatusNotFound, fmt.Sprintf("Node not found: %v", err))
+		return
+	}
+
+	inventory, err := nodeMgr.RefreshInventory(instanceName, nodeIdentifier)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to gather inventory: %v", err))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, inventory)
+}
+
+// NodeApply generates configuration and applies it to node. With
+// ?dryRun=true it only reports what applying would change.
 func (api *API) NodeApply(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	instanceName := vars["name"]
@@ -221,12 +303,27 @@ func (api *API) NodeApply(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	nodeMgr := node.NewManager(api.dataDir)
+
+	// ?dryRun=true renders and diffs the configuration without applying it
+	if r.URL.Query().Get("dryRun") == "true" {
+		result, err := nodeMgr.DryRun(instanceName, nodeIdentifier)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to render node configuration: %v", err))
+			return
+		}
+		respondJSON(w, http.StatusOK, result)
+		return
+	}
+
 	// Apply always uses default options (no body needed)
 	opts := node.ApplyOptions{}
 
 	// Apply node configuration
-	nodeMgr := node.NewManager(api.dataDir)
 	if err := nodeMgr.Apply(instanceName, nodeIdentifier, opts); err != nil {
+		if respondLocked(w, err) {
+			return
+		}
 		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to apply node configuration: %v", err))
 		return
 	}
@@ -258,14 +355,236 @@ func (api *API) NodeUpdate(w http.ResponseWriter, r *http.Request) {
 
 	// Update node
 	nodeMgr := node.NewManager(api.dataDir)
+	before, err := nodeMgr.Get(instanceName, nodeIdentifier)
+	if err != nil {
+		respondError(w, http.StatusNotFound, fmt.Sprintf("Node not found: %v", err))
+		return
+	}
 	if err := nodeMgr.Update(instanceName, nodeIdentifier, updates); err != nil {
+		if respondLocked(w, err) {
+			return
+		}
+		if invalidNode(err) {
+			respondError(w, http.StatusBadRequest, fmt.Sprintf("Invalid node: %v", err))
+			return
+		}
 		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to update node: %v", err))
 		return
 	}
 
-	respondJSON(w, http.StatusOK, map[string]string{
+	response := map[string]string{
 		"message": "Node updated successfully",
 		"node":    nodeIdentifier,
+	}
+
+	// Labels, taints and annotations are changed on the running node too.
+	// config.yaml keeps the change if that fails; applying the node's
+	// configuration again sets them as well.
+	_, labels := updates["labels"]
+	_, taints := updates["taints"]
+	_, annotations := updates["annotations"]
+	if labels || taints || annotations {
+		after, err := nodeMgr.Get(instanceName, nodeIdentifier)
+		if err == nil {
+			err = nodeMgr.ReconcileMetadata(instanceName, before, after)
+		}
+		if err != nil {
+			response["warning"] = fmt.Sprintf("Node updated, but updating the running node failed: %v", err)
+		}
+	}
+
+	respondJSON(w, http.StatusOK, response)
+}
+
+// invalidNode reports whether err rejects a node's labels, taints,
+// annotations or storage
+func invalidNode(err error) bool {
+	var metadataErr *node.MetadataError
+	var storageErr *node.StorageError
+	return errors.As(err, &metadataErr) || errors.As(err, &storageErr)
+}
+
+// NodeUpgrade starts a rolling Talos upgrade of the instance's nodes
+func (api *API) NodeUpgrade(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	instanceName := vars["name"]
+
+	// Validate instance exists
+	if err := api.instance.ValidateInstance(instanceName); err != nil {
+		respondError(w, http.StatusNotFound, fmt.Sprintf("Instance not found: %v", err))
+		return
+	}
+
+	var opts node.UpgradeOptions
+	if err := json.NewDecoder(r.Body).Decode(&opts); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	nodeMgr := node.NewManager(api.dataDir)
+	plan, err := nodeMgr.PlanUpgrade(instanceName, opts)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("Cannot upgrade: %v", err))
+		return
+	}
+
+	// Check and start under the node operations lock, so a node action
+	// cannot start between the check and the upgrade being recorded
+	lock, err := storage.AcquireLock(nodeMgr.GetOperationsLockPath(instanceName))
+	if err != nil {
+		if !respondLocked(w, err) {
+			respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to lock node operations: %v", err))
+		}
+		return
+	}
+	defer lock.Release()
+
+	// The upgrade reboots every node in turn, so it waits for other upgrades
+	// and for disruptive node operations
+	opsMgr := operations.NewManager(api.dataDir)
+	if op, err := activeNodeOperation(opsMgr, instanceName, "", true); err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to check operations: %v", err))
+		return
+	} else if op != nil {
+		respondError(w, http.StatusConflict, fmt.Sprintf("Operation %s is in progress", op.ID))
+		return
+	}
+
+	opID, err := opsMgr.Start(instanceName, "upgrade_talos", plan.Version)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to start operation: %v", err))
+		return
+	}
+
+	go func() {
+		out := opsMgr.Output(instanceName, opID, api.broadcaster)
+		defer out.Close()
+		defer func() {
+			if r := recover(); r != nil {
+				opsMgr.Update(instanceName, opID, "failed", fmt.Sprintf("Internal error: %v", r), 0)
+			}
+		}()
+
+		opsMgr.UpdateStatus(instanceName, opID, "running")
+		if err := nodeMgr.Upgrade(instanceName, opts, out); err != nil {
+			opsMgr.Update(instanceName, opID, "failed", err.Error(), 0)
+		} else {
+			opsMgr.Update(instanceName, opID, "completed", fmt.Sprintf("Nodes upgraded to Talos %s", plan.Version), 100)
+		}
+	}()
+
+	respondJSON(w, http.StatusAccepted, map[string]interface{}{
+		"operation_id": opID,
+		"message":      fmt.Sprintf("Upgrade to Talos %s initiated", plan.Version),
+		"plan":         plan,
+	})
+}
+
+// NodeAction runs a lifecycle action (reboot, shutdown, reset, drain or
+// uncordon) on a node as an operation. Shutdown and reset need a
+// confirmation token (see requireConfirmation). Actions that would break
+// etcd quorum or leave no node accepting workloads are refused unless the
+// body sets force, which needs a confirmation token too. Disruptive actions
+// and removals run one at a time per instance.
+func (api *API) NodeAction(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	instanceName := vars["name"]
+	hostname := vars["node"]
+	action := vars["action"]
+
+	// Validate instance exists
+	if err := api.instance.ValidateInstance(instanceName); err != nil {
+		respondError(w, http.StatusNotFound, fmt.Sprintf("Instance not found: %v", err))
+		return
+	}
+
+	if !node.IsAction(action) {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("Unknown action %q", action))
+		return
+	}
+
+	// The body is optional
+	var opts node.ActionOptions
+	if err := json.NewDecoder(r.Body).Decode(&opts); err != nil && err != io.EOF {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	nodeMgr := node.NewManager(api.dataDir)
+	if _, err := nodeMgr.Get(instanceName, hostname); err != nil {
+		respondError(w, http.StatusNotFound, fmt.Sprintf("Node not found: %v", err))
+		return
+	}
+
+	// Check and start under the node operations lock, so concurrent requests
+	// on different nodes cannot all pass the quorum and capacity checks
+	lock, err := storage.AcquireLock(nodeMgr.GetOperationsLockPath(instanceName))
+	if err != nil {
+		if !respondLocked(w, err) {
+			respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to lock node operations: %v", err))
+		}
+		return
+	}
+	defer lock.Release()
+
+	// Nothing else may be restarting nodes or acting on this one
+	opsMgr := operations.NewManager(api.dataDir)
+	if op, err := activeNodeOperation(opsMgr, instanceName, hostname, node.IsDisruptiveAction(action)); err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to check operations: %v", err))
+		return
+	} else if op != nil {
+		respondError(w, http.StatusConflict, fmt.Sprintf("Operation %s is in progress", op.ID))
+		return
+	}
+
+	if _, err := nodeMgr.CheckAction(instanceName, hostname, action, opts); err != nil {
+		respondError(w, http.StatusConflict, fmt.Sprintf("Cannot %s %s: %v", action, hostname, err))
+		return
+	}
+
+	var warnings []string
+	switch action {
+	case node.ActionShutdown:
+		warnings = append(warnings, fmt.Sprintf("Shutting down %s takes it offline until it is powered on again", hostname))
+	case node.ActionReset:
+		warnings = append(warnings, fmt.Sprintf("Resetting %s wipes its configuration and data", hostname))
+	}
+	confirmAction := action
+	if opts.Force {
+		warnings = append(warnings, fmt.Sprintf("Forcing skips the etcd quorum and workload capacity checks for %s", hostname))
+		confirmAction += "+force"
+	}
+	if len(warnings) > 0 && !api.requireConfirmation(w, r,
+		fmt.Sprintf("%s:%s/%s", confirmAction, instanceName, hostname), strings.Join(warnings, ". ")) {
+		return
+	}
+
+	opID, err := opsMgr.Start(instanceName, "node_"+action, hostname)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to start operation: %v", err))
+		return
+	}
+
+	go func() {
+		out := opsMgr.Output(instanceName, opID, api.broadcaster)
+		defer out.Close()
+		defer func() {
+			if r := recover(); r != nil {
+				opsMgr.Update(instanceName, opID, "failed", fmt.Sprintf("Internal error: %v", r), 0)
+			}
+		}()
+
+		opsMgr.UpdateStatus(instanceName, opID, "running")
+		if err := nodeMgr.RunAction(instanceName, hostname, action, out); err != nil {
+			opsMgr.Update(instanceName, opID, "failed", err.Error(), 0)
+		} else {
+			opsMgr.Update(instanceName, opID, "completed", fmt.Sprintf("Node %s: %s completed", hostname, action), 100)
+		}
+	}()
+
+	respondJSON(w, http.StatusAccepted, map[string]string{
+		"operation_id": opID,
+		"message":      fmt.Sprintf("Node %s: %s initiated", hostname, action),
 	})
 }
 
@@ -292,7 +611,10 @@ func (api *API) NodeFetchTemplates(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// NodeDelete removes a node
+// NodeDelete removes a node from the cluster and the instance as an
+// operation (see node.Manager.Remove). ?reset=true also wipes the machine,
+// which needs a confirmation token; ?force=true keeps going when the node
+// cannot be drained or reset.
 func (api *API) NodeDelete(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	instanceName := vars["name"]
@@ -304,14 +626,105 @@ func (api *API) NodeDelete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Delete node
+	opts := node.RemoveOptions{
+		Reset: r.URL.Query().Get("reset") == "true",
+		Force: r.URL.Query().Get("force") == "true",
+	}
+
 	nodeMgr := node.NewManager(api.dataDir)
-	if err := nodeMgr.Delete(instanceName, nodeIdentifier); err != nil {
-		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to delete node: %v", err))
+	if _, err := nodeMgr.Get(instanceName, nodeIdentifier); err != nil {
+		respondError(w, http.StatusNotFound, fmt.Sprintf("Node not found: %v", err))
 		return
 	}
 
-	respondJSON(w, http.StatusOK, map[string]string{
-		"message": "Node deleted successfully",
+	// Check and start under the node operations lock, so concurrent removals
+	// cannot all pass the control plane and quorum checks
+	lock, err := storage.AcquireLock(nodeMgr.GetOperationsLockPath(instanceName))
+	if err != nil {
+		if !respondLocked(w, err) {
+			respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to lock node operations: %v", err))
+		}
+		return
+	}
+	defer lock.Release()
+
+	// Nothing else may be restarting or removing nodes
+	opsMgr := operations.NewManager(api.dataDir)
+	if op, err := activeNodeOperation(opsMgr, instanceName, nodeIdentifier, true); err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to check operations: %v", err))
+		return
+	} else if op != nil {
+		respondError(w, http.StatusConflict, fmt.Sprintf("Operation %s is in progress", op.ID))
+		return
+	}
+
+	if _, err := nodeMgr.CheckRemove(instanceName, nodeIdentifier); err != nil {
+		respondError(w, http.StatusConflict, fmt.Sprintf("Cannot remove %s: %v", nodeIdentifier, err))
+		return
+	}
+
+	if opts.Reset && !api.requireConfirmation(w, r, fmt.Sprintf("remove:%s/%s", instanceName, nodeIdentifier),
+		fmt.Sprintf("Removing %s with reset wipes its configuration and data", nodeIdentifier)) {
+		return
+	}
+
+	opID, err := opsMgr.Start(instanceName, "node_remove", nodeIdentifier)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to start operation: %v", err))
+		return
+	}
+
+	go func() {
+		out := opsMgr.Output(instanceName, opID, api.broadcaster)
+		defer out.Close()
+		defer func() {
+			if r := recover(); r != nil {
+				opsMgr.Update(instanceName, opID, "failed", fmt.Sprintf("Internal error: %v", r), 0)
+			}
+		}()
+
+		opsMgr.UpdateStatus(instanceName, opID, "running")
+		if err := nodeMgr.Remove(instanceName, nodeIdentifier, opts, out); err != nil {
+			opsMgr.Update(instanceName, opID, "failed", err.Error(), 0)
+		} else {
+			opsMgr.Update(instanceName, opID, "completed", fmt.Sprintf("Node %s removed", nodeIdentifier), 100)
+		}
+	}()
+
+	respondJSON(w, http.StatusAccepted, map[string]string{
+		"operation_id": opID,
+		"message":      fmt.Sprintf("Removal of node %s initiated", nodeIdentifier),
 	})
 }
+
+// activeNodeOperation returns a pending or running operation that a new one
+// on the node must wait for: another one on the node, or an upgrade. A
+// disruptive operation also waits for every other disruptive one on the
+// instance, as its safety checks count the nodes that are up. An empty
+// hostname names no node, for operations on the whole cluster.
+func activeNodeOperation(opsMgr *operations.Manager, instanceName, hostname string, disruptive bool) (*operations.Operation, error) {
+	for _, status := range []string{"pending", "running"} {
+		active, err := opsMgr.Find(instanceName, operations.Filter{Status: status})
+		if err != nil {
+			return nil, err
+		}
+		for _, op := range active {
+			if (strings.HasPrefix(op.Type, "node_") && op.Target == hostname) ||
+				(disruptive && disruptiveOperation(op.Type)) ||
+				op.Type == "upgrade_talos" || op.Type == "upgrade_kubernetes" {
+				return &op, nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+// disruptiveOperation reports whether an operation type takes a node out of
+// the cluster while it runs
+func disruptiveOperation(opType string) bool {
+	if opType == "node_remove" {
+		return true
+	}
+	action, ok := strings.CutPrefix(opType, "node_")
+	return ok && node.IsDisruptiveAction(action)
+}
@@ -2,7 +2,6 @@ package v1
 
 import (
 	"bufio"
-	"encoding/json"
 	"fmt"
 	"net/http"
 	"os"
@@ -47,9 +46,13 @@ func (api *API) OperationList(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// List operations
+	// List operations, optionally only those of a type or status
 	opsMgr := operations.NewManager(api.dataDir)
-	ops, err := opsMgr.List(instanceName)
+	ops, err := opsMgr.Find(instanceName, operations.Filter{
+		Type:   r.URL.Query().Get("type"),
+		Status: r.URL.Query().Get("status"),
+		Target: r.URL.Query().Get("target"),
+	})
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to list operations: %v", err))
 		return
@@ -110,15 +113,9 @@ func (api *API) OperationStream(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Check if operation is already completed
-	statusFile := filepath.Join(api.dataDir, "instances", instanceName, "operations", opID+".json")
 	isCompleted := false
-	if data, err := os.ReadFile(statusFile); err == nil {
-		var op map[string]interface{}
-		if err := json.Unmarshal(data, &op); err == nil {
-			if status, ok := op["status"].(string); ok {
-				isCompleted = (status == "completed" || status == "failed")
-			}
-		}
+	if op, err := operations.NewManager(api.dataDir).GetByInstance(instanceName, opID); err == nil {
+		isCompleted = (op.Status == "completed" || op.Status == "failed")
 	}
 
 	// Send existing log file content first (if exists)
@@ -0,0 +1,490 @@
+package v1
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"github.com/wild-cloud/wild-central/daemon/internal/migrations"
+	"github.com/wild-cloud/wild-central/daemon/internal/operations"
+	"github.com/wild-cloud/wild-central/daemon/internal/rotation"
+	"github.com/wild-cloud/wild-central/daemon/internal/secrets"
+	"github.com/wild-cloud/wild-central/daemon/internal/secretsync"
+	"github.com/wild-cloud/wild-central/daemon/internal/storage"
+)
+
+// SecretsEncryptionStatus reports whether secrets encryption is enabled and
+// which instances are stored encrypted
+func (api *API) SecretsEncryptionStatus(w http.ResponseWriter, r *http.Request) {
+	instances, err := api.instance.ListInstances()
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to list instances: %v", err))
+		return
+	}
+
+	status := make(map[string]interface{})
+	for _, name := range instances {
+		files := secrets.SecretFiles(api.instance.GetInstancePath(name))
+		encrypted := len(files) > 0
+		for _, path := range files {
+			if !api.secrets.IsFileEncrypted(path) {
+				encrypted = false
+				break
+			}
+		}
+		status[name] = map[string]bool{"encrypted": encrypted}
+	}
+
+	mode := "key_file"
+	if os.Getenv(secrets.EnvPassphrase) != "" {
+		mode = "passphrase"
+	}
+
+	response := map[string]interface{}{
+		"enabled":   false,
+		"instances": status,
+	}
+	if key := secrets.ActiveKey(); key != nil {
+		response["enabled"] = true
+		response["key_id"] = key.ID
+		response["mode"] = mode
+	}
+
+	respondJSON(w, http.StatusOK, response)
+}
+
+// SecretsEncrypt migrates an instance's plaintext secrets to encrypted storage.
+// A key file is created if encryption is not configured yet.
+func (api *API) SecretsEncrypt(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	name := vars["name"]
+
+	if err := api.instance.ValidateInstance(name); err != nil {
+		respondError(w, http.StatusNotFound, fmt.Sprintf("Instance not found: %v", err))
+		return
+	}
+
+	store, err := api.secrets.Open(api.instance.GetInstancePath(name))
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to open secrets: %v", err))
+		return
+	}
+	if store.Type() == secrets.BackendVault {
+		respondError(w, http.StatusBadRequest, "Instance secrets are stored in Vault, not in a local file")
+		return
+	}
+
+	key, err := secrets.InitKey(api.dataDir)
+	if err != nil {
+		respondError(w, http.StatusConflict, fmt.Sprintf("Secrets encryption unavailable: %v", err))
+		return
+	}
+
+	encrypted, err := api.secrets.EncryptInstance(api.instance.GetInstancePath(name))
+	if err != nil {
+		if respondLocked(w, err) {
+			return
+		}
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to encrypt secrets: %v", err))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"key_id":    key.ID,
+		"encrypted": encrypted,
+		"message":   fmt.Sprintf("Encrypted %d file(s)", len(encrypted)),
+	})
+}
+
+// SecretsRotateKey replaces the master key and re-wraps all encrypted secrets
+// of instances in use and in the trash
+func (api *API) SecretsRotateKey(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Passphrase string `json:"passphrase,omitempty"` // Required when unlocked with a passphrase
+	}
+
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+	}
+
+	if secrets.ActiveKey() == nil {
+		respondError(w, http.StatusConflict, "Secrets encryption is not configured")
+		return
+	}
+
+	// Trashed instances too, so a restored one can still be decrypted
+	instancePaths, err := migrations.InstanceDirs(api.dataDir)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to list instances: %v", err))
+		return
+	}
+
+	key, err := api.secrets.RotateKey(api.dataDir, instancePaths, req.Passphrase)
+	if err != nil {
+		if respondLocked(w, err) {
+			return
+		}
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to rotate key: %v", err))
+		return
+	}
+
+	message := "Secrets key rotated successfully"
+	if os.Getenv(secrets.EnvPassphrase) != "" {
+		message += fmt.Sprintf("; restart the daemon with the new passphrase in %s", secrets.EnvPassphrase)
+	}
+
+	respondJSON(w, http.StatusOK, map[string]string{
+		"key_id":  key.ID,
+		"message": message,
+	})
+}
+
+// SecretsRotate generates a new value for a secret and propagates it to the
+// services and apps that reference it as a tracked operation
+func (api *API) SecretsRotate(w http.ResponseWriter, r *http.Request) {
+	api.startRotation(w, r, "rotate_secret", false)
+}
+
+// SecretsRollback restores the previous value of a secret and propagates it
+func (api *API) SecretsRollback(w http.ResponseWriter, r *http.Request) {
+	api.startRotation(w, r, "rollback_secret", true)
+}
+
+// startRotation validates a rotate or rollback request and runs it in the background
+func (api *API) startRotation(w http.ResponseWriter, r *http.Request, opType string, rollback bool) {
+	vars := mux.Vars(r)
+	instanceName := vars["name"]
+
+	if err := api.instance.ValidateInstance(instanceName); err != nil {
+		respondError(w, http.StatusNotFound, fmt.Sprintf("Instance not found: %v", err))
+		return
+	}
+
+	req := struct {
+		Key    string `json:"key"`
+		Length int    `json:"length,omitempty"`
+		Deploy *bool  `json:"deploy,omitempty"` // Redeploy services, defaults to true
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.Key == "" {
+		respondError(w, http.StatusBadRequest, "key is required")
+		return
+	}
+
+	opts := rotation.Options{Length: req.Length, Deploy: true}
+	if req.Deploy != nil {
+		opts.Deploy = *req.Deploy
+	}
+
+	// A running rotation holds the rotation lock until it ends, so this
+	// waits for it and then answers 409 Conflict naming the operation
+	rotationMgr := rotation.NewManager(api.dataDir, api.directoryPath)
+	lock, err := storage.AcquireLock(rotationMgr.LockPath(instanceName))
+	if err != nil {
+		if !respondLocked(w, err) {
+			respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to lock rotations: %v", err))
+		}
+		return
+	}
+	defer lock.Release()
+
+	instancePath := api.instance.GetInstancePath(instanceName)
+	if rollback {
+		history, err := api.secrets.History(instancePath, req.Key)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to read secret history: %v", err))
+			return
+		}
+		if len(history) == 0 {
+			respondError(w, http.StatusConflict, fmt.Sprintf("No previous value recorded for %s", req.Key))
+			return
+		}
+	} else {
+		store, err := api.secrets.Open(instancePath)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to open secrets: %v", err))
+			return
+		}
+		if value, err := store.Get(req.Key); err != nil || value == "" || value == "null" {
+			respondError(w, http.StatusNotFound, fmt.Sprintf("Secret %s is not set", req.Key))
+			return
+		}
+	}
+
+	opsMgr := operations.NewManager(api.dataDir)
+	opID, err := opsMgr.Start(instanceName, opType, req.Key)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to start operation: %v", err))
+		return
+	}
+
+	go func() {
+		out := opsMgr.Output(instanceName, opID, api.broadcaster)
+		defer out.Close()
+		defer func() {
+			if r := recover(); r != nil {
+				opsMgr.Update(instanceName, opID, "failed", fmt.Sprintf("Internal error: %v", r), 0)
+			}
+		}()
+
+		opsMgr.UpdateStatus(instanceName, opID, "running")
+
+		// Takes the rotation lock again, recorded as held by this operation
+		var err error
+		if rollback {
+			_, err = rotationMgr.Rollback(instanceName, req.Key, opts, out, api.broadcaster)
+		} else {
+			_, err = rotationMgr.Rotate(instanceName, req.Key, opts, out, api.broadcaster)
+		}
+
+		if err != nil {
+			opsMgr.Update(instanceName, opID, "failed", err.Error(), 0)
+		} else if rollback {
+			opsMgr.Update(instanceName, opID, "completed", fmt.Sprintf("Secret %s rolled back", req.Key), 100)
+		} else {
+			opsMgr.Update(instanceName, opID, "completed", fmt.Sprintf("Secret %s rotated", req.Key), 100)
+		}
+	}()
+
+	respondJSON(w, http.StatusAccepted, map[string]string{
+		"operation_id": opID,
+		"message":      fmt.Sprintf("Secret %s %s initiated", req.Key, strings.TrimSuffix(opType, "_secret")),
+	})
+}
+
+// SecretsReferences lists the services and apps that use a secret
+func (api *API) SecretsReferences(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	instanceName := vars["name"]
+
+	if err := api.instance.ValidateInstance(instanceName); err != nil {
+		respondError(w, http.StatusNotFound, fmt.Sprintf("Instance not found: %v", err))
+		return
+	}
+
+	key := r.URL.Query().Get("key")
+	if key == "" {
+		respondError(w, http.StatusBadRequest, "key query parameter is required")
+		return
+	}
+
+	refs, err := rotation.NewManager(api.dataDir, api.directoryPath).FindReferences(instanceName, key)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to find references: %v", err))
+		return
+	}
+	if refs == nil {
+		refs = []rotation.Reference{}
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"key":        key,
+		"references": refs,
+	})
+}
+
+// SecretsHistory lists when a secret's previous values were replaced. Values are never returned.
+func (api *API) SecretsHistory(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	instanceName := vars["name"]
+
+	if err := api.instance.ValidateInstance(instanceName); err != nil {
+		respondError(w, http.StatusNotFound, fmt.Sprintf("Instance not found: %v", err))
+		return
+	}
+
+	key := r.URL.Query().Get("key")
+	if key == "" {
+		respondError(w, http.StatusBadRequest, "key query parameter is required")
+		return
+	}
+
+	history, err := api.secrets.History(api.instance.GetInstancePath(instanceName), key)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to read secret history: %v", err))
+		return
+	}
+	if history == nil {
+		history = []secrets.HistoryEntry{}
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"key":     key,
+		"history": history,
+	})
+}
+
+// checkReveal refuses requests for raw secret values unless the daemon was
+// started with revealing allowed. Each reveal also needs a confirmation
+// token, bound to the calling client and to what is revealed, so a token is
+// good for one reveal by the client it was issued to.
+func (api *API) checkReveal(w http.ResponseWriter, r *http.Request, target, warning string) bool {
+	if allowed, _ := secrets.RevealAllowed(); !allowed {
+		respondError(w, http.StatusForbidden, fmt.Sprintf("Revealing secrets is disabled; start the daemon with %s=allow to enable it", secrets.EnvReveal))
+		return false
+	}
+
+	key, err := clientKey(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return false
+	}
+	return api.requireConfirmation(w, r, fmt.Sprintf("reveal:%s:%s", key, target), warning)
+}
+
+// SecretsGet returns the raw value of one secret by dot-notation path
+func (api *API) SecretsGet(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	name := vars["name"]
+	path := vars["path"]
+
+	if err := api.instance.ValidateInstance(name); err != nil {
+		respondError(w, http.StatusNotFound, fmt.Sprintf("Instance not found: %v", err))
+		return
+	}
+
+	if !api.checkReveal(w, r, name+"/"+path, fmt.Sprintf("This returns the value of %s in plaintext", path)) {
+		return
+	}
+
+	store, err := api.secrets.Open(api.instance.GetInstancePath(name))
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to open secrets: %v", err))
+		return
+	}
+
+	value, err := store.Get(path)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to get secret: %v", err))
+		return
+	}
+	if value == "" || value == "null" {
+		respondError(w, http.StatusNotFound, fmt.Sprintf("Secret %s is not set", path))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]string{
+		"key":   path,
+		"value": value,
+	})
+}
+
+// SecretsDelete removes one secret by dot-notation path
+func (api *API) SecretsDelete(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	name := vars["name"]
+	path := vars["path"]
+
+	if err := api.instance.ValidateInstance(name); err != nil {
+		respondError(w, http.StatusNotFound, fmt.Sprintf("Instance not found: %v", err))
+		return
+	}
+
+	secretsPath := api.instance.GetInstanceSecretsPath(name)
+	if value, err := api.secrets.GetSecret(secretsPath, path); err != nil || value == "null" {
+		respondError(w, http.StatusNotFound, fmt.Sprintf("Secret %s not found", path))
+		return
+	}
+
+	if err := api.secrets.DeleteSecret(secretsPath, path); err != nil {
+		if respondLocked(w, err) {
+			return
+		}
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to delete secret: %v", err))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]string{
+		"message": fmt.Sprintf("Secret %s deleted", path),
+	})
+}
+
+// SecretsSyncStatus reports which declared Kubernetes Secrets are missing or
+// have drifted from the instance secrets, without changing anything
+func (api *API) SecretsSyncStatus(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	instanceName := vars["name"]
+
+	if err := api.instance.ValidateInstance(instanceName); err != nil {
+		respondError(w, http.StatusNotFound, fmt.Sprintf("Instance not found: %v", err))
+		return
+	}
+
+	results, err := secretsync.NewManager(api.dataDir, api.directoryPath).Sync(instanceName, secrets.SyncOptions{DryRun: true})
+	if results == nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to check secrets: %v", err))
+		return
+	}
+
+	inSync := err == nil
+	for _, result := range results {
+		if result.Status != secrets.SyncInSync {
+			inSync = false
+		}
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"in_sync": inSync,
+		"secrets": results,
+	})
+}
+
+// SecretsSync creates or updates the declared Kubernetes Secrets of an instance
+func (api *API) SecretsSync(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	instanceName := vars["name"]
+
+	if err := api.instance.ValidateInstance(instanceName); err != nil {
+		respondError(w, http.StatusNotFound, fmt.Sprintf("Instance not found: %v", err))
+		return
+	}
+
+	opsMgr := operations.NewManager(api.dataDir)
+	opID, err := opsMgr.Start(instanceName, "sync_secrets", instanceName)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to start operation: %v", err))
+		return
+	}
+
+	go func() {
+		out := opsMgr.Output(instanceName, opID, api.broadcaster)
+		defer out.Close()
+		defer func() {
+			if r := recover(); r != nil {
+				opsMgr.Update(instanceName, opID, "failed", fmt.Sprintf("Internal error: %v", r), 0)
+			}
+		}()
+
+		opsMgr.UpdateStatus(instanceName, opID, "running")
+
+		results, err := secretsync.NewManager(api.dataDir, api.directoryPath).Sync(instanceName, secrets.SyncOptions{CreateNamespaces: true})
+		for _, result := range results {
+			line := fmt.Sprintf("%s %s/%s: %s", result.Source, result.Namespace, result.Name, result.Status)
+			if result.Error != "" {
+				line += " (" + result.Error + ")"
+			}
+			out.Printf("%s\n", line)
+		}
+
+		if err != nil {
+			opsMgr.Update(instanceName, opID, "failed", err.Error(), 0)
+		} else {
+			opsMgr.Update(instanceName, opID, "completed", fmt.Sprintf("Synced %d Kubernetes secret(s)", len(results)), 100)
+		}
+	}()
+
+	respondJSON(w, http.StatusAccepted, map[string]string{
+		"operation_id": opID,
+		"message":      "Secret sync initiated",
+	})
+}
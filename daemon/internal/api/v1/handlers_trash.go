@@ -0,0 +1,128 @@
+package v1
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"github.com/wild-cloud/wild-central/daemon/internal/instance"
+)
+
+// TrashList lists deleted instances that can still be restored
+func (api *API) TrashList(w http.ResponseWriter, r *http.Request) {
+	// Drop expired entries first so they are not offered for restore
+	if _, err := api.instance.PurgeExpiredTrash(); err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to purge expired trash: %v", err))
+		return
+	}
+
+	entries, err := api.instance.ListTrash()
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to list trash: %v", err))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"trash": entries,
+	})
+}
+
+// TrashRestore restores a deleted instance by trash ID or instance name.
+// The body may set "name" to restore under a different name.
+func (api *API) TrashRestore(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	var req struct {
+		Name string `json:"name"`
+	}
+	if r.ContentLength > 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+	}
+
+	if _, err := api.instance.FindTrash(id); err != nil {
+		respondError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if req.Name != "" {
+		if err := instance.ValidateInstanceName(req.Name); err != nil {
+			respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	name, err := api.instance.RestoreInstance(id, req.Name)
+	if err != nil {
+		if strings.Contains(err.Error(), "already exists") {
+			respondError(w, http.StatusConflict, err.Error())
+			return
+		}
+		if respondLocked(w, err) {
+			return
+		}
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to restore instance: %v", err))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]string{
+		"name":    name,
+		"message": "Instance restored",
+	})
+}
+
+// TrashPurge permanently removes a deleted instance from the trash. It needs
+// a confirmation token.
+func (api *API) TrashPurge(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	entry, err := api.instance.FindTrash(id)
+	if err != nil {
+		respondError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	if !api.requireConfirmation(w, r, "purge:"+entry.ID,
+		fmt.Sprintf("Purging %s destroys its Talos secrets; an existing cluster can no longer be managed", entry.Name)) {
+		return
+	}
+
+	if err := api.instance.PurgeTrash(entry.ID); err != nil {
+		if respondLocked(w, err) {
+			return
+		}
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to purge instance: %v", err))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]string{
+		"id":      entry.ID,
+		"message": "Instance purged",
+	})
+}
+
+// requireConfirmation checks the ?confirm= token for a destructive action.
+// Without a valid token it responds 428 Precondition Required with a fresh
+// token, which the client repeats the request with, and returns false.
+func (api *API) requireConfirmation(w http.ResponseWriter, r *http.Request, action, warning string) bool {
+	if api.instance.ConsumeConfirmation(action, r.URL.Query().Get("confirm")) {
+		return true
+	}
+
+	token, expires, err := api.instance.IssueConfirmation(action)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return false
+	}
+
+	respondJSON(w, http.StatusPreconditionRequired, map[string]interface{}{
+		"error":         "Confirmation required: " + warning + ". Repeat the request with ?confirm=<confirm_token>",
+		"confirm_token": token,
+		"expires_at":    expires,
+	})
+	return false
+}
@@ -50,6 +50,27 @@ func (api *API) InstanceUtilitiesHealth(w http.ResponseWriter, r *http.Request)
 	})
 }
 
+// InstanceUtilitiesReachable reports whether an instance's cluster API answers
+func (api *API) InstanceUtilitiesReachable(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	instanceName := vars["name"]
+
+	// Validate instance exists
+	if err := api.instance.ValidateInstance(instanceName); err != nil {
+		respondError(w, http.StatusNotFound, fmt.Sprintf("Instance not found: %v", err))
+		return
+	}
+
+	kubeconfigPath := filepath.Join(api.dataDir, "instances", instanceName, "kubeconfig")
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data": map[string]bool{
+			"reachable": utilities.ClusterReachable(kubeconfigPath),
+		},
+	})
+}
+
 // UtilitiesDashboardToken returns a Kubernetes dashboard token
 func (api *API) UtilitiesDashboardToken(w http.ResponseWriter, r *http.Request) {
 	token, err := utilities.GetDashboardToken()
@@ -101,7 +122,12 @@ func (api *API) UtilitiesControlPlaneIP(w http.ResponseWriter, r *http.Request)
 }
 
 // UtilitiesSecretCopy copies a secret between namespaces
+//
+// Deprecated: declare kubernetesSecrets in a service or app manifest and
+// sync them with POST /api/v1/instances/{name}/secrets/sync instead.
 func (api *API) UtilitiesSecretCopy(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Deprecation", "true")
+
 	vars := mux.Vars(r)
 	secretName := vars["secret"]
 
@@ -5,9 +5,11 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
 
 	"gopkg.in/yaml.v3"
 
+	"github.com/wild-cloud/wild-central/daemon/internal/secrets"
 	"github.com/wild-cloud/wild-central/daemon/internal/storage"
 	"github.com/wild-cloud/wild-central/daemon/internal/tools"
 )
@@ -34,6 +36,18 @@ type App struct {
 	Category     string            `json:"category" yaml:"category"`
 	Dependencies []string          `json:"dependencies" yaml:"dependencies"`
 	Config       map[string]string `json:"config,omitempty" yaml:"config,omitempty"`
+
+	Requires        []AppRequirement       `json:"requires,omitempty" yaml:"requires,omitempty"`
+	DefaultConfig   map[string]interface{} `json:"defaultConfig,omitempty" yaml:"defaultConfig,omitempty"`
+	RequiredSecrets []string               `json:"requiredSecrets,omitempty" yaml:"requiredSecrets,omitempty"`
+	RotationHooks   []secrets.RotationHook `json:"rotationHooks,omitempty" yaml:"rotationHooks,omitempty"`
+
+	KubernetesSecrets []secrets.SecretMapping `json:"kubernetesSecrets,omitempty" yaml:"kubernetesSecrets,omitempty"`
+}
+
+// AppRequirement names another app an app depends on
+type AppRequirement struct {
+	Name string `json:"name" yaml:"name"`
 }
 
 // DeployedApp represents a deployed application instance
@@ -129,6 +143,11 @@ func (m *Manager) Add(instanceName, appName string, config map[string]string) er
 		return err
 	}
 
+	// Generate the app's secrets and resolve those shared with dependencies
+	if err := m.ProvisionSecrets(instanceName, app); err != nil {
+		return err
+	}
+
 	// Add app to config.yaml
 	// Path: apps.deployed.{appName}
 	configFile := filepath.Join(m.dataDir, "instances", instanceName, "config.yaml")
@@ -138,8 +157,6 @@ func (m *Manager) Add(instanceName, appName string, config map[string]string) er
 
 	// Use yq to set configuration
 	// This is simplified - real implementation would use yq wrapper
-	_ = app // Use app for validation
-
 	// TODO: Set each config value via yq
 	_ = configFile
 	_ = basePath
@@ -150,6 +167,15 @@ func (m *Manager) Add(instanceName, appName string, config map[string]string) er
 
 // Deploy deploys an app to the cluster
 func (m *Manager) Deploy(instanceName, appName string) error {
+	// Refuse to deploy with missing or invalid secrets
+	problems, err := m.CheckSecrets(instanceName, appName)
+	if err != nil {
+		return err
+	}
+	if len(problems) > 0 {
+		return &SecretsError{App: appName, Problems: problems}
+	}
+
 	kubeconfigPath := tools.GetKubeconfigPath(m.dataDir, instanceName)
 
 	// Get app manifests
@@ -205,3 +231,97 @@ func (m *Manager) GetStatus(instanceName, appName string) (*DeployedApp, error)
 
 	return app, nil
 }
+
+// GetAppDir returns the directory holding an app's manifest and templates
+func (m *Manager) GetAppDir(appName string) string {
+	return filepath.Join(m.appsDir, appName)
+}
+
+// SecretMappings returns the Kubernetes Secrets the app's secrets are synced
+// into: the declared kubernetesSecrets (namespace defaults to the app name),
+// plus <app>-secrets holding every required secret keyed by its full path,
+// as the app templates expect.
+func (a *App) SecretMappings() []secrets.SecretMapping {
+	var mappings []secrets.SecretMapping
+	declaresDefault := false
+	for _, mapping := range a.KubernetesSecrets {
+		if mapping.Namespace == "" {
+			mapping.Namespace = a.Name
+		}
+		if mapping.Name == a.Name+"-secrets" && mapping.Namespace == a.Name {
+			declaresDefault = true
+		}
+		mappings = append(mappings, mapping)
+	}
+
+	if !declaresDefault && len(a.RequiredSecrets) > 0 {
+		data := make(map[string]string, len(a.RequiredSecrets))
+		for _, key := range a.RequiredSecrets {
+			data[key] = key
+		}
+		mappings = append(mappings, secrets.SecretMapping{
+			Name:      a.Name + "-secrets",
+			Namespace: a.Name,
+			Data:      data,
+		})
+	}
+
+	return mappings
+}
+
+// ApplySecrets syncs the app's Kubernetes Secrets with the instance secrets
+func (m *Manager) ApplySecrets(instanceName, appName string) ([]secrets.SyncResult, error) {
+	app, err := m.Get(appName)
+	if err != nil {
+		return nil, err
+	}
+
+	store, err := secrets.NewManager().Open(m.instancePath(instanceName))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open secrets: %w", err)
+	}
+
+	results, err := secrets.SyncKubernetesSecrets(store, tools.GetKubeconfigPath(m.dataDir, instanceName),
+		app.SecretMappings(), secrets.SyncOptions{CreateNamespaces: true})
+	for i := range results {
+		results[i].Source = "app/" + appName
+	}
+	return results, err
+}
+
+// ListConfigured returns the apps with a section in the instance config
+func (m *Manager) ListConfigured(instanceName string) ([]string, error) {
+	data, err := os.ReadFile(filepath.Join(m.instancePath(instanceName), "config.yaml"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	var config struct {
+		Apps map[string]interface{} `yaml:"apps"`
+	}
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse config: %w", err)
+	}
+
+	var names []string
+	for name := range config.Apps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Restart restarts the app's workloads so they pick up changed secrets
+func (m *Manager) Restart(instanceName, appName string) error {
+	cmd := exec.Command("kubectl", "rollout", "restart", "deployment,statefulset", "-n", appName)
+	tools.WithKubeconfig(cmd, tools.GetKubeconfigPath(m.dataDir, instanceName))
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to restart app: %w\nOutput: %s", err, string(output))
+	}
+
+	return nil
+}
@@ -0,0 +1,292 @@
+package apps
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/wild-cloud/wild-central/daemon/internal/secrets"
+)
+
+// SecretProblem describes a required secret that is missing or invalid
+type SecretProblem struct {
+	Key     string `json:"key"`
+	Problem string `json:"problem"`
+}
+
+// SecretsError reports the required secrets of an app that cannot be used
+type SecretsError struct {
+	App      string
+	Problems []SecretProblem
+}
+
+func (e *SecretsError) Error() string {
+	var parts []string
+	for _, p := range e.Problems {
+		parts = append(parts, fmt.Sprintf("%s: %s", p.Key, p.Problem))
+	}
+	return fmt.Sprintf("app %s has unusable secrets: %s", e.App, strings.Join(parts, "; "))
+}
+
+// dbSchemes maps database apps to the URL scheme of their connection strings
+var dbSchemes = map[string]string{
+	"postgres": "postgresql",
+	"mysql":    "mysql",
+}
+
+// dbSSLModeParams maps URL schemes to the query parameter that carries the
+// app's dbSSLMode
+var dbSSLModeParams = map[string]string{
+	"postgresql": "sslmode",
+	"mysql":      "ssl-mode",
+}
+
+// ProvisionSecrets makes sure every secret in the app's requiredSecrets is set.
+// The app's own secrets are generated when missing; a dbUrl secret is built
+// from the app's database config and password. Secrets of other apps are
+// never generated here: they must belong to a required app and already exist,
+// so the app shares the dependency's value. Nothing is written if any
+// reference cannot be resolved.
+func (m *Manager) ProvisionSecrets(instanceName string, app *App) error {
+	store, err := secrets.NewManager().Open(m.instancePath(instanceName))
+	if err != nil {
+		return fmt.Errorf("failed to open secrets: %w", err)
+	}
+
+	// Validate everything before generating anything
+	var problems []SecretProblem
+	for _, key := range app.RequiredSecrets {
+		owner, problem := secretOwner(app, key)
+		if problem != "" {
+			problems = append(problems, SecretProblem{Key: key, Problem: problem})
+			continue
+		}
+		if owner != app.Name {
+			value, err := store.Get(key)
+			if err != nil {
+				return err
+			}
+			if !isSet(value) {
+				problems = append(problems, SecretProblem{Key: key, Problem: fmt.Sprintf("not set; add app %s first", owner)})
+			}
+		}
+	}
+	if len(problems) > 0 {
+		return &SecretsError{App: app.Name, Problems: problems}
+	}
+
+	// Generate own secrets, leaving derived URLs until passwords exist
+	var urls []string
+	for _, key := range app.RequiredSecrets {
+		if owner, _ := secretOwner(app, key); owner != app.Name {
+			continue
+		}
+		if strings.HasSuffix(key, ".dbUrl") {
+			urls = append(urls, key)
+			continue
+		}
+
+		value, err := store.Get(key)
+		if err != nil {
+			return err
+		}
+		if isSet(value) {
+			continue
+		}
+		generated, err := secrets.GenerateSecret(secrets.DefaultSecretLength)
+		if err != nil {
+			return err
+		}
+		if err := store.Set(key, generated); err != nil {
+			return fmt.Errorf("failed to set secret %s: %w", key, err)
+		}
+	}
+
+	for _, key := range urls {
+		value, err := store.Get(key)
+		if err != nil {
+			return err
+		}
+		if isSet(value) {
+			continue
+		}
+		dbURL, err := m.buildDBURL(instanceName, app, store)
+		if err != nil {
+			return &SecretsError{App: app.Name, Problems: []SecretProblem{{Key: key, Problem: err.Error()}}}
+		}
+		if err := store.Set(key, dbURL); err != nil {
+			return fmt.Errorf("failed to set secret %s: %w", key, err)
+		}
+	}
+
+	return nil
+}
+
+// CheckSecrets reports required secrets that are missing, reference an app
+// the app does not require, or (for dbUrl) no longer match the app's config
+func (m *Manager) CheckSecrets(instanceName, appName string) ([]SecretProblem, error) {
+	app, err := m.Get(appName)
+	if err != nil {
+		return nil, err
+	}
+
+	store, err := secrets.NewManager().Open(m.instancePath(instanceName))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open secrets: %w", err)
+	}
+
+	var problems []SecretProblem
+	for _, key := range app.RequiredSecrets {
+		if _, problem := secretOwner(app, key); problem != "" {
+			problems = append(problems, SecretProblem{Key: key, Problem: problem})
+			continue
+		}
+
+		value, err := store.Get(key)
+		if err != nil {
+			return nil, err
+		}
+		if !isSet(value) {
+			problems = append(problems, SecretProblem{Key: key, Problem: "not set"})
+			continue
+		}
+
+		if strings.HasSuffix(key, ".dbUrl") {
+			if expected, err := m.buildDBURL(instanceName, app, store); err == nil && expected != value {
+				problems = append(problems, SecretProblem{Key: key, Problem: "does not match the app's database config or password"})
+			}
+		}
+	}
+
+	return problems, nil
+}
+
+// secretOwner returns the app a required secret belongs to, or a problem if
+// the key is malformed or belongs to an app that is not required
+func secretOwner(app *App, key string) (string, string) {
+	parts := strings.Split(key, ".")
+	if len(parts) < 3 || parts[0] != "apps" {
+		return "", "must be under apps.<app-name>"
+	}
+	for _, part := range parts {
+		if part == "" {
+			return "", "invalid key"
+		}
+	}
+
+	owner := parts[1]
+	if owner == app.Name {
+		return owner, ""
+	}
+	for _, req := range app.Requires {
+		if req.Name == owner {
+			return owner, ""
+		}
+	}
+	return "", fmt.Sprintf("belongs to app %s, which %s does not require", owner, app.Name)
+}
+
+// buildDBURL builds a connection string from the app's database config and
+// its dbPassword secret. The scheme comes from the database app it requires;
+// a configured dbSSLMode is passed on as the scheme's SSL mode parameter.
+func (m *Manager) buildDBURL(instanceName string, app *App, store secrets.Store) (string, error) {
+	scheme := ""
+	for _, req := range app.Requires {
+		if s, ok := dbSchemes[req.Name]; ok {
+			scheme = s
+			break
+		}
+	}
+	if scheme == "" {
+		return "", fmt.Errorf("cannot build database URL: app requires no known database")
+	}
+
+	cfg, err := m.appConfig(instanceName, app)
+	if err != nil {
+		return "", err
+	}
+	user := firstString(cfg, "dbUser", "dbUsername")
+	host := firstString(cfg, "dbHost", "dbHostname")
+	name := firstString(cfg, "dbName", "dbDatabase")
+	if user == "" || host == "" || name == "" {
+		return "", fmt.Errorf("cannot build database URL: app config needs db user, host and name")
+	}
+	if port := firstString(cfg, "dbPort"); port != "" {
+		host = host + ":" + port
+	}
+
+	password, err := store.Get(fmt.Sprintf("apps.%s.dbPassword", app.Name))
+	if err != nil {
+		return "", err
+	}
+	if !isSet(password) {
+		return "", fmt.Errorf("cannot build database URL: apps.%s.dbPassword is not set", app.Name)
+	}
+
+	u := url.URL{
+		Scheme: scheme,
+		User:   url.UserPassword(user, password),
+		Host:   host,
+		Path:   "/" + name,
+	}
+	if mode := firstString(cfg, "dbSSLMode"); mode != "" {
+		u.RawQuery = url.Values{dbSSLModeParams[scheme]: {mode}}.Encode()
+	}
+	return u.String(), nil
+}
+
+// appConfig returns the app's section of the instance config, falling back
+// to the manifest defaults for keys the instance does not set
+func (m *Manager) appConfig(instanceName string, app *App) (map[string]interface{}, error) {
+	cfg := make(map[string]interface{})
+	for k, v := range app.DefaultConfig {
+		cfg[k] = v
+	}
+
+	data, err := os.ReadFile(filepath.Join(m.instancePath(instanceName), "config.yaml"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return cfg, nil
+		}
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	var instanceConfig struct {
+		Apps map[string]map[string]interface{} `yaml:"apps"`
+	}
+	if err := yaml.Unmarshal(data, &instanceConfig); err != nil {
+		return nil, fmt.Errorf("failed to parse config: %w", err)
+	}
+	for k, v := range instanceConfig.Apps[app.Name] {
+		cfg[k] = v
+	}
+
+	return cfg, nil
+}
+
+func (m *Manager) instancePath(instanceName string) string {
+	return filepath.Join(m.dataDir, "instances", instanceName)
+}
+
+// firstString returns the first of the given keys set to a scalar value
+func firstString(cfg map[string]interface{}, keys ...string) string {
+	for _, key := range keys {
+		if v, ok := cfg[key]; ok && v != nil {
+			if _, nested := v.(map[string]interface{}); nested {
+				continue
+			}
+			if s := fmt.Sprintf("%v", v); s != "" {
+				return s
+			}
+		}
+	}
+	return ""
+}
+
+func isSet(value string) bool {
+	return value != "" && value != "null"
+}
@@ -0,0 +1,146 @@
+package apps
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/wild-cloud/wild-central/daemon/internal/secrets"
+)
+
+// setupSecretsTest creates an instance with an encrypted secrets backend,
+// which needs no external tools, and an apps directory
+func setupSecretsTest(t *testing.T) (*Manager, secrets.Store) {
+	t.Helper()
+
+	key, err := secrets.GenerateKey()
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+	secrets.Unlock(key)
+	t.Cleanup(secrets.Lock)
+
+	dataDir := t.TempDir()
+	appsDir := t.TempDir()
+	instancePath := filepath.Join(dataDir, "instances", "test")
+	os.MkdirAll(instancePath, 0755)
+	os.WriteFile(filepath.Join(instancePath, "config.yaml"),
+		[]byte("secretsBackend:\n  type: encrypted-file\napps:\n  blog:\n    dbName: blogdb\n"), 0644)
+
+	secretsMgr := secrets.NewManager()
+	if err := secretsMgr.EnsureSecretsFile(instancePath); err != nil {
+		t.Fatalf("EnsureSecretsFile failed: %v", err)
+	}
+	store, err := secretsMgr.Open(instancePath)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	manifests := map[string]string{
+		"postgres": "name: postgres\nrequiredSecrets:\n  - apps.postgres.password\n",
+		"blog": `name: blog
+requires:
+  - name: postgres
+defaultConfig:
+  dbHost: postgres.postgres.svc.cluster.local
+  dbUser: blog
+  dbName: blog
+requiredSecrets:
+  - apps.blog.dbPassword
+  - apps.blog.dbUrl
+  - apps.postgres.password
+`,
+		"rogue": "name: rogue\nrequiredSecrets:\n  - apps.postgres.password\n  - cloudflare.token\n",
+	}
+	for name, manifest := range manifests {
+		os.MkdirAll(filepath.Join(appsDir, name), 0755)
+		os.WriteFile(filepath.Join(appsDir, name, "manifest.yaml"), []byte(manifest), 0644)
+	}
+
+	return NewManager(dataDir, appsDir), store
+}
+
+func TestProvisionSecrets(t *testing.T) {
+	m, store := setupSecretsTest(t)
+
+	// Dependency secrets are not generated on behalf of another app
+	err := m.Add("test", "blog", nil)
+	var secretsErr *SecretsError
+	if !errors.As(err, &secretsErr) || len(secretsErr.Problems) != 1 || secretsErr.Problems[0].Key != "apps.postgres.password" {
+		t.Fatalf("Expected missing postgres password, got %v", err)
+	}
+	if value, _ := store.Get("apps.blog.dbPassword"); value != "null" {
+		t.Error("Nothing should be generated when a reference is unresolved")
+	}
+
+	if err := m.Add("test", "postgres", nil); err != nil {
+		t.Fatalf("Add postgres failed: %v", err)
+	}
+	postgresPassword, _ := store.Get("apps.postgres.password")
+
+	if err := m.Add("test", "blog", nil); err != nil {
+		t.Fatalf("Add blog failed: %v", err)
+	}
+	if value, _ := store.Get("apps.postgres.password"); value != postgresPassword {
+		t.Error("Shared dependency secret was changed")
+	}
+
+	dbPassword, _ := store.Get("apps.blog.dbPassword")
+	dbURL, _ := store.Get("apps.blog.dbUrl")
+	expected := "postgresql://blog:" + dbPassword + "@postgres.postgres.svc.cluster.local/blogdb"
+	if dbURL != expected {
+		t.Errorf("Expected dbUrl %q, got %q", expected, dbURL)
+	}
+
+	// Re-adding keeps existing values
+	if err := m.Add("test", "blog", nil); err != nil {
+		t.Fatalf("Re-add failed: %v", err)
+	}
+	if value, _ := store.Get("apps.blog.dbPassword"); value != dbPassword {
+		t.Error("Existing secret was regenerated")
+	}
+
+	problems, err := m.CheckSecrets("test", "blog")
+	if err != nil || len(problems) != 0 {
+		t.Fatalf("Expected no problems, got %v, %v", problems, err)
+	}
+
+	// The configured SSL mode is part of the URL
+	os.WriteFile(filepath.Join(m.instancePath("test"), "config.yaml"),
+		[]byte("secretsBackend:\n  type: encrypted-file\napps:\n  blog:\n    dbName: blogdb\n    dbSSLMode: disable\n"), 0644)
+	problems, _ = m.CheckSecrets("test", "blog")
+	if len(problems) != 1 || problems[0].Key != "apps.blog.dbUrl" {
+		t.Errorf("Expected dbUrl without sslmode to be stale, got %v", problems)
+	}
+	blog, _ := m.Get("blog")
+	if dbURL, err := m.buildDBURL("test", blog, store); err != nil || dbURL != expected+"?sslmode=disable" {
+		t.Errorf("Expected dbUrl %q, got %q, %v", expected+"?sslmode=disable", dbURL, err)
+	}
+
+	// A rotated password leaves the URL stale
+	store.Set("apps.blog.dbPassword", "rotated")
+	problems, _ = m.CheckSecrets("test", "blog")
+	if len(problems) != 1 || problems[0].Key != "apps.blog.dbUrl" {
+		t.Errorf("Expected stale dbUrl, got %v", problems)
+	}
+}
+
+func TestCheckSecrets_InvalidReferences(t *testing.T) {
+	m, _ := setupSecretsTest(t)
+
+	problems, err := m.CheckSecrets("test", "rogue")
+	if err != nil {
+		t.Fatalf("CheckSecrets failed: %v", err)
+	}
+	if len(problems) != 2 {
+		t.Fatalf("Expected 2 problems, got %v", problems)
+	}
+	if !strings.Contains(problems[0].Problem, "does not require") {
+		t.Errorf("Expected undeclared dependency problem, got %q", problems[0].Problem)
+	}
+	if !strings.Contains(problems[1].Problem, "apps.<app-name>") {
+		t.Errorf("Expected malformed key problem, got %q", problems[1].Problem)
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/wild-cloud/wild-central/daemon/internal/state"
 	"github.com/wild-cloud/wild-central/daemon/internal/storage"
 	"github.com/wild-cloud/wild-central/daemon/internal/tools"
 )
@@ -104,8 +105,7 @@ func (m *Manager) BackupApp(instanceName, appName string) (*BackupInfo, error) {
 	}
 
 	// Save backup metadata
-	metaFile := filepath.Join(backupDir, "backup.json")
-	if err := m.saveBackupMeta(metaFile, info); err != nil {
+	if err := m.saveBackupMeta(instanceName, info); err != nil {
 		return nil, fmt.Errorf("failed to save backup metadata: %w", err)
 	}
 
@@ -151,12 +151,8 @@ func (m *Manager) ListBackups(instanceName, appName string) ([]*BackupInfo, erro
 	}
 
 	var backups []*BackupInfo
-	metaFile := filepath.Join(appBackupDir, "backup.json")
-	if storage.FileExists(metaFile) {
-		info, err := m.loadBackupMeta(metaFile)
-		if err == nil {
-			backups = append(backups, info)
-		}
+	if info, err := m.loadBackupMeta(instanceName, appName); err == nil && info != nil {
+		backups = append(backups, info)
 	}
 
 	return backups, nil
@@ -193,7 +189,7 @@ func (m *Manager) backupPostgres(kubeconfigPath, appName, backupDir, timestamp s
 	if err != nil {
 		return nil, fmt.Errorf("pg_dump failed: %w", err)
 	}
-	if err := os.WriteFile(dbDump, output, 0600); err != nil {
+	if err := storage.WriteFile(dbDump, output, 0600); err != nil {
 		return nil, fmt.Errorf("failed to write database dump: %w", err)
 	}
 
@@ -205,7 +201,7 @@ func (m *Manager) backupPostgres(kubeconfigPath, appName, backupDir, timestamp s
 	if err != nil {
 		return nil, fmt.Errorf("pg_dumpall failed: %w", err)
 	}
-	if err := os.WriteFile(globalsFile, output, 0600); err != nil {
+	if err := storage.WriteFile(globalsFile, output, 0600); err != nil {
 		return nil, fmt.Errorf("failed to write globals dump: %w", err)
 	}
 
@@ -236,7 +232,7 @@ func (m *Manager) backupMySQL(kubeconfigPath, appName, backupDir, timestamp stri
 	if err != nil {
 		return nil, fmt.Errorf("mysqldump failed: %w", err)
 	}
-	if err := os.WriteFile(dbDump, output, 0600); err != nil {
+	if err := storage.WriteFile(dbDump, output, 0600); err != nil {
 		return nil, fmt.Errorf("failed to write database dump: %w", err)
 	}
 
@@ -289,7 +285,7 @@ func (m *Manager) backupPVCs(kubeconfigPath, appName, backupDir string) ([]strin
 
 		// Extract tar to backup directory
 		tarFile := filepath.Join(pvcBackupDir, "data.tar")
-		if err := os.WriteFile(tarFile, tarData, 0600); err != nil {
+		if err := storage.WriteFile(tarFile, tarData, 0600); err != nil {
 			return nil, fmt.Errorf("failed to write PVC backup: %w", err)
 		}
 		files = append(files, tarFile)
@@ -471,23 +467,89 @@ func (m *Manager) detectDatabaseType(kubeconfigPath, appName string) (string, er
 	return "", nil
 }
 
-// saveBackupMeta saves backup metadata to JSON file
-func (m *Manager) saveBackupMeta(path string, info *BackupInfo) error {
-	data, err := json.MarshalIndent(info, "", "  ")
+// backupsBucket holds backup metadata in the instance state store, keyed by app
+const backupsBucket = "backups"
+
+// store opens the instance state store. The first time it is opened,
+// backup.json files left in the staging directory by earlier versions are
+// imported.
+func (m *Manager) store(instanceName string) (*state.DB, error) {
+	db, err := state.Open(state.InstancePath(m.dataDir, instanceName))
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Once("backups-json", func() error {
+		return m.importLegacy(db, instanceName)
+	}); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+// importLegacy imports backup.json files left by earlier versions
+func (m *Manager) importLegacy(db *state.DB, instanceName string) error {
+	legacy, _ := filepath.Glob(filepath.Join(m.GetStagingDir(instanceName), "apps", "*", "backup.json"))
+	if len(legacy) == 0 {
+		return nil
+	}
+
+	var imported []string
+	_, err := db.Migrate("backups-json", func(tx *state.Tx) error {
+		for _, path := range legacy {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			var info BackupInfo
+			if err := json.Unmarshal(data, &info); err != nil || info.AppName == "" {
+				continue
+			}
+			if err := putBackupMeta(tx, &info); err != nil {
+				return err
+			}
+			imported = append(imported, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("importing backup metadata: %w", err)
+	}
+
+	for _, path := range imported {
+		os.Remove(path)
+	}
+	return nil
+}
+
+func putBackupMeta(tx *state.Tx, info *BackupInfo) error {
+	return tx.Put(backupsBucket, info.AppName, info, state.Index{"status": info.Status})
+}
+
+// saveBackupMeta saves backup metadata
+func (m *Manager) saveBackupMeta(instanceName string, info *BackupInfo) error {
+	db, err := m.store(instanceName)
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(path, data, 0600)
+	return db.Update(func(tx *state.Tx) error {
+		return putBackupMeta(tx, info)
+	})
 }
 
-// loadBackupMeta loads backup metadata from JSON file
-func (m *Manager) loadBackupMeta(path string) (*BackupInfo, error) {
-	data, err := os.ReadFile(path)
+// loadBackupMeta loads an app's backup metadata, or nil if there is none
+func (m *Manager) loadBackupMeta(instanceName, appName string) (*BackupInfo, error) {
+	db, err := m.store(instanceName)
 	if err != nil {
 		return nil, err
 	}
+
 	var info BackupInfo
-	if err := json.Unmarshal(data, &info); err != nil {
+	found := false
+	err = db.View(func(tx *state.Tx) error {
+		found, err = tx.Get(backupsBucket, appName, &info)
+		return err
+	})
+	if err != nil || !found {
 		return nil, err
 	}
 	return &info, nil
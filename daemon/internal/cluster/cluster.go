@@ -10,6 +10,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/wild-cloud/wild-central/daemon/internal/secrets"
 	"github.com/wild-cloud/wild-central/daemon/internal/storage"
 	"github.com/wild-cloud/wild-central/daemon/internal/tools"
 )
@@ -30,9 +31,10 @@ func NewManager(dataDir string) *Manager {
 
 // ClusterConfig contains cluster configuration parameters
 type ClusterConfig struct {
-	ClusterName string `json:"cluster_name"`
-	VIP         string `json:"vip"` // Control plane virtual IP
-	Version     string `json:"version"`
+	ClusterName       string `json:"cluster_name"`
+	VIP               string `json:"vip"` // Control plane virtual IP
+	Version           string `json:"version"`
+	KubernetesVersion string `json:"kubernetes_version,omitempty"` // Talos default when empty
 }
 
 // ClusterStatus represents cluster health and status
@@ -82,17 +84,44 @@ func (m *Manager) GenerateConfig(instanceName string, config *ClusterConfig) err
 
 	// Generate config with secrets
 	endpoint := fmt.Sprintf("https://%s:6443", config.VIP)
-	cmd = exec.Command("talosctl", "gen", "config",
+	args := []string{"gen", "config",
 		"--with-secrets", "secrets.yaml",
 		config.ClusterName,
 		endpoint,
-	)
+	}
+	if config.KubernetesVersion != "" {
+		args = append(args, "--kubernetes-version", strings.TrimPrefix(config.KubernetesVersion, "v"))
+	}
+	cmd = exec.Command("talosctl", args...)
 	cmd.Dir = generatedDir
 	output, err = cmd.CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("failed to generate config: %w\nOutput: %s", err, string(output))
 	}
 
+	// talosctl writes the talosconfig next to the machine configs; move it to
+	// where every talosctl call reads it, so there is only one copy to update
+	talosconfigPath := tools.GetTalosconfigPath(m.dataDir, instanceName)
+	if err := storage.EnsureDir(filepath.Dir(talosconfigPath), 0755); err != nil {
+		return fmt.Errorf("failed to create talosconfig directory: %w", err)
+	}
+	if err := os.Rename(filepath.Join(generatedDir, "talosconfig"), talosconfigPath); err != nil {
+		return fmt.Errorf("failed to move talosconfig: %w", err)
+	}
+
+	// Machine configs and the talosconfig hold the cluster CA keys and admin
+	// credentials in plaintext
+	if err := secrets.RestrictCredentialFiles(filepath.Join(m.dataDir, "instances", instanceName)); err != nil {
+		return fmt.Errorf("failed to restrict generated config permissions: %w", err)
+	}
+
+	// Encrypt the Talos secrets bundle at rest when encryption is enabled
+	if secrets.ActiveKey() != nil {
+		if err := secrets.NewManager().EncryptFile(secretsFile); err != nil {
+			return fmt.Errorf("failed to encrypt talos secrets: %w", err)
+		}
+	}
+
 	return nil
 }
 
@@ -160,7 +189,7 @@ func (m *Manager) retrieveKubeconfigFromCluster(instanceName, nodeIP string, tim
 
 		if output, err := cmdKubeconfig.CombinedOutput(); err == nil {
 			log.Printf("Successfully retrieved kubeconfig for instance %s", instanceName)
-			return nil
+			return storage.EnsureFilePermissions(kubeconfigPath, 0600)
 		} else {
 			// Check if we've exceeded deadline
 			if !time.Now().Before(deadline) {
@@ -256,7 +285,7 @@ func (m *Manager) ConfigureEndpoints(instanceName string, includeNodes bool) err
 		return fmt.Errorf("failed to retrieve kubeconfig: %w\nOutput: %s", err, string(output))
 	}
 
-	return nil
+	return storage.EnsureFilePermissions(kubeconfigPath, 0600)
 }
 
 // GetStatus retrieves cluster status
@@ -406,7 +435,7 @@ func (m *Manager) GetKubeconfig(instanceName string) (string, error) {
 
 // GetTalosconfig returns the talosconfig for the cluster
 func (m *Manager) GetTalosconfig(instanceName string) (string, error) {
-	talosconfigPath := filepath.Join(m.GetGeneratedDir(instanceName), "talosconfig")
+	talosconfigPath := tools.GetTalosconfigPath(m.dataDir, instanceName)
 
 	if !storage.FileExists(talosconfigPath) {
 		return "", fmt.Errorf("talosconfig not found - cluster may not be initialized")
@@ -477,13 +506,16 @@ func (m *Manager) Reset(instanceName string, confirm bool) error {
 			return fmt.Errorf("failed to remove generated configs: %w", err)
 		}
 	}
+	if err := os.Remove(tools.GetTalosconfigPath(m.dataDir, instanceName)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove talosconfig: %w", err)
+	}
 
 	return nil
 }
 
 // ConfigureContext configures talosctl context for the cluster
 func (m *Manager) ConfigureContext(instanceName, clusterName string) error {
-	talosconfigPath := filepath.Join(m.GetGeneratedDir(instanceName), "talosconfig")
+	talosconfigPath := tools.GetTalosconfigPath(m.dataDir, instanceName)
 
 	if !storage.FileExists(talosconfigPath) {
 		return fmt.Errorf("talosconfig not found")
@@ -0,0 +1,559 @@
+package cluster
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/wild-cloud/wild-central/daemon/internal/config"
+	"github.com/wild-cloud/wild-central/daemon/internal/operations"
+	"github.com/wild-cloud/wild-central/daemon/internal/storage"
+	"github.com/wild-cloud/wild-central/daemon/internal/tools"
+)
+
+// kubectlTimeout bounds the cluster queries made before an upgrade
+const kubectlTimeout = 15 * time.Second
+
+// maxKubeletSkew is how many minor versions kubelets may trail the API server
+const maxKubeletSkew = 3
+
+// KubernetesUpgradeOptions selects the Kubernetes version to upgrade to
+type KubernetesUpgradeOptions struct {
+	Version string `json:"version"`           // Target version, e.g. v1.34.1
+	DryRun  bool   `json:"dry_run,omitempty"` // Only report what talosctl upgrade-k8s would change
+}
+
+// RemovedAPIUsage is a manifest using an API version Kubernetes no longer
+// serves, or will stop serving
+type RemovedAPIUsage struct {
+	File        string `json:"file"`
+	Line        int    `json:"line"`
+	APIVersion  string `json:"api_version"`
+	Kind        string `json:"kind"`
+	RemovedIn   string `json:"removed_in"`
+	Replacement string `json:"replacement,omitempty"`
+}
+
+// KubernetesPreflight is the result of the checks run before a Kubernetes
+// upgrade
+type KubernetesPreflight struct {
+	CurrentVersion string            `json:"current_version"`
+	TargetVersion  string            `json:"target_version"`
+	Checks         []HealthCheck     `json:"checks"`
+	RemovedAPIs    []RemovedAPIUsage `json:"removed_apis"`
+	Ready          bool              `json:"ready"` // No check is failing
+}
+
+// failures returns the messages of the failing checks
+func (p *KubernetesPreflight) failures() []string {
+	var messages []string
+	for _, check := range p.Checks {
+		if check.Status == "failing" {
+			messages = append(messages, check.Message)
+		}
+	}
+	return messages
+}
+
+// removedAPI is an API version Kubernetes stopped serving. An empty kind
+// covers every kind of the API version.
+type removedAPI struct {
+	apiVersion  string
+	kind        string
+	removedIn   int // Minor version of Kubernetes 1.x
+	replacement string
+}
+
+// removedAPIs lists the API removals from the Kubernetes deprecation guide.
+// Specific kinds come before the wildcard of their API version.
+var removedAPIs = []removedAPI{
+	{"extensions/v1beta1", "Ingress", 22, "networking.k8s.io/v1"},
+	{"extensions/v1beta1", "NetworkPolicy", 16, "networking.k8s.io/v1"},
+	{"extensions/v1beta1", "PodSecurityPolicy", 16, "policy/v1beta1"},
+	{"extensions/v1beta1", "", 16, "apps/v1"},
+	{"apps/v1beta1", "", 16, "apps/v1"},
+	{"apps/v1beta2", "", 16, "apps/v1"},
+	{"admissionregistration.k8s.io/v1beta1", "", 22, "admissionregistration.k8s.io/v1"},
+	{"apiextensions.k8s.io/v1beta1", "", 22, "apiextensions.k8s.io/v1"},
+	{"apiregistration.k8s.io/v1beta1", "", 22, "apiregistration.k8s.io/v1"},
+	{"authentication.k8s.io/v1beta1", "", 22, "authentication.k8s.io/v1"},
+	{"authorization.k8s.io/v1beta1", "", 22, "authorization.k8s.io/v1"},
+	{"certificates.k8s.io/v1beta1", "", 22, "certificates.k8s.io/v1"},
+	{"coordination.k8s.io/v1beta1", "", 22, "coordination.k8s.io/v1"},
+	{"networking.k8s.io/v1beta1", "", 22, "networking.k8s.io/v1"},
+	{"rbac.authorization.k8s.io/v1beta1", "", 22, "rbac.authorization.k8s.io/v1"},
+	{"scheduling.k8s.io/v1beta1", "", 22, "scheduling.k8s.io/v1"},
+	{"storage.k8s.io/v1beta1", "CSIStorageCapacity", 27, "storage.k8s.io/v1"},
+	{"storage.k8s.io/v1beta1", "", 22, "storage.k8s.io/v1"},
+	{"batch/v1beta1", "", 25, "batch/v1"},
+	{"discovery.k8s.io/v1beta1", "", 25, "discovery.k8s.io/v1"},
+	{"events.k8s.io/v1beta1", "", 25, "events.k8s.io/v1"},
+	{"autoscaling/v2beta1", "", 25, "autoscaling/v2"},
+	{"policy/v1beta1", "PodSecurityPolicy", 25, ""},
+	{"policy/v1beta1", "", 25, "policy/v1"},
+	{"node.k8s.io/v1beta1", "", 25, "node.k8s.io/v1"},
+	{"autoscaling/v2beta2", "", 26, "autoscaling/v2"},
+	{"flowcontrol.apiserver.k8s.io/v1beta1", "", 26, "flowcontrol.apiserver.k8s.io/v1"},
+	{"flowcontrol.apiserver.k8s.io/v1beta2", "", 29, "flowcontrol.apiserver.k8s.io/v1"},
+	{"flowcontrol.apiserver.k8s.io/v1beta3", "", 32, "flowcontrol.apiserver.k8s.io/v1"},
+}
+
+// lookupRemovedAPI returns the removal covering a manifest's API version
+// and kind, if any
+func lookupRemovedAPI(apiVersion, kind string) (removedAPI, bool) {
+	for _, api := range removedAPIs {
+		if api.apiVersion == apiVersion && (api.kind == kind || api.kind == "") {
+			return api, true
+		}
+	}
+	return removedAPI{}, false
+}
+
+// kubeVersion is a parsed Kubernetes version
+type kubeVersion struct {
+	major, minor, patch int
+}
+
+// parseKubeVersion parses versions such as v1.34.1, 1.34 or v1.33.1+k3s1
+func parseKubeVersion(s string) (kubeVersion, error) {
+	var v kubeVersion
+	text := strings.TrimPrefix(strings.TrimSpace(s), "v")
+	if i := strings.IndexAny(text, "-+"); i >= 0 {
+		text = text[:i]
+	}
+	parts := strings.Split(text, ".")
+	if len(parts) < 2 || len(parts) > 3 {
+		return v, fmt.Errorf("invalid Kubernetes version %q", s)
+	}
+	numbers := make([]int, 3)
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return v, fmt.Errorf("invalid Kubernetes version %q", s)
+		}
+		numbers[i] = n
+	}
+	return kubeVersion{numbers[0], numbers[1], numbers[2]}, nil
+}
+
+func (v kubeVersion) String() string {
+	return fmt.Sprintf("v%d.%d.%d", v.major, v.minor, v.patch)
+}
+
+func (v kubeVersion) compare(o kubeVersion) int {
+	for _, d := range []int{v.major - o.major, v.minor - o.minor, v.patch - o.patch} {
+		if d != 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// PreflightKubernetesUpgrade checks that the cluster can move to the target
+// version: the version skew rules hold, and neither the cluster services
+// nor the given app manifest directories use APIs the target no longer
+// serves.
+func (m *Manager) PreflightKubernetesUpgrade(instanceName, version string, appManifestDirs []string) (*KubernetesPreflight, error) {
+	target, err := parseKubeVersion(version)
+	if err != nil {
+		return nil, err
+	}
+
+	current, kubelets, err := m.kubernetesVersions(instanceName)
+	if err != nil {
+		return nil, err
+	}
+
+	manifestDirs := append(m.serviceManifestDirs(instanceName), appManifestDirs...)
+	usages, err := scanRemovedAPIs(manifestDirs)
+	if err != nil {
+		return nil, err
+	}
+
+	return evaluatePreflight(current, kubelets, target, usages), nil
+}
+
+// evaluatePreflight evaluates the skew rules and the API usages found for an
+// upgrade from current to target
+func evaluatePreflight(current kubeVersion, kubelets map[string]kubeVersion, target kubeVersion, usages []RemovedAPIUsage) *KubernetesPreflight {
+	p := &KubernetesPreflight{
+		CurrentVersion: current.String(),
+		TargetVersion:  target.String(),
+		Checks:         []HealthCheck{},
+		RemovedAPIs:    []RemovedAPIUsage{},
+	}
+
+	// The control plane moves one minor version at a time and never back
+	check := HealthCheck{Name: "Target Version", Status: "passing"}
+	switch {
+	case target.major != current.major:
+		check.Status = "failing"
+		check.Message = fmt.Sprintf("cannot upgrade across major versions (%s to %s)", current, target)
+	case target.compare(current) < 0:
+		check.Status = "failing"
+		check.Message = fmt.Sprintf("%s is older than the running %s; Kubernetes cannot be downgraded", target, current)
+	case target.compare(current) == 0:
+		check.Status = "warning"
+		check.Message = fmt.Sprintf("cluster already runs %s; upgrading only reconciles the control plane", target)
+	case target.minor > current.minor+1:
+		check.Status = "failing"
+		check.Message = fmt.Sprintf("upgrade one minor version at a time: to v%d.%d first", current.major, current.minor+1)
+	default:
+		check.Message = fmt.Sprintf("%s to %s", current, target)
+	}
+	p.Checks = append(p.Checks, check)
+
+	// Kubelets stay behind until talosctl gets to them, and may not trail
+	// the new API server by more than maxKubeletSkew minor versions
+	check = HealthCheck{Name: "Kubelet Version Skew", Status: "passing", Message: "all kubelets within the supported skew"}
+	var behind, ahead []string
+	hostnames := make([]string, 0, len(kubelets))
+	for hostname := range kubelets {
+		hostnames = append(hostnames, hostname)
+	}
+	sort.Strings(hostnames)
+	for _, hostname := range hostnames {
+		kubelet := kubelets[hostname]
+		switch {
+		case kubelet.major != target.major || target.minor-kubelet.minor > maxKubeletSkew:
+			behind = append(behind, fmt.Sprintf("%s (%s)", hostname, kubelet))
+		case kubelet.compare(current) > 0:
+			ahead = append(ahead, fmt.Sprintf("%s (%s)", hostname, kubelet))
+		}
+	}
+	switch {
+	case len(behind) > 0:
+		check.Status = "failing"
+		check.Message = fmt.Sprintf("kubelets more than %d minor versions behind %s: %s", maxKubeletSkew, target, strings.Join(behind, ", "))
+	case len(ahead) > 0:
+		check.Status = "warning"
+		check.Message = fmt.Sprintf("kubelets newer than the API server: %s", strings.Join(ahead, ", "))
+	}
+	p.Checks = append(p.Checks, check)
+
+	// APIs the target removes break the manifests using them. Those
+	// removed earlier already fail to apply, and those removed later will.
+	var removedNow, removedBefore, removedLater int
+	for _, usage := range usages {
+		removedIn, _ := parseKubeVersion(usage.RemovedIn)
+		switch {
+		case removedIn.minor <= current.minor:
+			removedBefore++
+		case removedIn.minor <= target.minor:
+			removedNow++
+		default:
+			removedLater++
+		}
+		p.RemovedAPIs = append(p.RemovedAPIs, usage)
+	}
+	check = HealthCheck{Name: "Removed APIs", Status: "passing", Message: "no manifests use APIs removed by " + target.String()}
+	switch {
+	case removedNow > 0:
+		check.Status = "failing"
+		check.Message = fmt.Sprintf("%d manifest(s) use APIs removed by %s", removedNow, target)
+	case removedBefore > 0:
+		check.Status = "warning"
+		check.Message = fmt.Sprintf("%d manifest(s) use APIs already removed in %s", removedBefore, current)
+	case removedLater > 0:
+		check.Status = "warning"
+		check.Message = fmt.Sprintf("%d manifest(s) use deprecated APIs removed in a later version", removedLater)
+	}
+	p.Checks = append(p.Checks, check)
+
+	p.Ready = len(p.failures()) == 0
+	return p
+}
+
+// kubernetesVersions returns the API server version and each node's
+// kubelet version
+func (m *Manager) kubernetesVersions(instanceName string) (kubeVersion, map[string]kubeVersion, error) {
+	var current kubeVersion
+
+	kubeconfigPath := tools.GetKubeconfigPath(m.dataDir, instanceName)
+	if !storage.FileExists(kubeconfigPath) {
+		return current, nil, fmt.Errorf("kubeconfig not found - cluster may not be bootstrapped")
+	}
+
+	output, err := kubectlOutput(kubeconfigPath, "version", "-o", "json")
+	if err != nil {
+		return current, nil, fmt.Errorf("failed to get Kubernetes version: %w", err)
+	}
+	var versionResult struct {
+		ServerVersion struct {
+			GitVersion string `json:"gitVersion"`
+		} `json:"serverVersion"`
+	}
+	if err := json.Unmarshal(output, &versionResult); err != nil {
+		return current, nil, fmt.Errorf("failed to parse Kubernetes version: %w", err)
+	}
+	if current, err = parseKubeVersion(versionResult.ServerVersion.GitVersion); err != nil {
+		return current, nil, fmt.Errorf("failed to parse Kubernetes version: %w", err)
+	}
+
+	output, err = kubectlOutput(kubeconfigPath, "get", "nodes", "-o", "json")
+	if err != nil {
+		return current, nil, fmt.Errorf("failed to get nodes: %w", err)
+	}
+	var nodesResult struct {
+		Items []struct {
+			Metadata struct {
+				Name string `json:"name"`
+			} `json:"metadata"`
+			Status struct {
+				NodeInfo struct {
+					KubeletVersion string `json:"kubeletVersion"`
+				} `json:"nodeInfo"`
+			} `json:"status"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(output, &nodesResult); err != nil {
+		return current, nil, fmt.Errorf("failed to parse nodes: %w", err)
+	}
+
+	kubelets := make(map[string]kubeVersion, len(nodesResult.Items))
+	for _, item := range nodesResult.Items {
+		kubelet, err := parseKubeVersion(item.Status.NodeInfo.KubeletVersion)
+		if err != nil {
+			return current, nil, fmt.Errorf("node %s: %w", item.Metadata.Name, err)
+		}
+		kubelets[item.Metadata.Name] = kubelet
+	}
+	return current, kubelets, nil
+}
+
+// kubectlOutput runs a kubectl query, giving up after kubectlTimeout
+func kubectlOutput(kubeconfigPath string, args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), kubectlTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "kubectl", append([]string{"--kubeconfig", kubeconfigPath}, args...)...)
+	output, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+			return nil, fmt.Errorf("%w: %s", err, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return nil, err
+	}
+	return output, nil
+}
+
+// serviceManifestDirs returns the manifests of the instance's cluster
+// services: the compiled kustomize directory where there is one, otherwise
+// the templates it is compiled from
+func (m *Manager) serviceManifestDirs(instanceName string) []string {
+	servicesDir := filepath.Join(m.dataDir, "instances", instanceName, "setup", "cluster-services")
+	entries, err := os.ReadDir(servicesDir)
+	if err != nil {
+		return nil
+	}
+
+	var dirs []string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		serviceDir := filepath.Join(servicesDir, entry.Name())
+		for _, name := range []string{"kustomize", "kustomize.template"} {
+			if storage.FileExists(filepath.Join(serviceDir, name)) {
+				dirs = append(dirs, filepath.Join(serviceDir, name))
+				break
+			}
+		}
+	}
+	return dirs
+}
+
+// scanRemovedAPIs finds the YAML documents in the given directories whose
+// apiVersion and kind have been removed from Kubernetes. Manifests are read
+// line by line rather than parsed, as many are still templates.
+func scanRemovedAPIs(dirs []string) ([]RemovedAPIUsage, error) {
+	var usages []RemovedAPIUsage
+	for _, dir := range dirs {
+		if !storage.FileExists(dir) {
+			continue
+		}
+		// Report paths starting at the service or app name
+		base := filepath.Dir(filepath.Dir(dir))
+
+		err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() || (filepath.Ext(path) != ".yaml" && filepath.Ext(path) != ".yml") {
+				return nil
+			}
+			name, err := filepath.Rel(base, path)
+			if err != nil {
+				name = path
+			}
+			found, err := scanManifest(path, name)
+			if err != nil {
+				return err
+			}
+			usages = append(usages, found...)
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan manifests in %s: %w", dir, err)
+		}
+	}
+	return usages, nil
+}
+
+// scanManifest checks each document of one manifest file
+func scanManifest(path, name string) ([]RemovedAPIUsage, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var usages []RemovedAPIUsage
+	var apiVersion, kind string
+	apiVersionLine := 0
+	check := func() {
+		if api, ok := lookupRemovedAPI(apiVersion, kind); ok && kind != "" {
+			usages = append(usages, RemovedAPIUsage{
+				File:        name,
+				Line:        apiVersionLine,
+				APIVersion:  apiVersion,
+				Kind:        kind,
+				RemovedIn:   fmt.Sprintf("v1.%d", api.removedIn),
+				Replacement: api.replacement,
+			})
+		}
+		apiVersion, kind, apiVersionLine = "", "", 0
+	}
+
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "---"):
+			check()
+		case strings.HasPrefix(line, "apiVersion:"):
+			apiVersion = topLevelValue(line)
+			apiVersionLine = lineNumber
+		case strings.HasPrefix(line, "kind:"):
+			kind = topLevelValue(line)
+		}
+	}
+	check()
+	return usages, scanner.Err()
+}
+
+// topLevelValue returns the value of a top-level "key: value" line
+func topLevelValue(line string) string {
+	_, value, _ := strings.Cut(line, ":")
+	if i := strings.Index(value, " #"); i >= 0 {
+		value = value[:i]
+	}
+	return strings.Trim(strings.TrimSpace(value), `"'`)
+}
+
+// UpgradeKubernetes runs talosctl upgrade-k8s against a control plane node,
+// streaming its output. talosctl upgrades the control plane components
+// one node at a time and then the kubelets. A dry run reports what would
+// change; a real one refuses to start when a preflight check fails, and
+// records the new version in config.yaml once it completes.
+func (m *Manager) UpgradeKubernetes(instanceName string, opts KubernetesUpgradeOptions, preflight *KubernetesPreflight, out *operations.Output) error {
+	out.Printf("🩺 Preflight: %s to %s\n", preflight.CurrentVersion, preflight.TargetVersion)
+	for _, check := range preflight.Checks {
+		out.Printf("    %s %s: %s\n", statusIcon(check.Status), check.Name, check.Message)
+	}
+	for _, usage := range preflight.RemovedAPIs {
+		out.Printf("    %s:%d %s %s (removed in %s)\n", usage.File, usage.Line, usage.APIVersion, usage.Kind, usage.RemovedIn)
+	}
+	if !preflight.Ready && !opts.DryRun {
+		return fmt.Errorf("preflight checks failed: %s", strings.Join(preflight.failures(), "; "))
+	}
+
+	nodeIP, err := m.controlPlaneIP(instanceName)
+	if err != nil {
+		return err
+	}
+
+	args := []string{"upgrade-k8s", "--nodes", nodeIP, "--to", strings.TrimPrefix(preflight.TargetVersion, "v")}
+	if opts.DryRun {
+		args = append(args, "--dry-run")
+		out.Printf("🔎 Dry run of the upgrade to %s through %s\n", preflight.TargetVersion, nodeIP)
+	} else {
+		out.Printf("⬆️  Upgrading Kubernetes to %s through %s\n", preflight.TargetVersion, nodeIP)
+	}
+
+	cmd := exec.Command("talosctl", args...)
+	tools.WithTalosconfig(cmd, tools.GetTalosconfigPath(m.dataDir, instanceName))
+	if err := out.Run(cmd); err != nil {
+		return fmt.Errorf("talosctl upgrade-k8s: %w", err)
+	}
+	if opts.DryRun {
+		out.Printf("✅ Dry run complete; nothing was changed\n")
+		return nil
+	}
+
+	// Record the version so configs generated from now on use it
+	configPath := filepath.Join(m.dataDir, "instances", instanceName, "config.yaml")
+	if err := config.NewManager().SetConfigValue(configPath, "cluster.kubernetes.version", preflight.TargetVersion); err != nil {
+		return fmt.Errorf("upgraded Kubernetes, but recording its version failed: %w", err)
+	}
+	out.Printf("✅ Kubernetes upgraded to %s\n", preflight.TargetVersion)
+	return nil
+}
+
+// controlPlaneIP returns the address of a control plane node with an
+// applied configuration, for talosctl to drive the upgrade through
+func (m *Manager) controlPlaneIP(instanceName string) (string, error) {
+	configPath := filepath.Join(m.dataDir, "instances", instanceName, "config.yaml")
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read config: %w", err)
+	}
+
+	var cfg struct {
+		Cluster struct {
+			Nodes struct {
+				Active map[string]struct {
+					Role     string `yaml:"role"`
+					TargetIP string `yaml:"targetIp"`
+					Applied  string `yaml:"applied"` // Older configs quote it
+				} `yaml:"active"`
+			} `yaml:"nodes"`
+		} `yaml:"cluster"`
+	}
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return "", fmt.Errorf("failed to parse config: %w", err)
+	}
+
+	var hostnames []string
+	for hostname, node := range cfg.Cluster.Nodes.Active {
+		if node.Role == "controlplane" && node.Applied == "true" && node.TargetIP != "" {
+			hostnames = append(hostnames, hostname)
+		}
+	}
+	if len(hostnames) == 0 {
+		return "", fmt.Errorf("no control plane node with an applied configuration")
+	}
+	sort.Strings(hostnames)
+	return cfg.Cluster.Nodes.Active[hostnames[0]].TargetIP, nil
+}
+
+func statusIcon(status string) string {
+	switch status {
+	case "passing":
+		return "✅"
+	case "warning":
+		return "⚠️ "
+	default:
+		return "❌"
+	}
+}
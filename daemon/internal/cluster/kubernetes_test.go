@@ -0,0 +1,171 @@
+package cluster
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func mustVersion(t *testing.T, s string) kubeVersion {
+	t.Helper()
+	v, err := parseKubeVersion(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func checkStatus(p *KubernetesPreflight, name string) string {
+	for _, check := range p.Checks {
+		if check.Name == name {
+			return check.Status
+		}
+	}
+	return ""
+}
+
+func TestParseKubeVersion(t *testing.T) {
+	for input, want := range map[string]kubeVersion{
+		"v1.34.1":      {1, 34, 1},
+		"1.33":         {1, 33, 0},
+		"v1.33.1+k3s1": {1, 33, 1},
+	} {
+		got, err := parseKubeVersion(input)
+		if err != nil || got != want {
+			t.Errorf("parseKubeVersion(%q) = %v, %v; want %v", input, got, err, want)
+		}
+	}
+	for _, input := range []string{"", "latest", "v1", "v1.x.0"} {
+		if _, err := parseKubeVersion(input); err == nil {
+			t.Errorf("parseKubeVersion(%q) should fail", input)
+		}
+	}
+}
+
+func TestEvaluatePreflight_VersionSkew(t *testing.T) {
+	kubelets := map[string]kubeVersion{
+		"control-1": mustVersion(t, "v1.33.1"),
+		"worker-1":  mustVersion(t, "v1.31.0"),
+	}
+
+	tests := []struct {
+		target  string
+		version string // Target Version status
+		kubelet string // Kubelet Version Skew status
+	}{
+		{"v1.34.1", "passing", "passing"},
+		{"v1.33.4", "passing", "passing"},
+		{"v1.33.1", "warning", "passing"},
+		{"v1.32.0", "failing", "passing"},
+		{"v1.35.0", "failing", "failing"},
+	}
+	for _, tt := range tests {
+		p := evaluatePreflight(mustVersion(t, "v1.33.1"), kubelets, mustVersion(t, tt.target), nil)
+		if got := checkStatus(p, "Target Version"); got != tt.version {
+			t.Errorf("%s: Target Version = %s, want %s", tt.target, got, tt.version)
+		}
+		if got := checkStatus(p, "Kubelet Version Skew"); got != tt.kubelet {
+			t.Errorf("%s: Kubelet Version Skew = %s, want %s", tt.target, got, tt.kubelet)
+		}
+		if p.Ready != (tt.version != "failing" && tt.kubelet != "failing") {
+			t.Errorf("%s: Ready = %v", tt.target, p.Ready)
+		}
+	}
+}
+
+func TestEvaluatePreflight_RemovedAPIs(t *testing.T) {
+	usages := []RemovedAPIUsage{{File: "apps/x/manifests/hpa.yaml", APIVersion: "flowcontrol.apiserver.k8s.io/v1beta3", Kind: "FlowSchema", RemovedIn: "v1.32"}}
+
+	p := evaluatePreflight(mustVersion(t, "v1.31.4"), nil, mustVersion(t, "v1.32.0"), usages)
+	if got := checkStatus(p, "Removed APIs"); got != "failing" || p.Ready {
+		t.Errorf("API removed by the target: status %s, ready %v", got, p.Ready)
+	}
+
+	p = evaluatePreflight(mustVersion(t, "v1.30.2"), nil, mustVersion(t, "v1.31.0"), usages)
+	if got := checkStatus(p, "Removed APIs"); got != "warning" || !p.Ready {
+		t.Errorf("API removed later: status %s, ready %v", got, p.Ready)
+	}
+}
+
+func TestScanRemovedAPIs(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "cluster-services", "traefik", "kustomize.template")
+	if err := os.MkdirAll(filepath.Join(dir, "templates"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	manifest := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: traefik
+  namespace: {{ .cloud.namespace }}
+---
+apiVersion: policy/v1beta1
+kind: PodDisruptionBudget
+spec:
+  template:
+    apiVersion: batch/v1beta1
+    kind: CronJob
+---
+# Ingress moved late
+kind: Ingress
+apiVersion: "extensions/v1beta1"
+`
+	if err := os.WriteFile(filepath.Join(dir, "templates", "deployment.yaml"), []byte(manifest), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "README.md"), []byte("apiVersion: batch/v1beta1\nkind: CronJob\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	usages, err := scanRemovedAPIs([]string{dir, filepath.Join(dir, "missing")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []RemovedAPIUsage{
+		{File: "traefik/kustomize.template/templates/deployment.yaml", Line: 7, APIVersion: "policy/v1beta1", Kind: "PodDisruptionBudget", RemovedIn: "v1.25", Replacement: "policy/v1"},
+		{File: "traefik/kustomize.template/templates/deployment.yaml", Line: 16, APIVersion: "extensions/v1beta1", Kind: "Ingress", RemovedIn: "v1.22", Replacement: "networking.k8s.io/v1"},
+	}
+	if len(usages) != len(want) {
+		t.Fatalf("found %d usages, want %d: %+v", len(usages), len(want), usages)
+	}
+	for i := range want {
+		if usages[i] != want[i] {
+			t.Errorf("usage %d = %+v, want %+v", i, usages[i], want[i])
+		}
+	}
+}
+
+func TestControlPlaneIP(t *testing.T) {
+	dataDir := t.TempDir()
+	instanceDir := filepath.Join(dataDir, "instances", "home")
+	if err := os.MkdirAll(instanceDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	config := `cluster:
+  nodes:
+    active:
+      worker-1:
+        role: worker
+        targetIp: 192.168.8.21
+        applied: true
+      control-2:
+        role: controlplane
+        targetIp: 192.168.8.12
+        applied: "true"
+      control-1:
+        role: controlplane
+        targetIp: 192.168.8.11
+        maintenance: true
+`
+	if err := os.WriteFile(filepath.Join(instanceDir, "config.yaml"), []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ip, err := NewManager(dataDir).controlPlaneIP("home")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ip != "192.168.8.12" {
+		t.Errorf("controlPlaneIP = %s, want 192.168.8.12", ip)
+	}
+}
@@ -4,8 +4,11 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 
 	"gopkg.in/yaml.v3"
+
+	"github.com/wild-cloud/wild-central/daemon/internal/storage"
 )
 
 // GlobalConfig represents the main configuration structure
@@ -80,7 +83,7 @@ func SaveGlobalConfig(config *GlobalConfig, configPath string) error {
 		return fmt.Errorf("marshaling config: %w", err)
 	}
 
-	return os.WriteFile(configPath, data, 0644)
+	return storage.WriteFile(configPath, data, 0644)
 }
 
 // IsEmpty checks if the configuration is empty or uninitialized
@@ -93,30 +96,87 @@ func (c *GlobalConfig) IsEmpty() bool {
 	return c.Cloud.DNS.IP == "" || c.Cluster.Nodes.Talos.Version == ""
 }
 
+// NodeConfig is an entry of cluster.nodes.active in an instance's config.yaml
 type NodeConfig struct {
-	Role      string `yaml:"role" json:"role"`
-	Interface string `yaml:"interface" json:"interface"`
-	Disk      string `yaml:"disk" json:"disk"`
-	CurrentIp string `yaml:"currentIp" json:"currentIp"`
+	Role        string                       `yaml:"role" json:"role"`
+	TargetIp    string                       `yaml:"targetIp,omitempty" json:"targetIp,omitempty"`
+	CurrentIp   string                       `yaml:"currentIp,omitempty" json:"currentIp,omitempty"`
+	Interface   string                       `yaml:"interface,omitempty" json:"interface,omitempty"`
+	Disk        string                       `yaml:"disk,omitempty" json:"disk,omitempty"`
+	Storage     map[string]map[string]string `yaml:"storage,omitempty" json:"storage,omitempty"`
+	Version     string                       `yaml:"version,omitempty" json:"version,omitempty"`
+	SchematicId string                       `yaml:"schematicId,omitempty" json:"schematicId,omitempty"`
+	Maintenance Flag                         `yaml:"maintenance,omitempty" json:"maintenance,omitempty"`
+	Configured  Flag                         `yaml:"configured,omitempty" json:"configured,omitempty"`
+	Applied     Flag                         `yaml:"applied,omitempty" json:"applied,omitempty"`
+	Labels      map[string]string            `yaml:"labels,omitempty" json:"labels,omitempty"`
+	Taints      map[string]string            `yaml:"taints,omitempty" json:"taints,omitempty"`
+	Annotations map[string]string            `yaml:"annotations,omitempty" json:"annotations,omitempty"`
 }
 
+// Flag is a boolean in config.yaml. 'wild config set' writes every value as
+// a string, so "true" and "false" are accepted as well.
+type Flag bool
+
+// UnmarshalYAML reads a boolean or a quoted boolean
+func (f *Flag) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind != yaml.ScalarNode {
+		return fmt.Errorf("line %d: expected true or false", value.Line)
+	}
+	parsed, err := strconv.ParseBool(value.Value)
+	if err != nil {
+		return fmt.Errorf("line %d: expected true or false, got %q", value.Line, value.Value)
+	}
+	*f = Flag(parsed)
+	return nil
+}
+
+// InstanceConfig is the schema of an instance's config.yaml. Scalars are
+// strings because 'wild config set' writes them as strings. Apps keep their
+// own settings under apps.<app>, and keys outside the schema are allowed.
 type InstanceConfig struct {
-	BaseDomain     string `yaml:"baseDomain" json:"baseDomain"`
-	Domain         string `yaml:"domain" json:"domain"`
-	InternalDomain string `yaml:"internalDomain" json:"internalDomain"`
-	Backup         struct {
-		Root string `yaml:"root" json:"root"`
-	} `yaml:"backup" json:"backup"`
-	DHCPRange string `yaml:"dhcpRange" json:"dhcpRange"`
-	NFS       struct {
-		Host      string `yaml:"host" json:"host"`
-		MediaPath string `yaml:"mediaPath" json:"mediaPath"`
-	} `yaml:"nfs" json:"nfs"`
+	Operator struct {
+		Email string `yaml:"email" json:"email"`
+	} `yaml:"operator" json:"operator"`
+	Cloud struct {
+		BaseDomain         string `yaml:"baseDomain" json:"baseDomain"`
+		Domain             string `yaml:"domain" json:"domain"`
+		InternalDomain     string `yaml:"internalDomain" json:"internalDomain"`
+		DHCPRange          string `yaml:"dhcpRange" json:"dhcpRange"`
+		DockerRegistryHost string `yaml:"dockerRegistryHost,omitempty" json:"dockerRegistryHost,omitempty"`
+		Router             struct {
+			IP         string `yaml:"ip" json:"ip"`
+			DynamicDns string `yaml:"dynamicDns,omitempty" json:"dynamicDns,omitempty"`
+		} `yaml:"router" json:"router"`
+		DNS struct {
+			IP               string `yaml:"ip" json:"ip"`
+			ExternalResolver string `yaml:"externalResolver" json:"externalResolver"`
+		} `yaml:"dns" json:"dns"`
+		Dnsmasq struct {
+			Interface string `yaml:"interface" json:"interface"`
+		} `yaml:"dnsmasq" json:"dnsmasq"`
+		NFS struct {
+			Host            string `yaml:"host" json:"host"`
+			MediaPath       string `yaml:"mediaPath" json:"mediaPath"`
+			StorageCapacity string `yaml:"storageCapacity,omitempty" json:"storageCapacity,omitempty"`
+		} `yaml:"nfs" json:"nfs"`
+		SMTP struct {
+			Host     string `yaml:"host,omitempty" json:"host,omitempty"`
+			Port     string `yaml:"port,omitempty" json:"port,omitempty"`
+			User     string `yaml:"user,omitempty" json:"user,omitempty"`
+			From     string `yaml:"from,omitempty" json:"from,omitempty"`
+			TLS      Flag   `yaml:"tls,omitempty" json:"tls,omitempty"`
+			StartTLS Flag   `yaml:"startTls,omitempty" json:"startTls,omitempty"`
+		} `yaml:"smtp,omitempty" json:"smtp,omitempty"`
+	} `yaml:"cloud" json:"cloud"`
 	Cluster struct {
 		Name           string `yaml:"name" json:"name"`
 		LoadBalancerIp string `yaml:"loadBalancerIp" json:"loadBalancerIp"`
 		IpAddressPool  string `yaml:"ipAddressPool" json:"ipAddressPool"`
-		CertManager    struct {
+		HostnamePrefix string `yaml:"hostnamePrefix" json:"hostnamePrefix"`
+		// Let control plane nodes run workloads, for clusters without workers
+		AllowSchedulingOnControlPlanes Flag `yaml:"allowSchedulingOnControlPlanes,omitempty" json:"allowSchedulingOnControlPlanes,omitempty"`
+		CertManager                    struct {
 			Cloudflare struct {
 				Domain string `yaml:"domain" json:"domain"`
 				ZoneID string `yaml:"zoneID" json:"zoneID"`
@@ -125,8 +185,7 @@ type InstanceConfig struct {
 		ExternalDns struct {
 			OwnerId string `yaml:"ownerId" json:"ownerId"`
 		} `yaml:"externalDns" json:"externalDns"`
-		HostnamePrefix string `yaml:"hostnamePrefix" json:"hostnamePrefix"`
-		Nodes          struct {
+		Nodes struct {
 			Talos struct {
 				Version     string `yaml:"version" json:"version"`
 				SchematicId string `yaml:"schematicId" json:"schematicId"`
@@ -134,9 +193,10 @@ type InstanceConfig struct {
 			Control struct {
 				Vip string `yaml:"vip" json:"vip"`
 			} `yaml:"control" json:"control"`
-			ActiveNodes []map[string]NodeConfig `yaml:"activeNodes" json:"activeNodes"`
-		}
+			Active map[string]NodeConfig `yaml:"active" json:"active"`
+		} `yaml:"nodes" json:"nodes"`
 	} `yaml:"cluster" json:"cluster"`
+	Apps map[string]map[string]interface{} `yaml:"apps,omitempty" json:"apps,omitempty"`
 }
 
 func LoadCloudConfig(configPath string) (*InstanceConfig, error) {
@@ -164,5 +224,22 @@ func SaveCloudConfig(config *InstanceConfig, configPath string) error {
 		return fmt.Errorf("marshaling config: %w", err)
 	}
 
-	return os.WriteFile(configPath, data, 0644)
+	return storage.WriteFile(configPath, data, 0644)
+}
+
+// ValidateInstanceConfigMap checks that a nested config map decodes into
+// InstanceConfig, the schema config.yaml is created from. Keys outside the
+// schema are allowed.
+func ValidateInstanceConfigMap(data map[string]interface{}) error {
+	raw, err := yaml.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("marshaling config: %w", err)
+	}
+
+	config := &InstanceConfig{}
+	if err := yaml.Unmarshal(raw, config); err != nil {
+		return fmt.Errorf("config does not match instance schema: %w", err)
+	}
+
+	return nil
 }
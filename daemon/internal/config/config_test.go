@@ -0,0 +1,133 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// An instance config as written by 'wild config set', node setup and app add
+const testInstanceConfig = `operator:
+  email: admin@example.com
+cloud:
+  baseDomain: example.com
+  domain: cloud.example.com
+  internalDomain: internal.cloud.example.com
+  dhcpRange: 192.168.8.100,192.168.8.200
+  router:
+    ip: 192.168.8.1
+  dns:
+    ip: 192.168.8.50
+    externalResolver: 1.1.1.1
+  dnsmasq:
+    interface: eth0
+  nfs:
+    host: 192.168.8.50
+    mediaPath: /data/media
+    storageCapacity: 1Ti
+  smtp:
+    host: smtp.example.com
+    port: 587
+    user: wild
+    from: noreply@example.com
+    tls: true
+    startTls: "false"
+cluster:
+  name: wild-cluster
+  loadBalancerIp: 192.168.8.80
+  ipAddressPool: 192.168.8.80-192.168.8.89
+  hostnamePrefix: ""
+  certManager:
+    cloudflare:
+      domain: example.com
+      zoneID: abc123
+  externalDns:
+    ownerId: wild-cloud
+  nodes:
+    talos:
+      version: v1.11.2
+      schematicId: 434a0300db532066f1098e05ac068159371d00f0aba0a3103a0e826e83825c82
+    control:
+      vip: 192.168.8.30
+    active:
+      control-1:
+        role: control
+        targetIp: 192.168.8.31
+        currentIp: 192.168.8.31
+        interface: eth0
+        disk: /dev/nvme0n1
+        maintenance: false
+        configured: "true"
+        applied: "true"
+        labels:
+          zone: rack-a
+      worker-1:
+        role: worker
+        targetIp: 192.168.8.41
+        disk: /dev/sda
+        storage:
+          longhorn:
+            role: longhorn
+            disk: /dev/sdb
+        notes: spare power supply
+apps:
+  ghost:
+    domain: blog.cloud.example.com
+    port: 2368
+    storage: 10Gi
+  postgres:
+    image: pgvector/pgvector:pg15
+`
+
+func decodeMap(t *testing.T, data string) map[string]interface{} {
+	t.Helper()
+	var out map[string]interface{}
+	if err := yaml.Unmarshal([]byte(data), &out); err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+	return out
+}
+
+func TestValidateInstanceConfigMap(t *testing.T) {
+	if err := ValidateInstanceConfigMap(decodeMap(t, testInstanceConfig)); err != nil {
+		t.Fatalf("real instance config rejected: %v", err)
+	}
+
+	tests := map[string]string{
+		"scalar section":   "cloud:\n  dns: 192.168.8.50\n",
+		"node list":        "cluster:\n  nodes:\n    active:\n      - control-1\n",
+		"bad flag":         "cluster:\n  nodes:\n    active:\n      control-1:\n        maintenance: maybe\n",
+		"scalar app":       "apps:\n  ghost: enabled\n",
+		"bad node storage": "cluster:\n  nodes:\n    active:\n      worker-1:\n        storage: /dev/sdb\n",
+	}
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			if err := ValidateInstanceConfigMap(decodeMap(t, data)); err == nil {
+				t.Error("expected a schema error")
+			}
+		})
+	}
+}
+
+func TestEnsureInstanceConfig(t *testing.T) {
+	instancePath := filepath.Join(t.TempDir(), "home")
+	if err := NewManager().EnsureInstanceConfig(instancePath); err != nil {
+		t.Fatalf("EnsureInstanceConfig failed: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(instancePath, "config.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"cloud:\n  baseDomain: \"\"\n", "operator:\n  email: \"\"\n", "    active: {}\n"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("initial config missing %q:\n%s", want, data)
+		}
+	}
+	if err := ValidateInstanceConfigMap(decodeMap(t, string(data))); err != nil {
+		t.Errorf("initial config does not match the schema: %v", err)
+	}
+}
@@ -1,9 +1,12 @@
 package config
 
 import (
+	"bytes"
 	"fmt"
 	"path/filepath"
 
+	"gopkg.in/yaml.v3"
+
 	"github.com/wild-cloud/wild-central/daemon/internal/storage"
 	"github.com/wild-cloud/wild-central/daemon/internal/tools"
 )
@@ -33,36 +36,17 @@ func (m *Manager) EnsureInstanceConfig(instancePath string) error {
 		return nil
 	}
 
-	// Create minimal config structure
-	initialConfig := `# Wild Cloud Instance Configuration
-baseDomain: ""
-domain: ""
-internalDomain: ""
-dhcpRange: ""
-backup:
-  root: ""
-nfs:
-  host: ""
-  mediaPath: ""
-cluster:
-  name: ""
-  loadBalancerIp: ""
-  ipAddressPool: ""
-  hostnamePrefix: ""
-  certManager:
-    cloudflare:
-      domain: ""
-      zoneID: ""
-  externalDns:
-    ownerId: ""
-  nodes:
-    talos:
-      version: ""
-      schematicId: ""
-    control:
-      vip: ""
-    activeNodes: []
-`
+	// Create the InstanceConfig structure with empty values
+	var buf bytes.Buffer
+	buf.WriteString("# Wild Cloud Instance Configuration\n")
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&InstanceConfig{}); err != nil {
+		return fmt.Errorf("encoding initial config: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return fmt.Errorf("encoding initial config: %w", err)
+	}
 
 	// Ensure instance directory exists
 	if err := storage.EnsureDir(instancePath, 0755); err != nil {
@@ -70,7 +54,7 @@ cluster:
 	}
 
 	// Write config with proper permissions
-	if err := storage.WriteFile(configPath, []byte(initialConfig), 0644); err != nil {
+	if err := storage.WriteFile(configPath, buf.Bytes(), 0644); err != nil {
 		return err
 	}
 
@@ -100,7 +84,9 @@ func (m *Manager) SetConfigValue(configPath, key, value string) error {
 	// Acquire lock before modifying
 	lockPath := configPath + ".lock"
 	return storage.WithLock(lockPath, func() error {
-		return m.yq.Set(configPath, fmt.Sprintf(".%s", key), value)
+		return storage.EditFile(configPath, func(copyPath string) error {
+			return m.yq.Set(copyPath, fmt.Sprintf(".%s", key), value)
+		})
 	})
 }
 
@@ -0,0 +1,197 @@
+package context
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/wild-cloud/wild-central/daemon/internal/storage"
+)
+
+// Context resolution
+//
+// The daemon and the CLI resolve the current instance with the same rules,
+// first match wins:
+//
+//  1. An explicit instance: the {name} in an API path, or the CLI --instance flag.
+//  2. The client's own context, set with POST /api/v1/context. Clients are
+//     identified by their API token (Authorization: Bearer) or, without one,
+//     by the X-Wild-Client-ID header the CLI sends.
+//  3. The daemon-wide default in <data dir>/current-context. Requests that
+//     identify no client read and write this value, as before.
+//
+// The CLI additionally falls back to its legacy ~/.wildcloud/current_instance
+// file between 2 and 3, and to the first instance after 3.
+
+// Sources a resolved context can come from
+const (
+	SourceClient  = "client"
+	SourceDefault = "default"
+)
+
+// ClientContext is the current instance of one client
+type ClientContext struct {
+	Instance  string    `yaml:"instance" json:"instance"`
+	UpdatedAt time.Time `yaml:"updatedAt" json:"updated_at"`
+}
+
+// clientIDPattern limits client IDs to safe, bounded identifiers
+var clientIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,128}$`)
+
+// TokenClientKey returns the client key for an API token. Only a hash of
+// the token is stored.
+func TokenClientKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return "token:" + hex.EncodeToString(sum[:16])
+}
+
+// IDClientKey returns the client key for a client ID
+func IDClientKey(clientID string) (string, error) {
+	if !clientIDPattern.MatchString(clientID) {
+		return "", fmt.Errorf("invalid client ID")
+	}
+	return "client:" + clientID, nil
+}
+
+// GetClientContextsPath returns the file holding per-client contexts
+func (m *Manager) GetClientContextsPath() string {
+	return filepath.Join(m.dataDir, "client-contexts.yaml")
+}
+
+func (m *Manager) readClientContexts() (map[string]ClientContext, error) {
+	contexts := make(map[string]ClientContext)
+
+	data, err := os.ReadFile(m.GetClientContextsPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return contexts, nil
+		}
+		return nil, fmt.Errorf("reading client contexts: %w", err)
+	}
+	if err := yaml.Unmarshal(data, &contexts); err != nil {
+		return nil, fmt.Errorf("parsing client contexts: %w", err)
+	}
+	if contexts == nil {
+		contexts = make(map[string]ClientContext)
+	}
+	return contexts, nil
+}
+
+// updateClientContexts applies fn to the client contexts under a lock
+func (m *Manager) updateClientContexts(fn func(map[string]ClientContext)) error {
+	if err := storage.EnsureDir(m.dataDir, 0755); err != nil {
+		return err
+	}
+
+	path := m.GetClientContextsPath()
+	return storage.WithLock(path+".lock", func() error {
+		contexts, err := m.readClientContexts()
+		if err != nil {
+			return err
+		}
+
+		fn(contexts)
+
+		data, err := yaml.Marshal(contexts)
+		if err != nil {
+			return fmt.Errorf("marshaling client contexts: %w", err)
+		}
+		return storage.WriteFile(path, data, 0644)
+	})
+}
+
+// GetClientContext returns the instance a client selected
+func (m *Manager) GetClientContext(clientKey string) (string, error) {
+	contexts, err := m.readClientContexts()
+	if err != nil {
+		return "", err
+	}
+
+	ctx, ok := contexts[clientKey]
+	if !ok || ctx.Instance == "" {
+		return "", fmt.Errorf("no context set for client")
+	}
+	return ctx.Instance, nil
+}
+
+// SetClientContext sets a client's current instance
+func (m *Manager) SetClientContext(clientKey, instanceName string) error {
+	if clientKey == "" {
+		return fmt.Errorf("client key cannot be empty")
+	}
+	if instanceName == "" {
+		return fmt.Errorf("instance name cannot be empty")
+	}
+	if !storage.FileExists(filepath.Join(m.dataDir, "instances", instanceName)) {
+		return fmt.Errorf("instance %s does not exist", instanceName)
+	}
+
+	return m.updateClientContexts(func(contexts map[string]ClientContext) {
+		contexts[clientKey] = ClientContext{Instance: instanceName, UpdatedAt: time.Now().UTC()}
+	})
+}
+
+// ClearClientContext removes a client's context so it falls back to the default
+func (m *Manager) ClearClientContext(clientKey string) error {
+	return m.updateClientContexts(func(contexts map[string]ClientContext) {
+		delete(contexts, clientKey)
+	})
+}
+
+// ResolveContext returns the current instance for a client and where it came
+// from: the client's own context if it still points to an instance, otherwise
+// the daemon-wide default. An empty clientKey only consults the default.
+func (m *Manager) ResolveContext(clientKey string) (string, string, error) {
+	if clientKey != "" {
+		if name, err := m.GetClientContext(clientKey); err == nil {
+			if storage.FileExists(filepath.Join(m.dataDir, "instances", name)) {
+				return name, SourceClient, nil
+			}
+		}
+	}
+
+	name, err := m.GetCurrentContext()
+	if err != nil {
+		return "", "", err
+	}
+	return name, SourceDefault, nil
+}
+
+// ForgetInstance clears every context, per-client and default, that points
+// to an instance. Used when the instance is deleted.
+func (m *Manager) ForgetInstance(instanceName string) error {
+	if current, err := m.GetCurrentContext(); err == nil && current == instanceName {
+		if err := m.ClearCurrentContext(); err != nil {
+			return err
+		}
+	}
+
+	contexts, err := m.readClientContexts()
+	if err != nil {
+		return err
+	}
+	found := false
+	for _, ctx := range contexts {
+		if ctx.Instance == instanceName {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil
+	}
+
+	return m.updateClientContexts(func(contexts map[string]ClientContext) {
+		for key, ctx := range contexts {
+			if ctx.Instance == instanceName {
+				delete(contexts, key)
+			}
+		}
+	})
+}
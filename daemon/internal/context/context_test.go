@@ -98,3 +98,76 @@ func TestManager_ClearCurrentContext(t *testing.T) {
 		t.Fatalf("Context should be cleared")
 	}
 }
+
+func TestManager_ClientContexts(t *testing.T) {
+	tmpDir := t.TempDir()
+	m := NewManager(tmpDir)
+
+	for _, name := range []string{"cloud1", "cloud2", "cloud3"} {
+		if err := os.MkdirAll(filepath.Join(tmpDir, "instances", name), 0755); err != nil {
+			t.Fatalf("Failed to create instance dir: %v", err)
+		}
+	}
+
+	alice := TokenClientKey("alice-token")
+	bob, err := IDClientKey("cli-1234")
+	if err != nil {
+		t.Fatalf("IDClientKey failed: %v", err)
+	}
+
+	if err := m.SetCurrentContext("cloud1"); err != nil {
+		t.Fatalf("SetCurrentContext failed: %v", err)
+	}
+	if err := m.SetClientContext(alice, "cloud2"); err != nil {
+		t.Fatalf("SetClientContext failed: %v", err)
+	}
+	if err := m.SetClientContext(bob, "cloud3"); err != nil {
+		t.Fatalf("SetClientContext failed: %v", err)
+	}
+
+	tests := []struct {
+		key        string
+		wantName   string
+		wantSource string
+	}{
+		{alice, "cloud2", SourceClient},
+		{bob, "cloud3", SourceClient},
+		{"client:unknown", "cloud1", SourceDefault},
+		{"", "cloud1", SourceDefault},
+	}
+	for _, tt := range tests {
+		name, source, err := m.ResolveContext(tt.key)
+		if err != nil {
+			t.Fatalf("ResolveContext(%q) failed: %v", tt.key, err)
+		}
+		if name != tt.wantName || source != tt.wantSource {
+			t.Errorf("ResolveContext(%q) = %s, %s; want %s, %s", tt.key, name, source, tt.wantName, tt.wantSource)
+		}
+	}
+
+	// Deleting an instance drops the contexts pointing to it
+	if err := m.ForgetInstance("cloud2"); err != nil {
+		t.Fatalf("ForgetInstance failed: %v", err)
+	}
+	if name, source, _ := m.ResolveContext(alice); name != "cloud1" || source != SourceDefault {
+		t.Errorf("after ForgetInstance got %s, %s; want default cloud1", name, source)
+	}
+	if name, _, _ := m.ResolveContext(bob); name != "cloud3" {
+		t.Errorf("other clients should keep their context, got %s", name)
+	}
+
+	if err := m.ClearClientContext(bob); err != nil {
+		t.Fatalf("ClearClientContext failed: %v", err)
+	}
+	if _, source, _ := m.ResolveContext(bob); source != SourceDefault {
+		t.Errorf("cleared client should fall back to default, got %s", source)
+	}
+}
+
+func TestIDClientKey_Invalid(t *testing.T) {
+	for _, id := range []string{"", "../etc", "has space", string(make([]byte, 200))} {
+		if _, err := IDClientKey(id); err == nil {
+			t.Errorf("IDClientKey(%q) should fail", id)
+		}
+	}
+}
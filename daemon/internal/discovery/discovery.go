@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/wild-cloud/wild-central/daemon/internal/node"
+	"github.com/wild-cloud/wild-central/daemon/internal/state"
 	"github.com/wild-cloud/wild-central/daemon/internal/storage"
 	"github.com/wild-cloud/wild-central/daemon/internal/tools"
 )
@@ -56,31 +57,79 @@ func (m *Manager) GetDiscoveryDir(instanceName string) string {
 	return filepath.Join(m.dataDir, "instances", instanceName, "discovery")
 }
 
-// GetDiscoveryStatusPath returns the path to discovery status file
+// GetDiscoveryStatusPath returns the path of the discovery status file
+// written by earlier versions. Status now lives in the instance state store.
 func (m *Manager) GetDiscoveryStatusPath(instanceName string) string {
 	return filepath.Join(m.GetDiscoveryDir(instanceName), "status.json")
 }
 
+// discoveryBucket holds discovery status in the instance state store
+const discoveryBucket = "discovery"
+
+// store opens the instance state store. The first time it is opened, a
+// status file left by earlier versions is imported.
+func (m *Manager) store(instanceName string) (*state.DB, error) {
+	db, err := state.Open(state.InstancePath(m.dataDir, instanceName))
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Once("discovery-json", func() error {
+		return m.importLegacy(db, instanceName)
+	}); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+// importLegacy imports the status file left by earlier versions
+func (m *Manager) importLegacy(db *state.DB, instanceName string) error {
+	legacy := m.GetDiscoveryStatusPath(instanceName)
+	if !storage.FileExists(legacy) {
+		return nil
+	}
+
+	_, err := db.Migrate("discovery-json", func(tx *state.Tx) error {
+		data, err := os.ReadFile(legacy)
+		if err != nil {
+			return err
+		}
+		var status DiscoveryStatus
+		if err := json.Unmarshal(data, &status); err != nil {
+			return nil // Unreadable status is treated as no discovery run
+		}
+		return tx.Put(discoveryBucket, "status", &status, nil)
+	})
+	if err != nil {
+		return fmt.Errorf("importing discovery status: %w", err)
+	}
+
+	os.Remove(legacy)
+	return nil
+}
+
 // GetDiscoveryStatus returns current discovery operation status
 func (m *Manager) GetDiscoveryStatus(instanceName string) (*DiscoveryStatus, error) {
-	statusPath := m.GetDiscoveryStatusPath(instanceName)
-
-	if !storage.FileExists(statusPath) {
-		// No discovery has been run yet
-		return &DiscoveryStatus{
-			Active:     false,
-			NodesFound: []DiscoveredNode{},
-		}, nil
+	db, err := m.store(instanceName)
+	if err != nil {
+		return nil, err
 	}
 
-	data, err := os.ReadFile(statusPath)
+	var status DiscoveryStatus
+	found := false
+	err = db.View(func(tx *state.Tx) error {
+		found, err = tx.Get(discoveryBucket, "status", &status)
+		return err
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to read discovery status: %w", err)
 	}
 
-	var status DiscoveryStatus
-	if err := json.Unmarshal(data, &status); err != nil {
-		return nil, fmt.Errorf("failed to parse discovery status: %w", err)
+	if !found {
+		// No discovery has been run yet
+		return &DiscoveryStatus{
+			Active:     false,
+			NodesFound: []DiscoveredNode{},
+		}, nil
 	}
 
 	return &status, nil
@@ -212,34 +261,32 @@ func (m *Manager) DiscoverNodes(instanceName string, ipList []string) ([]Discove
 	return nodes, nil
 }
 
-// ClearDiscoveryStatus removes discovery status file
+// ClearDiscoveryStatus removes the discovery status
 func (m *Manager) ClearDiscoveryStatus(instanceName string) error {
-	statusPath := m.GetDiscoveryStatusPath(instanceName)
-
-	if !storage.FileExists(statusPath) {
-		return nil // Already cleared, idempotent
+	db, err := m.store(instanceName)
+	if err != nil {
+		return err
 	}
 
-	return os.Remove(statusPath)
+	return db.Update(func(tx *state.Tx) error {
+		if !tx.Exists(discoveryBucket, "status") {
+			return nil // Already cleared, idempotent
+		}
+		return tx.Delete(discoveryBucket, "status")
+	})
 }
 
-// writeDiscoveryStatus writes discovery status to disk
+// writeDiscoveryStatus saves the discovery status
 func (m *Manager) writeDiscoveryStatus(instanceName string, status *DiscoveryStatus) error {
-	discoveryDir := m.GetDiscoveryDir(instanceName)
-
-	// Ensure directory exists
-	if err := storage.EnsureDir(discoveryDir, 0755); err != nil {
+	db, err := m.store(instanceName)
+	if err != nil {
 		return err
 	}
 
-	statusPath := m.GetDiscoveryStatusPath(instanceName)
-
-	data, err := json.MarshalIndent(status, "", "  ")
+	err = db.Update(func(tx *state.Tx) error {
+		return tx.Put(discoveryBucket, "status", status, nil)
+	})
 	if err != nil {
-		return fmt.Errorf("failed to marshal discovery status: %w", err)
-	}
-
-	if err := storage.WriteFile(statusPath, data, 0644); err != nil {
 		return fmt.Errorf("failed to write discovery status: %w", err)
 	}
 
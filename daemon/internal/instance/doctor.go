@@ -0,0 +1,362 @@
+package instance
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/wild-cloud/wild-central/daemon/internal/config"
+	"github.com/wild-cloud/wild-central/daemon/internal/operations"
+	"github.com/wild-cloud/wild-central/daemon/internal/secrets"
+	"github.com/wild-cloud/wild-central/daemon/internal/storage"
+	"github.com/wild-cloud/wild-central/daemon/internal/tools"
+)
+
+// Doctor checks
+const (
+	CheckPermissions = "permissions"
+	CheckDirectories = "directories"
+	CheckLocks       = "locks"
+	CheckTalosconfig = "talosconfig"
+	CheckOperations  = "operations"
+	CheckConfig      = "config"
+	CheckTempFiles   = "tempfiles"
+)
+
+// staleTempAge is how long a temporary file must be unused to count as left behind
+const staleTempAge = 10 * time.Minute
+
+// instanceSubdirs are the directories every instance has
+var instanceSubdirs = []string{"talos", "k8s", "logs", "backups"}
+
+// Finding is one problem found by Doctor
+type Finding struct {
+	Check    string `json:"check"`
+	Path     string `json:"path,omitempty"` // Relative to the instance directory
+	Problem  string `json:"problem"`
+	Fixable  bool   `json:"fixable"`
+	Fixed    bool   `json:"fixed"`
+	FixError string `json:"fix_error,omitempty"`
+	Hint     string `json:"hint,omitempty"` // What to do when it cannot be fixed automatically
+}
+
+// Report is the result of checking an instance
+type Report struct {
+	Instance  string    `json:"instance"`
+	CheckedAt time.Time `json:"checked_at"`
+	Repaired  bool      `json:"repaired"` // Whether fixes were applied
+	Healthy   bool      `json:"healthy"`  // No problems remain
+	Findings  []Finding `json:"findings"`
+}
+
+// doctor accumulates findings and applies fixes for one run
+type doctor struct {
+	m            *Manager
+	name         string
+	instancePath string
+	fix          bool
+	findings     []Finding
+}
+
+// Doctor checks an instance's files for problems that ValidateInstance
+// rejects or that break later operations. With fix set, problems that can be
+// repaired safely are repaired; the rest are reported with a hint.
+func (m *Manager) Doctor(name string, fix bool) (*Report, error) {
+	if !m.InstanceExists(name) {
+		return nil, fmt.Errorf("instance %s does not exist", name)
+	}
+
+	d := &doctor{
+		m:            m,
+		name:         name,
+		instancePath: m.GetInstancePath(name),
+		fix:          fix,
+	}
+
+	d.checkDirectories()
+	d.checkPermissions()
+	d.checkLocks()
+	d.checkTempFiles()
+	d.checkTalosconfig()
+	d.checkOperations()
+	d.checkConfig()
+
+	report := &Report{
+		Instance:  name,
+		CheckedAt: time.Now().UTC(),
+		Repaired:  fix,
+		Healthy:   true,
+		Findings:  d.findings,
+	}
+	if report.Findings == nil {
+		report.Findings = []Finding{}
+	}
+	for _, f := range report.Findings {
+		if !f.Fixed {
+			report.Healthy = false
+		}
+	}
+
+	return report, nil
+}
+
+// report records a problem, running repair when fixing is enabled
+func (d *doctor) report(f Finding, repair func() error) {
+	if repair != nil {
+		f.Fixable = true
+		if d.fix {
+			if err := repair(); err != nil {
+				f.FixError = err.Error()
+			} else {
+				f.Fixed = true
+			}
+		}
+	}
+	d.findings = append(d.findings, f)
+}
+
+func (d *doctor) rel(path string) string {
+	if rel, err := filepath.Rel(d.instancePath, path); err == nil {
+		return rel
+	}
+	return path
+}
+
+// checkDirectories looks for missing subdirectories and base files
+func (d *doctor) checkDirectories() {
+	for _, subdir := range instanceSubdirs {
+		path := filepath.Join(d.instancePath, subdir)
+		info, err := os.Stat(path)
+		if err == nil && info.IsDir() {
+			continue
+		}
+		if err == nil {
+			d.report(Finding{Check: CheckDirectories, Path: subdir, Problem: "is a file, expected a directory",
+				Hint: "move the file aside and run repair again"}, nil)
+			continue
+		}
+		d.report(Finding{Check: CheckDirectories, Path: subdir, Problem: "missing directory"}, func() error {
+			return storage.EnsureDir(path, 0755)
+		})
+	}
+
+	if !storage.FileExists(d.m.GetInstanceConfigPath(d.name)) {
+		d.report(Finding{Check: CheckDirectories, Path: "config.yaml", Problem: "missing config file"}, func() error {
+			return d.m.configMgr.EnsureInstanceConfig(d.instancePath)
+		})
+	}
+	if !storage.FileExists(d.m.GetInstanceSecretsPath(d.name)) {
+		d.report(Finding{Check: CheckDirectories, Path: "secrets.yaml", Problem: "missing secrets file"}, func() error {
+			return d.m.secretsMgr.EnsureSecretsFile(d.instancePath)
+		})
+	}
+}
+
+// checkPermissions makes sure files holding credentials are private
+func (d *doctor) checkPermissions() {
+	files := append(secrets.SecretFiles(d.instancePath), secrets.CredentialFiles(d.instancePath)...)
+
+	seen := make(map[string]bool)
+	for _, path := range files {
+		if seen[path] {
+			continue
+		}
+		seen[path] = true
+
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		if mode := info.Mode().Perm(); mode != 0600 {
+			d.report(Finding{
+				Check:   CheckPermissions,
+				Path:    d.rel(path),
+				Problem: fmt.Sprintf("mode %04o, expected 0600", mode),
+			}, func() error {
+				return storage.EnsureFilePermissions(path, 0600)
+			})
+		}
+	}
+}
+
+// checkLocks finds lock files still recording a holder that has let go
+func (d *doctor) checkLocks() {
+	var locks []string
+	filepath.WalkDir(d.instancePath, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if !entry.IsDir() && (entry.Name() == ".lock" || strings.HasSuffix(entry.Name(), ".lock")) {
+			locks = append(locks, path)
+		}
+		return nil
+	})
+
+	for _, path := range locks {
+		stale, err := storage.ClearStaleLock(path, true)
+		if err != nil || !stale {
+			continue
+		}
+		d.report(Finding{
+			Check:   CheckLocks,
+			Path:    d.rel(path),
+			Problem: "lock file records a holder but nobody holds it",
+		}, func() error {
+			_, err := storage.ClearStaleLock(path, false)
+			return err
+		})
+	}
+}
+
+// checkTempFiles finds temporary files left behind by writes that were
+// interrupted before they replaced their target
+func (d *doctor) checkTempFiles() {
+	filepath.WalkDir(d.instancePath, func(path string, entry os.DirEntry, err error) error {
+		if err != nil || entry.IsDir() || !isWriteTempFile(entry.Name()) {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil || time.Since(info.ModTime()) < staleTempAge {
+			return nil
+		}
+		d.report(Finding{
+			Check:   CheckTempFiles,
+			Path:    d.rel(path),
+			Problem: "temporary file from an interrupted write",
+		}, func() error {
+			return os.Remove(path)
+		})
+		return nil
+	})
+}
+
+// isWriteTempFile reports whether name looks like a storage.WriteFile temporary file
+func isWriteTempFile(name string) bool {
+	if !strings.HasPrefix(name, ".") {
+		return false
+	}
+	i := strings.LastIndex(name, ".tmp-")
+	return i > 1 && strings.HasPrefix(name, storage.TempFilePrefix(name[1:i]))
+}
+
+// checkTalosconfig makes sure the talosconfig talosctl is run with
+// (setup/cluster-nodes/generated) exists when an older cluster config
+// generation only wrote it to talos/generated, and that a copy left there
+// agrees with it
+func (d *doctor) checkTalosconfig() {
+	generated := filepath.Join(d.instancePath, "talos", "generated", "talosconfig")
+	used := tools.GetTalosconfigPath(d.m.dataDir, d.name)
+	if generated == used {
+		return
+	}
+
+	generatedData, generatedErr := os.ReadFile(generated)
+	usedData, usedErr := os.ReadFile(used)
+
+	switch {
+	case generatedErr != nil && usedErr != nil:
+		// Cluster config not generated yet
+	case usedErr != nil:
+		d.report(Finding{
+			Check:   CheckTalosconfig,
+			Path:    d.rel(used),
+			Problem: "missing; talosconfig only exists at " + d.rel(generated),
+		}, func() error {
+			return copyFile(generated, used)
+		})
+	case generatedErr != nil:
+		// Generated configs only keep the talosconfig that talosctl uses
+	case !bytes.Equal(generatedData, usedData):
+		d.report(Finding{
+			Check:   CheckTalosconfig,
+			Path:    d.rel(used),
+			Problem: "differs from " + d.rel(generated),
+			Hint:    "check which talosconfig reaches the cluster and copy it over the other",
+		}, nil)
+	}
+}
+
+// checkOperations finds operation log directories whose operation record is gone
+func (d *doctor) checkOperations() {
+	opsMgr := operations.NewManager(d.m.dataDir)
+	opsDir := opsMgr.GetOperationsDir(d.name)
+	entries, err := os.ReadDir(opsDir)
+	if err != nil {
+		return
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		if opsMgr.Exists(d.name, entry.Name()) {
+			continue
+		}
+		logDir := filepath.Join(opsDir, entry.Name())
+		d.report(Finding{
+			Check:   CheckOperations,
+			Path:    d.rel(logDir),
+			Problem: "log of an operation that no longer exists",
+		}, func() error {
+			return os.RemoveAll(logDir)
+		})
+	}
+}
+
+// checkConfig reports config.yaml content that does not parse or does not
+// fit the instance config schema. These are never changed automatically.
+func (d *doctor) checkConfig() {
+	for _, file := range []string{"config.yaml", "instance.yaml"} {
+		data, err := os.ReadFile(filepath.Join(d.instancePath, file))
+		if err != nil {
+			continue
+		}
+
+		var parsed map[string]interface{}
+		if err := yaml.Unmarshal(data, &parsed); err != nil {
+			d.report(Finding{
+				Check:   CheckConfig,
+				Path:    file,
+				Problem: fmt.Sprintf("does not parse: %v", err),
+				Hint:    "fix the YAML by hand; the file is not changed by repair",
+			}, nil)
+			continue
+		}
+
+		if file != "config.yaml" {
+			continue
+		}
+		var typeErr *yaml.TypeError
+		if err := yaml.Unmarshal(data, &config.InstanceConfig{}); errors.As(err, &typeErr) {
+			problems := append([]string(nil), typeErr.Errors...)
+			sort.Strings(problems)
+			for _, problem := range problems {
+				d.report(Finding{
+					Check:   CheckConfig,
+					Path:    file,
+					Problem: problem,
+					Hint:    "correct the value with 'wild config set'",
+				}, nil)
+			}
+		}
+	}
+}
+
+// copyFile copies src to dst, creating dst's directory, with mode 0600
+func copyFile(src, dst string) error {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", src, err)
+	}
+	if err := storage.EnsureDir(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	return storage.WriteFile(dst, data, 0600)
+}
@@ -0,0 +1,166 @@
+package instance
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/wild-cloud/wild-central/daemon/internal/storage"
+	"github.com/wild-cloud/wild-central/daemon/internal/tools"
+)
+
+func findingFor(report *Report, check, path string) *Finding {
+	for i, f := range report.Findings {
+		if f.Check == check && f.Path == path {
+			return &report.Findings[i]
+		}
+	}
+	return nil
+}
+
+func TestDoctor_HealthyInstance(t *testing.T) {
+	m := NewManager(t.TempDir())
+	if err := m.CreateInstance("home"); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := m.Doctor("home", false)
+	if err != nil {
+		t.Fatalf("Doctor failed: %v", err)
+	}
+	if !report.Healthy || len(report.Findings) != 0 {
+		t.Errorf("expected healthy report, got %+v", report.Findings)
+	}
+}
+
+func TestDoctor_DetectAndRepair(t *testing.T) {
+	m := NewManager(t.TempDir())
+	if err := m.CreateInstance("home"); err != nil {
+		t.Fatal(err)
+	}
+	instancePath := m.GetInstancePath("home")
+
+	// Wrong mode on secrets, missing subdirectory
+	os.Chmod(m.GetInstanceSecretsPath("home"), 0644)
+	os.RemoveAll(filepath.Join(instancePath, "logs"))
+
+	// Lock record left by a holder that crashed
+	lock := filepath.Join(instancePath, "config.yaml.lock")
+	os.WriteFile(lock, []byte(`{"path":"config.yaml.lock","pid":4121}`), 0644)
+
+	// talosconfig only in talos/generated
+	generated := filepath.Join(instancePath, "talos", "generated", "talosconfig")
+	os.MkdirAll(filepath.Dir(generated), 0755)
+	os.WriteFile(generated, []byte("context: home"), 0600)
+
+	// Leftover of an interrupted write
+	tempFile := filepath.Join(instancePath, ".config.yaml.tmp-123")
+	os.WriteFile(tempFile, []byte("cluster:"), 0644)
+	old := time.Now().Add(-time.Hour)
+	os.Chtimes(tempFile, old, old)
+
+	// Orphaned operation log
+	orphan := filepath.Join(instancePath, "operations", "op_gone")
+	os.MkdirAll(orphan, 0755)
+	os.WriteFile(filepath.Join(orphan, "output.log"), []byte("done"), 0644)
+
+	report, err := m.Doctor("home", false)
+	if err != nil {
+		t.Fatalf("Doctor failed: %v", err)
+	}
+	if report.Healthy {
+		t.Fatal("expected problems to be found")
+	}
+
+	used := tools.GetTalosconfigPath(m.dataDir, "home")
+	usedRel, _ := filepath.Rel(instancePath, used)
+	expected := []struct{ check, path string }{
+		{CheckPermissions, "secrets.yaml"},
+		{CheckDirectories, "logs"},
+		{CheckLocks, "config.yaml.lock"},
+		{CheckTempFiles, ".config.yaml.tmp-123"},
+		{CheckTalosconfig, usedRel},
+		{CheckOperations, filepath.Join("operations", "op_gone")},
+	}
+	for _, e := range expected {
+		f := findingFor(report, e.check, e.path)
+		if f == nil {
+			t.Errorf("missing %s finding for %s in %+v", e.check, e.path, report.Findings)
+			continue
+		}
+		if !f.Fixable || f.Fixed {
+			t.Errorf("finding %+v should be fixable and not fixed on a check", f)
+		}
+	}
+
+	// Nothing changes without fix
+	if info, _ := os.Stat(m.GetInstanceSecretsPath("home")); info.Mode().Perm() != 0644 {
+		t.Error("check-only run changed permissions")
+	}
+
+	report, err = m.Doctor("home", true)
+	if err != nil {
+		t.Fatalf("Doctor with fix failed: %v", err)
+	}
+	if !report.Healthy {
+		t.Errorf("expected everything fixed, got %+v", report.Findings)
+	}
+
+	if err := m.ValidateInstance("home"); err != nil {
+		t.Errorf("instance still invalid after repair: %v", err)
+	}
+	if holder, err := storage.ReadLockHolder(lock); err != nil || holder != nil {
+		t.Errorf("stale lock record not cleared: %+v, %v", holder, err)
+	}
+	if data, err := os.ReadFile(used); err != nil || string(data) != "context: home" {
+		t.Errorf("talosconfig not copied: %q, %v", data, err)
+	}
+	if _, err := os.Stat(tempFile); !os.IsNotExist(err) {
+		t.Error("temporary file not removed")
+	}
+	if _, err := os.Stat(orphan); !os.IsNotExist(err) {
+		t.Error("orphaned operation log not removed")
+	}
+
+	report, _ = m.Doctor("home", false)
+	if len(report.Findings) != 0 {
+		t.Errorf("expected no findings after repair, got %+v", report.Findings)
+	}
+}
+
+func TestDoctor_ConfigProblems(t *testing.T) {
+	m := NewManager(t.TempDir())
+	if err := m.CreateInstance("home"); err != nil {
+		t.Fatal(err)
+	}
+
+	// A map where the schema expects a string
+	config := "cluster:\n  name:\n    nested: value\n"
+	os.WriteFile(m.GetInstanceConfigPath("home"), []byte(config), 0644)
+	os.WriteFile(m.GetMetadataPath("home"), []byte("labels: [unclosed"), 0644)
+
+	report, err := m.Doctor("home", true)
+	if err != nil {
+		t.Fatalf("Doctor failed: %v", err)
+	}
+
+	configFinding := findingFor(report, CheckConfig, "config.yaml")
+	if configFinding == nil {
+		t.Fatalf("expected a config.yaml finding, got %+v", report.Findings)
+	}
+	if configFinding.Fixable || configFinding.Hint == "" {
+		t.Errorf("config problems should not be fixable and should carry a hint: %+v", configFinding)
+	}
+	if findingFor(report, CheckConfig, "instance.yaml") == nil {
+		t.Errorf("expected an instance.yaml finding, got %+v", report.Findings)
+	}
+	if report.Healthy {
+		t.Error("report should not be healthy with unfixable problems")
+	}
+
+	data, _ := os.ReadFile(m.GetInstanceConfigPath("home"))
+	if string(data) != config {
+		t.Error("repair must not change config.yaml")
+	}
+}
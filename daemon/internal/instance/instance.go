@@ -4,6 +4,11 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
 
 	"github.com/wild-cloud/wild-central/daemon/internal/config"
 	"github.com/wild-cloud/wild-central/daemon/internal/context"
@@ -17,6 +22,9 @@ type Manager struct {
 	configMgr     *config.Manager
 	secretsMgr    *secrets.Manager
 	contextMgr    *context.Manager
+
+	confirmMu     sync.Mutex
+	confirmations map[string]confirmation // Outstanding confirmation tokens by action
 }
 
 // NewManager creates a new instance manager
@@ -26,6 +34,7 @@ func NewManager(dataDir string) *Manager {
 		configMgr:     config.NewManager(),
 		secretsMgr:    secrets.NewManager(),
 		contextMgr:    context.NewManager(dataDir),
+		confirmations: make(map[string]confirmation),
 	}
 }
 
@@ -57,11 +66,23 @@ func (m *Manager) InstanceExists(name string) bool {
 	return storage.FileExists(m.GetInstancePath(name))
 }
 
-// CreateInstance creates a new Wild Cloud instance with initial structure
-func (m *Manager) CreateInstance(name string) error {
+// ValidateInstanceName checks that name can be used as an instance
+// directory name: not empty, not . or .., and without path separators
+func ValidateInstanceName(name string) error {
 	if name == "" {
 		return fmt.Errorf("instance name cannot be empty")
 	}
+	if name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf("invalid instance name %q", name)
+	}
+	return nil
+}
+
+// CreateInstance creates a new Wild Cloud instance with initial structure
+func (m *Manager) CreateInstance(name string) error {
+	if err := ValidateInstanceName(name); err != nil {
+		return err
+	}
 
 	instancePath := m.GetInstancePath(name)
 
@@ -97,41 +118,60 @@ func (m *Manager) CreateInstance(name string) error {
 			}
 		}
 
+		// Record creation time
+		now := time.Now().UTC()
+		metaData, err := yaml.Marshal(Metadata{CreatedAt: now, UpdatedAt: now})
+		if err != nil {
+			return fmt.Errorf("marshaling metadata: %w", err)
+		}
+		if err := storage.WriteFile(m.GetMetadataPath(name), metaData, 0644); err != nil {
+			return fmt.Errorf("creating metadata file: %w", err)
+		}
+
 		return nil
 	})
 }
 
-// DeleteInstance removes a Wild Cloud instance
-func (m *Manager) DeleteInstance(name string) error {
+// DeleteInstance moves a Wild Cloud instance to the trash, where it is kept
+// until its retention period ends or it is purged
+func (m *Manager) DeleteInstance(name string) (*TrashEntry, error) {
 	if name == "" {
-		return fmt.Errorf("instance name cannot be empty")
+		return nil, fmt.Errorf("instance name cannot be empty")
 	}
 
-	instancePath := m.GetInstancePath(name)
-
 	// Check if instance exists
 	if !m.InstanceExists(name) {
-		return fmt.Errorf("instance %s does not exist", name)
+		return nil, fmt.Errorf("instance %s does not exist", name)
 	}
 
-	// Clear context if this is the current instance
-	currentContext, err := m.contextMgr.GetCurrentContext()
-	if err == nil && currentContext == name {
-		if err := m.contextMgr.ClearCurrentContext(); err != nil {
-			return fmt.Errorf("clearing current context: %w", err)
-		}
+	if err := m.clearContextFor(name); err != nil {
+		return nil, err
 	}
 
 	// Acquire lock for instance deletion
+	var entry *TrashEntry
 	lockPath := filepath.Join(m.dataDir, "instances", ".lock")
-	return storage.WithLock(lockPath, func() error {
-		// Remove instance directory
-		if err := os.RemoveAll(instancePath); err != nil {
-			return fmt.Errorf("removing instance directory: %w", err)
-		}
-
-		return nil
+	err := storage.WithLock(lockPath, func() error {
+		var err error
+		entry, err = m.trashInstance(name)
+		return err
 	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Expired entries are cleaned up opportunistically
+	m.PurgeExpiredTrash()
+
+	return entry, nil
+}
+
+// clearContextFor clears every client and default context that points to the instance
+func (m *Manager) clearContextFor(name string) error {
+	if err := m.contextMgr.ForgetInstance(name); err != nil {
+		return fmt.Errorf("clearing current context: %w", err)
+	}
+	return nil
 }
 
 // ListInstances returns a list of all instance names
@@ -127,7 +127,7 @@ func TestManager_DeleteInstance(t *testing.T) {
 	}
 
 	// Delete instance
-	err = m.DeleteInstance(instanceName)
+	_, err = m.DeleteInstance(instanceName)
 	if err != nil {
 		t.Fatalf("DeleteInstance failed: %v", err)
 	}
@@ -139,7 +139,7 @@ func TestManager_DeleteInstance(t *testing.T) {
 	}
 
 	// Deleting non-existent instance should error
-	err = m.DeleteInstance(instanceName)
+	_, err = m.DeleteInstance(instanceName)
 	if err == nil {
 		t.Fatalf("Deleting non-existent instance should error")
 	}
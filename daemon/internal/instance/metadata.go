@@ -0,0 +1,312 @@
+package instance
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/wild-cloud/wild-central/daemon/internal/operations"
+	"github.com/wild-cloud/wild-central/daemon/internal/storage"
+	"github.com/wild-cloud/wild-central/daemon/internal/tools"
+)
+
+// Metadata describes an instance. It is kept in instance.yaml, separate from
+// config.yaml, so it never reaches templates or the cluster.
+type Metadata struct {
+	Description       string            `yaml:"description,omitempty" json:"description,omitempty"`
+	Labels            map[string]string `yaml:"labels,omitempty" json:"labels,omitempty"`
+	Template          string            `yaml:"template,omitempty" json:"template,omitempty"`
+	DirectoryRevision string            `yaml:"directoryRevision,omitempty" json:"directory_revision,omitempty"` // Wild Cloud Directory revision the instance was created or last updated from
+	CreatedAt         time.Time         `yaml:"createdAt" json:"created_at"`
+	UpdatedAt         time.Time         `yaml:"updatedAt" json:"updated_at"`
+}
+
+// Summary is an instance's metadata plus its state, as shown in listings
+type Summary struct {
+	Name             string                `json:"name"`
+	Metadata         Metadata              `json:"metadata"`
+	NodeCount        int                   `json:"node_count"`
+	Bootstrapped     bool                  `json:"bootstrapped"`
+	ServicesDeployed []string              `json:"services_deployed"`
+	LastOperation    *operations.Operation `json:"last_operation,omitempty"`
+}
+
+// GetMetadataPath returns the path to an instance's metadata file
+func (m *Manager) GetMetadataPath(name string) string {
+	return filepath.Join(m.GetInstancePath(name), "instance.yaml")
+}
+
+// LoadMetadata reads an instance's metadata. Instances created before
+// metadata was recorded get their creation time from the directory.
+func (m *Manager) LoadMetadata(name string) (*Metadata, error) {
+	if !m.InstanceExists(name) {
+		return nil, fmt.Errorf("instance %s does not exist", name)
+	}
+
+	var meta Metadata
+	data, err := os.ReadFile(m.GetMetadataPath(name))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("reading metadata: %w", err)
+	}
+	if err == nil {
+		if err := yaml.Unmarshal(data, &meta); err != nil {
+			return nil, fmt.Errorf("parsing metadata: %w", err)
+		}
+	}
+
+	if meta.CreatedAt.IsZero() {
+		if info, err := os.Stat(m.GetInstancePath(name)); err == nil {
+			meta.CreatedAt = info.ModTime().UTC()
+		}
+	}
+	if meta.UpdatedAt.IsZero() {
+		meta.UpdatedAt = meta.CreatedAt
+	}
+
+	return &meta, nil
+}
+
+// UpdateMetadata applies fn to the instance's metadata and saves the result
+// with UpdatedAt set to now
+func (m *Manager) UpdateMetadata(name string, fn func(*Metadata)) (*Metadata, error) {
+	var result *Metadata
+	err := storage.WithLock(m.GetMetadataPath(name)+".lock", func() error {
+		meta, err := m.LoadMetadata(name)
+		if err != nil {
+			return err
+		}
+
+		fn(meta)
+		if err := ValidateLabels(meta.Labels); err != nil {
+			return err
+		}
+		meta.UpdatedAt = time.Now().UTC()
+
+		data, err := yaml.Marshal(meta)
+		if err != nil {
+			return fmt.Errorf("marshaling metadata: %w", err)
+		}
+		if err := storage.WriteFile(m.GetMetadataPath(name), data, 0644); err != nil {
+			return fmt.Errorf("writing metadata: %w", err)
+		}
+
+		result = meta
+		return nil
+	})
+	return result, err
+}
+
+// Touch records that the instance changed, optionally from a new directory revision
+func (m *Manager) Touch(name, directoryRevision string) error {
+	_, err := m.UpdateMetadata(name, func(meta *Metadata) {
+		if directoryRevision != "" {
+			meta.DirectoryRevision = directoryRevision
+		}
+	})
+	return err
+}
+
+// ValidateLabels checks label keys and values. Keys are non-empty and, like
+// values, may not contain '=' or ',', which separate labels in selectors.
+func ValidateLabels(labels map[string]string) error {
+	for key, value := range labels {
+		if key == "" || strings.ContainsAny(key, "=, ") {
+			return fmt.Errorf("invalid label key %q", key)
+		}
+		if strings.ContainsAny(value, "=,") {
+			return fmt.Errorf("invalid value for label %s", key)
+		}
+	}
+	return nil
+}
+
+// ParseSelector parses label selectors of the form "env=prod,site=home".
+// A bare key matches instances that have the label with any value.
+func ParseSelector(selectors ...string) (map[string]string, error) {
+	selector := make(map[string]string)
+	for _, s := range selectors {
+		for _, part := range strings.Split(s, ",") {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+			key, value, _ := strings.Cut(part, "=")
+			key = strings.TrimSpace(key)
+			if key == "" {
+				return nil, fmt.Errorf("invalid label selector %q", part)
+			}
+			selector[key] = strings.TrimSpace(value)
+		}
+	}
+	return selector, nil
+}
+
+// MatchesSelector reports whether labels satisfy every entry of selector.
+// An empty selector value only requires the key to be present.
+func MatchesSelector(labels, selector map[string]string) bool {
+	for key, want := range selector {
+		have, ok := labels[key]
+		if !ok || (want != "" && have != want) {
+			return false
+		}
+	}
+	return true
+}
+
+// DirectoryRevision identifies the Wild Cloud Directory in use: the git
+// commit when it is a checkout, otherwise the contents of its VERSION file.
+// Returns "" when neither is available.
+func DirectoryRevision(directoryPath string) string {
+	if directoryPath == "" {
+		return ""
+	}
+
+	cmd := exec.Command("git", "-C", directoryPath, "rev-parse", "--short", "HEAD")
+	if output, err := cmd.Output(); err == nil {
+		return strings.TrimSpace(string(output))
+	}
+
+	if data, err := os.ReadFile(filepath.Join(directoryPath, "VERSION")); err == nil {
+		return strings.TrimSpace(string(data))
+	}
+
+	return ""
+}
+
+// ListInstanceSummaries returns a summary of every instance whose labels
+// match selector, sorted by name
+func (m *Manager) ListInstanceSummaries(selector map[string]string) ([]Summary, error) {
+	names, err := m.ListInstances()
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+
+	summaries := []Summary{}
+	for _, name := range names {
+		summary, err := m.GetSummary(name)
+		if err != nil {
+			return nil, fmt.Errorf("summarizing instance %s: %w", name, err)
+		}
+		if MatchesSelector(summary.Metadata.Labels, selector) {
+			summaries = append(summaries, *summary)
+		}
+	}
+
+	return summaries, nil
+}
+
+// GetSummary returns an instance's metadata and state. It only reads files
+// in the data directory and never contacts the cluster.
+func (m *Manager) GetSummary(name string) (*Summary, error) {
+	meta, err := m.LoadMetadata(name)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &Summary{
+		Name:         name,
+		Metadata:     *meta,
+		Bootstrapped: storage.FileExists(tools.GetKubeconfigPath(m.dataDir, name)),
+	}
+
+	// A broken config should not hide the instance from listings
+	summary.NodeCount, _ = m.countNodes(name)
+
+	ops, err := m.listOperations(name)
+	if err != nil {
+		return nil, err
+	}
+	summary.ServicesDeployed = m.deployedServices(name, ops)
+	if len(ops) > 0 {
+		last := ops[len(ops)-1]
+		summary.LastOperation = &last
+	}
+
+	return summary, nil
+}
+
+// countNodes counts the active nodes in the instance config
+func (m *Manager) countNodes(name string) (int, error) {
+	data, err := os.ReadFile(m.GetInstanceConfigPath(name))
+	if err != nil {
+		return 0, err
+	}
+
+	var cfg struct {
+		Cluster struct {
+			Nodes struct {
+				Active map[string]interface{} `yaml:"active"`
+			} `yaml:"nodes"`
+		} `yaml:"cluster"`
+	}
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return 0, err
+	}
+
+	return len(cfg.Cluster.Nodes.Active), nil
+}
+
+// listOperations returns the instance's operations oldest first
+func (m *Manager) listOperations(name string) ([]operations.Operation, error) {
+	ops, err := operations.NewManager(m.dataDir).List(name)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(ops, func(i, j int) bool {
+		return ops[i].StartedAt.Before(ops[j].StartedAt)
+	})
+	return ops, nil
+}
+
+// deployedServices replays completed service operations in order. Installing
+// all services counts every service set up in the instance at that point;
+// a deploy, such as the redeploy of a secret rotation, counts its service.
+func (m *Manager) deployedServices(name string, ops []operations.Operation) []string {
+	deployed := make(map[string]bool)
+	for _, op := range ops {
+		if op.Status != "completed" {
+			continue
+		}
+		switch op.Type {
+		case "install_service", "deploy_service":
+			deployed[op.Target] = true
+		case "delete_service":
+			delete(deployed, op.Target)
+		case "install_all_services":
+			for _, svc := range m.installedServices(name) {
+				deployed[svc] = true
+			}
+		}
+	}
+
+	services := []string{}
+	for svc := range deployed {
+		services = append(services, svc)
+	}
+	sort.Strings(services)
+	return services
+}
+
+// installedServices lists services with a manifest in the instance
+func (m *Manager) installedServices(name string) []string {
+	dir := filepath.Join(m.GetInstancePath(name), "setup", "cluster-services")
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+
+	var services []string
+	for _, entry := range entries {
+		if entry.IsDir() && storage.FileExists(filepath.Join(dir, entry.Name(), "wild-manifest.yaml")) {
+			services = append(services, entry.Name())
+		}
+	}
+	return services
+}
@@ -0,0 +1,165 @@
+package instance
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/wild-cloud/wild-central/daemon/internal/operations"
+	"github.com/wild-cloud/wild-central/daemon/internal/storage"
+)
+
+func TestMetadata_RecordedOnCreate(t *testing.T) {
+	m := NewManager(t.TempDir())
+
+	opts := CreateOptions{
+		Description:       "Home lab",
+		Labels:            map[string]string{"env": "home"},
+		DirectoryRevision: "abc1234",
+	}
+	if err := m.CreateInstanceWithOptions("home", opts); err != nil {
+		t.Fatalf("CreateInstanceWithOptions failed: %v", err)
+	}
+
+	meta, err := m.LoadMetadata("home")
+	if err != nil {
+		t.Fatalf("LoadMetadata failed: %v", err)
+	}
+	if meta.Description != "Home lab" || meta.Labels["env"] != "home" || meta.DirectoryRevision != "abc1234" {
+		t.Errorf("unexpected metadata: %+v", meta)
+	}
+	if meta.CreatedAt.IsZero() || meta.UpdatedAt.Before(meta.CreatedAt) {
+		t.Errorf("unexpected timestamps: created %v, updated %v", meta.CreatedAt, meta.UpdatedAt)
+	}
+}
+
+func TestMetadata_LegacyInstance(t *testing.T) {
+	m := NewManager(t.TempDir())
+	if err := m.CreateInstance("old"); err != nil {
+		t.Fatalf("CreateInstance failed: %v", err)
+	}
+	os.Remove(m.GetMetadataPath("old"))
+
+	meta, err := m.LoadMetadata("old")
+	if err != nil {
+		t.Fatalf("LoadMetadata failed: %v", err)
+	}
+	if meta.CreatedAt.IsZero() {
+		t.Error("expected creation time from the instance directory")
+	}
+}
+
+func TestMetadata_InvalidLabels(t *testing.T) {
+	m := NewManager(t.TempDir())
+
+	err := m.CreateInstanceWithOptions("bad", CreateOptions{Labels: map[string]string{"a=b": "c"}})
+	if err == nil {
+		t.Fatal("expected invalid label to be rejected")
+	}
+	if m.InstanceExists("bad") {
+		t.Error("instance should not be created with invalid labels")
+	}
+}
+
+func TestSelector(t *testing.T) {
+	selector, err := ParseSelector("env=prod,site", "tier=edge")
+	if err != nil {
+		t.Fatalf("ParseSelector failed: %v", err)
+	}
+
+	tests := []struct {
+		labels map[string]string
+		want   bool
+	}{
+		{map[string]string{"env": "prod", "site": "a", "tier": "edge"}, true},
+		{map[string]string{"env": "prod", "tier": "edge"}, false},
+		{map[string]string{"env": "dev", "site": "a", "tier": "edge"}, false},
+		{nil, false},
+	}
+	for _, tt := range tests {
+		if got := MatchesSelector(tt.labels, selector); got != tt.want {
+			t.Errorf("MatchesSelector(%v) = %v, want %v", tt.labels, got, tt.want)
+		}
+	}
+
+	if _, err := ParseSelector("=x"); err == nil {
+		t.Error("expected error for selector without key")
+	}
+}
+
+func TestListInstanceSummaries(t *testing.T) {
+	dataDir := t.TempDir()
+	m := NewManager(dataDir)
+
+	if err := m.CreateInstanceWithOptions("prod", CreateOptions{Labels: map[string]string{"env": "prod"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.CreateInstanceWithOptions("dev", CreateOptions{Labels: map[string]string{"env": "dev"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Two nodes and a bootstrapped cluster for prod
+	config := "cluster:\n  nodes:\n    active:\n      cp1:\n        role: controlplane\n      w1:\n        role: worker\n"
+	if err := os.WriteFile(m.GetInstanceConfigPath("prod"), []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(m.GetInstancePath("prod"), "kubeconfig"), []byte("{}"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// Service history: traefik and metallb installed, then metallb deleted;
+	// longhorn failed to install and was deployed later
+	opsDir := filepath.Join(m.GetInstancePath("prod"), "operations")
+	os.MkdirAll(opsDir, 0755)
+	start := time.Now().Add(-time.Hour)
+	ops := []operations.Operation{
+		{ID: "op1", Type: "install_service", Target: "traefik", Status: "completed", StartedAt: start},
+		{ID: "op2", Type: "install_service", Target: "metallb", Status: "completed", StartedAt: start.Add(time.Minute)},
+		{ID: "op3", Type: "install_service", Target: "longhorn", Status: "failed", StartedAt: start.Add(2 * time.Minute)},
+		{ID: "op4", Type: "delete_service", Target: "metallb", Status: "completed", StartedAt: start.Add(3 * time.Minute)},
+		{ID: "op5", Type: "deploy_service", Target: "longhorn", Status: "completed", StartedAt: start.Add(4 * time.Minute)},
+	}
+	for _, op := range ops {
+		data, _ := json.Marshal(op)
+		if err := os.WriteFile(filepath.Join(opsDir, op.ID+".json"), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	all, err := m.ListInstanceSummaries(nil)
+	if err != nil {
+		t.Fatalf("ListInstanceSummaries failed: %v", err)
+	}
+	if len(all) != 2 || all[0].Name != "dev" || all[1].Name != "prod" {
+		t.Fatalf("unexpected summaries: %+v", all)
+	}
+
+	filtered, err := m.ListInstanceSummaries(map[string]string{"env": "prod"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(filtered) != 1 {
+		t.Fatalf("expected 1 summary, got %d", len(filtered))
+	}
+
+	prod := filtered[0]
+	if prod.NodeCount != 2 || !prod.Bootstrapped {
+		t.Errorf("unexpected node count %d or bootstrapped %v", prod.NodeCount, prod.Bootstrapped)
+	}
+	if len(prod.ServicesDeployed) != 2 || prod.ServicesDeployed[0] != "longhorn" || prod.ServicesDeployed[1] != "traefik" {
+		t.Errorf("unexpected services: %v", prod.ServicesDeployed)
+	}
+	if prod.LastOperation == nil || prod.LastOperation.ID != "op5" {
+		t.Errorf("unexpected last operation: %+v", prod.LastOperation)
+	}
+
+	dev := all[0]
+	if dev.Bootstrapped || dev.NodeCount != 0 || dev.LastOperation != nil {
+		t.Errorf("unexpected dev summary: %+v", dev)
+	}
+	if storage.FileExists(filepath.Join(m.GetInstancePath("dev"), "operations")) {
+		t.Error("summarizing should not create the operations directory")
+	}
+}
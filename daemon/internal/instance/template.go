@@ -0,0 +1,278 @@
+package instance
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/wild-cloud/wild-central/daemon/internal/config"
+	"github.com/wild-cloud/wild-central/daemon/internal/storage"
+)
+
+// Template describes a named starting point for a new instance
+type Template struct {
+	Name        string                 `yaml:"name" json:"name"`
+	Description string                 `yaml:"description" json:"description"`
+	Config      map[string]interface{} `yaml:"config,omitempty" json:"config,omitempty"`
+	Required    []string               `yaml:"required,omitempty" json:"required,omitempty"` // Config paths that must be set at creation
+	Source      string                 `yaml:"-" json:"source"`                              // File the template was loaded from
+}
+
+// CreateOptions contains options for creating an instance
+type CreateOptions struct {
+	Template *Template         // Optional template to start from
+	Values   map[string]string // Initial config values (dot notation), applied over the template
+
+	Description       string            // Recorded in the instance metadata
+	Labels            map[string]string // Recorded in the instance metadata
+	DirectoryRevision string            // Wild Cloud Directory revision the instance is created from
+}
+
+// GetTemplatesDir returns the data directory location for user-defined instance templates
+func (m *Manager) GetTemplatesDir() string {
+	return filepath.Join(m.dataDir, "templates")
+}
+
+// LoadTemplate reads and parses an instance template file
+func LoadTemplate(path string) (*Template, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading template %s: %w", path, err)
+	}
+
+	var tmpl Template
+	if err := yaml.Unmarshal(data, &tmpl); err != nil {
+		return nil, fmt.Errorf("parsing template %s: %w", path, err)
+	}
+
+	// Use file name as template name when not declared
+	if tmpl.Name == "" {
+		tmpl.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	tmpl.Source = path
+
+	return &tmpl, nil
+}
+
+// ListTemplates returns all templates found in the given directories.
+// Directories are searched in order; the first template with a given name wins.
+func ListTemplates(dirs ...string) ([]Template, error) {
+	seen := make(map[string]bool)
+	templates := []Template{}
+
+	for _, dir := range dirs {
+		if !storage.FileExists(dir) {
+			continue
+		}
+
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return nil, fmt.Errorf("reading templates directory %s: %w", dir, err)
+		}
+
+		for _, entry := range entries {
+			ext := filepath.Ext(entry.Name())
+			if entry.IsDir() || (ext != ".yaml" && ext != ".yml") {
+				continue
+			}
+
+			tmpl, err := LoadTemplate(filepath.Join(dir, entry.Name()))
+			if err != nil {
+				// Skip templates that don't parse
+				continue
+			}
+
+			if seen[tmpl.Name] {
+				continue
+			}
+			seen[tmpl.Name] = true
+			templates = append(templates, *tmpl)
+		}
+	}
+
+	sort.Slice(templates, func(i, j int) bool {
+		return templates[i].Name < templates[j].Name
+	})
+
+	return templates, nil
+}
+
+// FindTemplate returns the named template from the given directories
+func FindTemplate(name string, dirs ...string) (*Template, error) {
+	templates, err := ListTemplates(dirs...)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, tmpl := range templates {
+		if tmpl.Name == name {
+			return &tmpl, nil
+		}
+	}
+
+	return nil, fmt.Errorf("template %s not found", name)
+}
+
+// Values flattens the template config into dot-notation keys
+func (t *Template) Values() map[string]string {
+	values := make(map[string]string)
+	flattenConfig("", t.Config, values)
+	return values
+}
+
+// flattenConfig walks a nested map and records leaf values under dot-notation keys
+func flattenConfig(prefix string, data map[string]interface{}, out map[string]string) {
+	for key, value := range data {
+		path := key
+		if prefix != "" {
+			path = prefix + "." + key
+		}
+
+		if nested, ok := value.(map[string]interface{}); ok {
+			flattenConfig(path, nested, out)
+			continue
+		}
+
+		if value == nil {
+			out[path] = ""
+		} else {
+			out[path] = fmt.Sprintf("%v", value)
+		}
+	}
+}
+
+// ValidateValues checks initial config values against the instance config schema
+// and ensures every required path has a non-empty value
+func ValidateValues(values map[string]string, required []string) error {
+	nested := make(map[string]interface{})
+	for key, value := range values {
+		if err := setNestedValue(nested, key, value); err != nil {
+			return err
+		}
+	}
+
+	if err := config.ValidateInstanceConfigMap(nested); err != nil {
+		return err
+	}
+
+	var missing []string
+	for _, path := range required {
+		if strings.TrimSpace(values[path]) == "" {
+			missing = append(missing, path)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("missing required configuration: %v", missing)
+	}
+
+	return nil
+}
+
+// setNestedValue stores a value in a nested map using dot notation
+func setNestedValue(data map[string]interface{}, path, value string) error {
+	keys := strings.Split(path, ".")
+	current := data
+
+	for i, key := range keys {
+		if key == "" {
+			return fmt.Errorf("invalid config path %q", path)
+		}
+
+		if i == len(keys)-1 {
+			if _, isMap := current[key].(map[string]interface{}); isMap {
+				return fmt.Errorf("config path %q conflicts with a nested value", path)
+			}
+			current[key] = value
+			return nil
+		}
+
+		next, ok := current[key].(map[string]interface{})
+		if !ok {
+			if _, exists := current[key]; exists {
+				return fmt.Errorf("config path %q conflicts with value at %q", path, strings.Join(keys[:i+1], "."))
+			}
+			next = make(map[string]interface{})
+			current[key] = next
+		}
+		current = next
+	}
+
+	return nil
+}
+
+// InitialValues merges template config with explicit values and returns the
+// result along with the config paths the template requires
+func (o CreateOptions) InitialValues() (map[string]string, []string) {
+	// Template values first, explicit values override them
+	values := make(map[string]string)
+	var required []string
+	if o.Template != nil {
+		for key, value := range o.Template.Values() {
+			values[key] = value
+		}
+		required = o.Template.Required
+	}
+	for key, value := range o.Values {
+		values[key] = value
+	}
+
+	return values, required
+}
+
+// CreateInstanceWithOptions creates a new instance and applies template and initial
+// values in one step. Values are validated before anything is written, and the
+// instance is removed again if initialization fails.
+func (m *Manager) CreateInstanceWithOptions(name string, opts CreateOptions) error {
+	if name == "" {
+		return fmt.Errorf("instance name cannot be empty")
+	}
+
+	if m.InstanceExists(name) {
+		return fmt.Errorf("instance %s already exists", name)
+	}
+
+	values, required := opts.InitialValues()
+	if err := ValidateValues(values, required); err != nil {
+		return fmt.Errorf("invalid initial configuration: %w", err)
+	}
+	if err := ValidateLabels(opts.Labels); err != nil {
+		return err
+	}
+
+	if err := m.CreateInstance(name); err != nil {
+		return err
+	}
+
+	// Skip empty values - the skeleton config already has them
+	initialConfig := make(map[string]string)
+	for key, value := range values {
+		if value != "" {
+			initialConfig[key] = value
+		}
+	}
+
+	if err := m.InitializeInstance(name, initialConfig); err != nil {
+		os.RemoveAll(m.GetInstancePath(name))
+		return fmt.Errorf("initializing instance: %w", err)
+	}
+
+	_, err := m.UpdateMetadata(name, func(meta *Metadata) {
+		meta.Description = opts.Description
+		meta.Labels = opts.Labels
+		meta.DirectoryRevision = opts.DirectoryRevision
+		if opts.Template != nil {
+			meta.Template = opts.Template.Name
+		}
+	})
+	if err != nil {
+		os.RemoveAll(m.GetInstancePath(name))
+		return fmt.Errorf("recording metadata: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,183 @@
+package instance
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTemplate(t *testing.T, dir, file, content string) {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("Failed to create template dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, file), []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write template: %v", err)
+	}
+}
+
+func TestListTemplates_Precedence(t *testing.T) {
+	userDir := filepath.Join(t.TempDir(), "user")
+	shippedDir := filepath.Join(t.TempDir(), "shipped")
+
+	writeTemplate(t, shippedDir, "home.yaml", "name: home\ndescription: shipped\n")
+	writeTemplate(t, shippedDir, "ha.yaml", "description: ha cluster\n")
+	writeTemplate(t, userDir, "home.yaml", "name: home\ndescription: user override\n")
+	writeTemplate(t, userDir, "notes.txt", "ignored")
+
+	templates, err := ListTemplates(userDir, shippedDir, filepath.Join(t.TempDir(), "missing"))
+	if err != nil {
+		t.Fatalf("ListTemplates failed: %v", err)
+	}
+
+	if len(templates) != 2 {
+		t.Fatalf("Expected 2 templates, got %d", len(templates))
+	}
+
+	// Sorted by name; name falls back to file name
+	if templates[0].Name != "ha" {
+		t.Errorf("Expected template name 'ha', got %q", templates[0].Name)
+	}
+	if templates[1].Description != "user override" {
+		t.Errorf("Expected user template to take precedence, got %q", templates[1].Description)
+	}
+
+	if _, err := FindTemplate("nonexistent", userDir, shippedDir); err == nil {
+		t.Error("Expected error for missing template")
+	}
+}
+
+func TestTemplate_Values(t *testing.T) {
+	tmpl := &Template{
+		Config: map[string]interface{}{
+			"cluster": map[string]interface{}{
+				"name": "home",
+				"nodes": map[string]interface{}{
+					"talos": map[string]interface{}{"version": "v1.11.0"},
+				},
+			},
+			"operator": map[string]interface{}{"email": nil},
+		},
+	}
+
+	values := tmpl.Values()
+
+	tests := map[string]string{
+		"cluster.name":                "home",
+		"cluster.nodes.talos.version": "v1.11.0",
+		"operator.email":              "",
+	}
+	for key, want := range tests {
+		if got, ok := values[key]; !ok || got != want {
+			t.Errorf("values[%q] = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestValidateValues(t *testing.T) {
+	tests := []struct {
+		name     string
+		values   map[string]string
+		required []string
+		wantErr  bool
+	}{
+		{
+			name:   "valid values",
+			values: map[string]string{"cluster.name": "home", "cloud.dns.ip": "192.168.1.2"},
+		},
+		{
+			name:     "missing required",
+			values:   map[string]string{"cluster.name": "home"},
+			required: []string{"cluster.nodes.control.vip"},
+			wantErr:  true,
+		},
+		{
+			name:    "empty path segment",
+			values:  map[string]string{"cluster..name": "home"},
+			wantErr: true,
+		},
+		{
+			name:    "conflicting paths",
+			values:  map[string]string{"cluster.name": "home", "cluster.name.first": "x"},
+			wantErr: true,
+		},
+		{
+			name:    "schema type mismatch",
+			values:  map[string]string{"cluster.nodes": "three"},
+			wantErr: true,
+		},
+		{
+			name:   "quoted node flag",
+			values: map[string]string{"cluster.nodes.active.control-1.maintenance": "true"},
+		},
+		{
+			name:    "invalid node flag",
+			values:  map[string]string{"cluster.nodes.active.control-1.maintenance": "maybe"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateValues(tt.values, tt.required)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateValues() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestManager_CreateInstanceWithOptions(t *testing.T) {
+	tmpDir := t.TempDir()
+	m := NewManager(tmpDir)
+
+	tmpl := &Template{
+		Name: "ha",
+		Config: map[string]interface{}{
+			"cluster": map[string]interface{}{"name": "from-template"},
+		},
+		Required: []string{"cluster.nodes.control.vip"},
+	}
+
+	// Missing required value must not leave an instance behind
+	err := m.CreateInstanceWithOptions("test-cloud", CreateOptions{Template: tmpl})
+	if err == nil {
+		t.Fatal("Expected error for missing required value")
+	}
+	if m.InstanceExists("test-cloud") {
+		t.Error("Instance should not exist after failed validation")
+	}
+
+	err = m.CreateInstanceWithOptions("test-cloud", CreateOptions{
+		Template: tmpl,
+		Values: map[string]string{
+			"cluster.name":              "override",
+			"cluster.nodes.control.vip": "192.168.1.90",
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreateInstanceWithOptions failed: %v", err)
+	}
+
+	configPath := m.GetInstanceConfigPath("test-cloud")
+	name, err := m.configMgr.GetConfigValue(configPath, "cluster.name")
+	if err != nil {
+		t.Fatalf("Failed to read cluster.name: %v", err)
+	}
+	if name != "override" {
+		t.Errorf("Expected explicit value to override template, got %q", name)
+	}
+
+	vip, err := m.configMgr.GetConfigValue(configPath, "cluster.nodes.control.vip")
+	if err != nil {
+		t.Fatalf("Failed to read vip: %v", err)
+	}
+	if vip != "192.168.1.90" {
+		t.Errorf("Expected vip 192.168.1.90, got %q", vip)
+	}
+
+	// Creating again should fail rather than overwrite
+	if err := m.CreateInstanceWithOptions("test-cloud", CreateOptions{}); err == nil {
+		t.Error("Expected error creating existing instance")
+	}
+}
@@ -0,0 +1,314 @@
+package instance
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/wild-cloud/wild-central/daemon/internal/storage"
+)
+
+// EnvTrashRetention overrides how long deleted instances are kept, as a Go
+// duration ("72h") or a number of days ("30d")
+const EnvTrashRetention = "WILD_CENTRAL_TRASH_RETENTION"
+
+// DefaultTrashRetention is how long deleted instances are kept by default
+const DefaultTrashRetention = 30 * 24 * time.Hour
+
+// confirmationTTL is how long a confirmation token stays valid
+const confirmationTTL = 5 * time.Minute
+
+// TrashEntry describes a deleted instance waiting in the trash
+type TrashEntry struct {
+	ID        string    `yaml:"id" json:"id"`
+	Name      string    `yaml:"name" json:"name"`
+	DeletedAt time.Time `yaml:"deletedAt" json:"deleted_at"`
+	ExpiresAt time.Time `yaml:"expiresAt" json:"expires_at"`
+}
+
+// confirmation is an outstanding confirmation token for a destructive action
+type confirmation struct {
+	token   string
+	expires time.Time
+}
+
+// GetTrashDir returns the directory deleted instances are moved to
+func (m *Manager) GetTrashDir() string {
+	return filepath.Join(m.dataDir, "trash")
+}
+
+// TrashRetention returns how long deleted instances are kept
+func TrashRetention() (time.Duration, error) {
+	value := strings.TrimSpace(os.Getenv(EnvTrashRetention))
+	if value == "" {
+		return DefaultTrashRetention, nil
+	}
+
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid %s %q", EnvTrashRetention, value)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid %s %q", EnvTrashRetention, value)
+	}
+	return d, nil
+}
+
+// trashInstance moves an instance directory into the trash. Must be called
+// with the instances lock held.
+func (m *Manager) trashInstance(name string) (*TrashEntry, error) {
+	retention, err := TrashRetention()
+	if err != nil {
+		return nil, err
+	}
+
+	trashDir := m.GetTrashDir()
+	if err := storage.EnsureDir(trashDir, 0700); err != nil {
+		return nil, fmt.Errorf("creating trash directory: %w", err)
+	}
+
+	now := time.Now().UTC()
+	entry := &TrashEntry{
+		ID:        fmt.Sprintf("%s-%s", name, now.Format("20060102150405")),
+		Name:      name,
+		DeletedAt: now,
+		ExpiresAt: now.Add(retention),
+	}
+	for i := 2; storage.FileExists(filepath.Join(trashDir, entry.ID)); i++ {
+		entry.ID = fmt.Sprintf("%s-%s-%d", name, now.Format("20060102150405"), i)
+	}
+
+	data, err := yaml.Marshal(entry)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling trash entry: %w", err)
+	}
+	if err := storage.WriteFile(m.trashEntryPath(entry.ID), data, 0600); err != nil {
+		return nil, fmt.Errorf("writing trash entry: %w", err)
+	}
+
+	if err := os.Rename(m.GetInstancePath(name), filepath.Join(trashDir, entry.ID)); err != nil {
+		os.Remove(m.trashEntryPath(entry.ID))
+		return nil, fmt.Errorf("moving instance to trash: %w", err)
+	}
+
+	return entry, nil
+}
+
+// trashEntryPath returns the record kept next to a trashed instance
+func (m *Manager) trashEntryPath(id string) string {
+	return filepath.Join(m.GetTrashDir(), id+".yaml")
+}
+
+// ListTrash returns deleted instances, newest first
+func (m *Manager) ListTrash() ([]TrashEntry, error) {
+	entries := []TrashEntry{}
+
+	files, err := os.ReadDir(m.GetTrashDir())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return entries, nil
+		}
+		return nil, fmt.Errorf("reading trash directory: %w", err)
+	}
+
+	for _, file := range files {
+		if file.IsDir() || filepath.Ext(file.Name()) != ".yaml" {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(m.GetTrashDir(), file.Name()))
+		if err != nil {
+			continue
+		}
+		var entry TrashEntry
+		if err := yaml.Unmarshal(data, &entry); err != nil || entry.ID == "" {
+			continue
+		}
+		if !storage.FileExists(filepath.Join(m.GetTrashDir(), entry.ID)) {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].DeletedAt.After(entries[j].DeletedAt)
+	})
+	return entries, nil
+}
+
+// FindTrash returns the trash entry with the given ID, or the most recently
+// deleted instance with that name
+func (m *Manager) FindTrash(idOrName string) (*TrashEntry, error) {
+	entries, err := m.ListTrash()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		if entry.ID == idOrName {
+			return &entry, nil
+		}
+	}
+	for _, entry := range entries {
+		if entry.Name == idOrName {
+			return &entry, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%s not found in trash", idOrName)
+}
+
+// RestoreInstance moves an instance out of the trash. It is restored under
+// its original name unless newName is set. Restoring over an existing
+// instance is refused.
+func (m *Manager) RestoreInstance(idOrName, newName string) (string, error) {
+	entry, err := m.FindTrash(idOrName)
+	if err != nil {
+		return "", err
+	}
+
+	name := entry.Name
+	if newName != "" {
+		name = newName
+	}
+	if err := ValidateInstanceName(name); err != nil {
+		return "", err
+	}
+
+	lockPath := filepath.Join(m.dataDir, "instances", ".lock")
+	err = storage.WithLock(lockPath, func() error {
+		if m.InstanceExists(name) {
+			return fmt.Errorf("instance %s already exists", name)
+		}
+		if err := storage.EnsureDir(filepath.Join(m.dataDir, "instances"), 0755); err != nil {
+			return err
+		}
+		if err := os.Rename(filepath.Join(m.GetTrashDir(), entry.ID), m.GetInstancePath(name)); err != nil {
+			return fmt.Errorf("restoring instance: %w", err)
+		}
+		os.Remove(m.trashEntryPath(entry.ID))
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	// Metadata is informational; a failure here does not undo the restore
+	m.Touch(name, "")
+
+	return name, nil
+}
+
+// PurgeTrash permanently removes an instance from the trash
+func (m *Manager) PurgeTrash(id string) error {
+	if id == "" || strings.ContainsAny(id, `/\`) || id == "." || id == ".." {
+		return fmt.Errorf("invalid trash ID %q", id)
+	}
+	if !storage.FileExists(m.trashEntryPath(id)) {
+		return fmt.Errorf("%s not found in trash", id)
+	}
+
+	if err := os.RemoveAll(filepath.Join(m.GetTrashDir(), id)); err != nil {
+		return fmt.Errorf("removing trashed instance: %w", err)
+	}
+	if err := os.Remove(m.trashEntryPath(id)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("removing trash entry: %w", err)
+	}
+
+	return nil
+}
+
+// PurgeExpiredTrash removes trashed instances past their retention and
+// returns their IDs
+func (m *Manager) PurgeExpiredTrash() ([]string, error) {
+	entries, err := m.ListTrash()
+	if err != nil {
+		return nil, err
+	}
+
+	var purged []string
+	now := time.Now()
+	for _, entry := range entries {
+		if now.Before(entry.ExpiresAt) {
+			continue
+		}
+		if err := m.PurgeTrash(entry.ID); err != nil {
+			return purged, err
+		}
+		purged = append(purged, entry.ID)
+	}
+
+	return purged, nil
+}
+
+// PermanentlyDeleteInstance removes an instance without keeping it in the
+// trash. Its Talos secrets are lost, so an existing cluster can no longer be
+// managed. Callers must obtain confirmation first.
+func (m *Manager) PermanentlyDeleteInstance(name string) error {
+	if name == "" {
+		return fmt.Errorf("instance name cannot be empty")
+	}
+	if !m.InstanceExists(name) {
+		return fmt.Errorf("instance %s does not exist", name)
+	}
+
+	if err := m.clearContextFor(name); err != nil {
+		return err
+	}
+
+	lockPath := filepath.Join(m.dataDir, "instances", ".lock")
+	return storage.WithLock(lockPath, func() error {
+		if err := os.RemoveAll(m.GetInstancePath(name)); err != nil {
+			return fmt.Errorf("removing instance directory: %w", err)
+		}
+		return nil
+	})
+}
+
+// IssueConfirmation returns a single-use token that confirms action (for
+// example "delete:my-cloud") for a few minutes
+func (m *Manager) IssueConfirmation(action string) (string, time.Time, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", time.Time{}, fmt.Errorf("generating confirmation token: %w", err)
+	}
+
+	c := confirmation{
+		token:   hex.EncodeToString(buf),
+		expires: time.Now().Add(confirmationTTL),
+	}
+
+	m.confirmMu.Lock()
+	defer m.confirmMu.Unlock()
+	m.confirmations[action] = c
+
+	return c.token, c.expires, nil
+}
+
+// ConsumeConfirmation reports whether token confirms action. A token is
+// only accepted once.
+func (m *Manager) ConsumeConfirmation(action, token string) bool {
+	m.confirmMu.Lock()
+	defer m.confirmMu.Unlock()
+
+	c, ok := m.confirmations[action]
+	if !ok || token == "" || subtle.ConstantTimeCompare([]byte(c.token), []byte(token)) != 1 {
+		return false
+	}
+	delete(m.confirmations, action)
+	return time.Now().Before(c.expires)
+}
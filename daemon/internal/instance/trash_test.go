@@ -0,0 +1,148 @@
+package instance
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTrash_DeleteAndRestore(t *testing.T) {
+	m := NewManager(t.TempDir())
+	if err := m.CreateInstance("home"); err != nil {
+		t.Fatalf("CreateInstance failed: %v", err)
+	}
+	talosSecrets := filepath.Join(m.GetInstancePath("home"), "talos", "secrets.yaml")
+	if err := os.WriteFile(talosSecrets, []byte("cluster: secret"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	entry, err := m.DeleteInstance("home")
+	if err != nil {
+		t.Fatalf("DeleteInstance failed: %v", err)
+	}
+	if m.InstanceExists("home") {
+		t.Fatal("instance should be gone after delete")
+	}
+	if entry.Name != "home" || !entry.ExpiresAt.After(entry.DeletedAt) {
+		t.Errorf("unexpected trash entry: %+v", entry)
+	}
+
+	entries, err := m.ListTrash()
+	if err != nil {
+		t.Fatalf("ListTrash failed: %v", err)
+	}
+	if len(entries) != 1 || entries[0].ID != entry.ID {
+		t.Fatalf("unexpected trash: %+v", entries)
+	}
+
+	// Restoring over a new instance of the same name is refused
+	if err := m.CreateInstance("home"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.RestoreInstance("home", ""); err == nil {
+		t.Fatal("expected restore over existing instance to fail")
+	}
+
+	// Names that would leave the instances directory are refused
+	for _, bad := range []string{"../escaped", "a/b", `a\b`, "..", "."} {
+		if _, err := m.RestoreInstance("home", bad); err == nil {
+			t.Errorf("restore as %q should be refused", bad)
+		}
+	}
+
+	name, err := m.RestoreInstance("home", "home-old")
+	if err != nil {
+		t.Fatalf("RestoreInstance failed: %v", err)
+	}
+	if name != "home-old" {
+		t.Errorf("restored as %s, want home-old", name)
+	}
+	data, err := os.ReadFile(filepath.Join(m.GetInstancePath("home-old"), "talos", "secrets.yaml"))
+	if err != nil || string(data) != "cluster: secret" {
+		t.Errorf("talos secrets not restored: %q, %v", data, err)
+	}
+
+	entries, _ = m.ListTrash()
+	if len(entries) != 0 {
+		t.Errorf("trash should be empty after restore, got %+v", entries)
+	}
+}
+
+func TestTrash_PurgeExpired(t *testing.T) {
+	m := NewManager(t.TempDir())
+	for _, name := range []string{"old", "new"} {
+		if err := m.CreateInstance(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Setenv(EnvTrashRetention, "720h")
+	if _, err := m.DeleteInstance("new"); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(EnvTrashRetention, "0d")
+	old, err := m.DeleteInstance("old")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Deleting purges expired entries, including one that just expired
+	entries, _ := m.ListTrash()
+	if len(entries) != 1 || entries[0].Name != "new" {
+		t.Errorf("unexpected trash after purge: %+v", entries)
+	}
+	if _, err := os.Stat(filepath.Join(m.GetTrashDir(), old.ID)); !os.IsNotExist(err) {
+		t.Error("purged instance directory still exists")
+	}
+
+	purged, err := m.PurgeExpiredTrash()
+	if err != nil || len(purged) != 0 {
+		t.Errorf("PurgeExpiredTrash = %v, %v; want nothing to purge", purged, err)
+	}
+}
+
+func TestTrashRetention(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"", DefaultTrashRetention, false},
+		{"7d", 7 * 24 * time.Hour, false},
+		{"90m", 90 * time.Minute, false},
+		{"soon", 0, true},
+		{"-1d", 0, true},
+	}
+	for _, tt := range tests {
+		t.Setenv(EnvTrashRetention, tt.value)
+		got, err := TrashRetention()
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("TrashRetention(%q) = %v, %v", tt.value, got, err)
+		}
+	}
+}
+
+func TestConfirmation(t *testing.T) {
+	m := NewManager(t.TempDir())
+
+	token, _, err := m.IssueConfirmation("delete:home")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if m.ConsumeConfirmation("delete:other", token) {
+		t.Error("token accepted for a different action")
+	}
+	if m.ConsumeConfirmation("delete:home", "wrong") {
+		t.Error("wrong token accepted")
+	}
+
+	token, _, _ = m.IssueConfirmation("delete:home")
+	if !m.ConsumeConfirmation("delete:home", token) {
+		t.Error("valid token rejected")
+	}
+	if m.ConsumeConfirmation("delete:home", token) {
+		t.Error("token accepted twice")
+	}
+}
@@ -0,0 +1,296 @@
+// Package migrations versions the layout of the data directory and upgrades
+// older layouts when the daemon starts.
+//
+// The layout version is kept in <data dir>/schema-version. A data directory
+// without the file predates versioning and is treated as version 0, unless
+// it holds no instances yet, in which case it is simply stamped with the
+// current version. Migrations apply to trashed instances too, so restoring
+// one brings back the current layout. Before migrating, the instance files
+// are archived under
+// <data dir>/migration-backups. Migrations run in order, and the version is
+// recorded after each one, so an interrupted upgrade resumes where it
+// stopped. Every migration must be safe to run again.
+package migrations
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wild-cloud/wild-central/daemon/internal/storage"
+)
+
+// Migration upgrades the data directory from Version-1 to Version
+type Migration struct {
+	Version     int
+	Description string
+	Apply       func(dataDir string) error
+}
+
+// migrations lists every migration in order. Append only; never renumber.
+var migrations = []Migration{
+	{1, "copy talosconfig from talos/generated to setup/cluster-nodes/generated", copyTalosconfigs},
+	{2, "move cluster.nodes.activeNodes entries into cluster.nodes.active", mergeActiveNodes},
+}
+
+// CurrentVersion is the data directory layout this daemon uses
+var CurrentVersion = migrations[len(migrations)-1].Version
+
+// Manager runs data directory migrations
+type Manager struct {
+	dataDir    string
+	migrations []Migration
+	current    int
+}
+
+// NewManager creates a new migrations manager
+func NewManager(dataDir string) *Manager {
+	return &Manager{
+		dataDir:    dataDir,
+		migrations: migrations,
+		current:    CurrentVersion,
+	}
+}
+
+// Result describes what Run did
+type Result struct {
+	From       int      `json:"from"`
+	To         int      `json:"to"`
+	Applied    []string `json:"applied"`
+	BackupPath string   `json:"backup_path,omitempty"`
+}
+
+// GetVersionPath returns the file holding the data directory version
+func (m *Manager) GetVersionPath() string {
+	return filepath.Join(m.dataDir, "schema-version")
+}
+
+// GetBackupDir returns the directory pre-migration archives are kept in
+func (m *Manager) GetBackupDir() string {
+	return filepath.Join(m.dataDir, "migration-backups")
+}
+
+// Version returns the data directory's layout version and whether it was
+// recorded. An unrecorded version is 0.
+func (m *Manager) Version() (int, bool, error) {
+	data, err := os.ReadFile(m.GetVersionPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, false, nil
+		}
+		return 0, false, fmt.Errorf("reading schema version: %w", err)
+	}
+
+	version, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || version < 0 {
+		return 0, false, fmt.Errorf("invalid schema version %q in %s", strings.TrimSpace(string(data)), m.GetVersionPath())
+	}
+	return version, true, nil
+}
+
+func (m *Manager) setVersion(version int) error {
+	return storage.WriteFile(m.GetVersionPath(), []byte(strconv.Itoa(version)+"\n"), 0644)
+}
+
+// Run brings the data directory up to the current version. It refuses a
+// data directory written by a newer daemon.
+func (m *Manager) Run() (*Result, error) {
+	version, recorded, err := m.Version()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{From: version, To: version, Applied: []string{}}
+
+	if version > m.current {
+		return nil, fmt.Errorf("data directory %s has schema version %d, newer than this daemon supports (%d); upgrade the daemon", m.dataDir, version, m.current)
+	}
+	if version == m.current {
+		return result, nil
+	}
+
+	if err := storage.EnsureDir(m.dataDir, 0755); err != nil {
+		return nil, err
+	}
+
+	if !recorded && !m.hasInstances() {
+		// Nothing to migrate in a new data directory
+		if err := m.setVersion(m.current); err != nil {
+			return nil, err
+		}
+		result.To = m.current
+		return result, nil
+	}
+
+	backupPath, err := m.backup(version)
+	if err != nil {
+		return nil, fmt.Errorf("backing up before migration: %w", err)
+	}
+	result.BackupPath = backupPath
+
+	for _, migration := range m.migrations {
+		if migration.Version <= version {
+			continue
+		}
+		log.Printf("Migrating data directory to version %d: %s", migration.Version, migration.Description)
+		if err := migration.Apply(m.dataDir); err != nil {
+			return result, fmt.Errorf("migration to version %d (%s) failed, backup at %s: %w",
+				migration.Version, migration.Description, backupPath, err)
+		}
+		if err := m.setVersion(migration.Version); err != nil {
+			return result, err
+		}
+		result.To = migration.Version
+		result.Applied = append(result.Applied, migration.Description)
+	}
+
+	return result, nil
+}
+
+// hasInstances reports whether any instance exists, in use or trashed
+func (m *Manager) hasInstances() bool {
+	dirs, _ := InstanceDirs(m.dataDir)
+	return len(dirs) > 0
+}
+
+// instanceParents lists the data directory entries that hold instance
+// directories: the instances in use and the trashed ones
+var instanceParents = []string{"instances", "trash"}
+
+// InstanceDirs lists the paths of every instance directory, in use or
+// trashed. Trash records sit next to the trashed directories as files and
+// are not listed.
+func InstanceDirs(dataDir string) ([]string, error) {
+	var dirs []string
+	for _, parent := range instanceParents {
+		entries, err := os.ReadDir(filepath.Join(dataDir, parent))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, fmt.Errorf("reading %s directory: %w", parent, err)
+		}
+
+		for _, entry := range entries {
+			if entry.IsDir() && !strings.HasPrefix(entry.Name(), ".") {
+				dirs = append(dirs, filepath.Join(dataDir, parent, entry.Name()))
+			}
+		}
+	}
+	return dirs, nil
+}
+
+// backupSkip lists data directory entries left out of migration backups:
+// downloaded assets and earlier backups. App backup staging inside each
+// instance is skipped as well.
+var backupSkip = map[string]bool{
+	"assets":            true,
+	"migration-backups": true,
+}
+
+// backup archives the data directory's state, without bulky downloads and
+// app backups, to a private tar.gz and returns its path
+func (m *Manager) backup(version int) (string, error) {
+	if err := storage.EnsureDir(m.GetBackupDir(), 0700); err != nil {
+		return "", err
+	}
+
+	name := fmt.Sprintf("schema-v%d-%s.tar.gz", version, time.Now().UTC().Format("20060102150405"))
+	path := filepath.Join(m.GetBackupDir(), name)
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return "", fmt.Errorf("creating backup %s: %w", path, err)
+	}
+
+	if err := m.writeArchive(f); err != nil {
+		f.Close()
+		os.Remove(path)
+		return "", err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(path)
+		return "", fmt.Errorf("syncing backup %s: %w", path, err)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(path)
+		return "", fmt.Errorf("closing backup %s: %w", path, err)
+	}
+
+	return path, nil
+}
+
+func (m *Manager) writeArchive(w io.Writer) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	err := filepath.WalkDir(m.dataDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(m.dataDir, path)
+		if err != nil || rel == "." {
+			return err
+		}
+
+		parts := strings.Split(rel, string(filepath.Separator))
+		if backupSkip[parts[0]] ||
+			(len(parts) == 3 && (parts[0] == "instances" || parts[0] == "trash") && parts[2] == "backups") {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if strings.HasSuffix(entry.Name(), ".lock") {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() && !info.IsDir() {
+			return nil // Sockets, symlinks and the like are not state
+		}
+
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(rel)
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		src, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+		_, err = io.Copy(tw, src)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("archiving data directory: %w", err)
+	}
+
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("archiving data directory: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("archiving data directory: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,200 @@
+package migrations
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func archivedNames(t *testing.T, path string) map[string]bool {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make(map[string]bool)
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		names[header.Name] = true
+	}
+	return names
+}
+
+func TestRun_NewDataDir(t *testing.T) {
+	m := NewManager(t.TempDir())
+
+	result, err := m.Run()
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if result.To != CurrentVersion || len(result.Applied) != 0 || result.BackupPath != "" {
+		t.Errorf("unexpected result for a new data dir: %+v", result)
+	}
+	if version, recorded, _ := m.Version(); !recorded || version != CurrentVersion {
+		t.Errorf("version = %d (recorded %v), want %d", version, recorded, CurrentVersion)
+	}
+}
+
+func TestRun_LegacyDataDir(t *testing.T) {
+	dataDir := t.TempDir()
+	instancePath := filepath.Join(dataDir, "instances", "home")
+	writeFile(t, filepath.Join(instancePath, "talos", "generated", "talosconfig"), "context: home\n")
+	writeFile(t, filepath.Join(instancePath, "config.yaml"), `cluster:
+  name: home
+  nodes:
+    # Nodes in the cluster
+    activeNodes:
+      - control-1:
+          role: controlplane
+          currentIp: 192.168.8.31
+`)
+	writeFile(t, filepath.Join(dataDir, "assets", "kernel"), "large download")
+
+	// A trashed instance is migrated too, so restoring it brings back the new layout
+	trashedPath := filepath.Join(dataDir, "trash", "old-20260101000000")
+	writeFile(t, filepath.Join(dataDir, "trash", "old-20260101000000.yaml"), "id: old-20260101000000\nname: old\n")
+	writeFile(t, filepath.Join(trashedPath, "config.yaml"), "cluster:\n  nodes:\n    activeNodes:\n      - worker-1:\n          role: worker\n")
+
+	m := NewManager(dataDir)
+	result, err := m.Run()
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if result.From != 0 || result.To != CurrentVersion || len(result.Applied) != CurrentVersion {
+		t.Errorf("unexpected result: %+v", result)
+	}
+
+	names := archivedNames(t, result.BackupPath)
+	if !names["instances/home/config.yaml"] {
+		t.Errorf("config.yaml missing from backup: %v", names)
+	}
+	if names["assets/kernel"] {
+		t.Error("assets should not be backed up")
+	}
+
+	used := filepath.Join(instancePath, "setup", "cluster-nodes", "generated", "talosconfig")
+	if data, err := os.ReadFile(used); err != nil || string(data) != "context: home\n" {
+		t.Errorf("talosconfig not copied: %q, %v", data, err)
+	}
+
+	config, _ := os.ReadFile(filepath.Join(instancePath, "config.yaml"))
+	if strings.Contains(string(config), "activeNodes") || !strings.Contains(string(config), "control-1:") {
+		t.Errorf("activeNodes not merged:\n%s", config)
+	}
+	config, _ = os.ReadFile(filepath.Join(trashedPath, "config.yaml"))
+	if strings.Contains(string(config), "activeNodes") || !strings.Contains(string(config), "worker-1:") {
+		t.Errorf("activeNodes not merged in the trashed instance:\n%s", config)
+	}
+	if !names["trash/old-20260101000000/config.yaml"] {
+		t.Errorf("trashed config.yaml missing from backup: %v", names)
+	}
+
+	// Already current: nothing to do
+	result, err = m.Run()
+	if err != nil || len(result.Applied) != 0 || result.BackupPath != "" {
+		t.Errorf("second Run = %+v, %v; want no-op", result, err)
+	}
+}
+
+func TestRun_RefusesNewerDataDir(t *testing.T) {
+	m := NewManager(t.TempDir())
+	os.WriteFile(m.GetVersionPath(), []byte("999\n"), 0644)
+
+	if _, err := m.Run(); err == nil || !strings.Contains(err.Error(), "newer") {
+		t.Errorf("expected refusal of a newer data dir, got %v", err)
+	}
+}
+
+func TestRun_ResumesAfterFailure(t *testing.T) {
+	dataDir := t.TempDir()
+	writeFile(t, filepath.Join(dataDir, "instances", "home", "config.yaml"), "cluster: {}\n")
+
+	var ran []int
+	fail := true
+	m := NewManager(dataDir)
+	m.migrations = []Migration{
+		{1, "first", func(string) error { ran = append(ran, 1); return nil }},
+		{2, "second", func(string) error {
+			ran = append(ran, 2)
+			if fail {
+				return errors.New("disk full")
+			}
+			return nil
+		}},
+	}
+	m.current = 2
+
+	if _, err := m.Run(); err == nil {
+		t.Fatal("expected the failing migration to stop Run")
+	}
+	if version, _, _ := m.Version(); version != 1 {
+		t.Errorf("version after failure = %d, want 1", version)
+	}
+
+	fail = false
+	if _, err := m.Run(); err != nil {
+		t.Fatalf("resumed Run failed: %v", err)
+	}
+	if len(ran) != 3 || ran[2] != 2 {
+		t.Errorf("migrations ran %v, want [1 2 2]", ran)
+	}
+}
+
+func TestMergeActiveNodesYAML(t *testing.T) {
+	input := `cluster:
+  nodes:
+    active:
+      control-1:
+        role: controlplane
+        currentIp: 192.168.8.31
+    activeNodes:
+      - control-1:
+          role: worker
+      - worker-1:
+          role: worker
+`
+	out, changed, err := mergeActiveNodesYAML([]byte(input))
+	if err != nil || !changed {
+		t.Fatalf("mergeActiveNodesYAML = %v, %v", changed, err)
+	}
+
+	got := string(out)
+	if strings.Contains(got, "activeNodes") {
+		t.Errorf("activeNodes not removed:\n%s", got)
+	}
+	if !strings.Contains(got, "worker-1:") || strings.Count(got, "role: worker") != 1 {
+		t.Errorf("existing node overwritten or new node missing:\n%s", got)
+	}
+
+	// Idempotent
+	if _, changed, _ := mergeActiveNodesYAML(out); changed {
+		t.Error("second merge changed the config")
+	}
+}
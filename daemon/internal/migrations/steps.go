@@ -0,0 +1,153 @@
+package migrations
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/wild-cloud/wild-central/daemon/internal/storage"
+)
+
+// copyTalosconfigs makes sure the talosconfig that talosctl is run with
+// (setup/cluster-nodes/generated) exists wherever cluster config generation
+// only wrote it to talos/generated. The generated copy is left in place.
+func copyTalosconfigs(dataDir string) error {
+	dirs, err := InstanceDirs(dataDir)
+	if err != nil {
+		return err
+	}
+
+	for _, instancePath := range dirs {
+		generated := filepath.Join(instancePath, "talos", "generated", "talosconfig")
+		used := filepath.Join(instancePath, "setup", "cluster-nodes", "generated", "talosconfig")
+
+		if storage.FileExists(used) || !storage.FileExists(generated) {
+			continue
+		}
+
+		data, err := os.ReadFile(generated)
+		if err != nil {
+			return fmt.Errorf("reading %s: %w", generated, err)
+		}
+		if err := storage.EnsureDir(filepath.Dir(used), 0755); err != nil {
+			return err
+		}
+		if err := storage.WriteFile(used, data, 0600); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// mergeActiveNodes moves nodes listed under cluster.nodes.activeNodes, the
+// list written by older instance templates, into the cluster.nodes.active
+// map the node manager uses. Nodes already in the map are kept as they are.
+func mergeActiveNodes(dataDir string) error {
+	dirs, err := InstanceDirs(dataDir)
+	if err != nil {
+		return err
+	}
+
+	for _, instancePath := range dirs {
+		configPath := filepath.Join(instancePath, "config.yaml")
+		data, err := os.ReadFile(configPath)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return fmt.Errorf("reading %s: %w", configPath, err)
+		}
+
+		updated, changed, err := mergeActiveNodesYAML(data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", configPath, err)
+		}
+		if !changed {
+			continue
+		}
+		if err := storage.WriteFile(configPath, updated, 0644); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// mergeActiveNodesYAML rewrites one config, keeping comments and key order
+func mergeActiveNodesYAML(data []byte) ([]byte, bool, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, false, fmt.Errorf("parsing config: %w", err)
+	}
+	if len(doc.Content) == 0 {
+		return data, false, nil
+	}
+
+	nodes := mappingValue(mappingValue(doc.Content[0], "cluster"), "nodes")
+	if nodes == nil {
+		return data, false, nil
+	}
+
+	listIndex := -1
+	for i := 0; i+1 < len(nodes.Content); i += 2 {
+		if nodes.Content[i].Value == "activeNodes" {
+			listIndex = i
+			break
+		}
+	}
+	if listIndex < 0 {
+		return data, false, nil
+	}
+	list := nodes.Content[listIndex+1]
+
+	active := mappingValue(nodes, "active")
+	if active == nil {
+		active = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		nodes.Content = append(nodes.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "active"}, active)
+	}
+
+	if list.Kind == yaml.SequenceNode {
+		for _, item := range list.Content {
+			if item.Kind != yaml.MappingNode {
+				return nil, false, fmt.Errorf("cluster.nodes.activeNodes entries must be maps of hostname to node")
+			}
+			for i := 0; i+1 < len(item.Content); i += 2 {
+				if mappingValue(active, item.Content[i].Value) != nil {
+					continue
+				}
+				active.Content = append(active.Content, item.Content[i], item.Content[i+1])
+			}
+		}
+	}
+
+	nodes.Content = append(nodes.Content[:listIndex], nodes.Content[listIndex+2:]...)
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return nil, false, fmt.Errorf("encoding config: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return nil, false, fmt.Errorf("encoding config: %w", err)
+	}
+	return buf.Bytes(), true, nil
+}
+
+// mappingValue returns the value of key in a mapping node, or nil
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
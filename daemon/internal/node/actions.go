@@ -0,0 +1,315 @@
+package node
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+
+	"github.com/wild-cloud/wild-central/daemon/internal/operations"
+	"github.com/wild-cloud/wild-central/daemon/internal/tools"
+)
+
+// Node lifecycle actions
+const (
+	ActionReboot   = "reboot"
+	ActionShutdown = "shutdown"
+	ActionReset    = "reset"
+	ActionDrain    = "drain"
+	ActionUncordon = "uncordon"
+)
+
+// actions maps each lifecycle action to whether it is destructive. A node
+// that is shut down needs someone to power it on again, and a reset node
+// loses its configuration and data.
+var actions = map[string]bool{
+	ActionReboot:   false,
+	ActionShutdown: true,
+	ActionReset:    true,
+	ActionDrain:    false,
+	ActionUncordon: false,
+}
+
+// actionDone describes a node once each action has finished
+var actionDone = map[string]string{
+	ActionReboot:   "rebooted",
+	ActionShutdown: "shut down",
+	ActionReset:    "reset; apply its configuration again to rejoin the cluster",
+	ActionDrain:    "drained",
+	ActionUncordon: "uncordoned",
+}
+
+// ActionOptions adjusts the safety checks of a lifecycle action
+type ActionOptions struct {
+	Force bool `json:"force,omitempty"` // Skip the quorum and capacity checks, accepting the downtime; needs confirmation
+}
+
+// IsAction reports whether action is a known lifecycle action
+func IsAction(action string) bool {
+	_, ok := actions[action]
+	return ok
+}
+
+// IsDestructiveAction reports whether action needs confirmation
+func IsDestructiveAction(action string) bool {
+	return actions[action]
+}
+
+// IsDisruptiveAction reports whether action takes capacity away from the
+// cluster while it runs: the node goes down or stops accepting workloads.
+// The checks of a disruptive action assume no other one is in progress.
+func IsDisruptiveAction(action string) bool {
+	return takesNodeDown(action) || action == ActionDrain
+}
+
+// GetOperationsLockPath returns the lock held while a node operation is
+// checked and started, so concurrent requests are checked one at a time
+func (m *Manager) GetOperationsLockPath(instanceName string) string {
+	return filepath.Join(m.GetInstancePath(instanceName), "node-operations.lock")
+}
+
+// takesNodeDown reports whether the node stops serving while the action runs
+func takesNodeDown(action string) bool {
+	return action == ActionReboot || action == ActionShutdown || action == ActionReset
+}
+
+// CheckAction checks that an action can run on a node: the node runs an
+// applied configuration, taking it down keeps etcd quorum, and draining or
+// stopping it leaves a node to schedule workloads on. Force skips the
+// last two. The checks see the cluster as it is, so callers run them under
+// GetOperationsLockPath with no other disruptive operation in progress.
+func (m *Manager) CheckAction(instanceName, hostname, action string, opts ActionOptions) (*Node, error) {
+	if !IsAction(action) {
+		return nil, fmt.Errorf("unknown action %q", action)
+	}
+
+	c, err := m.loadConfig(instanceName)
+	if err != nil {
+		return nil, err
+	}
+	node, ok := c.node(hostname)
+	if !ok {
+		return nil, fmt.Errorf("node %s not found", hostname)
+	}
+	c.applyDefaults(node)
+
+	if !node.Applied || node.Maintenance {
+		return nil, fmt.Errorf("node %s is not running an applied configuration", hostname)
+	}
+	if opts.Force {
+		return node, nil
+	}
+
+	if node.Role == "controlplane" && takesNodeDown(action) {
+		others, err := checkQuorum(c, node, action)
+		if err != nil {
+			return nil, err
+		}
+		if err := m.etcdHealthy(instanceName, others); err != nil {
+			return nil, fmt.Errorf("etcd is not healthy on the other control planes, so taking %s down could lose quorum: %w", hostname, err)
+		}
+	}
+
+	if takesNodeDown(action) || action == ActionDrain {
+		schedulable, err := m.schedulableNodes(instanceName)
+		if err != nil {
+			return nil, fmt.Errorf("cannot check which nodes accept workloads: %w", err)
+		}
+		if err := checkCapacity(hostname, action, schedulable); err != nil {
+			return nil, err
+		}
+	}
+
+	return node, nil
+}
+
+// checkQuorum checks that etcd keeps quorum while a control plane is down,
+// from the control planes config.yaml says are running. It returns the
+// addresses of the other members.
+func checkQuorum(c *nodeConfig, node *Node, action string) ([]string, error) {
+	var others []string
+	for hostname := range c.Cluster.Nodes.Active {
+		other, _ := c.node(hostname)
+		if hostname != node.Hostname && other.Role == "controlplane" && other.Applied && !other.Maintenance {
+			others = append(others, other.TargetIP)
+		}
+	}
+	sort.Strings(others)
+
+	members := len(others) + 1
+	if action == ActionReset {
+		// A graceful reset leaves etcd first, so the rest only need to be a
+		// quorum among themselves
+		if len(others) == 0 {
+			return nil, fmt.Errorf("%s is the only control plane; resetting it destroys the cluster's etcd", node.Hostname)
+		}
+		return others, nil
+	}
+	if quorum := members/2 + 1; len(others) < quorum {
+		return nil, fmt.Errorf("etcd has %d member(s) and needs %d for quorum, so taking %s down stops the control plane", members, quorum, node.Hostname)
+	}
+	return others, nil
+}
+
+// checkCapacity checks that some other node still accepts workloads once
+// the action takes hostname out
+func checkCapacity(hostname, action string, schedulable []string) error {
+	found := false
+	for _, name := range schedulable {
+		if name == hostname {
+			found = true
+			break
+		}
+	}
+	// A node that takes no workloads now can go without changing that
+	if found && len(schedulable) == 1 {
+		return fmt.Errorf("%s is the only node accepting workloads; %s would leave none", hostname, action)
+	}
+	return nil
+}
+
+// schedulableNodes returns the Ready nodes that accept workloads: not
+// cordoned and without a NoSchedule or NoExecute taint
+func (m *Manager) schedulableNodes(instanceName string) ([]string, error) {
+	output, err := m.kubectlOutput(instanceName, "get", "nodes", "-o", "json")
+	if err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		Items []struct {
+			Metadata struct {
+				Name string `json:"name"`
+			} `json:"metadata"`
+			Spec struct {
+				Unschedulable bool `json:"unschedulable"`
+				Taints        []struct {
+					Effect string `json:"effect"`
+				} `json:"taints"`
+			} `json:"spec"`
+			Status struct {
+				Conditions []struct {
+					Type   string `json:"type"`
+					Status string `json:"status"`
+				} `json:"conditions"`
+			} `json:"status"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(output, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse nodes: %w", err)
+	}
+
+	var names []string
+	for _, item := range result.Items {
+		if item.Spec.Unschedulable {
+			continue
+		}
+		tainted := false
+		for _, taint := range item.Spec.Taints {
+			if taint.Effect == "NoSchedule" || taint.Effect == "NoExecute" {
+				tainted = true
+			}
+		}
+		ready := false
+		for _, cond := range item.Status.Conditions {
+			if cond.Type == "Ready" && cond.Status == "True" {
+				ready = true
+			}
+		}
+		if ready && !tainted {
+			names = append(names, item.Metadata.Name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// RunAction runs a lifecycle action on a node, streaming the output of the
+// talosctl or kubectl commands doing it. Callers check it with CheckAction
+// first. A reboot waits for the node to be Ready again. A reset leaves the
+// node in maintenance mode, so it is marked as such and needs applying
+// again.
+func (m *Manager) RunAction(instanceName, hostname, action string, out *operations.Output) error {
+	c, err := m.loadConfig(instanceName)
+	if err != nil {
+		return err
+	}
+	node, ok := c.node(hostname)
+	if !ok {
+		return fmt.Errorf("node %s not found", hostname)
+	}
+
+	talosctl := func(args ...string) error {
+		cmd := exec.Command("talosctl", append(args, "--nodes", node.TargetIP)...)
+		tools.WithTalosconfig(cmd, tools.GetTalosconfigPath(m.dataDir, instanceName))
+		return out.Run(cmd)
+	}
+	kubectl := func(args ...string) error {
+		kubeconfigPath := tools.GetKubeconfigPath(m.dataDir, instanceName)
+		return out.Run(exec.Command("kubectl", append([]string{"--kubeconfig", kubeconfigPath}, args...)...))
+	}
+
+	switch action {
+	case ActionReboot:
+		out.Printf("🔄 Rebooting %s (%s)\n", hostname, node.TargetIP)
+		if err := talosctl("reboot"); err != nil {
+			return fmt.Errorf("rebooting %s: %w", hostname, err)
+		}
+		out.Printf("⏳ Waiting for %s to be Ready\n", hostname)
+		if err := waitFor(hostname+" to be Ready", func() error {
+			return m.nodeReady(instanceName, hostname)
+		}); err != nil {
+			return err
+		}
+
+	case ActionShutdown:
+		out.Printf("⏻  Shutting down %s (%s)\n", hostname, node.TargetIP)
+		if err := talosctl("shutdown"); err != nil {
+			return fmt.Errorf("shutting down %s: %w", hostname, err)
+		}
+
+	case ActionReset:
+		out.Printf("🧹 Resetting %s (%s)\n", hostname, node.TargetIP)
+		if err := talosctl("reset", "--graceful", "--reboot"); err != nil {
+			return fmt.Errorf("resetting %s: %w", hostname, err)
+		}
+		err := m.updateConfig(instanceName, func(c *nodeConfig) error {
+			node, ok := c.node(hostname)
+			if !ok {
+				return fmt.Errorf("node %s not found", hostname)
+			}
+			node.Applied = false
+			node.Maintenance = true
+			c.Cluster.Nodes.Active[hostname] = *node
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("%s was reset, but recording it failed: %w", hostname, err)
+		}
+		// The node no longer runs the configuration applied to it
+		if err := os.Remove(m.getAppliedConfigPath(instanceName, hostname)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove applied configuration: %w", err)
+		}
+
+	case ActionDrain:
+		out.Printf("🚰 Draining %s\n", hostname)
+		if err := kubectl("drain", hostname, "--ignore-daemonsets", "--delete-emptydir-data",
+			"--timeout", waitTimeout.String()); err != nil {
+			return fmt.Errorf("draining %s: %w", hostname, err)
+		}
+
+	case ActionUncordon:
+		out.Printf("▶️  Uncordoning %s\n", hostname)
+		if err := kubectl("uncordon", hostname); err != nil {
+			return fmt.Errorf("uncordoning %s: %w", hostname, err)
+		}
+
+	default:
+		return fmt.Errorf("unknown action %q", action)
+	}
+
+	out.Printf("✅ %s %s\n", hostname, actionDone[action])
+	return nil
+}
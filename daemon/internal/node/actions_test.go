@@ -0,0 +1,77 @@
+package node
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestCheckQuorum(t *testing.T) {
+	controlPlanes := func(n int) *nodeConfig {
+		nodes := map[string]Node{
+			"worker-1": {Role: "worker", TargetIP: "192.168.8.41", Applied: true},
+			// Not running yet, so not an etcd member
+			"control-9": {Role: "controlplane", TargetIP: "192.168.8.39", Maintenance: true},
+		}
+		for i := 1; i <= n; i++ {
+			nodes[fmt.Sprintf("control-%d", i)] = Node{Role: "controlplane", TargetIP: fmt.Sprintf("192.168.8.3%d", i), Applied: true}
+		}
+		return testNodeConfig(nodes)
+	}
+
+	tests := []struct {
+		controlPlanes int
+		action        string
+		ok            bool
+	}{
+		{1, ActionReboot, false},
+		{1, ActionReset, false},
+		{2, ActionReboot, false},
+		{2, ActionShutdown, false},
+		{2, ActionReset, true}, // The other member alone is a quorum once it has left
+		{3, ActionReboot, true},
+		{3, ActionShutdown, true},
+	}
+	for _, tt := range tests {
+		c := controlPlanes(tt.controlPlanes)
+		node, _ := c.node("control-1")
+		others, err := checkQuorum(c, node, tt.action)
+		if (err == nil) != tt.ok {
+			t.Errorf("%d control planes, %s: err = %v, want ok %v", tt.controlPlanes, tt.action, err, tt.ok)
+		}
+		if err == nil && len(others) != tt.controlPlanes-1 {
+			t.Errorf("%d control planes, %s: others = %v", tt.controlPlanes, tt.action, others)
+		}
+	}
+}
+
+func TestCheckCapacity(t *testing.T) {
+	tests := []struct {
+		hostname    string
+		schedulable []string
+		ok          bool
+	}{
+		{"worker-1", []string{"worker-1", "worker-2"}, true},
+		{"worker-1", []string{"worker-1"}, false},
+		{"worker-2", []string{"worker-1"}, true}, // Cordoned already
+		{"control-1", nil, true},
+	}
+	for _, tt := range tests {
+		if err := checkCapacity(tt.hostname, ActionDrain, tt.schedulable); (err == nil) != tt.ok {
+			t.Errorf("drain %s with %v schedulable: err = %v, want ok %v", tt.hostname, tt.schedulable, err, tt.ok)
+		}
+	}
+}
+
+func TestCheckAction_NotRunning(t *testing.T) {
+	m := newTestManager(t, testConfig)
+
+	// control-1 is in maintenance and worker-1 was never applied
+	for _, hostname := range []string{"control-1", "worker-1"} {
+		if _, err := m.CheckAction("home", hostname, ActionReboot, ActionOptions{Force: true}); err == nil {
+			t.Errorf("expected %s to be refused", hostname)
+		}
+	}
+	if _, err := m.CheckAction("home", "worker-1", "explode", ActionOptions{}); err == nil {
+		t.Error("expected an unknown action to be refused")
+	}
+}
@@ -0,0 +1,100 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/wild-cloud/wild-central/daemon/internal/tools"
+)
+
+// talosctlTimeout bounds quick talosctl queries to a node, so an unreachable
+// node does not hold up the caller
+const talosctlTimeout = 15 * time.Second
+
+// Waiting for nodes to come back after an upgrade or reboot
+var (
+	waitTimeout  = 10 * time.Minute
+	pollInterval = 5 * time.Second
+)
+
+// talosctlOutput runs a talosctl command against a node, giving up after
+// talosctlTimeout
+func talosctlOutput(talosconfigPath string, args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), talosctlTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "talosctl", args...)
+	tools.WithTalosconfig(cmd, talosconfigPath)
+	return commandOutput(cmd)
+}
+
+// kubectlOutput runs kubectl against an instance's cluster
+func (m *Manager) kubectlOutput(instanceName string, args ...string) ([]byte, error) {
+	kubeconfigPath := tools.GetKubeconfigPath(m.dataDir, instanceName)
+
+	ctx, cancel := context.WithTimeout(context.Background(), talosctlTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "kubectl", append([]string{"--kubeconfig", kubeconfigPath}, args...)...)
+	return commandOutput(cmd)
+}
+
+// commandOutput runs cmd and returns its stdout, or an error carrying its
+// stderr
+func commandOutput(cmd *exec.Cmd) ([]byte, error) {
+	name := cmd.Args[0]
+	if len(cmd.Args) > 1 {
+		name += " " + cmd.Args[1]
+	}
+
+	output, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+			return nil, fmt.Errorf("%s: %s", name, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return output, nil
+}
+
+// nodeReady checks that Kubernetes reports the node Ready
+func (m *Manager) nodeReady(instanceName, hostname string) error {
+	output, err := m.kubectlOutput(instanceName, "get", "node", hostname,
+		"-o", `jsonpath={.status.conditions[?(@.type=="Ready")].status}`)
+	if err != nil {
+		return err
+	}
+	if status := strings.TrimSpace(string(output)); status != "True" {
+		return fmt.Errorf("node %s is not Ready (%s)", hostname, status)
+	}
+	return nil
+}
+
+// etcdHealthy checks that every given control plane answers as a healthy
+// etcd member
+func (m *Manager) etcdHealthy(instanceName string, controlPlaneIPs []string) error {
+	if len(controlPlaneIPs) == 0 {
+		return nil
+	}
+	talosconfigPath := tools.GetTalosconfigPath(m.dataDir, instanceName)
+	_, err := talosctlOutput(talosconfigPath, "etcd", "status", "--nodes", strings.Join(controlPlaneIPs, ","))
+	return err
+}
+
+// waitFor polls check until it succeeds or waitTimeout passes
+func waitFor(what string, check func() error) error {
+	deadline := time.Now().Add(waitTimeout)
+	for {
+		err := check()
+		if err == nil {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out after %s waiting for %s: %w", waitTimeout, what, err)
+		}
+		time.Sleep(pollInterval)
+	}
+}
@@ -0,0 +1,457 @@
+package node
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/wild-cloud/wild-central/daemon/internal/storage"
+)
+
+// nodeConfig is the part of an instance's config.yaml the node manager uses.
+// It is read with a single parse instead of one yq call per field.
+type nodeConfig struct {
+	Cluster struct {
+		Nodes struct {
+			Talos struct {
+				Version     string `yaml:"version"`
+				SchematicID string `yaml:"schematicId"`
+			} `yaml:"talos"`
+			Active map[string]Node `yaml:"active"`
+		} `yaml:"nodes"`
+	} `yaml:"cluster"`
+}
+
+// node returns the node registered under hostname, with Hostname filled in
+func (c *nodeConfig) node(hostname string) (*Node, bool) {
+	node, ok := c.Cluster.Nodes.Active[hostname]
+	if !ok {
+		return nil, false
+	}
+	node.Hostname = hostname
+	return &node, true
+}
+
+// applyDefaults fills in the instance-level Talos version and schematic ID
+// where the node does not set its own
+func (c *nodeConfig) applyDefaults(node *Node) {
+	if node.Version == "" {
+		node.Version = c.Cluster.Nodes.Talos.Version
+	}
+	if node.SchematicID == "" {
+		node.SchematicID = c.Cluster.Nodes.Talos.SchematicID
+	}
+}
+
+// nodeFieldOrder is the order known fields are written in. The hostname is
+// the key of the entry and is not repeated inside it.
+var nodeFieldOrder = []string{
+	"role", "targetIp", "currentIp", "interface", "disk", "storage",
+	"version", "schematicId", "maintenance", "configured", "applied",
+	"labels", "taints", "annotations",
+}
+
+// UnmarshalYAML reads a cluster.nodes.active entry. Flags written as quoted
+// strings by older yq-based writes ("true") are accepted, and keys the
+// manager does not know are kept in Extra.
+func (n *Node) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode && value.Tag == "!!null" {
+		return nil
+	}
+	if value.Kind != yaml.MappingNode {
+		return fmt.Errorf("line %d: node entry must be a map", value.Line)
+	}
+
+	for i := 0; i+1 < len(value.Content); i += 2 {
+		key, val := value.Content[i].Value, value.Content[i+1]
+
+		var err error
+		switch key {
+		case "role":
+			err = val.Decode(&n.Role)
+		case "targetIp":
+			err = val.Decode(&n.TargetIP)
+		case "currentIp":
+			err = val.Decode(&n.CurrentIP)
+		case "interface":
+			err = val.Decode(&n.Interface)
+		case "disk":
+			err = val.Decode(&n.Disk)
+		case "version":
+			err = val.Decode(&n.Version)
+		case "schematicId":
+			err = val.Decode(&n.SchematicID)
+		case "maintenance":
+			n.Maintenance, err = decodeFlag(val)
+		case "configured":
+			n.Configured, err = decodeFlag(val)
+		case "applied":
+			n.Applied, err = decodeFlag(val)
+		case "labels":
+			err = val.Decode(&n.Labels)
+		case "taints":
+			err = val.Decode(&n.Taints)
+		case "annotations":
+			err = val.Decode(&n.Annotations)
+		case "storage":
+			err = val.Decode(&n.Storage)
+		default:
+			var extra interface{}
+			if err = val.Decode(&extra); err == nil {
+				if n.Extra == nil {
+					n.Extra = make(map[string]interface{})
+				}
+				n.Extra[key] = extra
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("line %d: %s: %w", val.Line, key, err)
+		}
+	}
+
+	return nil
+}
+
+// MarshalYAML writes a cluster.nodes.active entry: known fields in a fixed
+// order, empty optional ones left out, then Extra sorted by key
+func (n Node) MarshalYAML() (interface{}, error) {
+	fields := map[string]interface{}{
+		"role":     n.Role,
+		"targetIp": n.TargetIP,
+		"disk":     n.Disk,
+	}
+	for key, value := range map[string]string{
+		"currentIp":   n.CurrentIP,
+		"interface":   n.Interface,
+		"version":     n.Version,
+		"schematicId": n.SchematicID,
+	} {
+		if value != "" {
+			fields[key] = value
+		}
+	}
+	for key, value := range map[string]bool{
+		"maintenance": n.Maintenance,
+		"configured":  n.Configured,
+		"applied":     n.Applied,
+	} {
+		if value {
+			fields[key] = true
+		}
+	}
+	for key, value := range map[string]map[string]string{
+		"labels":      n.Labels,
+		"taints":      n.Taints,
+		"annotations": n.Annotations,
+	} {
+		if len(value) > 0 {
+			fields[key] = value
+		}
+	}
+	if len(n.Storage) > 0 {
+		fields["storage"] = n.Storage
+	}
+
+	out := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	add := func(key string, value interface{}) error {
+		var v yaml.Node
+		if err := v.Encode(value); err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		out.Content = append(out.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, &v)
+		return nil
+	}
+
+	for _, key := range nodeFieldOrder {
+		if value, ok := fields[key]; ok {
+			if err := add(key, value); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	extraKeys := make([]string, 0, len(n.Extra))
+	for key := range n.Extra {
+		if !isNodeField(key) {
+			extraKeys = append(extraKeys, key)
+		}
+	}
+	sort.Strings(extraKeys)
+	for _, key := range extraKeys {
+		if err := add(key, n.Extra[key]); err != nil {
+			return nil, err
+		}
+	}
+
+	return out, nil
+}
+
+func isNodeField(key string) bool {
+	for _, field := range nodeFieldOrder {
+		if field == key {
+			return true
+		}
+	}
+	return false
+}
+
+// decodeFlag reads a boolean that may have been written as a string
+func decodeFlag(value *yaml.Node) (bool, error) {
+	if value.Kind != yaml.ScalarNode {
+		return false, fmt.Errorf("expected true or false")
+	}
+	if value.Tag == "!!null" || value.Value == "" {
+		return false, nil
+	}
+	return strconv.ParseBool(value.Value)
+}
+
+// getConfigPath returns the path to an instance's config.yaml
+func (m *Manager) getConfigPath(instanceName string) string {
+	return filepath.Join(m.GetInstancePath(instanceName), "config.yaml")
+}
+
+// loadConfig parses the node configuration of an instance
+func (m *Manager) loadConfig(instanceName string) (*nodeConfig, error) {
+	configPath := m.getConfigPath(instanceName)
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read nodes: %w", err)
+	}
+
+	var c nodeConfig
+	if err := yaml.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", configPath, err)
+	}
+	return &c, nil
+}
+
+// updateConfig runs fn on the instance's node configuration under the
+// config.yaml lock and writes back the nodes fn left in cluster.nodes.active,
+// and the Talos defaults if fn changed them. Only the keys fn changed are
+// rewritten; everything else in config.yaml is left as it was.
+func (m *Manager) updateConfig(instanceName string, fn func(c *nodeConfig) error) error {
+	configPath := m.getConfigPath(instanceName)
+
+	return storage.WithLock(configPath+".lock", func() error {
+		data, err := os.ReadFile(configPath)
+		if err != nil {
+			return fmt.Errorf("failed to read nodes: %w", err)
+		}
+
+		var doc yaml.Node
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return fmt.Errorf("failed to parse %s: %w", configPath, err)
+		}
+		if len(doc.Content) == 0 {
+			doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}}
+		}
+
+		var c nodeConfig
+		if err := doc.Decode(&c); err != nil {
+			return fmt.Errorf("failed to parse %s: %w", configPath, err)
+		}
+		if c.Cluster.Nodes.Active == nil {
+			c.Cluster.Nodes.Active = make(map[string]Node)
+		}
+
+		talos := c.Cluster.Nodes.Talos
+		if err := fn(&c); err != nil {
+			return err
+		}
+
+		nodes := ensureMapping(ensureMapping(doc.Content[0], "cluster"), "nodes")
+		if c.Cluster.Nodes.Talos != talos {
+			defaults := ensureMapping(nodes, "talos")
+			setScalar(defaults, "version", c.Cluster.Nodes.Talos.Version)
+			setScalar(defaults, "schematicId", c.Cluster.Nodes.Talos.SchematicID)
+		}
+		if err := mergeNodes(ensureMapping(nodes, "active"), c.Cluster.Nodes.Active); err != nil {
+			return fmt.Errorf("failed to update nodes: %w", err)
+		}
+
+		var buf bytes.Buffer
+		enc := yaml.NewEncoder(&buf)
+		enc.SetIndent(2)
+		if err := enc.Encode(&doc); err != nil {
+			return fmt.Errorf("failed to encode %s: %w", configPath, err)
+		}
+		if err := enc.Close(); err != nil {
+			return fmt.Errorf("failed to encode %s: %w", configPath, err)
+		}
+
+		return storage.WriteFile(configPath, buf.Bytes(), 0644)
+	})
+}
+
+// mergeNodes makes the active mapping hold exactly nodes, in place
+func mergeNodes(active *yaml.Node, nodes map[string]Node) error {
+	kept := active.Content[:0]
+	seen := make(map[string]bool, len(nodes))
+	for i := 0; i+1 < len(active.Content); i += 2 {
+		hostname := active.Content[i].Value
+		node, ok := nodes[hostname]
+		if !ok {
+			continue // Deleted
+		}
+		seen[hostname] = true
+		if err := mergeEntry(active.Content[i+1], node); err != nil {
+			return fmt.Errorf("%s: %w", hostname, err)
+		}
+		kept = append(kept, active.Content[i], active.Content[i+1])
+	}
+	active.Content = kept
+
+	added := make([]string, 0, len(nodes))
+	for hostname := range nodes {
+		if !seen[hostname] {
+			added = append(added, hostname)
+		}
+	}
+	sort.Strings(added)
+	for _, hostname := range added {
+		var entry yaml.Node
+		if err := entry.Encode(nodes[hostname]); err != nil {
+			return fmt.Errorf("%s: %w", hostname, err)
+		}
+		active.Content = append(active.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: hostname}, &entry)
+	}
+
+	if len(active.Content) > 0 {
+		active.Style = 0 // An empty "active: {}" becomes a block mapping
+	}
+	return nil
+}
+
+// mergeEntry updates an existing node entry to hold node. Only the keys whose
+// value changed are rewritten, added or, once empty, removed; the others keep
+// their exact form, comments included, so untouched entries do not churn.
+func mergeEntry(entry *yaml.Node, node Node) error {
+	var want yaml.Node
+	if err := want.Encode(node); err != nil {
+		return err
+	}
+	var old Node
+	if entry.Kind != yaml.MappingNode || entry.Decode(&old) != nil {
+		*entry = want
+		return nil
+	}
+
+	oldValues, newValues := old.fieldValues(), node.fieldValues()
+	changed := func(key string) bool {
+		return !sameField(oldValues[key], newValues[key])
+	}
+
+	wanted := make(map[string]*yaml.Node, len(want.Content)/2)
+	for i := 0; i+1 < len(want.Content); i += 2 {
+		wanted[want.Content[i].Value] = want.Content[i+1]
+	}
+
+	kept := entry.Content[:0]
+	for i := 0; i+1 < len(entry.Content); i += 2 {
+		key := entry.Content[i].Value
+		if changed(key) {
+			value, ok := wanted[key]
+			if !ok {
+				continue // Cleared
+			}
+			value.HeadComment = entry.Content[i+1].HeadComment
+			value.LineComment = entry.Content[i+1].LineComment
+			entry.Content[i+1] = value
+		}
+		delete(wanted, key)
+		kept = append(kept, entry.Content[i], entry.Content[i+1])
+	}
+	entry.Content = kept
+
+	for i := 0; i+1 < len(want.Content); i += 2 {
+		key := want.Content[i].Value
+		if _, ok := wanted[key]; ok && changed(key) {
+			entry.Content = append(entry.Content, want.Content[i], want.Content[i+1])
+		}
+	}
+	return nil
+}
+
+// fieldValues returns every field of n by its config.yaml key, empty ones
+// included, followed by Extra
+func (n Node) fieldValues() map[string]interface{} {
+	values := map[string]interface{}{
+		"role":        n.Role,
+		"targetIp":    n.TargetIP,
+		"currentIp":   n.CurrentIP,
+		"interface":   n.Interface,
+		"disk":        n.Disk,
+		"storage":     n.Storage,
+		"version":     n.Version,
+		"schematicId": n.SchematicID,
+		"maintenance": n.Maintenance,
+		"configured":  n.Configured,
+		"applied":     n.Applied,
+		"labels":      n.Labels,
+		"taints":      n.Taints,
+		"annotations": n.Annotations,
+	}
+	for key, value := range n.Extra {
+		if !isNodeField(key) {
+			values[key] = value
+		}
+	}
+	return values
+}
+
+// sameField reports whether two field values are equal. Nil and empty maps
+// are the same, as neither is written.
+func sameField(a, b interface{}) bool {
+	av, bv := reflect.ValueOf(a), reflect.ValueOf(b)
+	if av.Kind() == reflect.Map && bv.Kind() == reflect.Map && av.Len() == 0 && bv.Len() == 0 {
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// ensureMapping returns the mapping under key, adding it (or replacing a
+// null) when missing
+func ensureMapping(parent *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(parent.Content); i += 2 {
+		if parent.Content[i].Value == key {
+			value := parent.Content[i+1]
+			if value.Kind != yaml.MappingNode {
+				*value = yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			}
+			return value
+		}
+	}
+
+	value := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	parent.Content = append(parent.Content,
+		&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, value)
+	return value
+}
+
+// setScalar sets a string value in a mapping node, keeping the key's place
+// and comments when it exists
+func setScalar(mapping *yaml.Node, key, value string) {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			existing := mapping.Content[i+1]
+			if existing.Kind == yaml.ScalarNode && existing.Value == value {
+				return
+			}
+			*existing = yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value,
+				HeadComment: existing.HeadComment, LineComment: existing.LineComment}
+			return
+		}
+	}
+	mapping.Content = append(mapping.Content,
+		&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key},
+		&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value})
+}
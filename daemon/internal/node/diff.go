@@ -0,0 +1,302 @@
+package node
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// diffContext is the number of unchanged lines shown around each change
+const diffContext = 3
+
+type diffLine struct {
+	op   byte // ' ', '-' or '+'
+	text string
+}
+
+// unifiedDiff returns a unified diff from a to b, or "" when they are equal
+func unifiedDiff(fromName, toName string, a, b []byte) string {
+	edits := diffLines(splitLines(a), splitLines(b))
+
+	var changes []int
+	for i, e := range edits {
+		if e.op != ' ' {
+			changes = append(changes, i)
+		}
+	}
+	if len(changes) == 0 {
+		return ""
+	}
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", fromName, toName)
+
+	for i := 0; i < len(changes); {
+		// Extend the hunk while the next change is close enough to share context
+		j := i
+		for j+1 < len(changes) && changes[j+1]-changes[j] <= 2*diffContext+1 {
+			j++
+		}
+		start := changes[i] - diffContext
+		if start < 0 {
+			start = 0
+		}
+		end := changes[j] + diffContext + 1
+		if end > len(edits) {
+			end = len(edits)
+		}
+
+		fromLine, toLine := 1, 1
+		for _, e := range edits[:start] {
+			if e.op != '+' {
+				fromLine++
+			}
+			if e.op != '-' {
+				toLine++
+			}
+		}
+		fromCount, toCount := 0, 0
+		for _, e := range edits[start:end] {
+			if e.op != '+' {
+				fromCount++
+			}
+			if e.op != '-' {
+				toCount++
+			}
+		}
+		if fromCount == 0 {
+			fromLine--
+		}
+		if toCount == 0 {
+			toLine--
+		}
+
+		fmt.Fprintf(&out, "@@ -%d,%d +%d,%d @@\n", fromLine, fromCount, toLine, toCount)
+		for _, e := range edits[start:end] {
+			out.WriteByte(e.op)
+			out.WriteString(e.text)
+			out.WriteByte('\n')
+		}
+		i = j + 1
+	}
+
+	return out.String()
+}
+
+func splitLines(data []byte) []string {
+	text := strings.TrimSuffix(string(data), "\n")
+	if text == "" {
+		return nil
+	}
+	return strings.Split(text, "\n")
+}
+
+// diffLines returns the edit script turning a into b, from a longest
+// common subsequence. Machine configs are a few hundred lines, so the
+// quadratic table is small.
+func diffLines(a, b []string) []diffLine {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var edits []diffLine
+	for _, line := range a[:prefix] {
+		edits = append(edits, diffLine{' ', line})
+	}
+
+	x, y := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	n, m := len(x), len(y)
+	lcs := make([][]int32, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int32, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < n && j < m {
+		switch {
+		case x[i] == y[j]:
+			edits = append(edits, diffLine{' ', x[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			edits = append(edits, diffLine{'-', x[i]})
+			i++
+		default:
+			edits = append(edits, diffLine{'+', y[j]})
+			j++
+		}
+	}
+	for ; i < n; i++ {
+		edits = append(edits, diffLine{'-', x[i]})
+	}
+	for ; j < m; j++ {
+		edits = append(edits, diffLine{'+', y[j]})
+	}
+
+	for _, line := range a[len(a)-suffix:] {
+		edits = append(edits, diffLine{' ', line})
+	}
+	return edits
+}
+
+// changedPaths returns the dotted paths of the values that differ between
+// two machine configs. Lists are compared whole. Multi-document configs are
+// compared document by document, the first being the machine config itself.
+func changedPaths(a, b []byte) ([]string, error) {
+	docsA, err := decodeDocuments(a)
+	if err != nil {
+		return nil, err
+	}
+	docsB, err := decodeDocuments(b)
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for i := 0; i < len(docsA) || i < len(docsB); i++ {
+		var va, vb interface{}
+		if i < len(docsA) {
+			va = docsA[i]
+		}
+		if i < len(docsB) {
+			vb = docsB[i]
+		}
+		prefix := ""
+		if i > 0 {
+			prefix = fmt.Sprintf("document[%d]", i)
+		}
+		paths = appendChangedPaths(paths, prefix, va, vb)
+	}
+
+	sort.Strings(paths)
+	return paths, nil
+}
+
+func decodeDocuments(data []byte) ([]interface{}, error) {
+	var docs []interface{}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var doc interface{}
+		if err := dec.Decode(&doc); err != nil {
+			if errors.Is(err, io.EOF) {
+				return docs, nil
+			}
+			return nil, fmt.Errorf("parsing machine config: %w", err)
+		}
+		docs = append(docs, doc)
+	}
+}
+
+func appendChangedPaths(paths []string, prefix string, a, b interface{}) []string {
+	mapA, okA := a.(map[string]interface{})
+	mapB, okB := b.(map[string]interface{})
+	if !okA || !okB {
+		if !reflect.DeepEqual(a, b) {
+			paths = append(paths, prefix)
+		}
+		return paths
+	}
+
+	for key, value := range mapA {
+		paths = appendChangedPaths(paths, joinPath(prefix, key), value, mapB[key])
+	}
+	for key := range mapB {
+		if _, ok := mapA[key]; !ok {
+			paths = append(paths, joinPath(prefix, key))
+		}
+	}
+	return paths
+}
+
+func joinPath(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
+
+// redactedValue replaces secret values in diffs returned to clients
+const redactedValue = "<redacted>"
+
+// secretKeys are machine config keys whose values are secrets: CA and
+// service account keys, machine and bootstrap tokens, and the like. Keys
+// ending in Secret or Token (secretboxEncryptionSecret, bootstrapToken) are
+// secrets too.
+var secretKeys = map[string]bool{
+	"key":        true,
+	"token":      true,
+	"privateKey": true,
+	"password":   true,
+	"secret":     true,
+}
+
+func isSecretKey(key string) bool {
+	return secretKeys[key] || strings.HasSuffix(key, "Secret") || strings.HasSuffix(key, "Token")
+}
+
+// redactSecrets returns a machine config with the values of secret keys
+// replaced by redactedValue, so it can be shown in a diff. Both sides of a
+// diff must be redacted, as the config is re-encoded.
+func redactSecrets(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(4)
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var doc yaml.Node
+		if err := dec.Decode(&doc); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("parsing machine config: %w", err)
+		}
+		redactNode(&doc)
+		if err := enc.Encode(&doc); err != nil {
+			return nil, fmt.Errorf("encoding machine config: %w", err)
+		}
+	}
+	if err := enc.Close(); err != nil {
+		return nil, fmt.Errorf("encoding machine config: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func redactNode(node *yaml.Node) {
+	if node.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			value := node.Content[i+1]
+			if isSecretKey(node.Content[i].Value) && value.Kind == yaml.ScalarNode && value.Value != "" {
+				*value = yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: redactedValue, LineComment: value.LineComment}
+				continue
+			}
+			redactNode(value)
+		}
+		return
+	}
+	for _, child := range node.Content {
+		redactNode(child)
+	}
+}
@@ -0,0 +1,131 @@
+package node
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const appliedConfig = `version: v1alpha1
+machine:
+  type: worker
+  install:
+    disk: /dev/sda
+    image: factory.talos.dev/installer/abc:v1.10.3
+  network:
+    hostname: worker-1
+  kubelet:
+    image: ghcr.io/siderolabs/kubelet:v1.33.1
+cluster:
+  clusterName: home
+`
+
+func TestUnifiedDiff(t *testing.T) {
+	rendered := strings.Replace(appliedConfig, "disk: /dev/sda", "disk: /dev/nvme0n1", 1)
+
+	want := `--- applied
++++ rendered
+@@ -2,7 +2,7 @@
+ machine:
+   type: worker
+   install:
+-    disk: /dev/sda
++    disk: /dev/nvme0n1
+     image: factory.talos.dev/installer/abc:v1.10.3
+   network:
+     hostname: worker-1
+`
+	if got := unifiedDiff("applied", "rendered", []byte(appliedConfig), []byte(rendered)); got != want {
+		t.Errorf("unexpected diff:\n%s", got)
+	}
+
+	if got := unifiedDiff("applied", "rendered", []byte(appliedConfig), []byte(appliedConfig)); got != "" {
+		t.Errorf("expected no diff for equal configs, got:\n%s", got)
+	}
+}
+
+func TestUnifiedDiff_SeparateHunks(t *testing.T) {
+	a := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\n"
+	b := "A\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk\nL\nm\n"
+
+	got := unifiedDiff("a", "b", []byte(a), []byte(b))
+	if strings.Count(got, "@@ -") != 2 ||
+		!strings.Contains(got, "@@ -1,4 +1,4 @@\n-a\n+A\n") ||
+		!strings.Contains(got, "@@ -9,4 +9,5 @@\n i\n j\n k\n-l\n+L\n+m\n") {
+		t.Errorf("unexpected hunks:\n%s", got)
+	}
+}
+
+func TestChangedPaths(t *testing.T) {
+	rendered := strings.Replace(appliedConfig, "disk: /dev/sda", "disk: /dev/nvme0n1", 1)
+	rendered = strings.Replace(rendered, "  network:\n    hostname: worker-1\n", "  network:\n    hostname: worker-1\n    nameservers: [192.168.8.1]\n", 1)
+
+	paths, err := changedPaths([]byte(appliedConfig), []byte(rendered))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"machine.install.disk", "machine.network.nameservers"}
+	if !reflect.DeepEqual(paths, want) {
+		t.Errorf("changedPaths = %v, want %v", paths, want)
+	}
+}
+
+func TestNewConfigDiff_RedactsSecrets(t *testing.T) {
+	const live = `version: v1alpha1
+machine:
+  type: controlplane
+  token: abcdef.0123456789abcdef
+  ca:
+    crt: LS0tLS1CRUdJTi1DRVJU
+    key: LS0tLS1CRUdJTi1LRVk=
+  install:
+    disk: /dev/sda
+cluster:
+  secretboxEncryptionSecret: c2VjcmV0Ym94
+  etcd:
+    ca:
+      key: ZXRjZC1rZXk=
+`
+	rendered := strings.Replace(live, "disk: /dev/sda", "disk: /dev/nvme0n1", 1)
+	rendered = strings.Replace(rendered, "c2VjcmV0Ym94", "cm90YXRlZA==", 1)
+
+	diff, err := newConfigDiff("node 192.168.8.31", []byte(live), []byte(rendered))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"abcdef.0123456789abcdef", "LS0tLS1CRUdJTi1LRVk=", "c2VjcmV0Ym94", "cm90YXRlZA==", "ZXRjZC1rZXk="} {
+		if strings.Contains(diff.Diff, secret) {
+			t.Errorf("diff contains secret %q:\n%s", secret, diff.Diff)
+		}
+	}
+	if !strings.Contains(diff.Diff, "+        disk: /dev/nvme0n1") {
+		t.Errorf("diff lost the real change:\n%s", diff.Diff)
+	}
+	want := []string{"cluster.secretboxEncryptionSecret", "machine.install.disk"}
+	if !reflect.DeepEqual(diff.ChangedPaths, want) {
+		t.Errorf("ChangedPaths = %v, want %v", diff.ChangedPaths, want)
+	}
+}
+
+func TestRebootNeeded(t *testing.T) {
+	tests := []struct {
+		name   string
+		result DryRunResult
+		want   bool
+	}{
+		{"maintenance", DryRunResult{MaintenanceMode: true}, true},
+		{"no baseline", DryRunResult{}, true},
+		{"install disk", DryRunResult{LastApplied: &ConfigDiff{ChangedPaths: []string{"machine.install.disk"}}}, true},
+		{"network only", DryRunResult{LastApplied: &ConfigDiff{ChangedPaths: []string{"machine.network.nameservers"}}}, false},
+		{"live wins", DryRunResult{
+			LastApplied: &ConfigDiff{ChangedPaths: []string{"machine.kernel.args"}},
+			Live:        &ConfigDiff{ChangedPaths: []string{}},
+		}, false},
+	}
+
+	for _, tt := range tests {
+		if got, reason := rebootNeeded(&tt.result); got != tt.want {
+			t.Errorf("%s: rebootNeeded = %v (%s), want %v", tt.name, got, reason, tt.want)
+		}
+	}
+}
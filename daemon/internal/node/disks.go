@@ -0,0 +1,205 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+const (
+	gib = 1 << 30
+
+	// minInstallDiskSize is the smallest disk Talos is installed on
+	minInstallDiskSize = 16 * gib
+	// preferredInstallDiskSize is what an install disk should have to hold
+	// images, logs and ephemeral volumes comfortably
+	preferredInstallDiskSize = 64 * gib
+)
+
+// Disk is a block device reported by talosctl get disks
+type Disk struct {
+	Path       string `yaml:"path" json:"path"`
+	Size       int64  `yaml:"size" json:"size"`
+	Model      string `yaml:"model,omitempty" json:"model,omitempty"`
+	Serial     string `yaml:"serial,omitempty" json:"serial,omitempty"`
+	Transport  string `yaml:"transport,omitempty" json:"transport,omitempty"` // nvme, sata, usb, virtio, mmc...
+	Rotational bool   `yaml:"rotational" json:"rotational"`
+	ReadOnly   bool   `yaml:"readonly,omitempty" json:"readonly,omitempty"`
+	CDROM      bool   `yaml:"cdrom,omitempty" json:"cdrom,omitempty"`
+	Type       string `yaml:"type" json:"type"`                                 // NVMe, SSD, HDD, MMC or virtual
+	Unsuitable string `yaml:"unsuitable,omitempty" json:"unsuitable,omitempty"` // Why Talos should not be installed on it
+}
+
+// listDisks returns the disks of a node in maintenance mode
+func listDisks(nodeIP string) ([]Disk, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), talosctlTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "talosctl", "get", "disks", "--insecure", "--nodes", nodeIP, "-o", "json")
+	output, err := commandOutput(cmd)
+	if err != nil {
+		return nil, err
+	}
+	return parseDisks(output)
+}
+
+// parseDisks reads talosctl get disks -o json output
+func parseDisks(output []byte) ([]Disk, error) {
+	var disks []Disk
+	err := decodeResources(output, func(_ string, raw json.RawMessage) error {
+		var spec struct {
+			DevPath    string `json:"dev_path"`
+			Size       int64  `json:"size"`
+			Model      string `json:"model"`
+			Serial     string `json:"serial"`
+			Transport  string `json:"transport"`
+			Rotational bool   `json:"rotational"`
+			ReadOnly   bool   `json:"readonly"`
+			CDROM      bool   `json:"cdrom"`
+		}
+		if err := json.Unmarshal(raw, &spec); err != nil {
+			return err
+		}
+		if spec.DevPath == "" {
+			return nil
+		}
+
+		disk := Disk{
+			Path:       spec.DevPath,
+			Size:       spec.Size,
+			Model:      strings.TrimSpace(spec.Model),
+			Serial:     strings.TrimSpace(spec.Serial),
+			Transport:  spec.Transport,
+			Rotational: spec.Rotational,
+			ReadOnly:   spec.ReadOnly,
+			CDROM:      spec.CDROM,
+		}
+		disk.Type = diskType(disk)
+		disk.Unsuitable = unsuitableReason(disk)
+		disks = append(disks, disk)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse disks: %w", err)
+	}
+
+	sort.Slice(disks, func(i, j int) bool { return disks[i].Path < disks[j].Path })
+	return disks, nil
+}
+
+// diskType classifies a disk by how fast it is likely to be
+func diskType(disk Disk) string {
+	switch {
+	case disk.Transport == "nvme" || strings.HasPrefix(disk.Path, "/dev/nvme"):
+		return "NVMe"
+	case disk.Transport == "mmc" || strings.HasPrefix(disk.Path, "/dev/mmcblk"):
+		return "MMC"
+	case disk.Transport == "virtio" || strings.HasPrefix(disk.Path, "/dev/vd"):
+		return "virtual"
+	case disk.Rotational:
+		return "HDD"
+	}
+	return "SSD"
+}
+
+// diskTypeRank orders disk types from the best install target down
+var diskTypeRank = map[string]int{"NVMe": 0, "SSD": 1, "virtual": 1, "MMC": 2, "HDD": 3}
+
+// unsuitableReason returns why Talos should not be installed on a disk, or
+// "" when it can be
+func unsuitableReason(disk Disk) string {
+	name := strings.TrimPrefix(disk.Path, "/dev/")
+	switch {
+	case disk.CDROM || strings.HasPrefix(name, "sr"):
+		return "CD-ROM"
+	case disk.ReadOnly:
+		return "read-only"
+	case strings.HasPrefix(name, "loop"), strings.HasPrefix(name, "zram"), strings.HasPrefix(name, "ram"):
+		return "not a physical disk"
+	case strings.HasPrefix(name, "mmcblk") && strings.Contains(name, "boot"):
+		return "MMC boot partition"
+	case disk.Transport == "usb":
+		// Usually the installer or a removable drive, and slow
+		return "USB disk"
+	case disk.Size < minInstallDiskSize:
+		return fmt.Sprintf("smaller than %s", formatSize(minInstallDiskSize))
+	}
+	return ""
+}
+
+// selectInstallDisk picks the disk to install Talos on and says why. It
+// skips unsuitable disks and prefers, in order: disks of at least
+// preferredInstallDiskSize, faster types, then the smallest, leaving larger
+// disks for data.
+func selectInstallDisk(disks []Disk) (string, string) {
+	var candidates []Disk
+	for _, disk := range disks {
+		if disk.Unsuitable == "" {
+			candidates = append(candidates, disk)
+		}
+	}
+	if len(candidates) == 0 {
+		if len(disks) == 0 {
+			return "", "no disks found"
+		}
+		return "", "no suitable disk: " + describeUnsuitable(disks)
+	}
+
+	roomy := func(d Disk) bool { return d.Size >= preferredInstallDiskSize }
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if roomy(a) != roomy(b) {
+			return roomy(a)
+		}
+		if diskTypeRank[a.Type] != diskTypeRank[b.Type] {
+			return diskTypeRank[a.Type] < diskTypeRank[b.Type]
+		}
+		return a.Size < b.Size
+	})
+
+	chosen := candidates[0]
+	reason := fmt.Sprintf("%s %s disk", formatSize(chosen.Size), chosen.Type)
+	if chosen.Model != "" {
+		reason += " (" + chosen.Model + ")"
+	}
+	if roomy(chosen) {
+		reason += fmt.Sprintf(", the fastest type with at least %s", formatSize(preferredInstallDiskSize))
+	} else {
+		reason += fmt.Sprintf(", the fastest type available; no suitable disk has %s", formatSize(preferredInstallDiskSize))
+	}
+	for _, other := range candidates[1:] {
+		if other.Type == chosen.Type && roomy(other) == roomy(chosen) {
+			reason += ", and the smallest of those, leaving larger disks for data"
+			break
+		}
+	}
+	if skipped := describeUnsuitable(disks); skipped != "" {
+		reason += "; skipped " + skipped
+	}
+	return chosen.Path, reason
+}
+
+// describeUnsuitable lists the unsuitable disks with their reasons
+func describeUnsuitable(disks []Disk) string {
+	var parts []string
+	for _, disk := range disks {
+		if disk.Unsuitable != "" {
+			parts = append(parts, fmt.Sprintf("%s (%s)", disk.Path, disk.Unsuitable))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// formatSize formats a byte count in GiB, or TiB from 1024 GiB
+func formatSize(size int64) string {
+	if size >= 1024*gib {
+		return fmt.Sprintf("%.1f TiB", float64(size)/(1024*gib))
+	}
+	if size%gib == 0 {
+		return fmt.Sprintf("%d GiB", size/gib)
+	}
+	return fmt.Sprintf("%.1f GiB", float64(size)/gib)
+}
@@ -0,0 +1,120 @@
+package node
+
+import (
+	"strings"
+	"testing"
+)
+
+// talosctl get disks -o json prints one resource after another
+const testDisksOutput = `{
+    "node": "",
+    "metadata": {"namespace": "runtime", "type": "Disks.block.talos.dev", "id": "nvme0n1"},
+    "spec": {"dev_path": "/dev/nvme0n1", "size": 512110190592, "model": "Samsung SSD 980 PRO 500GB", "serial": "S5GXNX0T ", "transport": "nvme", "rotational": false, "readonly": false, "cdrom": false}
+}
+{
+    "node": "",
+    "metadata": {"namespace": "runtime", "type": "Disks.block.talos.dev", "id": "sda"},
+    "spec": {"dev_path": "/dev/sda", "size": 2000398934016, "model": "ST2000DM008", "transport": "sata", "rotational": true}
+}
+{
+    "node": "",
+    "metadata": {"namespace": "runtime", "type": "Disks.block.talos.dev", "id": "sdb"},
+    "spec": {"dev_path": "/dev/sdb", "size": 32010928128, "model": "Ultra Fit", "transport": "usb", "rotational": false}
+}
+{
+    "node": "",
+    "metadata": {"namespace": "runtime", "type": "Disks.block.talos.dev", "id": "loop0"},
+    "spec": {"dev_path": "/dev/loop0", "size": 73728000, "readonly": true}
+}
+`
+
+func TestParseDisks(t *testing.T) {
+	disks, err := parseDisks([]byte(testDisksOutput))
+	if err != nil {
+		t.Fatalf("parseDisks failed: %v", err)
+	}
+	if len(disks) != 4 {
+		t.Fatalf("got %d disks, want 4: %+v", len(disks), disks)
+	}
+
+	want := map[string]struct{ kind, unsuitable string }{
+		"/dev/loop0":   {"SSD", "read-only"},
+		"/dev/nvme0n1": {"NVMe", ""},
+		"/dev/sda":     {"HDD", ""},
+		"/dev/sdb":     {"SSD", "USB disk"},
+	}
+	for _, disk := range disks {
+		if w := want[disk.Path]; disk.Type != w.kind || disk.Unsuitable != w.unsuitable {
+			t.Errorf("%s: type %q, unsuitable %q; want %q, %q", disk.Path, disk.Type, disk.Unsuitable, w.kind, w.unsuitable)
+		}
+	}
+	if disks[1].Serial != "S5GXNX0T" || disks[1].Model != "Samsung SSD 980 PRO 500GB" {
+		t.Errorf("unexpected nvme0n1: %+v", disks[1])
+	}
+
+	if _, err := parseDisks([]byte("{not json")); err == nil {
+		t.Error("expected an error for invalid output")
+	}
+}
+
+func TestSelectInstallDisk(t *testing.T) {
+	disk := func(path, kind string, sizeGiB int64, unsuitable string) Disk {
+		return Disk{Path: path, Type: kind, Size: sizeGiB * gib, Unsuitable: unsuitable}
+	}
+
+	tests := []struct {
+		name   string
+		disks  []Disk
+		want   string
+		reason []string // Substrings of the reason
+	}{
+		{
+			name:   "NVMe over larger HDD, USB skipped",
+			disks:  []Disk{disk("/dev/sda", "HDD", 2000, ""), disk("/dev/nvme0n1", "NVMe", 500, ""), disk("/dev/sdb", "SSD", 32, "USB disk")},
+			want:   "/dev/nvme0n1",
+			reason: []string{"500 GiB NVMe disk", "fastest type with at least 64 GiB", "skipped /dev/sdb (USB disk)"},
+		},
+		{
+			name:   "smallest of the fastest type",
+			disks:  []Disk{disk("/dev/nvme0n1", "NVMe", 1000, ""), disk("/dev/nvme1n1", "NVMe", 128, "")},
+			want:   "/dev/nvme1n1",
+			reason: []string{"smallest of those, leaving larger disks for data"},
+		},
+		{
+			name:  "roomy SSD over small NVMe",
+			disks: []Disk{disk("/dev/nvme0n1", "NVMe", 32, ""), disk("/dev/sda", "SSD", 480, "")},
+			want:  "/dev/sda",
+		},
+		{
+			name:   "nothing roomy",
+			disks:  []Disk{disk("/dev/mmcblk0", "MMC", 32, ""), disk("/dev/sda", "HDD", 20, "")},
+			want:   "/dev/mmcblk0",
+			reason: []string{"no suitable disk has 64 GiB"},
+		},
+		{
+			name:   "nothing suitable",
+			disks:  []Disk{disk("/dev/sda", "SSD", 8, "smaller than 16 GiB")},
+			want:   "",
+			reason: []string{"no suitable disk: /dev/sda (smaller than 16 GiB)"},
+		},
+		{
+			name:   "no disks",
+			want:   "",
+			reason: []string{"no disks found"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, reason := selectInstallDisk(tt.disks)
+			if got != tt.want {
+				t.Errorf("selected %q, want %q (%s)", got, tt.want, reason)
+			}
+			for _, want := range tt.reason {
+				if !strings.Contains(reason, want) {
+					t.Errorf("reason %q does not contain %q", reason, want)
+				}
+			}
+		})
+	}
+}
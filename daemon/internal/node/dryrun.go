@@ -0,0 +1,203 @@
+package node
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/wild-cloud/wild-central/daemon/internal/storage"
+	"github.com/wild-cloud/wild-central/daemon/internal/tools"
+)
+
+// rebootPaths are machine config sections Talos cannot change on a running
+// node; changing them means the apply reboots it. Used when talosctl cannot
+// tell us itself.
+var rebootPaths = []string{
+	"machine.disks",
+	"machine.install",
+	"machine.kernel",
+	"machine.systemDiskEncryption",
+}
+
+// ConfigDiff is the difference between a node's earlier machine
+// configuration and the newly rendered one. Secret values (keys, tokens,
+// encryption secrets) are redacted from the diff; a changed secret only
+// shows in ChangedPaths.
+type ConfigDiff struct {
+	Source       string   `json:"source"` // Where the earlier configuration came from
+	Diff         string   `json:"diff"`   // Unified diff, empty when unchanged
+	ChangedPaths []string `json:"changed_paths"`
+}
+
+// DryRunResult describes what applying a node's configuration would do
+type DryRunResult struct {
+	Node            string      `json:"node"`
+	DeployIP        string      `json:"deploy_ip"`
+	MaintenanceMode bool        `json:"maintenance_mode"`
+	Valid           bool        `json:"valid"`
+	Validation      string      `json:"validation,omitempty"` // talosctl validate output
+	LastApplied     *ConfigDiff `json:"last_applied,omitempty"`
+	Live            *ConfigDiff `json:"live,omitempty"`
+	LiveError       string      `json:"live_error,omitempty"`
+	RebootRequired  bool        `json:"reboot_required"`
+	RebootReason    string      `json:"reboot_reason,omitempty"`
+}
+
+// DryRun renders the node's final machine configuration without applying
+// it. The result diffs it against the configuration last applied from here
+// and, when the node is reachable, against the one it runs, validates it
+// with talosctl and says whether applying it would reboot the node.
+func (m *Manager) DryRun(instanceName, nodeIdentifier string) (*DryRunResult, error) {
+	c, err := m.loadConfig(instanceName)
+	if err != nil {
+		return nil, err
+	}
+	node, ok := c.node(nodeIdentifier)
+	if !ok {
+		return nil, fmt.Errorf("node %s not found", nodeIdentifier)
+	}
+	c.applyDefaults(node)
+
+	// Render outside setup/cluster-nodes so the files apply uses stay as they are
+	outDir, err := os.MkdirTemp("", "wild-node-dryrun-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create dry-run directory: %w", err)
+	}
+	defer os.RemoveAll(outDir)
+
+	finalConfig, err := m.renderConfig(instanceName, node, outDir, true)
+	if err != nil {
+		return nil, err
+	}
+	rendered, err := os.ReadFile(finalConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rendered configuration: %w", err)
+	}
+
+	deployIP, maintenanceMode := deployTarget(node)
+	result := &DryRunResult{
+		Node:            node.Hostname,
+		DeployIP:        deployIP,
+		MaintenanceMode: maintenanceMode,
+	}
+
+	// Validate
+	talosconfigPath := tools.GetTalosconfigPath(m.dataDir, instanceName)
+	validation, err := talosctlOutput(talosconfigPath, "validate", "--config", finalConfig, "--mode", "metal")
+	result.Valid = err == nil
+	result.Validation = strings.TrimSpace(string(validation))
+	if err != nil {
+		result.Validation = err.Error()
+	}
+
+	// Diff against the configuration last applied from here
+	appliedPath := m.getAppliedConfigPath(instanceName, node.Hostname)
+	if !storage.FileExists(appliedPath) && node.Applied {
+		// Applied before applied/ was kept; final/ holds the last render
+		appliedPath = filepath.Join(m.GetInstancePath(instanceName), "setup", "cluster-nodes", "final", node.Hostname+".yaml")
+	}
+	if applied, err := os.ReadFile(appliedPath); err == nil {
+		result.LastApplied, err = newConfigDiff(appliedPath, applied, rendered)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Diff against the running configuration. A node in maintenance mode
+	// has none yet.
+	if !maintenanceMode {
+		live, err := talosctlOutput(talosconfigPath, "read", "--nodes", deployIP, "/system/state/config.yaml")
+		if err != nil {
+			result.LiveError = err.Error()
+		} else {
+			result.Live, err = newConfigDiff("node "+deployIP, live, rendered)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	result.RebootRequired, result.RebootReason = rebootNeeded(result)
+
+	// Talos knows best; ask it when the node is up and the config is valid
+	if result.Live != nil && result.Valid {
+		summary, err := talosctlOutput(talosconfigPath, "apply-config", "--dry-run", "--nodes", deployIP, "--file", finalConfig)
+		if err == nil {
+			switch text := string(summary); {
+			case strings.Contains(text, "without a reboot"):
+				result.RebootRequired, result.RebootReason = false, "reported by talosctl apply-config --dry-run"
+			case strings.Contains(text, "with a reboot"):
+				result.RebootRequired, result.RebootReason = true, "reported by talosctl apply-config --dry-run"
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// newConfigDiff compares two machine configs. The diff is made between
+// redacted copies, as it goes back to the client.
+func newConfigDiff(source string, earlier, rendered []byte) (*ConfigDiff, error) {
+	paths, err := changedPaths(earlier, rendered)
+	if err != nil {
+		return nil, fmt.Errorf("comparing with %s: %w", source, err)
+	}
+	if paths == nil {
+		paths = []string{}
+	}
+
+	redactedEarlier, err := redactSecrets(earlier)
+	if err != nil {
+		return nil, fmt.Errorf("comparing with %s: %w", source, err)
+	}
+	redactedRendered, err := redactSecrets(rendered)
+	if err != nil {
+		return nil, fmt.Errorf("comparing with %s: %w", source, err)
+	}
+
+	return &ConfigDiff{
+		Source:       source,
+		Diff:         unifiedDiff(source, "rendered", redactedEarlier, redactedRendered),
+		ChangedPaths: paths,
+	}, nil
+}
+
+// rebootNeeded estimates whether applying reboots the node, from its mode
+// and the sections that change
+func rebootNeeded(result *DryRunResult) (bool, string) {
+	if result.MaintenanceMode {
+		return true, "node is in maintenance mode; applying installs Talos and reboots it"
+	}
+
+	diff := result.Live
+	if diff == nil {
+		diff = result.LastApplied
+	}
+	if diff == nil {
+		return true, "no earlier configuration to compare against"
+	}
+
+	for _, path := range diff.ChangedPaths {
+		for _, prefix := range rebootPaths {
+			if path == prefix || strings.HasPrefix(path, prefix+".") {
+				return true, fmt.Sprintf("%s changes", path)
+			}
+		}
+	}
+	return false, ""
+}
+
+// recordApplied keeps a private copy of the configuration applied to a node
+func (m *Manager) recordApplied(instanceName, hostname, finalConfig string) error {
+	data, err := os.ReadFile(finalConfig)
+	if err != nil {
+		return err
+	}
+
+	appliedPath := m.getAppliedConfigPath(instanceName, hostname)
+	if err := storage.EnsureDir(filepath.Dir(appliedPath), 0700); err != nil {
+		return err
+	}
+	return storage.WriteFile(appliedPath, data, 0600)
+}
@@ -0,0 +1,368 @@
+package node
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/wild-cloud/wild-central/daemon/internal/storage"
+	"github.com/wild-cloud/wild-central/daemon/internal/tools"
+)
+
+// Inventory is the hardware of a node as Talos reports it, kept in the
+// instance so capacity can be planned and replacement hardware matched
+// without the node being reachable
+type Inventory struct {
+	GatheredAt time.Time   `yaml:"gatheredAt" json:"gathered_at"`
+	System     System      `yaml:"system" json:"system"`
+	CPUs       []CPU       `yaml:"cpus" json:"cpus"`
+	Memory     Memory      `yaml:"memory" json:"memory"`
+	NICs       []NIC       `yaml:"nics" json:"nics"`
+	Disks      []Disk      `yaml:"disks" json:"disks"`
+	GPUs       []PCIDevice `yaml:"gpus" json:"gpus"`
+	// Warnings lists the parts that could not be read, e.g. because the
+	// node's Talos version does not report them
+	Warnings []string `yaml:"warnings,omitempty" json:"warnings,omitempty"`
+}
+
+// System identifies the machine
+type System struct {
+	Manufacturer string `yaml:"manufacturer,omitempty" json:"manufacturer,omitempty"`
+	ProductName  string `yaml:"productName,omitempty" json:"product_name,omitempty"`
+	SerialNumber string `yaml:"serialNumber,omitempty" json:"serial_number,omitempty"`
+}
+
+// CPU is one processor socket
+type CPU struct {
+	Manufacturer string `yaml:"manufacturer,omitempty" json:"manufacturer,omitempty"`
+	Model        string `yaml:"model" json:"model"`
+	Cores        int    `yaml:"cores" json:"cores"`
+	Threads      int    `yaml:"threads" json:"threads"`
+	MaxSpeedMHz  int    `yaml:"maxSpeedMhz,omitempty" json:"max_speed_mhz,omitempty"`
+}
+
+// Memory is the installed memory
+type Memory struct {
+	TotalMB int            `yaml:"totalMb" json:"total_mb"`
+	Modules []MemoryModule `yaml:"modules" json:"modules"`
+}
+
+// MemoryModule is one installed memory module
+type MemoryModule struct {
+	Locator      string `yaml:"locator,omitempty" json:"locator,omitempty"`
+	SizeMB       int    `yaml:"sizeMb" json:"size_mb"`
+	SpeedMTs     int    `yaml:"speedMts,omitempty" json:"speed_mts,omitempty"`
+	Manufacturer string `yaml:"manufacturer,omitempty" json:"manufacturer,omitempty"`
+	PartNumber   string `yaml:"partNumber,omitempty" json:"part_number,omitempty"`
+}
+
+// NIC is a physical network interface
+type NIC struct {
+	Name      string `yaml:"name" json:"name"`
+	MAC       string `yaml:"mac" json:"mac"`
+	SpeedMbit int    `yaml:"speedMbit,omitempty" json:"speed_mbit,omitempty"` // 0 when the link is down
+	Up        bool   `yaml:"up" json:"up"`
+	Driver    string `yaml:"driver,omitempty" json:"driver,omitempty"`
+	Vendor    string `yaml:"vendor,omitempty" json:"vendor,omitempty"`
+	Product   string `yaml:"product,omitempty" json:"product,omitempty"`
+}
+
+// PCIDevice is a PCI device, such as a GPU
+type PCIDevice struct {
+	Vendor  string `yaml:"vendor" json:"vendor"`
+	Product string `yaml:"product" json:"product"`
+	Class   string `yaml:"class" json:"class"`
+}
+
+// gpuClasses are the PCI classes of graphics and compute accelerators
+var gpuClasses = []string{"Display controller", "Processing accelerators"}
+
+// decodeResources calls fn with the ID and spec of each resource in
+// talosctl get -o json output, a stream of JSON documents
+func decodeResources(output []byte, fn func(id string, spec json.RawMessage) error) error {
+	dec := json.NewDecoder(bytes.NewReader(output))
+	for {
+		var resource struct {
+			Metadata struct {
+				ID string `json:"id"`
+			} `json:"metadata"`
+			Spec json.RawMessage `json:"spec"`
+		}
+		if err := dec.Decode(&resource); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if len(resource.Spec) == 0 {
+			continue
+		}
+		if err := fn(resource.Metadata.ID, resource.Spec); err != nil {
+			return fmt.Errorf("%s: %w", resource.Metadata.ID, err)
+		}
+	}
+}
+
+// GatherInventory reads a node's hardware through talosctl. A node in
+// maintenance mode is queried with --insecure. Parts that cannot be read
+// are listed in Warnings; an unreachable node is an error.
+func (m *Manager) GatherInventory(instanceName string, node *Node) (*Inventory, error) {
+	ip := node.TargetIP
+	var insecure []string
+	if node.Maintenance {
+		insecure = []string{"--insecure"}
+		if node.CurrentIP != "" {
+			ip = node.CurrentIP
+		}
+	}
+	if ip == "" {
+		return nil, fmt.Errorf("node %s has no IP address", node.Hostname)
+	}
+	talosconfigPath := tools.GetTalosconfigPath(m.dataDir, instanceName)
+	get := func(resource string) ([]byte, error) {
+		args := append([]string{"get", resource, "--nodes", ip, "-o", "json"}, insecure...)
+		return talosctlOutput(talosconfigPath, args...)
+	}
+
+	return gatherInventory(get)
+}
+
+// gatherInventory builds an inventory from talosctl get output. The disks
+// have to be read; everything else is best effort.
+func gatherInventory(get func(resource string) ([]byte, error)) (*Inventory, error) {
+	inv := &Inventory{
+		GatheredAt: time.Now().UTC().Truncate(time.Second),
+		CPUs:       []CPU{},
+		Memory:     Memory{Modules: []MemoryModule{}},
+		NICs:       []NIC{},
+		GPUs:       []PCIDevice{},
+	}
+
+	output, err := get("disks")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read disks: %w", err)
+	}
+	if inv.Disks, err = parseDisks(output); err != nil {
+		return nil, err
+	}
+
+	parts := []struct {
+		resource string
+		parse    func(output []byte) error
+	}{
+		{"systeminformation", inv.parseSystem},
+		{"cpus", inv.parseCPUs},
+		{"memorymodules", inv.parseMemory},
+		{"links", inv.parseNICs},
+		{"pcidevices", inv.parseGPUs},
+	}
+	for _, part := range parts {
+		output, err := get(part.resource)
+		if err == nil {
+			err = part.parse(output)
+		}
+		if err != nil {
+			inv.Warnings = append(inv.Warnings, fmt.Sprintf("%s: %v", part.resource, err))
+		}
+	}
+	return inv, nil
+}
+
+func (inv *Inventory) parseSystem(output []byte) error {
+	return decodeResources(output, func(_ string, raw json.RawMessage) error {
+		var spec struct {
+			Manufacturer string `json:"manufacturer"`
+			ProductName  string `json:"productName"`
+			SerialNumber string `json:"serialnumber"`
+		}
+		if err := json.Unmarshal(raw, &spec); err != nil {
+			return err
+		}
+		inv.System = System{
+			Manufacturer: strings.TrimSpace(spec.Manufacturer),
+			ProductName:  strings.TrimSpace(spec.ProductName),
+			SerialNumber: strings.TrimSpace(spec.SerialNumber),
+		}
+		return nil
+	})
+}
+
+func (inv *Inventory) parseCPUs(output []byte) error {
+	return decodeResources(output, func(_ string, raw json.RawMessage) error {
+		var spec struct {
+			Manufacturer string `json:"manufacturer"`
+			ProductName  string `json:"productName"`
+			MaxSpeed     int    `json:"maxSpeedMhz"`
+			CoreCount    int    `json:"coreCount"`
+			ThreadCount  int    `json:"threadCount"`
+		}
+		if err := json.Unmarshal(raw, &spec); err != nil {
+			return err
+		}
+		inv.CPUs = append(inv.CPUs, CPU{
+			Manufacturer: strings.TrimSpace(spec.Manufacturer),
+			Model:        strings.TrimSpace(spec.ProductName),
+			Cores:        spec.CoreCount,
+			Threads:      spec.ThreadCount,
+			MaxSpeedMHz:  spec.MaxSpeed,
+		})
+		return nil
+	})
+}
+
+func (inv *Inventory) parseMemory(output []byte) error {
+	return decodeResources(output, func(_ string, raw json.RawMessage) error {
+		var spec struct {
+			Size          int    `json:"size"` // MB
+			DeviceLocator string `json:"deviceLocator"`
+			Speed         int    `json:"speed"`
+			Manufacturer  string `json:"manufacturer"`
+			ProductName   string `json:"productName"`
+		}
+		if err := json.Unmarshal(raw, &spec); err != nil {
+			return err
+		}
+		// Empty slots are reported with no size
+		if spec.Size == 0 {
+			return nil
+		}
+		inv.Memory.Modules = append(inv.Memory.Modules, MemoryModule{
+			Locator:      strings.TrimSpace(spec.DeviceLocator),
+			SizeMB:       spec.Size,
+			SpeedMTs:     spec.Speed,
+			Manufacturer: strings.TrimSpace(spec.Manufacturer),
+			PartNumber:   strings.TrimSpace(spec.ProductName),
+		})
+		inv.Memory.TotalMB += spec.Size
+		return nil
+	})
+}
+
+func (inv *Inventory) parseNICs(output []byte) error {
+	err := decodeResources(output, func(id string, raw json.RawMessage) error {
+		var spec struct {
+			Type             string `json:"type"`
+			Kind             string `json:"kind"`
+			HardwareAddr     string `json:"hardwareAddr"`
+			PermanentAddr    string `json:"permanentAddr"`
+			SpeedMbit        int    `json:"speedMbit"`
+			OperationalState string `json:"operationalState"`
+			Driver           string `json:"driver"`
+			Vendor           string `json:"vendor"`
+			Product          string `json:"product"`
+			BusPath          string `json:"busPath"`
+		}
+		if err := json.Unmarshal(raw, &spec); err != nil {
+			return err
+		}
+		// Physical Ethernet interfaces only: no loopback, bonds, VLANs or
+		// bridges, which have a kind, and no virtual devices without a bus
+		if spec.Type != "ether" || spec.Kind != "" || spec.BusPath == "" {
+			return nil
+		}
+		mac := spec.PermanentAddr
+		if mac == "" {
+			mac = spec.HardwareAddr
+		}
+		nic := NIC{
+			Name:    id,
+			MAC:     mac,
+			Up:      spec.OperationalState == "up",
+			Driver:  spec.Driver,
+			Vendor:  spec.Vendor,
+			Product: spec.Product,
+		}
+		// The kernel reports -1 or a huge value for links that are down
+		if nic.Up && spec.SpeedMbit > 0 && spec.SpeedMbit < 1000000 {
+			nic.SpeedMbit = spec.SpeedMbit
+		}
+		inv.NICs = append(inv.NICs, nic)
+		return nil
+	})
+	sort.Slice(inv.NICs, func(i, j int) bool { return inv.NICs[i].Name < inv.NICs[j].Name })
+	return err
+}
+
+func (inv *Inventory) parseGPUs(output []byte) error {
+	return decodeResources(output, func(_ string, raw json.RawMessage) error {
+		var spec struct {
+			Class   string `json:"class"`
+			Vendor  string `json:"vendor"`
+			Product string `json:"product"`
+		}
+		if err := json.Unmarshal(raw, &spec); err != nil {
+			return err
+		}
+		for _, class := range gpuClasses {
+			if spec.Class == class {
+				inv.GPUs = append(inv.GPUs, PCIDevice{Vendor: spec.Vendor, Product: spec.Product, Class: spec.Class})
+			}
+		}
+		return nil
+	})
+}
+
+// getInventoryPath returns where a node's inventory is kept
+func (m *Manager) getInventoryPath(instanceName, hostname string) string {
+	return filepath.Join(m.GetInstancePath(instanceName), "setup", "cluster-nodes", "inventory", hostname+".yaml")
+}
+
+// Inventory returns the inventory last gathered for a node, or nil if
+// there is none
+func (m *Manager) Inventory(instanceName, hostname string) (*Inventory, error) {
+	data, err := os.ReadFile(m.getInventoryPath(instanceName, hostname))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read inventory: %w", err)
+	}
+	var inv Inventory
+	if err := yaml.Unmarshal(data, &inv); err != nil {
+		return nil, fmt.Errorf("failed to parse inventory: %w", err)
+	}
+	return &inv, nil
+}
+
+// RefreshInventory gathers a node's inventory and keeps it in the instance
+func (m *Manager) RefreshInventory(instanceName, hostname string) (*Inventory, error) {
+	c, err := m.loadConfig(instanceName)
+	if err != nil {
+		return nil, err
+	}
+	node, ok := c.node(hostname)
+	if !ok {
+		return nil, fmt.Errorf("node %s not found", hostname)
+	}
+
+	inv, err := m.GatherInventory(instanceName, node)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.saveInventory(instanceName, hostname, inv); err != nil {
+		return nil, err
+	}
+	return inv, nil
+}
+
+func (m *Manager) saveInventory(instanceName, hostname string, inv *Inventory) error {
+	data, err := yaml.Marshal(inv)
+	if err != nil {
+		return fmt.Errorf("failed to write inventory: %w", err)
+	}
+	path := m.getInventoryPath(instanceName, hostname)
+	if err := storage.EnsureDir(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if err := storage.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write inventory: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package node
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// talosctl get <resource> -o json output of a small GPU worker
+var testResources = map[string]string{
+	"disks":             testDisksOutput,
+	"systeminformation": `{"metadata": {"id": "systeminformation"}, "spec": {"manufacturer": "ASUS", "productName": "PN53 ", "serialnumber": "M7PDCG000123"}}`,
+	"cpus":              `{"metadata": {"id": "CPU_0"}, "spec": {"socket": "AM5", "manufacturer": "Advanced Micro Devices, Inc.", "productName": "AMD Ryzen 7 7735H with Radeon Graphics", "maxSpeedMhz": 4750, "coreCount": 8, "threadCount": 16}}`,
+	"memorymodules": `{"metadata": {"id": "ChannelA-DIMM0"}, "spec": {"size": 16384, "deviceLocator": "DIMM 0", "speed": 4800, "manufacturer": "Kingston", "productName": "KF548S38-16"}}
+{"metadata": {"id": "ChannelB-DIMM0"}, "spec": {"size": 16384, "deviceLocator": "DIMM 1", "speed": 4800, "manufacturer": "Kingston", "productName": "KF548S38-16"}}
+{"metadata": {"id": "ChannelB-DIMM1"}, "spec": {"size": 0, "deviceLocator": "DIMM 2"}}`,
+	"links": `{"metadata": {"id": "lo"}, "spec": {"type": "loopback", "hardwareAddr": "00:00:00:00:00:00", "operationalState": "unknown"}}
+{"metadata": {"id": "enp2s0"}, "spec": {"type": "ether", "kind": "", "hardwareAddr": "a8:5e:45:01:02:03", "permanentAddr": "a8:5e:45:01:02:03", "speedMbit": 2500, "operationalState": "up", "driver": "r8169", "vendor": "Realtek Semiconductor Co., Ltd.", "product": "RTL8125 2.5GbE Controller", "busPath": "0000:02:00.0"}}
+{"metadata": {"id": "enp3s0"}, "spec": {"type": "ether", "kind": "", "hardwareAddr": "a8:5e:45:01:02:04", "speedMbit": 4294967295, "operationalState": "down", "driver": "igc", "busPath": "0000:03:00.0"}}
+{"metadata": {"id": "bond0"}, "spec": {"type": "ether", "kind": "bond", "hardwareAddr": "a8:5e:45:01:02:03", "operationalState": "up"}}
+{"metadata": {"id": "flannel.1"}, "spec": {"type": "ether", "kind": "vxlan", "hardwareAddr": "1e:6b:7c:00:00:01", "operationalState": "unknown"}}`,
+	"pcidevices": `{"metadata": {"id": "0000:01:00.0"}, "spec": {"class": "Display controller", "subclass": "VGA compatible controller", "vendor": "NVIDIA Corporation", "product": "AD104 [GeForce RTX 4070]"}}
+{"metadata": {"id": "0000:02:00.0"}, "spec": {"class": "Network controller", "subclass": "Ethernet controller", "vendor": "Realtek Semiconductor Co., Ltd.", "product": "RTL8125 2.5GbE Controller"}}`,
+}
+
+func fakeGet(resources map[string]string) func(string) ([]byte, error) {
+	return func(resource string) ([]byte, error) {
+		output, ok := resources[resource]
+		if !ok {
+			return nil, fmt.Errorf("talosctl get: resource type %q is not registered", resource)
+		}
+		return []byte(output), nil
+	}
+}
+
+func TestGatherInventory(t *testing.T) {
+	inv, err := gatherInventory(fakeGet(testResources))
+	if err != nil {
+		t.Fatalf("gatherInventory failed: %v", err)
+	}
+
+	if inv.System != (System{Manufacturer: "ASUS", ProductName: "PN53", SerialNumber: "M7PDCG000123"}) {
+		t.Errorf("system = %+v", inv.System)
+	}
+	wantCPUs := []CPU{{Manufacturer: "Advanced Micro Devices, Inc.", Model: "AMD Ryzen 7 7735H with Radeon Graphics", Cores: 8, Threads: 16, MaxSpeedMHz: 4750}}
+	if !reflect.DeepEqual(inv.CPUs, wantCPUs) {
+		t.Errorf("cpus = %+v", inv.CPUs)
+	}
+	if inv.Memory.TotalMB != 32768 || len(inv.Memory.Modules) != 2 || inv.Memory.Modules[1].Locator != "DIMM 1" {
+		t.Errorf("memory = %+v", inv.Memory)
+	}
+	wantNICs := []NIC{
+		{Name: "enp2s0", MAC: "a8:5e:45:01:02:03", SpeedMbit: 2500, Up: true, Driver: "r8169", Vendor: "Realtek Semiconductor Co., Ltd.", Product: "RTL8125 2.5GbE Controller"},
+		{Name: "enp3s0", MAC: "a8:5e:45:01:02:04", Driver: "igc"},
+	}
+	if !reflect.DeepEqual(inv.NICs, wantNICs) {
+		t.Errorf("nics =\n%+v\nwant\n%+v", inv.NICs, wantNICs)
+	}
+	if len(inv.Disks) != 4 || inv.Disks[1].Model != "Samsung SSD 980 PRO 500GB" {
+		t.Errorf("disks = %+v", inv.Disks)
+	}
+	if len(inv.GPUs) != 1 || inv.GPUs[0].Product != "AD104 [GeForce RTX 4070]" {
+		t.Errorf("gpus = %+v", inv.GPUs)
+	}
+	if len(inv.Warnings) != 0 {
+		t.Errorf("unexpected warnings: %v", inv.Warnings)
+	}
+}
+
+func TestGatherInventory_Partial(t *testing.T) {
+	// Older Talos versions do not report PCI devices
+	resources := map[string]string{"disks": testDisksOutput, "cpus": testResources["cpus"]}
+	inv, err := gatherInventory(fakeGet(resources))
+	if err != nil {
+		t.Fatalf("gatherInventory failed: %v", err)
+	}
+	if len(inv.CPUs) != 1 || len(inv.Warnings) != 4 || !strings.HasPrefix(inv.Warnings[3], "pcidevices: ") {
+		t.Errorf("unexpected inventory: %+v", inv)
+	}
+	if inv.GPUs == nil || inv.NICs == nil {
+		t.Error("missing parts should be empty lists")
+	}
+
+	// Without disks the node was not reachable
+	if _, err := gatherInventory(fakeGet(map[string]string{})); err == nil {
+		t.Error("expected an error when disks cannot be read")
+	}
+}
+
+func TestInventory_RoundTrip(t *testing.T) {
+	m := newTestManager(t, testConfig)
+
+	if inv, err := m.Inventory("home", "worker-1"); inv != nil || err != nil {
+		t.Fatalf("Inventory = %+v, %v; want none", inv, err)
+	}
+
+	want, err := gatherInventory(fakeGet(testResources))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.saveInventory("home", "worker-1", want); err != nil {
+		t.Fatalf("saveInventory failed: %v", err)
+	}
+	got, err := m.Inventory("home", "worker-1")
+	if err != nil {
+		t.Fatalf("Inventory failed: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("inventory changed on the way through the file:\n%+v\nwant\n%+v", got, want)
+	}
+}
@@ -0,0 +1,289 @@
+package node
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Node metadata is kept in config.yaml as labels, taints and annotations on
+// each node. Taints use the Talos machine.nodeTaints form, key: value:Effect,
+// where the value may be left out.
+
+var (
+	// A qualified name with an optional DNS subdomain prefix, as Kubernetes
+	// requires of label and annotation keys
+	metadataKeyPattern = regexp.MustCompile(`^([a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*/)?[A-Za-z0-9]([-A-Za-z0-9_.]{0,61}[A-Za-z0-9])?$`)
+	labelValuePattern  = regexp.MustCompile(`^([A-Za-z0-9]([-A-Za-z0-9_.]{0,61}[A-Za-z0-9])?)?$`)
+)
+
+var taintEffects = []string{"NoSchedule", "PreferNoSchedule", "NoExecute"}
+
+// MetadataError is a label, taint or annotation Kubernetes would refuse
+type MetadataError struct {
+	Field   string // labels, taints or annotations
+	Key     string
+	Message string
+}
+
+func (e *MetadataError) Error() string {
+	return fmt.Sprintf("%s: %s: %s", e.Field, e.Key, e.Message)
+}
+
+// validateMetadata checks a node's labels, taints and annotations
+func validateMetadata(node *Node) error {
+	for _, field := range []struct {
+		name   string
+		values map[string]string
+	}{
+		{"labels", node.Labels},
+		{"taints", node.Taints},
+		{"annotations", node.Annotations},
+	} {
+		for _, key := range sortedKeys(field.values) {
+			if len(key) > 253 || !metadataKeyPattern.MatchString(key) {
+				return &MetadataError{field.name, key, "invalid key"}
+			}
+			value := field.values[key]
+			switch field.name {
+			case "labels":
+				if !labelValuePattern.MatchString(value) {
+					return &MetadataError{field.name, key, fmt.Sprintf("invalid value %q", value)}
+				}
+			case "taints":
+				taintValue, effect := parseTaint(value)
+				if !labelValuePattern.MatchString(taintValue) {
+					return &MetadataError{field.name, key, fmt.Sprintf("invalid value %q", taintValue)}
+				}
+				if !isTaintEffect(effect) {
+					return &MetadataError{field.name, key, fmt.Sprintf("effect must be one of %s, as in value:NoSchedule", strings.Join(taintEffects, ", "))}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// parseTaint splits a taint written as value:Effect, or just Effect
+func parseTaint(taint string) (value, effect string) {
+	if i := strings.LastIndex(taint, ":"); i >= 0 {
+		return taint[:i], taint[i+1:]
+	}
+	return "", taint
+}
+
+func isTaintEffect(effect string) bool {
+	for _, e := range taintEffects {
+		if e == effect {
+			return true
+		}
+	}
+	return false
+}
+
+// updateMetadata merges a labels, taints or annotations update into values:
+// a string sets a key and null removes it
+func updateMetadata(field string, values map[string]string, update interface{}) (map[string]string, error) {
+	changes, ok := update.(map[string]interface{})
+	if !ok {
+		return nil, &MetadataError{field, "", "expected a map of keys to values"}
+	}
+	merged := make(map[string]string, len(values)+len(changes))
+	for key, value := range values {
+		merged[key] = value
+	}
+	for key, value := range changes {
+		switch v := value.(type) {
+		case nil:
+			delete(merged, key)
+		case string:
+			merged[key] = v
+		default:
+			return nil, &MetadataError{field, key, "value must be a string, or null to remove it"}
+		}
+	}
+	if len(merged) == 0 {
+		return nil, nil
+	}
+	return merged, nil
+}
+
+func sortedKeys(values map[string]string) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// withNodeMetadata sets the node's labels, taints and annotations in a
+// rendered patch as machine.nodeLabels, nodeTaints and nodeAnnotations, so
+// Talos applies them when the node registers. Keys a template sets are
+// overridden by config.yaml. A node without metadata keeps its patch as
+// rendered.
+func withNodeMetadata(patch []byte, node *Node) ([]byte, error) {
+	if len(node.Labels) == 0 && len(node.Taints) == 0 && len(node.Annotations) == 0 {
+		return patch, nil
+	}
+
+	return editPatch(patch, func(machine *yaml.Node) error {
+		for _, field := range []struct {
+			key    string
+			values map[string]string
+		}{
+			{"nodeLabels", node.Labels},
+			{"nodeTaints", node.Taints},
+			{"nodeAnnotations", node.Annotations},
+		} {
+			if len(field.values) == 0 {
+				continue
+			}
+			mapping, err := mappingValue(machine, field.key)
+			if err != nil {
+				return err
+			}
+			for _, key := range sortedKeys(field.values) {
+				if existing := lookupKey(mapping, key); existing != nil {
+					// Keep the template's comments on the line
+					existing.Kind, existing.Tag, existing.Style = yaml.ScalarNode, "!!str", 0
+					existing.Value, existing.Content = field.values[key], nil
+					continue
+				}
+				mapping.Content = append(mapping.Content,
+					&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key},
+					&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: field.values[key]})
+			}
+		}
+		return nil
+	})
+}
+
+// liveMetadata is the metadata of a Kubernetes node object
+type liveMetadata struct {
+	Labels      map[string]string
+	Annotations map[string]string
+	Taints      []liveTaint
+}
+
+type liveTaint struct {
+	Key    string `json:"key"`
+	Value  string `json:"value"`
+	Effect string `json:"effect"`
+}
+
+// metadataCommands returns the kubectl label, annotate and taint arguments
+// that take a live node from live to the metadata config.yaml now gives it.
+// Only keys config.yaml set before are removed; labels and taints added by
+// Kubernetes or by hand are left alone.
+func metadataCommands(before, after *Node, live *liveMetadata) [][]string {
+	var commands [][]string
+
+	for _, field := range []struct {
+		verb           string
+		old, new, live map[string]string
+	}{
+		{"label", before.Labels, after.Labels, live.Labels},
+		{"annotate", before.Annotations, after.Annotations, live.Annotations},
+	} {
+		var args []string
+		for _, key := range sortedKeys(field.old) {
+			if _, kept := field.new[key]; !kept {
+				if _, present := field.live[key]; present {
+					args = append(args, key+"-")
+				}
+			}
+		}
+		for _, key := range sortedKeys(field.new) {
+			if value, present := field.live[key]; !present || value != field.new[key] {
+				args = append(args, key+"="+field.new[key])
+			}
+		}
+		if len(args) > 0 {
+			commands = append(commands, append([]string{field.verb, "node", after.Hostname, "--overwrite"}, args...))
+		}
+	}
+
+	var args []string
+	for _, taint := range live.Taints {
+		_, owned := before.Taints[taint.Key]
+		wanted, kept := after.Taints[taint.Key]
+		if !kept {
+			if owned {
+				args = append(args, taint.Key+":"+taint.Effect+"-")
+			}
+			continue
+		}
+		// A taint whose effect changed is a different taint to Kubernetes
+		if _, effect := parseTaint(wanted); effect != taint.Effect {
+			args = append(args, taint.Key+":"+taint.Effect+"-")
+		}
+	}
+	for _, key := range sortedKeys(after.Taints) {
+		value, effect := parseTaint(after.Taints[key])
+		found := false
+		for _, taint := range live.Taints {
+			if taint.Key == key && taint.Value == value && taint.Effect == effect {
+				found = true
+				break
+			}
+		}
+		if found {
+			continue
+		}
+		if value == "" {
+			args = append(args, key+":"+effect)
+		} else {
+			args = append(args, key+"="+value+":"+effect)
+		}
+	}
+	if len(args) > 0 {
+		commands = append(commands, append([]string{"taint", "node", after.Hostname, "--overwrite"}, args...))
+	}
+
+	return commands
+}
+
+// ReconcileMetadata brings the labels, taints and annotations of a running
+// node in line with config.yaml after they were changed from before. Talos
+// only reads machine.nodeLabels and nodeTaints when its configuration is
+// applied, so the change is made with kubectl as well. Nodes that have not
+// joined get their metadata from the patch when applied.
+func (m *Manager) ReconcileMetadata(instanceName string, before, after *Node) error {
+	if !joined(after) {
+		return nil
+	}
+
+	output, err := m.kubectlOutput(instanceName, "get", "node", after.Hostname, "-o", "json")
+	if err != nil {
+		return err
+	}
+	var object struct {
+		Metadata struct {
+			Labels      map[string]string `json:"labels"`
+			Annotations map[string]string `json:"annotations"`
+		} `json:"metadata"`
+		Spec struct {
+			Taints []liveTaint `json:"taints"`
+		} `json:"spec"`
+	}
+	if err := json.Unmarshal(output, &object); err != nil {
+		return fmt.Errorf("failed to parse node %s: %w", after.Hostname, err)
+	}
+	live := &liveMetadata{
+		Labels:      object.Metadata.Labels,
+		Annotations: object.Metadata.Annotations,
+		Taints:      object.Spec.Taints,
+	}
+
+	for _, args := range metadataCommands(before, after, live) {
+		if _, err := m.kubectlOutput(instanceName, args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
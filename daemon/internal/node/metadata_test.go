@@ -0,0 +1,165 @@
+package node
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidateMetadata(t *testing.T) {
+	tests := []struct {
+		name string
+		node Node
+		want string // Substring of the error; empty when valid
+	}{
+		{"valid", Node{
+			Labels:      map[string]string{"nvidia.com/gpu.present": "true", "node.longhorn.io/create-default-disk": "true", "empty": ""},
+			Taints:      map[string]string{"nvidia.com/gpu": "present:NoSchedule", "dedicated": "NoExecute"},
+			Annotations: map[string]string{"wild-cloud.io/notes": "Bought 2024, spare PSU in the cupboard"},
+		}, ""},
+		{"bad label key", Node{Labels: map[string]string{"-gpu": "true"}}, "labels: -gpu: invalid key"},
+		{"bad label value", Node{Labels: map[string]string{"gpu": "a b"}}, `labels: gpu: invalid value "a b"`},
+		{"missing effect", Node{Taints: map[string]string{"gpu": "present"}}, "taints: gpu: effect must be one of"},
+		{"bad effect", Node{Taints: map[string]string{"gpu": "present:NoScheduleAtAll"}}, "taints: gpu: effect must be one of"},
+		{"bad annotation key", Node{Annotations: map[string]string{"a/b/c": "x"}}, "annotations: a/b/c: invalid key"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateMetadata(&tt.node)
+			if tt.want == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			var metadataErr *MetadataError
+			if !errors.As(err, &metadataErr) || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want a MetadataError containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpdate_Metadata(t *testing.T) {
+	m := newTestManager(t, testConfig)
+
+	updates := map[string]interface{}{
+		"labels": map[string]interface{}{"zone": nil, "nvidia.com/gpu.present": "true"},
+		"taints": map[string]interface{}{"nvidia.com/gpu": "present:NoSchedule"},
+	}
+	if err := m.Update("home", "control-1", updates); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	node, _ := m.Get("home", "control-1")
+	if !reflect.DeepEqual(node.Labels, map[string]string{"nvidia.com/gpu.present": "true"}) {
+		t.Errorf("labels = %v", node.Labels)
+	}
+	if node.Taints["nvidia.com/gpu"] != "present:NoSchedule" {
+		t.Errorf("taints = %v", node.Taints)
+	}
+	if config := readConfig(t, m); !strings.Contains(config, "        taints:\n          nvidia.com/gpu: present:NoSchedule\n") {
+		t.Errorf("taints not written:\n%s", config)
+	}
+
+	// Removing the last key drops the field
+	if err := m.Update("home", "control-1", map[string]interface{}{"labels": map[string]interface{}{"nvidia.com/gpu.present": nil}}); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if config := readConfig(t, m); strings.Contains(config, "labels:") {
+		t.Errorf("empty labels still written:\n%s", config)
+	}
+
+	err := m.Update("home", "control-1", map[string]interface{}{"taints": map[string]interface{}{"gpu": "Sometimes"}})
+	var metadataErr *MetadataError
+	if !errors.As(err, &metadataErr) {
+		t.Errorf("invalid taint: error = %v, want a MetadataError", err)
+	}
+}
+
+func TestWithNodeMetadata(t *testing.T) {
+	patch := []byte(`machine:
+  install:
+    disk: /dev/sda
+  nodeLabels:
+    zone: rack-a # from the template
+    tier: gold
+`)
+
+	// Without metadata the patch is left as rendered
+	if got, err := withNodeMetadata(patch, &Node{}); err != nil || string(got) != string(patch) {
+		t.Errorf("patch changed without metadata: %q, %v", got, err)
+	}
+
+	node := &Node{
+		Labels:      map[string]string{"zone": "rack-b", "nvidia.com/gpu.present": "true"},
+		Taints:      map[string]string{"nvidia.com/gpu": "present:NoSchedule"},
+		Annotations: map[string]string{"wild-cloud.io/notes": "spare PSU"},
+	}
+	got, err := withNodeMetadata(patch, node)
+	if err != nil {
+		t.Fatalf("withNodeMetadata failed: %v", err)
+	}
+	want := `machine:
+  install:
+    disk: /dev/sda
+  nodeLabels:
+    zone: rack-b # from the template
+    tier: gold
+    nvidia.com/gpu.present: "true"
+  nodeTaints:
+    nvidia.com/gpu: present:NoSchedule
+  nodeAnnotations:
+    wild-cloud.io/notes: spare PSU
+`
+	if string(got) != want {
+		t.Errorf("patch =\n%s\nwant\n%s", got, want)
+	}
+
+	if got, err := withNodeMetadata(nil, &Node{Labels: map[string]string{"zone": "a"}}); err != nil || string(got) != "machine:\n  nodeLabels:\n    zone: a\n" {
+		t.Errorf("empty patch: %q, %v", got, err)
+	}
+	if _, err := withNodeMetadata([]byte("machine: []\n"), node); err == nil {
+		t.Error("expected an error when machine is not a map")
+	}
+}
+
+func TestMetadataCommands(t *testing.T) {
+	before := &Node{
+		Hostname:    "worker-1",
+		Labels:      map[string]string{"zone": "rack-a", "gpu": "true"},
+		Taints:      map[string]string{"gpu": "present:NoSchedule", "dedicated": "ml:NoSchedule"},
+		Annotations: map[string]string{"notes": "old"},
+	}
+	after := &Node{
+		Hostname: "worker-1",
+		Labels:   map[string]string{"zone": "rack-b", "storage": "longhorn"},
+		Taints:   map[string]string{"dedicated": "ml:NoExecute"},
+	}
+	live := &liveMetadata{
+		Labels:      map[string]string{"kubernetes.io/hostname": "worker-1", "zone": "rack-a", "gpu": "true", "storage": "longhorn"},
+		Annotations: map[string]string{"talos.dev/owned-labels": "[]"},
+		Taints: []liveTaint{
+			{Key: "gpu", Value: "present", Effect: "NoSchedule"},
+			{Key: "dedicated", Value: "ml", Effect: "NoSchedule"},
+			{Key: "node.kubernetes.io/unreachable", Effect: "NoExecute"},
+		},
+	}
+
+	got := metadataCommands(before, after, live)
+	want := [][]string{
+		{"label", "node", "worker-1", "--overwrite", "gpu-", "zone=rack-b"},
+		{"taint", "node", "worker-1", "--overwrite", "gpu:NoSchedule-", "dedicated:NoSchedule-", "dedicated=ml:NoExecute"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("commands =\n%v\nwant\n%v", got, want)
+	}
+
+	if got := metadataCommands(after, after, &liveMetadata{
+		Labels: after.Labels,
+		Taints: []liveTaint{{Key: "dedicated", Value: "ml", Effect: "NoExecute"}},
+	}); len(got) != 0 {
+		t.Errorf("expected no commands for a node in sync, got %v", got)
+	}
+}
@@ -5,9 +5,10 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
-	"strings"
+	"sort"
 
 	"github.com/wild-cloud/wild-central/daemon/internal/config"
+	"github.com/wild-cloud/wild-central/daemon/internal/storage"
 	"github.com/wild-cloud/wild-central/daemon/internal/tools"
 )
 
@@ -34,21 +35,36 @@ type Node struct {
 	TargetIP    string `yaml:"targetIp" json:"target_ip"`
 	CurrentIP   string `yaml:"currentIp,omitempty" json:"current_ip,omitempty"` // For maintenance mode detection
 	Interface   string `yaml:"interface,omitempty" json:"interface,omitempty"`
-	Disk        string `yaml:"disk" json:"disk"`
+	Disk        string `yaml:"disk" json:"disk"` // Install disk
 	Version     string `yaml:"version,omitempty" json:"version,omitempty"`
 	SchematicID string `yaml:"schematicId,omitempty" json:"schematic_id,omitempty"`
 	Maintenance bool   `yaml:"maintenance,omitempty" json:"maintenance"` // Explicit maintenance mode flag
 	Configured  bool   `yaml:"configured,omitempty" json:"configured"`
 	Applied     bool   `yaml:"applied,omitempty" json:"applied"`
+
+	// Kubernetes metadata of the node, rendered into its patch as
+	// machine.nodeLabels, nodeTaints and nodeAnnotations. Taints are
+	// written value:Effect, as Talos takes them.
+	Labels      map[string]string `yaml:"labels,omitempty" json:"labels,omitempty"`
+	Taints      map[string]string `yaml:"taints,omitempty" json:"taints,omitempty"`
+	Annotations map[string]string `yaml:"annotations,omitempty" json:"annotations,omitempty"`
+
+	// Additional disks by volume name, rendered into the patch
+	Storage map[string]StorageVolume `yaml:"storage,omitempty" json:"storage,omitempty"`
+
+	// Extra holds keys of the node's config.yaml entry that the manager
+	// does not know, so they survive being read and written back
+	Extra map[string]interface{} `yaml:",inline" json:"extra,omitempty"`
 }
 
 // HardwareInfo contains discovered hardware information
 type HardwareInfo struct {
-	IP              string           `json:"ip"`
-	Interface       string           `json:"interface"`
-	Disks           []tools.DiskInfo `json:"disks"`
-	SelectedDisk    string           `json:"selected_disk"`
-	MaintenanceMode bool             `json:"maintenance_mode"`
+	IP              string `json:"ip"`
+	Interface       string `json:"interface"`
+	Disks           []Disk `json:"disks"`
+	SelectedDisk    string `json:"selected_disk"`
+	SelectionReason string `json:"selection_reason"` // Why SelectedDisk was chosen, or why none was
+	MaintenanceMode bool   `json:"maintenance_mode"`
 }
 
 // ApplyOptions contains options for node apply
@@ -61,73 +77,23 @@ func (m *Manager) GetInstancePath(instanceName string) string {
 	return filepath.Join(m.dataDir, "instances", instanceName)
 }
 
-// List returns all nodes for an instance
+// List returns all nodes for an instance, sorted by hostname
 func (m *Manager) List(instanceName string) ([]Node, error) {
-	instancePath := m.GetInstancePath(instanceName)
-	configPath := filepath.Join(instancePath, "config.yaml")
-
-	yq := tools.NewYQ()
-
-	// Get all node hostnames from cluster.nodes.active
-	output, err := yq.Exec("eval", ".cluster.nodes.active | keys", configPath)
+	c, err := m.loadConfig(instanceName)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read nodes: %w", err)
-	}
-
-	// Parse hostnames (yq returns YAML array)
-	hostnamesYAML := string(output)
-	if hostnamesYAML == "" || hostnamesYAML == "null\n" {
-		return []Node{}, nil
+		return nil, err
 	}
 
-	// Get hostnames line by line
-	var hostnames []string
-	for _, line := range strings.Split(hostnamesYAML, "\n") {
-		line = strings.TrimSpace(line)
-		if line != "" && line != "null" && line != "-" {
-			// Remove leading "- " from YAML array
-			hostname := line
-			if len(hostname) > 2 && hostname[0:2] == "- " {
-				hostname = hostname[2:]
-			}
-			if hostname != "" {
-				hostnames = append(hostnames, hostname)
-			}
-		}
+	hostnames := make([]string, 0, len(c.Cluster.Nodes.Active))
+	for hostname := range c.Cluster.Nodes.Active {
+		hostnames = append(hostnames, hostname)
 	}
+	sort.Strings(hostnames)
 
-	// Get details for each node
-	var nodes []Node
+	nodes := make([]Node, 0, len(hostnames))
 	for _, hostname := range hostnames {
-		basePath := fmt.Sprintf(".cluster.nodes.active.%s", hostname)
-
-		// Get node fields
-		role, _ := yq.Exec("eval", basePath+".role", configPath)
-		targetIP, _ := yq.Exec("eval", basePath+".targetIp", configPath)
-		currentIP, _ := yq.Exec("eval", basePath+".currentIp", configPath)
-		disk, _ := yq.Exec("eval", basePath+".disk", configPath)
-		iface, _ := yq.Exec("eval", basePath+".interface", configPath)
-		version, _ := yq.Exec("eval", basePath+".version", configPath)
-		schematicID, _ := yq.Exec("eval", basePath+".schematicId", configPath)
-		maintenance, _ := yq.Exec("eval", basePath+".maintenance", configPath)
-		configured, _ := yq.Exec("eval", basePath+".configured", configPath)
-		applied, _ := yq.Exec("eval", basePath+".applied", configPath)
-
-		node := Node{
-			Hostname:    hostname,
-			Role:        tools.CleanYQOutput(string(role)),
-			TargetIP:    tools.CleanYQOutput(string(targetIP)),
-			CurrentIP:   tools.CleanYQOutput(string(currentIP)),
-			Disk:        tools.CleanYQOutput(string(disk)),
-			Interface:   tools.CleanYQOutput(string(iface)),
-			Version:     tools.CleanYQOutput(string(version)),
-			SchematicID: tools.CleanYQOutput(string(schematicID)),
-			Maintenance: tools.CleanYQOutput(string(maintenance)) == "true",
-			Configured:  tools.CleanYQOutput(string(configured)) == "true",
-			Applied:     tools.CleanYQOutput(string(applied)) == "true",
-		}
-
-		nodes = append(nodes, node)
+		node, _ := c.node(hostname)
+		nodes = append(nodes, *node)
 	}
 
 	return nodes, nil
@@ -135,26 +101,21 @@ func (m *Manager) List(instanceName string) ([]Node, error) {
 
 // Get returns a specific node by hostname
 func (m *Manager) Get(instanceName, hostname string) (*Node, error) {
-	// Get all nodes
-	nodes, err := m.List(instanceName)
+	c, err := m.loadConfig(instanceName)
 	if err != nil {
 		return nil, err
 	}
 
-	// Find node by hostname
-	for _, node := range nodes {
-		if node.Hostname == hostname {
-			return &node, nil
-		}
+	node, ok := c.node(hostname)
+	if !ok {
+		return nil, fmt.Errorf("node %s not found", hostname)
 	}
 
-	return nil, fmt.Errorf("node %s not found", hostname)
+	return node, nil
 }
 
 // Add registers a new node in config.yaml
 func (m *Manager) Add(instanceName string, node *Node) error {
-	instancePath := m.GetInstancePath(instanceName)
-
 	// Validate node data
 	if node.Hostname == "" {
 		return fmt.Errorf("hostname is required")
@@ -165,30 +126,11 @@ func (m *Manager) Add(instanceName string, node *Node) error {
 	if node.Disk == "" {
 		return fmt.Errorf("disk is required")
 	}
-
-	// Check if node already exists - ERROR if yes
-	existing, err := m.Get(instanceName, node.Hostname)
-	if err == nil && existing != nil {
-		return fmt.Errorf("node %s already exists", node.Hostname)
-	}
-
-	configPath := filepath.Join(instancePath, "config.yaml")
-	yq := tools.NewYQ()
-
-	// If schematicId not provided, use instance-level default from cluster.nodes.talos.schematicId
-	if node.SchematicID == "" {
-		defaultSchematicID, err := yq.Get(configPath, ".cluster.nodes.talos.schematicId")
-		if err == nil && defaultSchematicID != "" && defaultSchematicID != "null" {
-			node.SchematicID = defaultSchematicID
-		}
+	if err := validateMetadata(node); err != nil {
+		return err
 	}
-
-	// If version not provided, use instance-level default from cluster.nodes.talos.version
-	if node.Version == "" {
-		defaultVersion, err := yq.Get(configPath, ".cluster.nodes.talos.version")
-		if err == nil && defaultVersion != "" && defaultVersion != "null" {
-			node.Version = defaultVersion
-		}
+	if err := validateStorage(node); err != nil {
+		return err
 	}
 
 	// Set maintenance=true if currentIP provided (node in maintenance mode)
@@ -196,74 +138,31 @@ func (m *Manager) Add(instanceName string, node *Node) error {
 		node.Maintenance = true
 	}
 
-	// Add node to config.yaml
-	// Path: cluster.nodes.active.{hostname}
-	basePath := fmt.Sprintf("cluster.nodes.active.%s", node.Hostname)
-
-	// Set each field
-	if err := yq.Set(configPath, basePath+".role", node.Role); err != nil {
-		return fmt.Errorf("failed to set role: %w", err)
-	}
-	if err := yq.Set(configPath, basePath+".disk", node.Disk); err != nil {
-		return fmt.Errorf("failed to set disk: %w", err)
-	}
-	if node.TargetIP != "" {
-		if err := yq.Set(configPath, basePath+".targetIp", node.TargetIP); err != nil {
-			return fmt.Errorf("failed to set targetIP: %w", err)
+	// Add node to config.yaml under cluster.nodes.active.{hostname}
+	return m.updateConfig(instanceName, func(c *nodeConfig) error {
+		// Check if node already exists - ERROR if yes
+		if _, exists := c.node(node.Hostname); exists {
+			return fmt.Errorf("node %s already exists", node.Hostname)
 		}
-	}
-	if node.CurrentIP != "" {
-		if err := yq.Set(configPath, basePath+".currentIp", node.CurrentIP); err != nil {
-			return fmt.Errorf("failed to set currentIP: %w", err)
-		}
-	}
-	if node.Interface != "" {
-		if err := yq.Set(configPath, basePath+".interface", node.Interface); err != nil {
-			return fmt.Errorf("failed to set interface: %w", err)
-		}
-	}
-	if node.Version != "" {
-		if err := yq.Set(configPath, basePath+".version", node.Version); err != nil {
-			return fmt.Errorf("failed to set version: %w", err)
-		}
-	}
-	if node.SchematicID != "" {
-		if err := yq.Set(configPath, basePath+".schematicId", node.SchematicID); err != nil {
-			return fmt.Errorf("failed to set schematicId: %w", err)
-		}
-	}
-	if node.Maintenance {
-		if err := yq.Set(configPath, basePath+".maintenance", "true"); err != nil {
-			return fmt.Errorf("failed to set maintenance: %w", err)
-		}
-	}
 
-	return nil
+		// Use instance-level defaults from cluster.nodes.talos when not provided
+		c.applyDefaults(node)
+
+		c.Cluster.Nodes.Active[node.Hostname] = *node
+		return nil
+	})
 }
 
-// Delete removes a node from config.yaml
+// Delete removes a node from config.yaml, leaving the machine alone. Remove
+// takes a node out of the cluster first.
 func (m *Manager) Delete(instanceName, nodeIdentifier string) error {
-	// Get node to find hostname
-	node, err := m.Get(instanceName, nodeIdentifier)
-	if err != nil {
-		return err
-	}
-
-	instancePath := m.GetInstancePath(instanceName)
-	configPath := filepath.Join(instancePath, "config.yaml")
-
-	// Delete node from config.yaml
-	// Path: cluster.nodes.active.{hostname}
-	nodePath := fmt.Sprintf("cluster.nodes.active.%s", node.Hostname)
-
-	yq := tools.NewYQ()
-	// Use yq to delete the node
-	_, err = yq.Exec("eval", "-i", fmt.Sprintf("del(%s)", nodePath), configPath)
-	if err != nil {
-		return fmt.Errorf("failed to delete node: %w", err)
-	}
-
-	return nil
+	return m.updateConfig(instanceName, func(c *nodeConfig) error {
+		if _, ok := c.node(nodeIdentifier); !ok {
+			return fmt.Errorf("node %s not found", nodeIdentifier)
+		}
+		delete(c.Cluster.Nodes.Active, nodeIdentifier)
+		return nil
+	})
 }
 
 // DetectHardware queries node hardware information via talosctl
@@ -282,22 +181,18 @@ func (m *Manager) DetectHardware(nodeIP string) (*HardwareInfo, error) {
 	}
 
 	// Get disks
-	disks, err := m.talosctl.GetDisks(nodeIP, insecure)
+	disks, err := listDisks(nodeIP)
 	if err != nil {
 		return nil, fmt.Errorf("failed to detect disks: %w", err)
 	}
-
-	// Select first disk as default
-	var selectedDisk string
-	if len(disks) > 0 {
-		selectedDisk = disks[0].Path
-	}
+	selectedDisk, reason := selectInstallDisk(disks)
 
 	return &HardwareInfo{
 		IP:              nodeIP,
 		Interface:       iface,
 		Disks:           disks,
 		SelectedDisk:    selectedDisk,
+		SelectionReason: reason,
 		MaintenanceMode: true,
 	}, nil
 }
@@ -311,35 +206,68 @@ func (m *Manager) DetectHardware(nodeIP string) (*HardwareInfo, error) {
 // 5. Update state: currentIP=targetIP, maintenance=false, applied=true
 func (m *Manager) Apply(instanceName, nodeIdentifier string, opts ApplyOptions) error {
 	// Get node configuration
-	node, err := m.Get(instanceName, nodeIdentifier)
+	c, err := m.loadConfig(instanceName)
 	if err != nil {
 		return err
 	}
-
-	instancePath := m.GetInstancePath(instanceName)
-	setupDir := filepath.Join(instancePath, "setup", "cluster-nodes")
-	configPath := filepath.Join(instancePath, "config.yaml")
-	yq := tools.NewYQ()
+	node, ok := c.node(nodeIdentifier)
+	if !ok {
+		return fmt.Errorf("node %s not found", nodeIdentifier)
+	}
 
 	// Ensure node has version and schematicId (use cluster defaults if missing)
-	if node.Version == "" {
-		defaultVersion, err := yq.Get(configPath, ".cluster.nodes.talos.version")
-		if err == nil && defaultVersion != "" && defaultVersion != "null" {
-			node.Version = defaultVersion
-		}
+	c.applyDefaults(node)
+
+	setupDir := filepath.Join(m.GetInstancePath(instanceName), "setup", "cluster-nodes")
+	finalConfig, err := m.renderConfig(instanceName, node, setupDir, false)
+	if err != nil {
+		return err
 	}
-	if node.SchematicID == "" {
-		defaultSchematicID, err := yq.Get(configPath, ".cluster.nodes.talos.schematicId")
-		if err == nil && defaultSchematicID != "" && defaultSchematicID != "null" {
-			node.SchematicID = defaultSchematicID
-		}
+
+	// Mark as configured
+	node.Configured = true
+	if err := m.updateNodeStatus(instanceName, node); err != nil {
+		return fmt.Errorf("failed to update node status: %w", err)
+	}
+
+	// Apply config
+	deployIP, maintenanceMode := deployTarget(node)
+	talosconfigPath := tools.GetTalosconfigPath(m.dataDir, instanceName)
+	if err := m.talosctl.ApplyConfig(deployIP, finalConfig, maintenanceMode, talosconfigPath); err != nil {
+		return fmt.Errorf("failed to apply config to %s: %w", deployIP, err)
+	}
+
+	// Keep what the node now runs, for later dry-run diffs
+	if err := m.recordApplied(instanceName, node.Hostname, finalConfig); err != nil {
+		return fmt.Errorf("failed to record applied configuration: %w", err)
+	}
+
+	// Post-application updates: move to production IP, exit maintenance mode
+	node.Applied = true
+	node.CurrentIP = node.TargetIP  // Node now on production IP
+	node.Maintenance = false         // Exit maintenance mode
+	if err := m.updateNodeStatus(instanceName, node); err != nil {
+		return fmt.Errorf("failed to update node status: %w", err)
 	}
 
+	return nil
+}
+
+// renderConfig generates the node's patch and final machine configuration
+// under outDir (patch/ and final/) and returns the final configuration path.
+// A dry run leaves the instance as it is, rendering from the directory's
+// templates when the instance has none yet.
+func (m *Manager) renderConfig(instanceName string, node *Node, outDir string, dryRun bool) (string, error) {
+	instancePath := m.GetInstancePath(instanceName)
+	setupDir := filepath.Join(instancePath, "setup", "cluster-nodes")
+
 	// Always auto-fetch templates if they don't exist
 	templatesDir := filepath.Join(setupDir, "patch.templates")
 	if !m.templatesExist(templatesDir) {
-		if err := m.copyTemplatesFromDirectory(templatesDir); err != nil {
-			return fmt.Errorf("failed to copy templates: %w", err)
+		if dryRun {
+			templatesDir = m.sourceTemplatesDir()
+		} else if err := m.copyTemplatesFromDirectory(templatesDir); err != nil {
+			return "", fmt.Errorf("failed to copy templates: %w", err)
 		}
 	}
 
@@ -354,76 +282,58 @@ func (m *Manager) Apply(instanceName, nodeIdentifier string, opts ApplyOptions)
 
 	// Check if base config exists
 	if _, err := os.Stat(baseConfig); err != nil {
-		return fmt.Errorf("base configuration not found: %s (run cluster config generation first)", baseConfig)
+		return "", fmt.Errorf("base configuration not found: %s (run cluster config generation first)", baseConfig)
 	}
 
 	// Generate node-specific patch file
-	patchFile, err := m.generateNodePatch(instanceName, node, setupDir)
+	patchFile, err := m.generateNodePatch(instanceName, node, templatesDir, outDir)
 	if err != nil {
-		return fmt.Errorf("failed to generate node patch: %w", err)
+		return "", fmt.Errorf("failed to generate node patch: %w", err)
 	}
 
 	// Generate final machine configuration (base + patch)
-	finalConfig, err := m.generateFinalConfig(node, baseConfig, patchFile, setupDir)
+	finalConfig, err := m.generateFinalConfig(node, baseConfig, patchFile, outDir)
 	if err != nil {
-		return fmt.Errorf("failed to generate final configuration: %w", err)
-	}
-
-	// Mark as configured
-	node.Configured = true
-	if err := m.updateNodeStatus(instanceName, node); err != nil {
-		return fmt.Errorf("failed to update node status: %w", err)
+		return "", fmt.Errorf("failed to generate final configuration: %w", err)
 	}
 
-	// Apply configuration to node
-	// Determine which IP to use and whether node is in maintenance mode
-	//
-	// Three scenarios:
-	// 1. Production node (currentIP empty/same, maintenance=false): use targetIP, no --insecure
-	// 2. IP changing (currentIP != targetIP): use currentIP, --insecure (always maintenance)
-	// 3. Maintenance at target (maintenance=true, no IP change): use targetIP, --insecure
-	var deployIP string
-	var maintenanceMode bool
+	return finalConfig, nil
+}
 
+// deployTarget returns the IP to apply configuration to and whether the
+// node is in maintenance mode there
+//
+// Three scenarios:
+// 1. Production node (currentIP empty/same, maintenance=false): use targetIP, no --insecure
+// 2. IP changing (currentIP != targetIP): use currentIP, --insecure (always maintenance)
+// 3. Maintenance at target (maintenance=true, no IP change): use targetIP, --insecure
+func deployTarget(node *Node) (string, bool) {
 	if node.CurrentIP != "" && node.CurrentIP != node.TargetIP {
 		// Scenario 2: IP is changing - node is at currentIP, moving to targetIP
-		deployIP = node.CurrentIP
-		maintenanceMode = true
-	} else if node.Maintenance {
-		// Scenario 3: Explicit maintenance mode, no IP change
-		deployIP = node.TargetIP
-		maintenanceMode = true
-	} else {
-		// Scenario 1: Production node at target IP
-		deployIP = node.TargetIP
-		maintenanceMode = false
+		return node.CurrentIP, true
 	}
-
-	// Apply config
-	talosconfigPath := tools.GetTalosconfigPath(m.dataDir, instanceName)
-	if err := m.talosctl.ApplyConfig(deployIP, finalConfig, maintenanceMode, talosconfigPath); err != nil {
-		return fmt.Errorf("failed to apply config to %s: %w", deployIP, err)
-	}
-
-	// Post-application updates: move to production IP, exit maintenance mode
-	node.Applied = true
-	node.CurrentIP = node.TargetIP  // Node now on production IP
-	node.Maintenance = false         // Exit maintenance mode
-	if err := m.updateNodeStatus(instanceName, node); err != nil {
-		return fmt.Errorf("failed to update node status: %w", err)
+	if node.Maintenance {
+		// Scenario 3: Explicit maintenance mode, no IP change
+		return node.TargetIP, true
 	}
+	// Scenario 1: Production node at target IP
+	return node.TargetIP, false
+}
 
-	return nil
+// getAppliedConfigPath returns where the configuration last applied to a
+// node is kept
+func (m *Manager) getAppliedConfigPath(instanceName, hostname string) string {
+	return filepath.Join(m.GetInstancePath(instanceName), "setup", "cluster-nodes", "applied", hostname+".yaml")
 }
 
-// generateNodePatch creates a node-specific patch file from template
-func (m *Manager) generateNodePatch(instanceName string, node *Node, setupDir string) (string, error) {
+// generateNodePatch renders the node's patch from its role's template
+func (m *Manager) generateNodePatch(instanceName string, node *Node, templatesDir, outDir string) (string, error) {
 	// Determine template file based on role
 	var templateFile string
 	if node.Role == "controlplane" {
-		templateFile = filepath.Join(setupDir, "patch.templates", "controlplane.yaml")
+		templateFile = filepath.Join(templatesDir, "controlplane.yaml")
 	} else {
-		templateFile = filepath.Join(setupDir, "patch.templates", "worker.yaml")
+		templateFile = filepath.Join(templatesDir, "worker.yaml")
 	}
 
 	// Read template
@@ -432,37 +342,33 @@ func (m *Manager) generateNodePatch(instanceName string, node *Node, setupDir st
 		return "", fmt.Errorf("failed to read template %s: %w", templateFile, err)
 	}
 
-	// Stage 1: Apply simple variable substitutions (like v.PoC does with sed)
-	patchContent := string(templateContent)
-	patchContent = strings.ReplaceAll(patchContent, "{{NODE_NAME}}", node.Hostname)
-	patchContent = strings.ReplaceAll(patchContent, "{{NODE_IP}}", node.TargetIP)
-	patchContent = strings.ReplaceAll(patchContent, "{{SCHEMATIC_ID}}", node.SchematicID)
-	patchContent = strings.ReplaceAll(patchContent, "{{VERSION}}", node.Version)
-
-	// Stage 2: Process through gomplate with config.yaml context (like v.PoC does with wild-compile-template)
-	instancePath := m.GetInstancePath(instanceName)
-	configPath := filepath.Join(instancePath, "config.yaml")
-
-	// Use gomplate to process template with config context
-	cmd := exec.Command("gomplate", "-c", ".="+configPath)
-	cmd.Stdin = strings.NewReader(patchContent)
-
-	output, err := cmd.CombinedOutput()
+	// Render in-process with config.yaml at the root, as gomplate did
+	source := legacyPlaceholders(node).Replace(string(templateContent))
+	data, err := m.patchData(instanceName, node)
 	if err != nil {
-		return "", fmt.Errorf("failed to process template with gomplate: %w\nOutput: %s", err, string(output))
+		return "", err
+	}
+	processedPatch, err := renderPatch(filepath.Base(templateFile), source, data, m.secretLookup(instanceName))
+	if err != nil {
+		return "", err
+	}
+	if processedPatch, err = withNodeMetadata(processedPatch, node); err != nil {
+		return "", err
+	}
+	if processedPatch, err = withStorage(processedPatch, node); err != nil {
+		return "", err
 	}
-
-	processedPatch := string(output)
 
 	// Create patch directory
-	patchDir := filepath.Join(setupDir, "patch")
+	patchDir := filepath.Join(outDir, "patch")
 	if err := os.MkdirAll(patchDir, 0755); err != nil {
 		return "", fmt.Errorf("failed to create patch directory: %w", err)
 	}
 
-	// Write patch file
+	// Write patch file; it is rendered from the config and secrets, so only
+	// the daemon may read it
 	patchFile := filepath.Join(patchDir, node.Hostname+".yaml")
-	if err := os.WriteFile(patchFile, []byte(processedPatch), 0644); err != nil {
+	if err := storage.WriteFile(patchFile, processedPatch, 0600); err != nil {
 		return "", fmt.Errorf("failed to write patch file: %w", err)
 	}
 
@@ -470,9 +376,9 @@ func (m *Manager) generateNodePatch(instanceName string, node *Node, setupDir st
 }
 
 // generateFinalConfig merges base config + patch to create final machine config
-func (m *Manager) generateFinalConfig(node *Node, baseConfig, patchFile, setupDir string) (string, error) {
+func (m *Manager) generateFinalConfig(node *Node, baseConfig, patchFile, outDir string) (string, error) {
 	// Create final config directory
-	finalDir := filepath.Join(setupDir, "final")
+	finalDir := filepath.Join(outDir, "final")
 	if err := os.MkdirAll(finalDir, 0755); err != nil {
 		return "", fmt.Errorf("failed to create final directory: %w", err)
 	}
@@ -504,11 +410,15 @@ func (m *Manager) templatesExist(templatesDir string) bool {
 	return err1 == nil && err2 == nil
 }
 
+// sourceTemplatesDir returns the directory/setup/cluster-nodes/patch.templates
+// directory. It should be in the same parent as the data directory.
+func (m *Manager) sourceTemplatesDir() string {
+	return filepath.Join(filepath.Dir(m.dataDir), "directory", "setup", "cluster-nodes", "patch.templates")
+}
+
 // copyTemplatesFromDirectory copies patch templates from directory/ to instance
 func (m *Manager) copyTemplatesFromDirectory(destDir string) error {
-	// Find the directory/setup/cluster-nodes/patch.templates directory
-	// It should be in the same parent as the data directory
-	sourceDir := filepath.Join(filepath.Dir(m.dataDir), "directory", "setup", "cluster-nodes", "patch.templates")
+	sourceDir := m.sourceTemplatesDir()
 
 	// Check if source directory exists
 	if _, err := os.Stat(sourceDir); err != nil {
@@ -546,118 +456,101 @@ func (m *Manager) copyFile(src, dst string) error {
 		return err
 	}
 
-	return os.WriteFile(dst, data, 0644)
+	return storage.WriteFile(dst, data, 0644)
 }
 
 // updateNodeStatus updates node status flags in config.yaml
 func (m *Manager) updateNodeStatus(instanceName string, node *Node) error {
-	instancePath := m.GetInstancePath(instanceName)
-	configPath := filepath.Join(instancePath, "config.yaml")
-	basePath := fmt.Sprintf("cluster.nodes.active.%s", node.Hostname)
-
-	yq := tools.NewYQ()
-
-	// Update maintenance flag
-	if node.Maintenance {
-		if err := yq.Set(configPath, basePath+".maintenance", "true"); err != nil {
-			return err
-		}
-	} else {
-		if err := yq.Set(configPath, basePath+".maintenance", "false"); err != nil {
-			return err
+	return m.updateConfig(instanceName, func(c *nodeConfig) error {
+		current, ok := c.node(node.Hostname)
+		if !ok {
+			return fmt.Errorf("node %s not found", node.Hostname)
 		}
-	}
 
-	// Update currentIP (may have changed after application)
-	if node.CurrentIP != "" {
-		if err := yq.Set(configPath, basePath+".currentIp", node.CurrentIP); err != nil {
-			return err
-		}
-	}
+		current.Maintenance = node.Maintenance
 
-	// Update configured flag
-	if node.Configured {
-		if err := yq.Set(configPath, basePath+".configured", "true"); err != nil {
-			return err
+		// Update currentIP (may have changed after application)
+		if node.CurrentIP != "" {
+			current.CurrentIP = node.CurrentIP
 		}
-	}
-
-	// Update applied flag
-	if node.Applied {
-		if err := yq.Set(configPath, basePath+".applied", "true"); err != nil {
-			return err
+		if node.Configured {
+			current.Configured = true
+		}
+		if node.Applied {
+			current.Applied = true
 		}
-	}
 
-	return nil
+		c.Cluster.Nodes.Active[node.Hostname] = *current
+		return nil
+	})
 }
 
 // Update modifies existing node configuration with partial updates
 func (m *Manager) Update(instanceName string, hostname string, updates map[string]interface{}) error {
-	// Get existing node
-	node, err := m.Get(instanceName, hostname)
-	if err != nil {
-		return fmt.Errorf("node %s not found", hostname)
-	}
+	return m.updateConfig(instanceName, func(c *nodeConfig) error {
+		node, ok := c.node(hostname)
+		if !ok {
+			return fmt.Errorf("node %s not found", hostname)
+		}
 
-	instancePath := m.GetInstancePath(instanceName)
-	configPath := filepath.Join(instancePath, "config.yaml")
-	basePath := fmt.Sprintf("cluster.nodes.active.%s", hostname)
-	yq := tools.NewYQ()
-
-	// Apply partial updates
-	for key, value := range updates {
-		switch key {
-		case "target_ip":
-			if strVal, ok := value.(string); ok {
-				node.TargetIP = strVal
-				if err := yq.Set(configPath, basePath+".targetIp", strVal); err != nil {
-					return fmt.Errorf("failed to update targetIp: %w", err)
+		// Apply partial updates
+		var err error
+		for key, value := range updates {
+			switch key {
+			case "target_ip":
+				if strVal, ok := value.(string); ok {
+					node.TargetIP = strVal
 				}
-			}
-		case "current_ip":
-			if strVal, ok := value.(string); ok {
-				node.CurrentIP = strVal
-				node.Maintenance = true // Auto-set maintenance when currentIP changes
-				if err := yq.Set(configPath, basePath+".currentIp", strVal); err != nil {
-					return fmt.Errorf("failed to update currentIp: %w", err)
+			case "current_ip":
+				if strVal, ok := value.(string); ok {
+					node.CurrentIP = strVal
+					node.Maintenance = true // Auto-set maintenance when currentIP changes
 				}
-				if err := yq.Set(configPath, basePath+".maintenance", "true"); err != nil {
-					return fmt.Errorf("failed to set maintenance: %w", err)
+			case "disk":
+				if strVal, ok := value.(string); ok {
+					node.Disk = strVal
 				}
-			}
-		case "disk":
-			if strVal, ok := value.(string); ok {
-				node.Disk = strVal
-				if err := yq.Set(configPath, basePath+".disk", strVal); err != nil {
-					return fmt.Errorf("failed to update disk: %w", err)
+			case "interface":
+				if strVal, ok := value.(string); ok {
+					node.Interface = strVal
 				}
-			}
-		case "interface":
-			if strVal, ok := value.(string); ok {
-				node.Interface = strVal
-				if err := yq.Set(configPath, basePath+".interface", strVal); err != nil {
-					return fmt.Errorf("failed to update interface: %w", err)
+			case "schematic_id":
+				if strVal, ok := value.(string); ok {
+					node.SchematicID = strVal
 				}
-			}
-		case "schematic_id":
-			if strVal, ok := value.(string); ok {
-				node.SchematicID = strVal
-				if err := yq.Set(configPath, basePath+".schematicId", strVal); err != nil {
-					return fmt.Errorf("failed to update schematicId: %w", err)
+			case "labels":
+				if node.Labels, err = updateMetadata(key, node.Labels, value); err != nil {
+					return err
 				}
-			}
-		case "maintenance":
-			if boolVal, ok := value.(bool); ok {
-				node.Maintenance = boolVal
-				if err := yq.Set(configPath, basePath+".maintenance", fmt.Sprintf("%t", boolVal)); err != nil {
-					return fmt.Errorf("failed to update maintenance: %w", err)
+			case "taints":
+				if node.Taints, err = updateMetadata(key, node.Taints, value); err != nil {
+					return err
+				}
+			case "annotations":
+				if node.Annotations, err = updateMetadata(key, node.Annotations, value); err != nil {
+					return err
+				}
+			case "storage":
+				if node.Storage, err = updateStorage(node.Storage, value); err != nil {
+					return err
 				}
 			}
 		}
-	}
+		if err := validateMetadata(node); err != nil {
+			return err
+		}
+		if err := validateStorage(node); err != nil {
+			return err
+		}
 
-	return nil
+		// An explicit maintenance flag wins over the one implied by current_ip
+		if boolVal, ok := updates["maintenance"].(bool); ok {
+			node.Maintenance = boolVal
+		}
+
+		c.Cluster.Nodes.Active[hostname] = *node
+		return nil
+	})
 }
 
 // FetchTemplates copies patch templates from directory/ to instance
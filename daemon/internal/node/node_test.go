@@ -0,0 +1,270 @@
+package node
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testConfig = `cluster:
+  name: home
+  nodes:
+    talos:
+      version: v1.10.3
+      schematicId: abc123
+    active:
+      # First control plane
+      control-1:
+        role: controlplane
+        targetIp: 192.168.8.31
+        disk: /dev/sda
+        maintenance: "true" # written by yq as a string
+        configured: true
+        labels:
+          zone: rack-a
+        notes: spare power supply
+      worker-1:
+        role: worker
+        targetIp: 192.168.8.41
+        disk: /dev/nvme0n1
+        version: v1.10.1
+`
+
+func newTestManager(t testing.TB, config string) *Manager {
+	t.Helper()
+	m := NewManager(t.TempDir())
+	configPath := m.getConfigPath("home")
+	if err := os.MkdirAll(filepath.Dir(configPath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(configPath, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func readConfig(t *testing.T, m *Manager) string {
+	t.Helper()
+	data, err := os.ReadFile(m.getConfigPath("home"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestList(t *testing.T) {
+	m := newTestManager(t, testConfig)
+
+	nodes, err := m.List("home")
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(nodes) != 2 || nodes[0].Hostname != "control-1" || nodes[1].Hostname != "worker-1" {
+		t.Fatalf("unexpected nodes: %+v", nodes)
+	}
+
+	control := nodes[0]
+	if control.Role != "controlplane" || control.TargetIP != "192.168.8.31" || !control.Maintenance || !control.Configured || control.Applied {
+		t.Errorf("unexpected control-1: %+v", control)
+	}
+	if control.Labels["zone"] != "rack-a" {
+		t.Errorf("labels not read: %+v", control.Labels)
+	}
+	if control.Extra["notes"] != "spare power supply" {
+		t.Errorf("unknown keys not kept: %+v", control.Extra)
+	}
+
+	if _, err := m.Get("home", "worker-2"); err == nil {
+		t.Error("expected an error for an unknown node")
+	}
+}
+
+func TestList_NoNodes(t *testing.T) {
+	m := newTestManager(t, "cluster:\n  nodes:\n    active: {}\n")
+
+	nodes, err := m.List("home")
+	if err != nil || nodes == nil || len(nodes) != 0 {
+		t.Errorf("List = %+v, %v; want an empty list", nodes, err)
+	}
+}
+
+func TestAddUpdateDelete(t *testing.T) {
+	m := newTestManager(t, testConfig)
+
+	node := &Node{Hostname: "worker-2", Role: "worker", Disk: "/dev/sdb", CurrentIP: "192.168.8.180"}
+	if err := m.Add("home", node); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if err := m.Add("home", node); err == nil {
+		t.Error("adding a node twice should fail")
+	}
+
+	added, err := m.Get("home", "worker-2")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if !added.Maintenance || added.Version != "v1.10.3" || added.SchematicID != "abc123" {
+		t.Errorf("defaults not applied: %+v", added)
+	}
+
+	updates := map[string]interface{}{"target_ip": "192.168.8.42", "current_ip": "192.168.8.181", "maintenance": false}
+	if err := m.Update("home", "worker-2", updates); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	updated, _ := m.Get("home", "worker-2")
+	if updated.TargetIP != "192.168.8.42" || updated.CurrentIP != "192.168.8.181" || updated.Maintenance {
+		t.Errorf("unexpected node after update: %+v", updated)
+	}
+
+	if err := m.Delete("home", "worker-2"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := m.Get("home", "worker-2"); err == nil {
+		t.Error("node still present after delete")
+	}
+
+	config := readConfig(t, m)
+	for _, want := range []string{"name: home", "# First control plane", "zone: rack-a", "version: v1.10.1"} {
+		if !strings.Contains(config, want) {
+			t.Errorf("config lost %q:\n%s", want, config)
+		}
+	}
+}
+
+func TestAdd_EmptyActiveMap(t *testing.T) {
+	m := newTestManager(t, "cluster:\n  nodes:\n    active: {}\n")
+
+	if err := m.Add("home", &Node{Hostname: "control-1", Role: "controlplane", Disk: "/dev/sda"}); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if config := readConfig(t, m); !strings.Contains(config, "    active:\n      control-1:\n        role: controlplane\n") {
+		t.Errorf("node not written as a block mapping:\n%s", config)
+	}
+}
+
+func TestUpdateNodeStatus_RoundTrip(t *testing.T) {
+	m := newTestManager(t, testConfig)
+
+	node, _ := m.Get("home", "control-1")
+	node.Maintenance = false
+	node.Applied = true
+	if err := m.updateNodeStatus("home", node); err != nil {
+		t.Fatalf("updateNodeStatus failed: %v", err)
+	}
+
+	got, _ := m.Get("home", "control-1")
+	if got.Maintenance || !got.Applied || !got.Configured || got.Labels["zone"] != "rack-a" || got.Extra["notes"] == nil {
+		t.Errorf("unexpected node after status update: %+v", got)
+	}
+
+	// Untouched nodes and keys are written back unchanged
+	config := readConfig(t, m)
+	if !strings.Contains(config, "      worker-1:\n        role: worker\n        targetIp: 192.168.8.41\n        disk: /dev/nvme0n1\n        version: v1.10.1\n") {
+		t.Errorf("worker-1 entry changed:\n%s", config)
+	}
+	if strings.Contains(config, "maintenance") {
+		t.Errorf("cleared maintenance flag still written:\n%s", config)
+	}
+}
+
+func TestUpdateConfig_KeepsUntouchedEntries(t *testing.T) {
+	const config = `cluster:
+  nodes:
+    active:
+      control-1:
+        role: controlplane
+        targetIp: ""
+        currentIp: ""
+        maintenance: false
+        configured: "true"
+      worker-1:
+        role: worker
+        targetIp: 192.168.8.41
+`
+	m := newTestManager(t, config)
+
+	if err := m.Update("home", "worker-1", map[string]interface{}{"target_ip": "192.168.8.42"}); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	want := strings.Replace(config, "192.168.8.41", "192.168.8.42", 1)
+	if got := readConfig(t, m); got != want {
+		t.Errorf("untouched keys rewritten:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+// benchmarkConfig returns a config with n nodes, each with every field set
+func benchmarkConfig(n int) string {
+	var b strings.Builder
+	b.WriteString("cluster:\n  nodes:\n    talos:\n      version: v1.10.3\n    active:\n")
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, "      node-%d:\n        role: worker\n        targetIp: 192.168.8.%d\n        currentIp: 192.168.8.%d\n"+
+			"        interface: eth0\n        disk: /dev/sda\n        version: v1.10.3\n        schematicId: abc123\n"+
+			"        maintenance: false\n        configured: true\n        applied: true\n", i, 40+i, 40+i)
+	}
+	return b.String()
+}
+
+func BenchmarkList(b *testing.B) {
+	m := newTestManager(b, benchmarkConfig(6))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := m.List("home"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkGet(b *testing.B) {
+	m := newTestManager(b, benchmarkConfig(6))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := m.Get("home", "node-5"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkUpdate(b *testing.B) {
+	m := newTestManager(b, benchmarkConfig(6))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := m.Update("home", "node-5", map[string]interface{}{"disk": fmt.Sprintf("/dev/sd%c", 'a'+i%2)}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkListYQ lists nodes the way List used to, with one yq call for the
+// hostnames and one per field, for comparison. It needs yq v4 on the PATH.
+func BenchmarkListYQ(b *testing.B) {
+	m := newTestManager(b, benchmarkConfig(6))
+	configPath := m.getConfigPath("home")
+
+	yq, err := exec.LookPath("yq")
+	if err == nil {
+		err = exec.Command(yq, "eval", ".", configPath).Run()
+	}
+	if err != nil {
+		b.Skip("yq v4 not installed")
+	}
+	fields := []string{"role", "targetIp", "currentIp", "disk", "interface", "version", "schematicId", "maintenance", "configured", "applied"}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := exec.Command(yq, "eval", ".cluster.nodes.active | keys", configPath).Run(); err != nil {
+			b.Fatal(err)
+		}
+		for n := 0; n < 6; n++ {
+			for _, field := range fields {
+				expr := fmt.Sprintf(".cluster.nodes.active.node-%d.%s", n, field)
+				if err := exec.Command(yq, "eval", expr, configPath).Run(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package node
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/wild-cloud/wild-central/daemon/internal/operations"
+	"github.com/wild-cloud/wild-central/daemon/internal/tools"
+)
+
+// RemoveOptions adjusts how a node is removed from the cluster
+type RemoveOptions struct {
+	Reset bool `json:"reset,omitempty"` // Wipe the machine with talosctl reset once it has left
+	Force bool `json:"force,omitempty"` // Keep going when the node cannot be drained or reset, e.g. because it is dead
+}
+
+// CheckRemove checks that a node can be removed. Removing the only running
+// control plane would leave the cluster without etcd and is refused.
+func (m *Manager) CheckRemove(instanceName, hostname string) (*Node, error) {
+	c, err := m.loadConfig(instanceName)
+	if err != nil {
+		return nil, err
+	}
+	node, ok := c.node(hostname)
+	if !ok {
+		return nil, fmt.Errorf("node %s not found", hostname)
+	}
+	c.applyDefaults(node)
+
+	if node.Role == "controlplane" && joined(node) {
+		for other := range c.Cluster.Nodes.Active {
+			n, _ := c.node(other)
+			if other != hostname && n.Role == "controlplane" && joined(n) {
+				return node, nil
+			}
+		}
+		return nil, fmt.Errorf("%s is the last control plane; removing it would destroy the cluster", hostname)
+	}
+	return node, nil
+}
+
+// joined reports whether a node runs an applied configuration, so it is
+// part of the cluster
+func joined(node *Node) bool {
+	return node.Applied && !node.Maintenance
+}
+
+// Remove takes a node out of the cluster and forgets it. A node that has
+// joined is cordoned and drained, leaves etcd if it is a control plane, is
+// deleted from Kubernetes and, when asked, reset. Its generated files and
+// inventory are deleted, and only then is it removed from config.yaml, so a
+// failed removal can be retried.
+func (m *Manager) Remove(instanceName, hostname string, opts RemoveOptions, out *operations.Output) error {
+	node, err := m.CheckRemove(instanceName, hostname)
+	if err != nil {
+		return err
+	}
+
+	opsMgr := operations.NewManager(m.dataDir)
+	talosconfigPath := tools.GetTalosconfigPath(m.dataDir, instanceName)
+	kubeconfigPath := tools.GetKubeconfigPath(m.dataDir, instanceName)
+
+	progress := func(pct int, format string, args ...interface{}) {
+		message := fmt.Sprintf(format, args...)
+		if out.ID() != "" {
+			opsMgr.UpdateProgress(instanceName, out.ID(), pct, message)
+		}
+		out.Printf("%s\n", message)
+	}
+	// tolerate reports a failed step and carries on when forced
+	tolerate := func(step string, err error) error {
+		if err == nil {
+			return nil
+		}
+		if opts.Force {
+			out.Printf("⚠️  %s failed, continuing: %v\n", step, err)
+			return nil
+		}
+		return fmt.Errorf("%s: %w", step, err)
+	}
+	kubectl := func(args ...string) error {
+		return out.Run(exec.Command("kubectl", append([]string{"--kubeconfig", kubeconfigPath}, args...)...))
+	}
+
+	if joined(node) {
+		progress(10, "🚧 Cordoning %s", hostname)
+		if err := tolerate("cordoning "+hostname, kubectl("cordon", hostname)); err != nil {
+			return err
+		}
+
+		progress(20, "🚰 Draining %s", hostname)
+		err := kubectl("drain", hostname, "--ignore-daemonsets", "--delete-emptydir-data",
+			"--timeout", waitTimeout.String())
+		if err := tolerate("draining "+hostname, err); err != nil {
+			return err
+		}
+
+		if node.Role == "controlplane" {
+			progress(40, "🗳️  Removing %s from etcd", hostname)
+			if err := m.leaveEtcd(instanceName, node, out); err != nil {
+				return fmt.Errorf("removing %s from etcd: %w", hostname, err)
+			}
+		}
+
+		progress(60, "🗑️  Deleting Kubernetes node %s", hostname)
+		if err := kubectl("delete", "node", hostname, "--ignore-not-found"); err != nil {
+			return fmt.Errorf("deleting node %s: %w", hostname, err)
+		}
+
+		if opts.Reset {
+			progress(70, "🧹 Resetting %s (%s)", hostname, node.TargetIP)
+			// Not graceful: the node has already been drained and left etcd
+			cmd := exec.Command("talosctl", "reset", "--nodes", node.TargetIP, "--graceful=false", "--reboot")
+			tools.WithTalosconfig(cmd, talosconfigPath)
+			if err := tolerate("resetting "+hostname, out.Run(cmd)); err != nil {
+				return err
+			}
+		}
+	}
+
+	progress(90, "📁 Deleting generated files and inventory of %s", hostname)
+	setupDir := filepath.Join(m.GetInstancePath(instanceName), "setup", "cluster-nodes")
+	for _, dir := range []string{"patch", "final", "applied", "inventory"} {
+		path := filepath.Join(setupDir, dir, hostname+".yaml")
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to delete %s: %w", path, err)
+		}
+	}
+
+	if err := m.Delete(instanceName, hostname); err != nil {
+		return err
+	}
+	out.Printf("✅ %s removed\n", hostname)
+	return nil
+}
+
+// leaveEtcd removes a control plane from etcd. The node leaves by itself
+// when it is reachable; otherwise another control plane removes its member.
+func (m *Manager) leaveEtcd(instanceName string, node *Node, out *operations.Output) error {
+	talosconfigPath := tools.GetTalosconfigPath(m.dataDir, instanceName)
+
+	cmd := exec.Command("talosctl", "etcd", "leave", "--nodes", node.TargetIP)
+	tools.WithTalosconfig(cmd, talosconfigPath)
+	leaveErr := out.Run(cmd)
+	if leaveErr == nil {
+		return nil
+	}
+	out.Printf("⚠️  %s could not leave etcd itself (%v); removing its member\n", node.Hostname, leaveErr)
+
+	c, err := m.loadConfig(instanceName)
+	if err != nil {
+		return err
+	}
+	var peerIP string
+	for hostname := range c.Cluster.Nodes.Active {
+		other, _ := c.node(hostname)
+		if hostname != node.Hostname && other.Role == "controlplane" && joined(other) {
+			peerIP = other.TargetIP
+			break
+		}
+	}
+	if peerIP == "" {
+		return leaveErr
+	}
+
+	members, err := talosctlOutput(talosconfigPath, "etcd", "members", "--nodes", peerIP)
+	if err != nil {
+		return err
+	}
+	memberID, ok := etcdMemberID(members, node.Hostname)
+	if !ok {
+		out.Printf("%s is not an etcd member\n", node.Hostname)
+		return nil
+	}
+
+	cmd = exec.Command("talosctl", "etcd", "remove-member", memberID, "--nodes", peerIP)
+	tools.WithTalosconfig(cmd, talosconfigPath)
+	return out.Run(cmd)
+}
+
+// etcdMemberID finds a member's ID in talosctl etcd members output:
+//
+//	NODE           ID                 HOSTNAME    PEER URLS                  CLIENT URLS                LEARNER
+//	192.168.8.31   2d0b9ae4d6b1c3a1   control-1   https://192.168.8.31:2380  https://192.168.8.31:2379  false
+func etcdMemberID(output []byte, hostname string) (string, bool) {
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	idColumn, hostnameColumn := -1, -1
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if idColumn < 0 {
+			for i, field := range fields {
+				switch field {
+				case "ID":
+					idColumn = i
+				case "HOSTNAME":
+					hostnameColumn = i
+				}
+			}
+			if hostnameColumn < 0 {
+				idColumn = -1
+			}
+			continue
+		}
+		if len(fields) > hostnameColumn && len(fields) > idColumn && fields[hostnameColumn] == hostname {
+			return fields[idColumn], true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,91 @@
+package node
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/wild-cloud/wild-central/daemon/internal/operations"
+)
+
+const clusterConfig = `cluster:
+  name: home
+  nodes:
+    active:
+      control-1:
+        role: controlplane
+        targetIp: 192.168.8.31
+        applied: true
+      control-2:
+        role: controlplane
+        targetIp: 192.168.8.32
+        maintenance: true
+      worker-1:
+        role: worker
+        targetIp: 192.168.8.41
+        applied: true
+`
+
+func TestCheckRemove(t *testing.T) {
+	m := newTestManager(t, clusterConfig)
+
+	if _, err := m.CheckRemove("home", "control-1"); err == nil || !strings.Contains(err.Error(), "last control plane") {
+		t.Errorf("removing the only running control plane: err = %v", err)
+	}
+	for _, hostname := range []string{"control-2", "worker-1"} {
+		if _, err := m.CheckRemove("home", hostname); err != nil {
+			t.Errorf("removing %s: %v", hostname, err)
+		}
+	}
+	if _, err := m.CheckRemove("home", "worker-9"); err == nil {
+		t.Error("expected an error for an unknown node")
+	}
+}
+
+func TestRemove_NotJoined(t *testing.T) {
+	m := newTestManager(t, clusterConfig)
+
+	// A node that never joined has nothing to leave; only its files go
+	setupDir := filepath.Join(m.GetInstancePath("home"), "setup", "cluster-nodes")
+	var files []string
+	for _, dir := range []string{"patch", "final"} {
+		path := filepath.Join(setupDir, dir, "control-2.yaml")
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("machine: {}\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, path)
+	}
+
+	if err := m.Remove("home", "control-2", RemoveOptions{}, operations.NewOutput("", nil)); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	for _, path := range files {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s not deleted", path)
+		}
+	}
+	if _, err := m.Get("home", "control-2"); err == nil {
+		t.Error("node still in config.yaml")
+	}
+	if _, err := m.Get("home", "control-1"); err != nil {
+		t.Errorf("other nodes lost: %v", err)
+	}
+}
+
+func TestEtcdMemberID(t *testing.T) {
+	output := []byte(`NODE           ID                 HOSTNAME    PEER URLS                   CLIENT URLS                 LEARNER
+192.168.8.31   2d0b9ae4d6b1c3a1   control-1   https://192.168.8.31:2380   https://192.168.8.31:2379   false
+192.168.8.31   7f3e1c0a9b8d6e52   control-2   https://192.168.8.32:2380   https://192.168.8.32:2379   false
+`)
+
+	if id, ok := etcdMemberID(output, "control-2"); !ok || id != "7f3e1c0a9b8d6e52" {
+		t.Errorf("etcdMemberID(control-2) = %q, %v", id, ok)
+	}
+	if _, ok := etcdMemberID(output, "control-3"); ok {
+		t.Error("found a member that does not exist")
+	}
+}
@@ -0,0 +1,298 @@
+package node
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Storage roles of a node's additional disks
+const (
+	StorageRoleLonghorn = "longhorn" // Longhorn data disk, mounted at /var/lib/longhorn
+	StorageRoleVolume   = "volume"   // General purpose volume
+)
+
+// userVolumeDir is where Talos mounts user volumes, each under its name
+const userVolumeDir = "/var/mnt"
+
+var (
+	// Talos volume names
+	volumeNamePattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,30}[a-z0-9])?$`)
+	// Sizes as Talos takes them, e.g. 100GB or 512GiB
+	volumeSizePattern = regexp.MustCompile(`^[0-9]+(\.[0-9]+)?\s*([KMGTP]i?B)$`)
+)
+
+// StorageVolume is an additional disk of a node, keyed by name under the
+// node's storage in config.yaml:
+//
+//	storage:
+//	  longhorn:
+//	    role: longhorn
+//	    disk: /dev/sdb
+//
+// A volume mounted at /var/mnt/<name>, the default for the volume role,
+// becomes a Talos user volume, which may use xfs or ext4. Any other mount
+// path, such as Longhorn's, is a machine.disks partition, which Talos
+// formats as xfs.
+type StorageVolume struct {
+	Role       string `yaml:"role" json:"role"`
+	Disk       string `yaml:"disk" json:"disk"`
+	MountPath  string `yaml:"mountPath,omitempty" json:"mount_path,omitempty"`
+	Filesystem string `yaml:"filesystem,omitempty" json:"filesystem,omitempty"` // xfs (default) or ext4
+	Size       string `yaml:"size,omitempty" json:"size,omitempty"`             // The whole disk when empty
+}
+
+// StorageError is a storage volume that cannot be rendered
+type StorageError struct {
+	Volume  string
+	Message string
+}
+
+func (e *StorageError) Error() string {
+	return fmt.Sprintf("storage %s: %s", e.Volume, e.Message)
+}
+
+// mountPath returns where the volume is mounted, defaulting by role
+func (v StorageVolume) mountPath(name string) string {
+	switch {
+	case v.MountPath != "":
+		return path.Clean(v.MountPath)
+	case v.Role == StorageRoleLonghorn:
+		return "/var/lib/longhorn"
+	}
+	return path.Join(userVolumeDir, name)
+}
+
+// userVolume reports whether the volume is a Talos user volume rather than
+// a machine.disks partition
+func (v StorageVolume) userVolume(name string) bool {
+	return v.mountPath(name) == path.Join(userVolumeDir, name)
+}
+
+func (v StorageVolume) filesystem() string {
+	if v.Filesystem == "" {
+		return "xfs"
+	}
+	return v.Filesystem
+}
+
+// validateStorage checks a node's storage volumes
+func validateStorage(node *Node) error {
+	disks := make(map[string]string, len(node.Storage))
+	for _, name := range sortedVolumeNames(node.Storage) {
+		volume := node.Storage[name]
+		fail := func(format string, args ...interface{}) error {
+			return &StorageError{name, fmt.Sprintf(format, args...)}
+		}
+
+		if !volumeNamePattern.MatchString(name) {
+			return fail("name must be lowercase letters, digits and dashes, at most 32 characters")
+		}
+		if volume.Role != StorageRoleLonghorn && volume.Role != StorageRoleVolume {
+			return fail("role must be %q or %q", StorageRoleLonghorn, StorageRoleVolume)
+		}
+		if !strings.HasPrefix(volume.Disk, "/dev/") {
+			return fail("disk must be a device path such as /dev/sdb")
+		}
+		if volume.Disk == node.Disk {
+			return fail("%s is the install disk", volume.Disk)
+		}
+		if other, ok := disks[volume.Disk]; ok {
+			return fail("%s is already used by %s", volume.Disk, other)
+		}
+		disks[volume.Disk] = name
+
+		mountPath := volume.mountPath(name)
+		if !strings.HasPrefix(mountPath, "/var/") {
+			return fail("mount path must be under /var, the only writable part of a Talos node")
+		}
+		if strings.HasPrefix(mountPath, userVolumeDir+"/") && !volume.userVolume(name) {
+			return fail("volumes under %s are mounted at %s", userVolumeDir, path.Join(userVolumeDir, name))
+		}
+		switch volume.filesystem() {
+		case "xfs":
+		case "ext4":
+			if !volume.userVolume(name) {
+				return fail("only volumes mounted at %s can use ext4; Talos formats other mount paths as xfs", path.Join(userVolumeDir, name))
+			}
+		default:
+			return fail("filesystem must be xfs or ext4")
+		}
+		if volume.Size != "" && !volumeSizePattern.MatchString(volume.Size) {
+			return fail("size must be like 100GB or 512GiB")
+		}
+	}
+	return nil
+}
+
+func sortedVolumeNames(volumes map[string]StorageVolume) []string {
+	names := make([]string, 0, len(volumes))
+	for name := range volumes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// updateStorage merges a storage update into volumes: a volume replaces the
+// one of the same name and null removes it
+func updateStorage(volumes map[string]StorageVolume, update interface{}) (map[string]StorageVolume, error) {
+	changes, ok := update.(map[string]interface{})
+	if !ok {
+		return nil, &StorageError{"", "expected a map of volume names to volumes"}
+	}
+	merged := make(map[string]StorageVolume, len(volumes)+len(changes))
+	for name, volume := range volumes {
+		merged[name] = volume
+	}
+	for name, value := range changes {
+		if value == nil {
+			delete(merged, name)
+			continue
+		}
+		data, err := json.Marshal(value)
+		if err != nil {
+			return nil, &StorageError{name, err.Error()}
+		}
+		var volume StorageVolume
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&volume); err != nil {
+			return nil, &StorageError{name, err.Error()}
+		}
+		merged[name] = volume
+	}
+	if len(merged) == 0 {
+		return nil, nil
+	}
+	return merged, nil
+}
+
+// userVolumeConfig is a Talos UserVolumeConfig document
+type userVolumeConfig struct {
+	APIVersion   string `yaml:"apiVersion"`
+	Kind         string `yaml:"kind"`
+	Name         string `yaml:"name"`
+	Provisioning struct {
+		DiskSelector struct {
+			Match string `yaml:"match"`
+		} `yaml:"diskSelector"`
+		MinSize string `yaml:"minSize"`
+		MaxSize string `yaml:"maxSize,omitempty"`
+		Grow    bool   `yaml:"grow,omitempty"`
+	} `yaml:"provisioning"`
+	Filesystem struct {
+		Type string `yaml:"type"`
+	} `yaml:"filesystem"`
+}
+
+// withStorage renders a node's storage volumes into a rendered patch:
+// machine.disks partitions, and UserVolumeConfig documents after the patch.
+// A machine.disks entry the template has for the same device is replaced.
+func withStorage(patch []byte, node *Node) ([]byte, error) {
+	if len(node.Storage) == 0 {
+		return patch, nil
+	}
+
+	names := sortedVolumeNames(node.Storage)
+	var partitions []string
+	for _, name := range names {
+		if !node.Storage[name].userVolume(name) {
+			partitions = append(partitions, name)
+		}
+	}
+
+	if len(partitions) > 0 {
+		var err error
+		patch, err = editPatch(patch, func(machine *yaml.Node) error {
+			return addMachineDisks(machine, node, partitions)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var out bytes.Buffer
+	out.Write(patch)
+	if len(patch) > 0 && !bytes.HasSuffix(patch, []byte("\n")) {
+		out.WriteString("\n")
+	}
+	for _, name := range names {
+		volume := node.Storage[name]
+		if !volume.userVolume(name) {
+			continue
+		}
+
+		doc := userVolumeConfig{APIVersion: "v1alpha1", Kind: "UserVolumeConfig", Name: name}
+		doc.Provisioning.DiskSelector.Match = fmt.Sprintf("disk.dev_path == '%s'", volume.Disk)
+		if volume.Size != "" {
+			doc.Provisioning.MinSize = volume.Size
+			doc.Provisioning.MaxSize = volume.Size
+		} else {
+			// Take the whole disk
+			doc.Provisioning.MinSize = "1GiB"
+			doc.Provisioning.Grow = true
+		}
+		doc.Filesystem.Type = volume.filesystem()
+
+		out.WriteString("---\n")
+		enc := yaml.NewEncoder(&out)
+		enc.SetIndent(2)
+		if err := enc.Encode(doc); err != nil {
+			return nil, fmt.Errorf("failed to write volume %s: %w", name, err)
+		}
+		if err := enc.Close(); err != nil {
+			return nil, fmt.Errorf("failed to write volume %s: %w", name, err)
+		}
+	}
+	return out.Bytes(), nil
+}
+
+// addMachineDisks adds a machine.disks entry with one partition for each of
+// the named volumes
+func addMachineDisks(machine *yaml.Node, node *Node, names []string) error {
+	disks := lookupKey(machine, "disks")
+	switch {
+	case disks == nil:
+		disks = &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		machine.Content = append(machine.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "disks"}, disks)
+	case disks.Kind == yaml.ScalarNode && disks.Tag == "!!null":
+		*disks = yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+	case disks.Kind != yaml.SequenceNode:
+		return fmt.Errorf("line %d: disks must be a list", disks.Line)
+	}
+
+	for _, name := range names {
+		volume := node.Storage[name]
+		partition := map[string]string{"mountpoint": volume.mountPath(name)}
+		if volume.Size != "" {
+			partition["size"] = volume.Size
+		}
+		var entry yaml.Node
+		if err := entry.Encode(map[string]interface{}{
+			"device":     volume.Disk,
+			"partitions": []map[string]string{partition},
+		}); err != nil {
+			return fmt.Errorf("storage %s: %w", name, err)
+		}
+
+		replaced := false
+		for i, existing := range disks.Content {
+			if device := lookupKey(existing, "device"); device != nil && device.Value == volume.Disk {
+				disks.Content[i] = &entry
+				replaced = true
+			}
+		}
+		if !replaced {
+			disks.Content = append(disks.Content, &entry)
+		}
+	}
+	return nil
+}
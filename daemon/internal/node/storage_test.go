@@ -0,0 +1,145 @@
+package node
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidateStorage(t *testing.T) {
+	tests := []struct {
+		name    string
+		storage map[string]StorageVolume
+		want    string // Substring of the error; empty when valid
+	}{
+		{"valid", map[string]StorageVolume{
+			"longhorn": {Role: StorageRoleLonghorn, Disk: "/dev/sdb"},
+			"data":     {Role: StorageRoleVolume, Disk: "/dev/sdc", Filesystem: "ext4", Size: "500GiB"},
+			"backups":  {Role: StorageRoleVolume, Disk: "/dev/sdd", MountPath: "/var/backups"},
+		}, ""},
+		{"bad name", map[string]StorageVolume{"Data": {Role: StorageRoleVolume, Disk: "/dev/sdb"}}, "name must be"},
+		{"bad role", map[string]StorageVolume{"data": {Role: "scratch", Disk: "/dev/sdb"}}, "role must be"},
+		{"no disk", map[string]StorageVolume{"data": {Role: StorageRoleVolume}}, "disk must be a device path"},
+		{"install disk", map[string]StorageVolume{"data": {Role: StorageRoleVolume, Disk: "/dev/sda"}}, "is the install disk"},
+		{"shared disk", map[string]StorageVolume{
+			"a": {Role: StorageRoleVolume, Disk: "/dev/sdb"},
+			"b": {Role: StorageRoleVolume, Disk: "/dev/sdb"},
+		}, "storage b: /dev/sdb is already used by a"},
+		{"outside /var", map[string]StorageVolume{"data": {Role: StorageRoleVolume, Disk: "/dev/sdb", MountPath: "/data"}}, "under /var"},
+		{"other user volume path", map[string]StorageVolume{"data": {Role: StorageRoleVolume, Disk: "/dev/sdb", MountPath: "/var/mnt/other"}}, "mounted at /var/mnt/data"},
+		{"ext4 partition", map[string]StorageVolume{"longhorn": {Role: StorageRoleLonghorn, Disk: "/dev/sdb", Filesystem: "ext4"}}, "Talos formats other mount paths as xfs"},
+		{"bad filesystem", map[string]StorageVolume{"data": {Role: StorageRoleVolume, Disk: "/dev/sdb", Filesystem: "btrfs"}}, "filesystem must be"},
+		{"bad size", map[string]StorageVolume{"data": {Role: StorageRoleVolume, Disk: "/dev/sdb", Size: "lots"}}, "size must be"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateStorage(&Node{Disk: "/dev/sda", Storage: tt.storage})
+			if tt.want == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			var storageErr *StorageError
+			if !errors.As(err, &storageErr) || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want a StorageError containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithStorage(t *testing.T) {
+	patch := []byte(`machine:
+  install:
+    disk: /dev/sda
+  disks:
+    - device: /dev/sdb # replaced by config.yaml
+      partitions:
+        - mountpoint: /var/old
+`)
+
+	if got, err := withStorage(patch, &Node{}); err != nil || string(got) != string(patch) {
+		t.Errorf("patch changed without storage: %q, %v", got, err)
+	}
+
+	node := &Node{Storage: map[string]StorageVolume{
+		"longhorn": {Role: StorageRoleLonghorn, Disk: "/dev/sdb"},
+		"backups":  {Role: StorageRoleVolume, Disk: "/dev/sdc", MountPath: "/var/backups", Size: "200GB"},
+		"data":     {Role: StorageRoleVolume, Disk: "/dev/nvme1n1", Filesystem: "ext4"},
+	}}
+	got, err := withStorage(patch, node)
+	if err != nil {
+		t.Fatalf("withStorage failed: %v", err)
+	}
+	want := `machine:
+  install:
+    disk: /dev/sda
+  disks:
+    - device: /dev/sdb
+      partitions:
+        - mountpoint: /var/lib/longhorn
+    - device: /dev/sdc
+      partitions:
+        - mountpoint: /var/backups
+          size: 200GB
+---
+apiVersion: v1alpha1
+kind: UserVolumeConfig
+name: data
+provisioning:
+  diskSelector:
+    match: disk.dev_path == '/dev/nvme1n1'
+  minSize: 1GiB
+  grow: true
+filesystem:
+  type: ext4
+`
+	if string(got) != want {
+		t.Errorf("patch =\n%s\nwant\n%s", got, want)
+	}
+
+	// Rendering again keeps the volume documents
+	if got, err := withNodeMetadata(got, &Node{Labels: map[string]string{"zone": "a"}}); err != nil || !strings.Contains(string(got), "kind: UserVolumeConfig") {
+		t.Errorf("volume documents lost: %s, %v", got, err)
+	}
+}
+
+func TestUpdate_Storage(t *testing.T) {
+	m := newTestManager(t, testConfig)
+
+	updates := map[string]interface{}{"storage": map[string]interface{}{
+		"longhorn": map[string]interface{}{"role": "longhorn", "disk": "/dev/sdb"},
+	}}
+	if err := m.Update("home", "worker-1", updates); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if config := readConfig(t, m); !strings.Contains(config, "        storage:\n          longhorn:\n            role: longhorn\n            disk: /dev/sdb\n") {
+		t.Errorf("storage not written:\n%s", config)
+	}
+	node, _ := m.Get("home", "worker-1")
+	if node.Storage["longhorn"].Disk != "/dev/sdb" {
+		t.Errorf("storage = %+v", node.Storage)
+	}
+
+	var storageErr *StorageError
+	err := m.Update("home", "worker-1", map[string]interface{}{"storage": map[string]interface{}{
+		"data": map[string]interface{}{"role": "volume", "disk": "/dev/nvme0n1"},
+	}})
+	if !errors.As(err, &storageErr) {
+		t.Errorf("install disk as storage: error = %v, want a StorageError", err)
+	}
+	err = m.Update("home", "worker-1", map[string]interface{}{"storage": map[string]interface{}{
+		"data": map[string]interface{}{"role": "volume", "device": "/dev/sdc"},
+	}})
+	if !errors.As(err, &storageErr) {
+		t.Errorf("unknown field: error = %v, want a StorageError", err)
+	}
+
+	if err := m.Update("home", "worker-1", map[string]interface{}{"storage": map[string]interface{}{"longhorn": nil}}); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if config := readConfig(t, m); strings.Contains(config, "storage:") {
+		t.Errorf("removed storage still written:\n%s", config)
+	}
+}
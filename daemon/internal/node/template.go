@@ -0,0 +1,328 @@
+package node
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/wild-cloud/wild-central/daemon/internal/secrets"
+)
+
+// legacyPlaceholders are substituted before a patch template is parsed.
+// Templates written for the shell scripts use them, sometimes inside
+// actions, e.g. {{ index .cluster.nodes.active "{{NODE_NAME}}" "disk" }}.
+func legacyPlaceholders(node *Node) *strings.Replacer {
+	return strings.NewReplacer(
+		"{{NODE_NAME}}", node.Hostname,
+		"{{NODE_IP}}", node.TargetIP,
+		"{{SCHEMATIC_ID}}", node.SchematicID,
+		"{{VERSION}}", node.Version,
+	)
+}
+
+// TemplateError is a patch template that failed to parse or render
+type TemplateError struct {
+	File    string
+	Line    int
+	Column  int    // 0 when unknown
+	Source  string // The template line at fault
+	Message string
+}
+
+func (e *TemplateError) Error() string {
+	position := fmt.Sprintf("%s:%d", e.File, e.Line)
+	if e.Column > 0 {
+		position += fmt.Sprintf(":%d", e.Column)
+	}
+	if e.Source == "" {
+		return fmt.Sprintf("%s: %s", position, e.Message)
+	}
+	return fmt.Sprintf("%s: %s\n%5d | %s", position, e.Message, e.Line, e.Source)
+}
+
+var (
+	// text/template errors: "template: NAME:LINE[:COL]: MESSAGE"
+	templateErrorPattern = regexp.MustCompile(`^template: (.+?):(\d+)(?::(\d+))?: (?s)(.*)$`)
+	// Execution errors repeat the template name before the failing action
+	executingPattern = regexp.MustCompile(`^executing "[^"]*" at `)
+)
+
+// newTemplateError turns a text/template error into a TemplateError
+// quoting the offending line of source
+func newTemplateError(name, source string, err error) error {
+	match := templateErrorPattern.FindStringSubmatch(err.Error())
+	if match == nil || match[1] != name {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+
+	line, _ := strconv.Atoi(match[2])
+	column, _ := strconv.Atoi(match[3])
+	tplErr := &TemplateError{
+		File:    name,
+		Line:    line,
+		Column:  column,
+		Message: executingPattern.ReplaceAllString(match[4], "at "),
+	}
+	if lines := strings.Split(source, "\n"); line >= 1 && line <= len(lines) {
+		tplErr.Source = lines[line-1]
+	}
+	return tplErr
+}
+
+// patchFuncs are the functions patch templates can use on top of the
+// text/template builtins
+func patchFuncs(secret func(key string) (string, error)) template.FuncMap {
+	return template.FuncMap{
+		// secret returns a value from the instance's secrets by dotted key
+		"secret": secret,
+		// default returns value, or fallback when value is empty
+		"default": func(fallback, value interface{}) interface{} {
+			if isEmpty(value) {
+				return fallback
+			}
+			return value
+		},
+		// required fails the render with message when value is empty
+		"required": func(message string, value interface{}) (interface{}, error) {
+			if isEmpty(value) {
+				return nil, fmt.Errorf("%s", message)
+			}
+			return value, nil
+		},
+		"quote": func(value interface{}) string {
+			return strconv.Quote(fmt.Sprint(value))
+		},
+		"join": func(sep string, values []interface{}) string {
+			parts := make([]string, len(values))
+			for i, v := range values {
+				parts[i] = fmt.Sprint(v)
+			}
+			return strings.Join(parts, sep)
+		},
+		"toYaml": func(value interface{}) (string, error) {
+			data, err := yaml.Marshal(value)
+			return strings.TrimSuffix(string(data), "\n"), err
+		},
+		// indent prefixes every line of text with n spaces
+		"indent": func(n int, text string) string {
+			pad := strings.Repeat(" ", n)
+			return pad + strings.ReplaceAll(text, "\n", "\n"+pad)
+		},
+	}
+}
+
+func isEmpty(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	case bool:
+		return !v
+	case int:
+		return v == 0
+	case []interface{}:
+		return len(v) == 0
+	case map[string]interface{}:
+		return len(v) == 0
+	case map[string]string:
+		return len(v) == 0
+	}
+	return false
+}
+
+// renderPatch renders a patch template. Data keys missing from a map are
+// errors rather than "<no value>" in the machine config.
+func renderPatch(name, source string, data map[string]interface{}, secret func(key string) (string, error)) ([]byte, error) {
+	tmpl, err := template.New(name).
+		Funcs(patchFuncs(secret)).
+		Option("missingkey=error").
+		Parse(source)
+	if err != nil {
+		return nil, newTemplateError(name, source, err)
+	}
+
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, data); err != nil {
+		return nil, newTemplateError(name, source, err)
+	}
+	return out.Bytes(), nil
+}
+
+// patchData is what a node's patch template renders with: the instance
+// config.yaml at the root, as gomplate gave it, and the node under .node
+// with its defaults applied
+func (m *Manager) patchData(instanceName string, node *Node) (map[string]interface{}, error) {
+	data := map[string]interface{}{}
+
+	raw, err := os.ReadFile(m.getConfigPath(instanceName))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+	if err := yaml.Unmarshal(raw, &data); err != nil {
+		return nil, fmt.Errorf("failed to parse config: %w", err)
+	}
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+
+	data["node"] = templateNode(node)
+	return data, nil
+}
+
+// templateNode returns the node's fields keyed as in config.yaml. Known
+// fields are always present, so templates can test them with if.
+func templateNode(node *Node) map[string]interface{} {
+	values := make(map[string]interface{}, len(node.Extra)+15)
+	for key, value := range node.Extra {
+		values[key] = value
+	}
+	values["hostname"] = node.Hostname
+	values["role"] = node.Role
+	values["targetIp"] = node.TargetIP
+	values["currentIp"] = node.CurrentIP
+	values["interface"] = node.Interface
+	values["disk"] = node.Disk
+	values["version"] = node.Version
+	values["schematicId"] = node.SchematicID
+	values["maintenance"] = node.Maintenance
+	values["configured"] = node.Configured
+	values["applied"] = node.Applied
+	values["labels"] = node.Labels
+	values["taints"] = node.Taints
+	values["annotations"] = node.Annotations
+	values["storage"] = templateStorage(node.Storage)
+	return values
+}
+
+// templateStorage returns storage volumes keyed as in config.yaml, with the
+// mount path and filesystem defaults filled in
+func templateStorage(volumes map[string]StorageVolume) map[string]interface{} {
+	values := make(map[string]interface{}, len(volumes))
+	for name, volume := range volumes {
+		values[name] = map[string]interface{}{
+			"role":       volume.Role,
+			"disk":       volume.Disk,
+			"mountPath":  volume.mountPath(name),
+			"filesystem": volume.filesystem(),
+			"size":       volume.Size,
+		}
+	}
+	return values
+}
+
+// secretLookup returns a function reading secrets of an instance. The
+// secrets are only read, through the instance's backend, if a template
+// asks for one.
+func (m *Manager) secretLookup(instanceName string) func(key string) (string, error) {
+	var tree map[string]interface{}
+	return func(key string) (string, error) {
+		if tree == nil {
+			store, err := secrets.NewManager().Open(m.GetInstancePath(instanceName))
+			if err != nil {
+				return "", fmt.Errorf("failed to open secrets: %w", err)
+			}
+			raw, err := store.Read()
+			if err != nil {
+				return "", fmt.Errorf("failed to read secrets: %w", err)
+			}
+			tree = map[string]interface{}{}
+			if err := yaml.Unmarshal(raw, &tree); err != nil {
+				return "", fmt.Errorf("failed to parse secrets: %w", err)
+			}
+		}
+
+		var value interface{} = tree
+		for _, part := range strings.Split(key, ".") {
+			mapping, ok := value.(map[string]interface{})
+			if !ok {
+				return "", fmt.Errorf("secret %s not found", key)
+			}
+			if value, ok = mapping[part]; !ok {
+				return "", fmt.Errorf("secret %s not found", key)
+			}
+		}
+		if _, ok := value.(map[string]interface{}); ok || value == nil {
+			return "", fmt.Errorf("secret %s is not a value", key)
+		}
+		return fmt.Sprint(value), nil
+	}
+}
+
+// editPatch calls edit with the machine section of a rendered patch, added
+// if missing, and writes the patch back. Documents after the first, such as
+// volume configs, are kept.
+func editPatch(patch []byte, edit func(machine *yaml.Node) error) ([]byte, error) {
+	var docs []*yaml.Node
+	dec := yaml.NewDecoder(bytes.NewReader(patch))
+	for {
+		var doc yaml.Node
+		if err := dec.Decode(&doc); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to parse rendered patch: %w", err)
+		}
+		docs = append(docs, &doc)
+	}
+	if len(docs) == 0 || len(docs[0].Content) == 0 {
+		docs = append([]*yaml.Node{{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}}}, docs...)
+	}
+	root := docs[0].Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("rendered patch is not a map")
+	}
+
+	machine, err := mappingValue(root, "machine")
+	if err != nil {
+		return nil, err
+	}
+	if err := edit(machine); err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	enc := yaml.NewEncoder(&out)
+	enc.SetIndent(2)
+	for _, doc := range docs {
+		if err := enc.Encode(doc); err != nil {
+			return nil, fmt.Errorf("failed to write patch: %w", err)
+		}
+	}
+	if err := enc.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write patch: %w", err)
+	}
+	return out.Bytes(), nil
+}
+
+// mappingValue returns the map under key in mapping, adding it if missing
+func mappingValue(mapping *yaml.Node, key string) (*yaml.Node, error) {
+	if value := lookupKey(mapping, key); value != nil {
+		if value.Kind == yaml.ScalarNode && value.Tag == "!!null" {
+			*value = yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		}
+		if value.Kind != yaml.MappingNode {
+			return nil, fmt.Errorf("line %d: %s must be a map", value.Line, key)
+		}
+		return value, nil
+	}
+	value := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	mapping.Content = append(mapping.Content,
+		&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, value)
+	return value, nil
+}
+
+func lookupKey(mapping *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,202 @@
+package node
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const templateTestConfig = `cloud:
+  router:
+    ip: 192.168.8.1
+cluster:
+  name: home
+  nodes:
+    control:
+      vip: 192.168.8.30
+    talos:
+      version: v1.11.0
+      schematicId: abc123
+    active:
+      control-1:
+        role: controlplane
+        targetIp: 192.168.8.31
+        interface: eth0
+        disk: /dev/sda
+      worker-1:
+        role: worker
+        targetIp: 192.168.8.41
+        interface: enp1s0
+        disk: /dev/nvme0n1
+        zone: rack-a
+`
+
+// renderTemplate renders a role's template from the directory for a node
+// of templateTestConfig
+func renderTemplate(t *testing.T, m *Manager, role, hostname string) string {
+	t.Helper()
+	source, err := os.ReadFile(filepath.Join("..", "..", "..", "directory", "setup", "cluster-nodes", "patch.templates", role+".yaml"))
+	if err != nil {
+		t.Skipf("patch templates not available: %v", err)
+	}
+
+	c, err := m.loadConfig("home")
+	if err != nil {
+		t.Fatal(err)
+	}
+	node, _ := c.node(hostname)
+	c.applyDefaults(node)
+
+	data, err := m.patchData("home", node)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := renderPatch(role+".yaml", legacyPlaceholders(node).Replace(string(source)), data, m.secretLookup("home"))
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	return string(out)
+}
+
+func TestRenderPatch_ControlPlaneTemplate(t *testing.T) {
+	m := newTestManager(t, templateTestConfig)
+
+	want := `machine:
+  install:
+    disk: /dev/sda
+    image: factory.talos.dev/metal-installer/abc123:v1.11.0
+  network:
+    hostname: "control-1"
+    interfaces:
+      - interface: eth0
+        dhcp: false
+        addresses:
+          - "192.168.8.31/24"
+        routes:
+          - network: 0.0.0.0/0
+            gateway: 192.168.8.1
+        vip:
+          ip: 192.168.8.30
+`
+	if got := renderTemplate(t, m, "controlplane", "control-1"); !strings.HasPrefix(got, want) {
+		t.Errorf("unexpected patch:\n%s", got)
+	}
+}
+
+func TestRenderPatch_ControlPlaneScheduling(t *testing.T) {
+	m := newTestManager(t, templateTestConfig)
+	if got := renderTemplate(t, m, "controlplane", "control-1"); strings.Contains(got, "allowSchedulingOnControlPlanes: true") {
+		t.Errorf("scheduling allowed without the config flag:\n%s", got)
+	}
+
+	config := strings.Replace(templateTestConfig, "  name: home\n", "  name: home\n  allowSchedulingOnControlPlanes: \"true\"\n", 1)
+	m = newTestManager(t, config)
+	if got := renderTemplate(t, m, "controlplane", "control-1"); !strings.Contains(got, "\ncluster:\n  allowSchedulingOnControlPlanes: true\n") {
+		t.Errorf("scheduling not allowed with the config flag:\n%s", got)
+	}
+}
+
+func TestRenderPatch_WorkerTemplate(t *testing.T) {
+	m := newTestManager(t, templateTestConfig)
+
+	want := `machine:
+  install:
+    disk: /dev/nvme0n1
+    image: factory.talos.dev/metal-installer/abc123:v1.11.0
+  network:
+    hostname: "worker-1"
+    interfaces:
+      - interface: enp1s0
+        dhcp: true
+        addresses:
+          - "192.168.8.41/24"
+`
+	if got := renderTemplate(t, m, "worker", "worker-1"); !strings.HasPrefix(got, want) {
+		t.Errorf("unexpected patch:\n%s", got)
+	}
+}
+
+func TestRenderPatch_NodeAndSecrets(t *testing.T) {
+	m := newTestManager(t, templateTestConfig)
+	secretsPath := filepath.Join(m.GetInstancePath("home"), "secrets.yaml")
+	if err := os.WriteFile(secretsPath, []byte("cluster:\n  wireguard:\n    privateKey: c2VjcmV0\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	c, _ := m.loadConfig("home")
+	node, _ := c.node("worker-1")
+	c.applyDefaults(node)
+	data, err := m.patchData("home", node)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	source := `hostname: {{ .node.hostname }}
+zone: {{ .node.zone }}
+version: {{ .node.version }}
+interface: {{ .node.interface | default "eth0" }}
+currentIp: {{ .node.currentIp | default .node.targetIp }}
+key: {{ secret "cluster.wireguard.privateKey" | quote }}
+`
+	out, err := renderPatch("worker.yaml", source, data, m.secretLookup("home"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `hostname: worker-1
+zone: rack-a
+version: v1.11.0
+interface: enp1s0
+currentIp: 192.168.8.41
+key: "c2VjcmV0"
+`
+	if string(out) != want {
+		t.Errorf("unexpected render:\n%s", out)
+	}
+}
+
+func TestRenderPatch_Errors(t *testing.T) {
+	data := map[string]interface{}{"cluster": map[string]interface{}{"name": "home"}}
+	noSecrets := func(key string) (string, error) { return "", errors.New("no secrets") }
+
+	tests := []struct {
+		name   string
+		source string
+		want   []string
+	}{
+		{
+			"missing key",
+			"machine:\n  network:\n    vip: {{ .cluster.nodes.control.vip }}\n",
+			[]string{"worker.yaml:3:", `map has no entry for key "nodes"`, "    3 |     vip: {{ .cluster.nodes.control.vip }}"},
+		},
+		{
+			"parse error",
+			"machine:\n  disk: {{ .cluster.name \n",
+			[]string{"unclosed action started at worker.yaml:2"},
+		},
+		{
+			"unknown function",
+			"a: 1\nb: {{ nope }}\n",
+			[]string{"worker.yaml:2:", `function "nope" not defined`},
+		},
+		{
+			"required",
+			"a: 1\nb: {{ required \"cluster.vip is required\" .cluster.vip }}\n",
+			[]string{"worker.yaml:2:", "cluster.vip is required"},
+		},
+	}
+
+	for _, tt := range tests {
+		_, err := renderPatch("worker.yaml", tt.source, data, noSecrets)
+		if err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+			continue
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("%s: error %q does not contain %q", tt.name, err, want)
+			}
+		}
+	}
+}
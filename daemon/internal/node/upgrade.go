@@ -0,0 +1,245 @@
+package node
+
+import (
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"github.com/wild-cloud/wild-central/daemon/internal/operations"
+	"github.com/wild-cloud/wild-central/daemon/internal/tools"
+)
+
+// upgradeTimeout is how long talosctl waits for one node's upgrade
+const upgradeTimeout = "30m"
+
+// UpgradeOptions selects what a rolling Talos upgrade installs and where
+type UpgradeOptions struct {
+	Version         string   `json:"version"`                     // Target Talos version, e.g. v1.11.2
+	SchematicID     string   `json:"schematic_id,omitempty"`      // Image Factory schematic; defaults to the instance's
+	Nodes           []string `json:"nodes,omitempty"`             // Hostnames to upgrade; every node when empty
+	AllowQuorumLoss bool     `json:"allow_quorum_loss,omitempty"` // Upgrade control planes of a cluster too small to keep etcd quorum
+}
+
+// UpgradeStep is one node in an upgrade plan
+type UpgradeStep struct {
+	Hostname string `json:"hostname"`
+	Role     string `json:"role"`
+	IP       string `json:"ip"`
+	From     string `json:"from,omitempty"` // Version the node runs now, as recorded in config.yaml
+	Reason   string `json:"reason,omitempty"`
+}
+
+// UpgradePlan is the order a rolling upgrade visits nodes in
+type UpgradePlan struct {
+	Version     string        `json:"version"`
+	SchematicID string        `json:"schematic_id"`
+	Image       string        `json:"image"`
+	Nodes       []UpgradeStep `json:"nodes"`   // Workers first, then control planes
+	Skipped     []UpgradeStep `json:"skipped"` // With the reason each is left alone
+}
+
+// installerImage returns the Image Factory installer for a schematic
+func installerImage(schematicID, version string) string {
+	return fmt.Sprintf("factory.talos.dev/metal-installer/%s:%s", schematicID, version)
+}
+
+// PlanUpgrade checks the options and returns the nodes an upgrade would
+// visit, in order
+func (m *Manager) PlanUpgrade(instanceName string, opts UpgradeOptions) (*UpgradePlan, error) {
+	c, err := m.loadConfig(instanceName)
+	if err != nil {
+		return nil, err
+	}
+	return planUpgrade(c, opts)
+}
+
+func planUpgrade(c *nodeConfig, opts UpgradeOptions) (*UpgradePlan, error) {
+	version := strings.TrimSpace(opts.Version)
+	if version == "" {
+		return nil, fmt.Errorf("version is required")
+	}
+	if !strings.HasPrefix(version, "v") {
+		version = "v" + version
+	}
+	schematicID := opts.SchematicID
+	if schematicID == "" {
+		schematicID = c.Cluster.Nodes.Talos.SchematicID
+	}
+	if schematicID == "" {
+		return nil, fmt.Errorf("schematic_id is required (cluster.nodes.talos.schematicId is not set)")
+	}
+
+	selected := make(map[string]bool, len(opts.Nodes))
+	for _, hostname := range opts.Nodes {
+		if _, ok := c.node(hostname); !ok {
+			return nil, fmt.Errorf("node %s not found", hostname)
+		}
+		selected[hostname] = true
+	}
+
+	plan := &UpgradePlan{
+		Version:     version,
+		SchematicID: schematicID,
+		Image:       installerImage(schematicID, version),
+		Nodes:       []UpgradeStep{},
+		Skipped:     []UpgradeStep{},
+	}
+
+	var workers, controlPlanes []UpgradeStep
+	running := 0 // Control planes running Talos, the etcd members
+	for hostname := range c.Cluster.Nodes.Active {
+		node, _ := c.node(hostname)
+		c.applyDefaults(node)
+		step := UpgradeStep{Hostname: hostname, Role: node.Role, IP: node.TargetIP, From: node.Version}
+
+		if node.Role == "controlplane" && node.Applied && !node.Maintenance {
+			running++
+		}
+
+		switch {
+		case len(selected) > 0 && !selected[hostname]:
+			continue
+		case !node.Applied || node.Maintenance:
+			step.Reason = "not running an applied configuration"
+			plan.Skipped = append(plan.Skipped, step)
+		case node.Version == version && node.SchematicID == schematicID:
+			step.Reason = "already at " + version
+			plan.Skipped = append(plan.Skipped, step)
+		case node.Role == "controlplane":
+			controlPlanes = append(controlPlanes, step)
+		default:
+			workers = append(workers, step)
+		}
+	}
+
+	byHostname := func(steps []UpgradeStep) {
+		sort.Slice(steps, func(i, j int) bool { return steps[i].Hostname < steps[j].Hostname })
+	}
+	byHostname(workers)
+	byHostname(controlPlanes)
+	byHostname(plan.Skipped)
+	plan.Nodes = append(append(plan.Nodes, workers...), controlPlanes...)
+
+	// Nodes go one at a time, so etcd loses one member at most. That keeps
+	// quorum only with three or more members.
+	if len(controlPlanes) > 0 && (running-1)/2 < 1 && !opts.AllowQuorumLoss {
+		return nil, fmt.Errorf("the cluster has %d control plane(s), so upgrading one loses etcd quorum until it is back; allow quorum loss to accept the downtime", running)
+	}
+
+	return plan, nil
+}
+
+// Upgrade upgrades Talos on the planned nodes one at a time: workers first,
+// then control planes. After each node it waits for Kubernetes to report it
+// Ready and, for control planes, for etcd to be healthy, then records the
+// new version in config.yaml. It stops at the first failure. Once every
+// node runs the target, the instance defaults are updated too, so nodes
+// added later install it.
+func (m *Manager) Upgrade(instanceName string, opts UpgradeOptions, out *operations.Output) error {
+	c, err := m.loadConfig(instanceName)
+	if err != nil {
+		return err
+	}
+	plan, err := planUpgrade(c, opts)
+	if err != nil {
+		return err
+	}
+
+	opsMgr := operations.NewManager(m.dataDir)
+	talosconfigPath := tools.GetTalosconfigPath(m.dataDir, instanceName)
+
+	for _, step := range plan.Skipped {
+		out.Printf("⏭️  Skipping %s: %s\n", step.Hostname, step.Reason)
+	}
+	if len(plan.Nodes) == 0 {
+		out.Printf("Nothing to upgrade\n")
+		return m.recordUpgradeDefaults(instanceName, plan, opts)
+	}
+
+	var controlPlaneIPs []string
+	for hostname := range c.Cluster.Nodes.Active {
+		node, _ := c.node(hostname)
+		if node.Role == "controlplane" && node.Applied && !node.Maintenance {
+			controlPlaneIPs = append(controlPlaneIPs, node.TargetIP)
+		}
+	}
+	sort.Strings(controlPlaneIPs)
+
+	// Start from a healthy cluster, or a failure could not be told apart
+	// from one the upgrade caused
+	out.Printf("🩺 Checking cluster health\n")
+	for _, step := range plan.Nodes {
+		if err := m.nodeReady(instanceName, step.Hostname); err != nil {
+			return fmt.Errorf("cluster is not healthy before the upgrade: %w", err)
+		}
+	}
+	if err := m.etcdHealthy(instanceName, controlPlaneIPs); err != nil {
+		return fmt.Errorf("etcd is not healthy before the upgrade: %w", err)
+	}
+
+	for i, step := range plan.Nodes {
+		if out.ID() != "" {
+			opsMgr.UpdateProgress(instanceName, out.ID(), i*100/len(plan.Nodes),
+				fmt.Sprintf("Upgrading %s (%d/%d)", step.Hostname, i+1, len(plan.Nodes)))
+		}
+		out.Printf("⬆️  [%d/%d] Upgrading %s (%s, %s) from %s to %s\n",
+			i+1, len(plan.Nodes), step.Hostname, step.Role, step.IP, step.From, plan.Version)
+
+		cmd := exec.Command("talosctl", "upgrade", "--nodes", step.IP, "--image", plan.Image,
+			"--wait", "--timeout", upgradeTimeout)
+		tools.WithTalosconfig(cmd, talosconfigPath)
+		if err := out.Run(cmd); err != nil {
+			return fmt.Errorf("upgrading %s: %w", step.Hostname, err)
+		}
+
+		out.Printf("⏳ Waiting for %s to be Ready\n", step.Hostname)
+		if err := waitFor(step.Hostname+" to be Ready", func() error {
+			return m.nodeReady(instanceName, step.Hostname)
+		}); err != nil {
+			return fmt.Errorf("upgrading %s: %w", step.Hostname, err)
+		}
+		if step.Role == "controlplane" {
+			out.Printf("⏳ Waiting for etcd to be healthy\n")
+			if err := waitFor("etcd to be healthy", func() error {
+				return m.etcdHealthy(instanceName, controlPlaneIPs)
+			}); err != nil {
+				return fmt.Errorf("upgrading %s: %w", step.Hostname, err)
+			}
+		}
+
+		err := m.updateConfig(instanceName, func(c *nodeConfig) error {
+			node, ok := c.node(step.Hostname)
+			if !ok {
+				return fmt.Errorf("node %s not found", step.Hostname)
+			}
+			node.Version = plan.Version
+			node.SchematicID = plan.SchematicID
+			c.Cluster.Nodes.Active[step.Hostname] = *node
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("%s upgraded, but recording its version failed: %w", step.Hostname, err)
+		}
+		out.Printf("✅ %s runs %s\n", step.Hostname, plan.Version)
+	}
+
+	if err := m.recordUpgradeDefaults(instanceName, plan, opts); err != nil {
+		return err
+	}
+	out.Printf("✅ Upgrade to %s complete\n", plan.Version)
+	return nil
+}
+
+// recordUpgradeDefaults makes the target the instance default once an
+// upgrade of every node has finished
+func (m *Manager) recordUpgradeDefaults(instanceName string, plan *UpgradePlan, opts UpgradeOptions) error {
+	if len(opts.Nodes) > 0 {
+		return nil
+	}
+	return m.updateConfig(instanceName, func(c *nodeConfig) error {
+		c.Cluster.Nodes.Talos.Version = plan.Version
+		c.Cluster.Nodes.Talos.SchematicID = plan.SchematicID
+		return nil
+	})
+}
@@ -0,0 +1,93 @@
+package node
+
+import (
+	"strings"
+	"testing"
+)
+
+func testNodeConfig(nodes map[string]Node) *nodeConfig {
+	c := &nodeConfig{}
+	c.Cluster.Nodes.Talos.Version = "v1.10.3"
+	c.Cluster.Nodes.Talos.SchematicID = "abc123"
+	c.Cluster.Nodes.Active = nodes
+	return c
+}
+
+func hostnames(steps []UpgradeStep) string {
+	var names []string
+	for _, step := range steps {
+		names = append(names, step.Hostname)
+	}
+	return strings.Join(names, ",")
+}
+
+func TestPlanUpgrade_Order(t *testing.T) {
+	c := testNodeConfig(map[string]Node{
+		"control-2": {Role: "controlplane", TargetIP: "192.168.8.32", Applied: true},
+		"control-1": {Role: "controlplane", TargetIP: "192.168.8.31", Applied: true},
+		"control-3": {Role: "controlplane", TargetIP: "192.168.8.33", Applied: true},
+		"worker-2":  {Role: "worker", TargetIP: "192.168.8.42", Applied: true},
+		"worker-1":  {Role: "worker", TargetIP: "192.168.8.41", Applied: true, Version: "v1.11.2"},
+		"worker-3":  {Role: "worker", TargetIP: "192.168.8.43", Maintenance: true},
+	})
+
+	plan, err := planUpgrade(c, UpgradeOptions{Version: "1.11.2"})
+	if err != nil {
+		t.Fatalf("planUpgrade failed: %v", err)
+	}
+	if plan.Version != "v1.11.2" || plan.Image != "factory.talos.dev/metal-installer/abc123:v1.11.2" {
+		t.Errorf("unexpected target: %+v", plan)
+	}
+	if got := hostnames(plan.Nodes); got != "worker-2,control-1,control-2,control-3" {
+		t.Errorf("upgrade order = %s", got)
+	}
+	if got := hostnames(plan.Skipped); got != "worker-1,worker-3" {
+		t.Errorf("skipped = %s", got)
+	}
+
+	// A new schematic reinstalls nodes already at the version
+	plan, _ = planUpgrade(c, UpgradeOptions{Version: "v1.11.2", SchematicID: "def456", Nodes: []string{"worker-1"}})
+	if got := hostnames(plan.Nodes); got != "worker-1" {
+		t.Errorf("selected upgrade = %s", got)
+	}
+}
+
+func TestPlanUpgrade_Quorum(t *testing.T) {
+	c := testNodeConfig(map[string]Node{
+		"control-1": {Role: "controlplane", TargetIP: "192.168.8.31", Applied: true},
+		"worker-1":  {Role: "worker", TargetIP: "192.168.8.41", Applied: true},
+	})
+
+	if _, err := planUpgrade(c, UpgradeOptions{Version: "v1.11.2"}); err == nil || !strings.Contains(err.Error(), "quorum") {
+		t.Errorf("expected a quorum error, got %v", err)
+	}
+	if _, err := planUpgrade(c, UpgradeOptions{Version: "v1.11.2", AllowQuorumLoss: true}); err != nil {
+		t.Errorf("upgrade with quorum loss allowed failed: %v", err)
+	}
+	if _, err := planUpgrade(c, UpgradeOptions{Version: "v1.11.2", Nodes: []string{"worker-1"}}); err != nil {
+		t.Errorf("worker-only upgrade failed: %v", err)
+	}
+	if _, err := planUpgrade(c, UpgradeOptions{Version: "v1.11.2", Nodes: []string{"worker-9"}}); err == nil {
+		t.Error("expected an error for an unknown node")
+	}
+}
+
+func TestRecordUpgradeDefaults(t *testing.T) {
+	m := newTestManager(t, testConfig)
+
+	plan := &UpgradePlan{Version: "v1.11.2", SchematicID: "def456"}
+	if err := m.recordUpgradeDefaults("home", plan, UpgradeOptions{}); err != nil {
+		t.Fatalf("recordUpgradeDefaults failed: %v", err)
+	}
+
+	c, err := m.loadConfig("home")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Cluster.Nodes.Talos.Version != "v1.11.2" || c.Cluster.Nodes.Talos.SchematicID != "def456" {
+		t.Errorf("defaults not updated: %+v", c.Cluster.Nodes.Talos)
+	}
+	if config := readConfig(t, m); !strings.Contains(config, "# First control plane") {
+		t.Errorf("config lost comments:\n%s", config)
+	}
+}
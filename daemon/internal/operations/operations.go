@@ -7,6 +7,7 @@ import (
 	"path/filepath"
 	"time"
 
+	"github.com/wild-cloud/wild-central/daemon/internal/state"
 	"github.com/wild-cloud/wild-central/daemon/internal/storage"
 )
 
@@ -36,11 +37,81 @@ type Operation struct {
 	EndedAt   time.Time `json:"ended_at,omitempty"`
 }
 
-// GetOperationsDir returns the operations directory for an instance
+// Filter selects operations by their indexed fields. Empty fields match anything.
+type Filter struct {
+	Type   string
+	Status string
+	Target string
+}
+
+// operationsBucket holds operation records in the instance state store
+const operationsBucket = "operations"
+
+// GetOperationsDir returns the operations directory for an instance. It
+// holds operation output logs; records live in the instance state store.
 func (m *Manager) GetOperationsDir(instanceName string) string {
 	return filepath.Join(m.dataDir, "instances", instanceName, "operations")
 }
 
+// store opens the instance state store. The first time it is opened,
+// operation records left as JSON files by earlier versions are imported.
+func (m *Manager) store(instanceName string) (*state.DB, error) {
+	db, err := state.Open(state.InstancePath(m.dataDir, instanceName))
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Once("operations-json", func() error {
+		return m.importLegacy(db, instanceName)
+	}); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+// importLegacy imports operation records left as JSON files by earlier versions
+func (m *Manager) importLegacy(db *state.DB, instanceName string) error {
+	legacy, _ := filepath.Glob(filepath.Join(m.GetOperationsDir(instanceName), "*.json"))
+	if len(legacy) == 0 {
+		return nil
+	}
+
+	var imported []string
+	_, err := db.Migrate("operations-json", func(tx *state.Tx) error {
+		for _, path := range legacy {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			var op Operation
+			if err := json.Unmarshal(data, &op); err != nil || op.ID == "" {
+				continue // Skip invalid JSON, as listing always did
+			}
+			if err := putOperation(tx, &op); err != nil {
+				return err
+			}
+			imported = append(imported, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("importing operation records: %w", err)
+	}
+
+	// The records are in the store now; unreadable files are left alone
+	for _, path := range imported {
+		os.Remove(path)
+	}
+	return nil
+}
+
+func putOperation(tx *state.Tx, op *Operation) error {
+	return tx.Put(operationsBucket, op.ID, op, state.Index{
+		"type":   op.Type,
+		"status": op.Status,
+		"target": op.Target,
+	})
+}
+
 // generateID generates a unique operation ID
 func generateID(opType, target string) string {
 	timestamp := time.Now().UnixNano()
@@ -70,7 +141,7 @@ func (m *Manager) Start(instanceName, opType, target string) (string, error) {
 		StartedAt: time.Now(),
 	}
 
-	// Write operation file
+	// Record operation
 	if err := m.writeOperation(op); err != nil {
 		return "", err
 	}
@@ -94,73 +165,83 @@ func (m *Manager) Get(opID string) (*Operation, error) {
 
 // GetByInstance returns an operation for a specific instance
 func (m *Manager) GetByInstance(instanceName, opID string) (*Operation, error) {
-	opsDir := m.GetOperationsDir(instanceName)
-	opPath := filepath.Join(opsDir, opID+".json")
-
-	if !storage.FileExists(opPath) {
-		return nil, fmt.Errorf("operation %s not found", opID)
-	}
-
-	data, err := os.ReadFile(opPath)
+	db, err := m.store(instanceName)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read operation: %w", err)
+		return nil, err
 	}
 
 	var op Operation
-	if err := json.Unmarshal(data, &op); err != nil {
-		return nil, fmt.Errorf("failed to parse operation: %w", err)
+	err = db.View(func(tx *state.Tx) error {
+		found, err := tx.Get(operationsBucket, opID, &op)
+		if err != nil {
+			return fmt.Errorf("failed to parse operation: %w", err)
+		}
+		if !found {
+			return fmt.Errorf("operation %s not found", opID)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return &op, nil
 }
 
-// Update modifies operation state
-func (m *Manager) Update(instanceName, opID, status, message string, progress int) error {
-	op, err := m.GetByInstance(instanceName, opID)
+// modify applies fn to an operation and saves it in one transaction
+func (m *Manager) modify(instanceName, opID string, fn func(op *Operation)) error {
+	db, err := m.store(instanceName)
 	if err != nil {
 		return err
 	}
 
-	op.Status = status
-	op.Message = message
-	op.Progress = progress
+	return db.Update(func(tx *state.Tx) error {
+		var op Operation
+		found, err := tx.Get(operationsBucket, opID, &op)
+		if err != nil {
+			return fmt.Errorf("failed to parse operation: %w", err)
+		}
+		if !found {
+			return fmt.Errorf("operation %s not found", opID)
+		}
 
-	if status == "completed" || status == "failed" || status == "cancelled" {
-		op.EndedAt = time.Now()
-	}
+		fn(&op)
+		return putOperation(tx, &op)
+	})
+}
 
-	return m.writeOperation(op)
+// Update modifies operation state
+func (m *Manager) Update(instanceName, opID, status, message string, progress int) error {
+	return m.modify(instanceName, opID, func(op *Operation) {
+		op.Status = status
+		op.Message = message
+		op.Progress = progress
+
+		if status == "completed" || status == "failed" || status == "cancelled" {
+			op.EndedAt = time.Now()
+		}
+	})
 }
 
 // UpdateStatus updates only the status
 func (m *Manager) UpdateStatus(instanceName, opID, status string) error {
-	op, err := m.GetByInstance(instanceName, opID)
-	if err != nil {
-		return err
-	}
-
-	op.Status = status
+	return m.modify(instanceName, opID, func(op *Operation) {
+		op.Status = status
 
-	if status == "completed" || status == "failed" || status == "cancelled" {
-		op.EndedAt = time.Now()
-	}
-
-	return m.writeOperation(op)
+		if status == "completed" || status == "failed" || status == "cancelled" {
+			op.EndedAt = time.Now()
+		}
+	})
 }
 
 // UpdateProgress updates operation progress
 func (m *Manager) UpdateProgress(instanceName, opID string, progress int, message string) error {
-	op, err := m.GetByInstance(instanceName, opID)
-	if err != nil {
-		return err
-	}
-
-	op.Progress = progress
-	if message != "" {
-		op.Message = message
-	}
-
-	return m.writeOperation(op)
+	return m.modify(instanceName, opID, func(op *Operation) {
+		op.Progress = progress
+		if message != "" {
+			op.Message = message
+		}
+	})
 }
 
 // Cancel requests operation cancellation
@@ -170,52 +251,65 @@ func (m *Manager) Cancel(instanceName, opID string) error {
 
 // List returns all operations for an instance
 func (m *Manager) List(instanceName string) ([]Operation, error) {
-	opsDir := m.GetOperationsDir(instanceName)
+	return m.Find(instanceName, Filter{})
+}
 
-	// Ensure directory exists
-	if err := storage.EnsureDir(opsDir, 0755); err != nil {
+// Find returns an instance's operations matching filter, using the store's
+// indexes rather than reading every record
+func (m *Manager) Find(instanceName string, filter Filter) ([]Operation, error) {
+	db, err := m.store(instanceName)
+	if err != nil {
 		return nil, err
 	}
 
-	// Read all operation files
-	entries, err := os.ReadDir(opsDir)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read operations directory: %w", err)
+	query := state.Query{}
+	if filter.Type != "" {
+		query["type"] = filter.Type
+	}
+	if filter.Status != "" {
+		query["status"] = filter.Status
+	}
+	if filter.Target != "" {
+		query["target"] = filter.Target
 	}
 
 	operations := []Operation{}
-	for _, entry := range entries {
-		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
-			continue
-		}
-
-		opPath := filepath.Join(opsDir, entry.Name())
-		data, err := os.ReadFile(opPath)
-		if err != nil {
-			continue // Skip files we can't read
+	err = db.View(func(tx *state.Tx) error {
+		for _, rec := range tx.Find(operationsBucket, query) {
+			var op Operation
+			if err := rec.Decode(&op); err != nil {
+				continue // Skip records we can't decode
+			}
+			operations = append(operations, op)
 		}
-
-		var op Operation
-		if err := json.Unmarshal(data, &op); err != nil {
-			continue // Skip invalid JSON
-		}
-
-		operations = append(operations, op)
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return operations, nil
 }
 
+// Exists reports whether an operation record exists
+func (m *Manager) Exists(instanceName, opID string) bool {
+	_, err := m.GetByInstance(instanceName, opID)
+	return err == nil
+}
+
 // Delete removes an operation record
 func (m *Manager) Delete(instanceName, opID string) error {
-	opsDir := m.GetOperationsDir(instanceName)
-	opPath := filepath.Join(opsDir, opID+".json")
-
-	if !storage.FileExists(opPath) {
-		return nil // Already deleted, idempotent
+	db, err := m.store(instanceName)
+	if err != nil {
+		return err
 	}
 
-	return os.Remove(opPath)
+	return db.Update(func(tx *state.Tx) error {
+		if !tx.Exists(operationsBucket, opID) {
+			return nil // Already deleted, idempotent
+		}
+		return tx.Delete(operationsBucket, opID)
+	})
 }
 
 // Cleanup removes old completed/failed operations
@@ -237,17 +331,14 @@ func (m *Manager) Cleanup(instanceName string, olderThan time.Duration) error {
 	return nil
 }
 
-// writeOperation writes operation to disk
+// writeOperation saves an operation record
 func (m *Manager) writeOperation(op *Operation) error {
-	opsDir := m.GetOperationsDir(op.Instance)
-	opPath := filepath.Join(opsDir, op.ID+".json")
-
-	data, err := json.MarshalIndent(op, "", "  ")
+	db, err := m.store(op.Instance)
 	if err != nil {
-		return fmt.Errorf("failed to marshal operation: %w", err)
+		return err
 	}
 
-	if err := storage.WriteFile(opPath, data, 0644); err != nil {
+	if err := db.Update(func(tx *state.Tx) error { return putOperation(tx, op) }); err != nil {
 		return fmt.Errorf("failed to write operation: %w", err)
 	}
 
@@ -0,0 +1,117 @@
+package operations
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestManager_StartUpdateFind(t *testing.T) {
+	m := NewManager(t.TempDir())
+
+	installID, err := m.Start("home", "install_service", "traefik")
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	if _, err := m.Start("home", "discover", "all"); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	if err := m.Update("home", installID, "completed", "done", 100); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	op, err := m.GetByInstance("home", installID)
+	if err != nil {
+		t.Fatalf("GetByInstance failed: %v", err)
+	}
+	if op.Status != "completed" || op.EndedAt.IsZero() {
+		t.Errorf("unexpected operation after update: %+v", op)
+	}
+
+	completed, err := m.Find("home", Filter{Status: "completed"})
+	if err != nil || len(completed) != 1 || completed[0].ID != installID {
+		t.Errorf("Find(completed) = %+v, %v", completed, err)
+	}
+	pending, _ := m.Find("home", Filter{Status: "pending", Type: "discover"})
+	if len(pending) != 1 {
+		t.Errorf("Find(pending discover) = %+v", pending)
+	}
+
+	if err := m.Delete("home", installID); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if m.Exists("home", installID) {
+		t.Error("operation still exists after delete")
+	}
+}
+
+func TestManager_ImportsJSONRecords(t *testing.T) {
+	dataDir := t.TempDir()
+	m := NewManager(dataDir)
+
+	opsDir := m.GetOperationsDir("home")
+	os.MkdirAll(opsDir, 0755)
+	legacy := Operation{
+		ID:        "op_install_service_traefik_1",
+		Type:      "install_service",
+		Target:    "traefik",
+		Instance:  "home",
+		Status:    "completed",
+		StartedAt: time.Now().Add(-time.Hour),
+	}
+	data, _ := json.Marshal(legacy)
+	os.WriteFile(filepath.Join(opsDir, legacy.ID+".json"), data, 0644)
+	os.WriteFile(filepath.Join(opsDir, "broken.json"), []byte("{"), 0644)
+
+	ops, err := m.Find("home", Filter{Type: "install_service"})
+	if err != nil {
+		t.Fatalf("Find failed: %v", err)
+	}
+	if len(ops) != 1 || ops[0].ID != legacy.ID {
+		t.Errorf("imported operations = %+v", ops)
+	}
+
+	if matches, _ := filepath.Glob(filepath.Join(opsDir, "*.json")); len(matches) != 1 {
+		t.Errorf("expected only the unreadable record left, got %v", matches)
+	}
+}
+
+func TestOutput_LogAndClose(t *testing.T) {
+	m := NewManager(t.TempDir())
+	broadcaster := NewBroadcaster()
+	ch := broadcaster.Subscribe("op_1")
+
+	out := m.Output("home", "op_1", broadcaster)
+	out.Printf("step %d\n", 1)
+	out.Write([]byte("partial "))
+	out.Write([]byte("line\nlast"))
+	if err := out.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	out.Printf("after close\n")
+
+	var lines []string
+	for data := range ch { // Ends once Close closes the stream
+		lines = append(lines, string(data))
+	}
+	if want := []string{"step 1", "partial line", "last"}; !reflect.DeepEqual(lines, want) {
+		t.Errorf("streamed %q, want %q", lines, want)
+	}
+
+	// A second output for the operation appends to its log
+	more := m.Output("home", "op_1", nil)
+	more.Printf("\nresumed\n")
+	more.Close()
+
+	data, err := os.ReadFile(filepath.Join(m.GetOperationsDir("home"), "op_1", "output.log"))
+	if err != nil {
+		t.Fatalf("output.log not written: %v", err)
+	}
+	if string(data) != "step 1\npartial line\nlast\nresumed\n" {
+		t.Errorf("output.log = %q", data)
+	}
+}
@@ -0,0 +1,143 @@
+package operations
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Output streams the progress of a long-running operation to the clients
+// following it and records it in the operation's output.log, so it can be
+// replayed once the operation is over. Close it when the operation ends.
+type Output struct {
+	opID        string
+	broadcaster *Broadcaster
+
+	mu     sync.Mutex
+	log    *os.File
+	line   bytes.Buffer // Incomplete line, published once it ends
+	closed bool
+}
+
+// NewOutput creates an output that only streams to the clients of an
+// operation. Either argument may be empty, in which case writes are
+// discarded.
+func NewOutput(opID string, broadcaster *Broadcaster) *Output {
+	return &Output{opID: opID, broadcaster: broadcaster}
+}
+
+// Output creates the output of an operation, appending to its output.log.
+// When the log cannot be opened, output is still streamed.
+func (m *Manager) Output(instanceName, opID string, broadcaster *Broadcaster) *Output {
+	out := NewOutput(opID, broadcaster)
+
+	logDir := filepath.Join(m.GetOperationsDir(instanceName), opID)
+	if err := os.MkdirAll(logDir, 0755); err != nil {
+		return out
+	}
+	if log, err := os.OpenFile(filepath.Join(logDir, "output.log"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644); err == nil {
+		out.log = log
+	}
+	return out
+}
+
+// ID returns the ID of the operation, or "" for an output without one
+func (o *Output) ID() string {
+	return o.opID
+}
+
+// Write records p in the log and publishes each complete line, as the
+// operation stream replays the log line by line
+func (o *Output) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.closed {
+		return len(p), nil
+	}
+	if o.log != nil {
+		o.log.Write(p)
+	}
+	if o.broadcaster == nil || o.opID == "" {
+		return len(p), nil
+	}
+
+	o.line.Write(p)
+	for {
+		line, err := o.line.ReadBytes('\n')
+		if err != nil {
+			// No complete line left; keep the rest for the next write
+			o.line.Write(line)
+			break
+		}
+		o.broadcaster.Publish(o.opID, line[:len(line)-1])
+	}
+	return len(p), nil
+}
+
+// Close publishes what is left of the last line, closes the log and ends
+// the stream of every client following the operation
+func (o *Output) Close() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.closed {
+		return nil
+	}
+	o.closed = true
+
+	if o.broadcaster != nil && o.opID != "" {
+		if o.line.Len() > 0 {
+			o.broadcaster.Publish(o.opID, append([]byte(nil), o.line.Bytes()...))
+			o.line.Reset()
+		}
+		o.broadcaster.Close(o.opID)
+	}
+	if o.log != nil {
+		return o.log.Close()
+	}
+	return nil
+}
+
+// Printf writes a formatted progress line
+func (o *Output) Printf(format string, args ...interface{}) {
+	fmt.Fprintf(o, format, args...)
+}
+
+// Run runs cmd, streaming its combined output line by line. The error
+// carries the last few lines, which usually say what went wrong.
+func (o *Output) Run(cmd *exec.Cmd) error {
+	reader, writer := io.Pipe()
+	cmd.Stdout = writer
+	cmd.Stderr = writer
+
+	var tail []string // Last lines, for the error
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		scanner := bufio.NewScanner(reader)
+		for scanner.Scan() {
+			line := scanner.Text()
+			o.Printf("    %s\n", line)
+			if tail = append(tail, line); len(tail) > 5 {
+				tail = tail[1:]
+			}
+		}
+		io.Copy(io.Discard, reader)
+	}()
+
+	err := cmd.Run()
+	writer.Close()
+	<-done
+
+	if err != nil && len(tail) > 0 {
+		return fmt.Errorf("%w: %s", err, strings.Join(tail, "; "))
+	}
+	return err
+}
@@ -123,27 +123,12 @@ func (m *Manager) DownloadAsset(instanceName, assetType, version, url string) er
 		return fmt.Errorf("failed to download %s: status %d", url, resp.StatusCode)
 	}
 
-	// Create temporary file
-	tmpFile := assetPath + ".tmp"
-	out, err := os.Create(tmpFile)
-	if err != nil {
-		return fmt.Errorf("failed to create file: %w", err)
-	}
-	defer out.Close()
-
-	// Copy data
-	_, err = io.Copy(out, resp.Body)
-	if err != nil {
-		os.Remove(tmpFile)
+	// Write through a temporary file so an interrupted download never
+	// replaces a good asset
+	if err := storage.WriteFileFrom(assetPath, resp.Body, 0644); err != nil {
 		return fmt.Errorf("failed to write file: %w", err)
 	}
 
-	// Move to final location
-	if err := os.Rename(tmpFile, assetPath); err != nil {
-		os.Remove(tmpFile)
-		return fmt.Errorf("failed to move file: %w", err)
-	}
-
 	return nil
 }
 
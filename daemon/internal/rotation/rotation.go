@@ -0,0 +1,537 @@
+// Package rotation rotates instance secrets and propagates the new values to
+// the cluster services and apps that use them.
+package rotation
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/wild-cloud/wild-central/daemon/internal/apps"
+	"github.com/wild-cloud/wild-central/daemon/internal/operations"
+	"github.com/wild-cloud/wild-central/daemon/internal/secrets"
+	"github.com/wild-cloud/wild-central/daemon/internal/services"
+	"github.com/wild-cloud/wild-central/daemon/internal/storage"
+	"github.com/wild-cloud/wild-central/daemon/internal/tools"
+)
+
+// Reference kinds
+const (
+	KindService = "service"
+	KindApp     = "app"
+)
+
+// Manager coordinates secret rotation for instances
+type Manager struct {
+	dataDir       string
+	directoryPath string
+	secrets       *secrets.Manager
+}
+
+// NewManager creates a new rotation manager
+func NewManager(dataDir, directoryPath string) *Manager {
+	return &Manager{
+		dataDir:       dataDir,
+		directoryPath: directoryPath,
+		secrets:       secrets.NewManager(),
+	}
+}
+
+// Reference is a service or app that uses a secret
+type Reference struct {
+	Kind  string                 `json:"kind"`
+	Name  string                 `json:"name"`
+	Files []string               `json:"files,omitempty"` // Files mentioning the secret, relative to the service or app
+	Hooks []secrets.RotationHook `json:"hooks,omitempty"`
+	dir   string
+}
+
+// Options controls a rotation
+type Options struct {
+	Length int  // Length of the generated value, defaults to 32
+	Deploy bool // Redeploy services after recompiling them
+}
+
+// Result summarizes a completed rotation
+type Result struct {
+	Key        string      `json:"key"`
+	References []Reference `json:"references"`
+}
+
+// rotationStep is a completed propagation step, kept so it can be undone
+type rotationStep struct {
+	ref  Reference
+	hook *secrets.RotationHook
+}
+
+func (m *Manager) instancePath(instanceName string) string {
+	return filepath.Join(m.dataDir, "instances", instanceName)
+}
+
+// LockPath returns the lock held while a rotation or rollback of the
+// instance runs, so they run one at a time
+func (m *Manager) LockPath(instanceName string) string {
+	return filepath.Join(m.instancePath(instanceName), "secrets-rotation.lock")
+}
+
+// lock takes the rotation lock for the operation writing to out
+func (m *Manager) lock(instanceName string, out *operations.Output) (*storage.Lock, error) {
+	lock, err := storage.AcquireOperationLock(m.LockPath(instanceName), out.ID())
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock rotations: %w", err)
+	}
+	return lock, nil
+}
+
+func (m *Manager) appsManager() *apps.Manager {
+	return apps.NewManager(m.dataDir, filepath.Join(m.directoryPath, "apps"))
+}
+
+func (m *Manager) servicesManager() *services.Manager {
+	return services.NewManager(m.dataDir, filepath.Join(m.directoryPath, "setup", "cluster-services"))
+}
+
+// FindReferences returns the installed services and configured apps whose
+// templates, scripts or rotation hooks reference a secret key
+func (m *Manager) FindReferences(instanceName, key string) ([]Reference, error) {
+	var refs []Reference
+
+	// Services installed on the instance
+	servicesDir := filepath.Join(m.instancePath(instanceName), "setup", "cluster-services")
+	entries, err := os.ReadDir(servicesDir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read services: %w", err)
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		serviceDir := filepath.Join(servicesDir, entry.Name())
+
+		var hooks []secrets.RotationHook
+		mapped := false
+		if manifest, err := services.LoadManifest(serviceDir); err == nil {
+			hooks = hooksFor(manifest.RotationHooks, key)
+			mapped = mapsKey(manifest.KubernetesSecrets, key)
+		}
+
+		files, err := scanFiles(serviceDir, key,
+			filepath.Join(serviceDir, "kustomize.template"),
+			filepath.Join(serviceDir, "install.sh"))
+		if err != nil {
+			return nil, err
+		}
+
+		if mapped {
+			files = append(files, "wild-manifest.yaml")
+		}
+
+		if len(files) > 0 || len(hooks) > 0 {
+			refs = append(refs, Reference{Kind: KindService, Name: entry.Name(), Files: files, Hooks: hooks, dir: serviceDir})
+		}
+	}
+
+	// Apps configured on the instance
+	appsMgr := m.appsManager()
+	configured, err := appsMgr.ListConfigured(instanceName)
+	if err != nil {
+		return nil, err
+	}
+	for _, appName := range configured {
+		app, err := appsMgr.Get(appName)
+		if err != nil {
+			continue
+		}
+		appDir := appsMgr.GetAppDir(appName)
+
+		files, err := scanFiles(appDir, key, appDir)
+		if err != nil {
+			return nil, err
+		}
+		hooks := hooksFor(app.RotationHooks, key)
+
+		if len(files) > 0 || len(hooks) > 0 {
+			refs = append(refs, Reference{Kind: KindApp, Name: appName, Files: files, Hooks: hooks, dir: appDir})
+		}
+	}
+
+	return refs, nil
+}
+
+// Rotate generates a new value for a secret and propagates it: rotation hooks
+// run first, then referencing services are recompiled (and redeployed) and
+// referencing apps get their Kubernetes Secret updated and are restarted.
+// The previous value is kept in the secret history. If any step fails, the
+// old value is restored, the hooks that already ran are reversed and the
+// services and apps already updated get the old value back.
+// Progress goes to out; each service is redeployed under an operation of
+// its own, streamed with broadcaster.
+func (m *Manager) Rotate(instanceName, key string, opts Options, out *operations.Output, broadcaster *operations.Broadcaster) (*Result, error) {
+	if opts.Length <= 0 {
+		opts.Length = 32
+	}
+
+	lock, err := m.lock(instanceName, out)
+	if err != nil {
+		return nil, err
+	}
+	defer lock.Release()
+
+	instancePath := m.instancePath(instanceName)
+	store, err := m.secrets.Open(instancePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open secrets: %w", err)
+	}
+
+	oldValue, err := store.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	if oldValue == "" || oldValue == "null" {
+		return nil, fmt.Errorf("secret %s is not set", key)
+	}
+
+	refs, err := m.FindReferences(instanceName, key)
+	if err != nil {
+		return nil, err
+	}
+
+	newValue, err := secrets.GenerateSecret(opts.Length)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := m.secrets.RecordHistory(instancePath, key, oldValue, out.ID()); err != nil {
+		return nil, fmt.Errorf("failed to record previous value: %w", err)
+	}
+	if err := store.Set(key, newValue); err != nil {
+		m.secrets.PopHistory(instancePath, key)
+		return nil, fmt.Errorf("failed to store new value: %w", err)
+	}
+
+	out.Printf("🔑 Rotated %s; propagating to %d reference(s)\n", key, len(refs))
+
+	done, err := m.propagate(instanceName, key, oldValue, newValue, refs, opts, out, broadcaster)
+	if err != nil {
+		out.Printf("❌ %v\n↩️  Restoring previous value of %s\n", err, key)
+		if restoreErr := store.Set(key, oldValue); restoreErr != nil {
+			return nil, fmt.Errorf("%w (restoring previous value also failed: %v)", err, restoreErr)
+		}
+		m.secrets.PopHistory(instancePath, key)
+		return nil, m.undo(instanceName, key, newValue, oldValue, done, opts, out, broadcaster, err)
+	}
+
+	out.Printf("✅ Rotation of %s complete\n", key)
+	return &Result{Key: key, References: refs}, nil
+}
+
+// Rollback restores the most recent previous value of a secret and
+// propagates it the same way as a rotation, undoing its own steps and
+// keeping the current value if propagation fails
+func (m *Manager) Rollback(instanceName, key string, opts Options, out *operations.Output, broadcaster *operations.Broadcaster) (*Result, error) {
+	lock, err := m.lock(instanceName, out)
+	if err != nil {
+		return nil, err
+	}
+	defer lock.Release()
+
+	instancePath := m.instancePath(instanceName)
+	store, err := m.secrets.Open(instancePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open secrets: %w", err)
+	}
+
+	current, err := store.Get(key)
+	if err != nil {
+		return nil, err
+	}
+
+	refs, err := m.FindReferences(instanceName, key)
+	if err != nil {
+		return nil, err
+	}
+
+	entry, err := m.secrets.PopHistory(instancePath, key)
+	if err != nil {
+		return nil, err
+	}
+	if err := store.Set(key, entry.Value); err != nil {
+		m.secrets.RecordHistory(instancePath, key, entry.Value, entry.OperationID)
+		return nil, fmt.Errorf("failed to restore previous value: %w", err)
+	}
+
+	out.Printf("↩️  Restored %s from %s; propagating to %d reference(s)\n",
+		key, entry.ReplacedAt.Format("2006-01-02 15:04:05"), len(refs))
+
+	done, err := m.propagate(instanceName, key, current, entry.Value, refs, opts, out, broadcaster)
+	if err != nil {
+		err = fmt.Errorf("rollback propagation failed: %w", err)
+		out.Printf("❌ %v\n↩️  Keeping the current value of %s\n", err, key)
+		if restoreErr := store.Set(key, current); restoreErr != nil {
+			return nil, fmt.Errorf("%w (restoring current value also failed: %v)", err, restoreErr)
+		}
+		m.secrets.RecordHistory(instancePath, key, entry.Value, entry.OperationID)
+		return nil, m.undo(instanceName, key, entry.Value, current, done, opts, out, broadcaster, err)
+	}
+
+	out.Printf("✅ Rollback of %s complete\n", key)
+	return &Result{Key: key, References: refs}, nil
+}
+
+// undo compensates a failed propagation once the stored value is back to
+// restored: hooks that ran are reversed, newest first, so external systems
+// accept the restored value again, then every service and app propagation
+// touched gets it back. cause is returned, naming anything left on failed.
+func (m *Manager) undo(instanceName, key, failed, restored string, done []rotationStep, opts Options, out *operations.Output, broadcaster *operations.Broadcaster, cause error) error {
+	var stuck []string
+	for i := len(done) - 1; i >= 0; i-- {
+		if hook := done[i].hook; hook != nil {
+			if err := m.runHook(instanceName, key, failed, restored, done[i].ref, *hook, out); err != nil {
+				out.Printf("⚠️  Undoing hook %s failed: %v\n", hook.Script, err)
+				stuck = append(stuck, fmt.Sprintf("hook %s of %s %s", hook.Script, done[i].ref.Kind, done[i].ref.Name))
+			}
+		}
+	}
+	for _, step := range done {
+		if step.hook == nil {
+			if err := m.apply(instanceName, step.ref, opts, out, broadcaster); err != nil {
+				out.Printf("⚠️  Restoring %s %s failed: %v\n", step.ref.Kind, step.ref.Name, err)
+				stuck = append(stuck, fmt.Sprintf("%s %s", step.ref.Kind, step.ref.Name))
+			}
+		}
+	}
+
+	if len(stuck) > 0 {
+		return fmt.Errorf("%w; these may still use the value that failed to propagate: %s", cause, strings.Join(stuck, ", "))
+	}
+	return cause
+}
+
+// propagate runs hooks, then updates services and apps. It returns the steps
+// that ran, including a service or app that failed partway through its
+// update, so a failed propagation can undo them.
+func (m *Manager) propagate(instanceName, key, oldValue, newValue string, refs []Reference, opts Options, out *operations.Output, broadcaster *operations.Broadcaster) ([]rotationStep, error) {
+	var done []rotationStep
+
+	total := 0
+	for _, ref := range refs {
+		total += len(ref.Hooks) + 1
+	}
+	step := 0
+	progress := func(message string) {
+		step++
+		if out.ID() != "" && total > 0 {
+			operations.NewManager(m.dataDir).UpdateProgress(instanceName, out.ID(), step*100/(total+1), message)
+		}
+	}
+
+	// Hooks change external state (e.g. database users) before dependents restart
+	for _, ref := range refs {
+		for i := range ref.Hooks {
+			hook := ref.Hooks[i]
+			progress(fmt.Sprintf("Running hook %s for %s %s", hook.Script, ref.Kind, ref.Name))
+			if err := m.runHook(instanceName, key, oldValue, newValue, ref, hook, out); err != nil {
+				return done, fmt.Errorf("hook %s for %s %s failed: %w", hook.Script, ref.Kind, ref.Name, err)
+			}
+			done = append(done, rotationStep{ref: ref, hook: &hook})
+		}
+	}
+
+	for _, ref := range refs {
+		progress(fmt.Sprintf("Updating %s %s", ref.Kind, ref.Name))
+		done = append(done, rotationStep{ref: ref})
+		if err := m.apply(instanceName, ref, opts, out, broadcaster); err != nil {
+			return done, fmt.Errorf("updating %s %s failed: %w", ref.Kind, ref.Name, err)
+		}
+	}
+
+	return done, nil
+}
+
+// apply pushes the current secret values to a service or app
+func (m *Manager) apply(instanceName string, ref Reference, opts Options, out *operations.Output, broadcaster *operations.Broadcaster) error {
+	switch ref.Kind {
+	case KindService:
+		servicesMgr := m.servicesManager()
+		out.Printf("🔧 Recompiling service %s\n", ref.Name)
+		if err := servicesMgr.Compile(instanceName, ref.Name); err != nil {
+			return err
+		}
+		if !opts.Deploy {
+			// Kubernetes Secrets are still updated; deploying would sync them too
+			_, err := servicesMgr.SyncSecrets(instanceName, ref.Name, false)
+			return err
+		}
+		out.Printf("🚀 Redeploying service %s\n", ref.Name)
+		return m.deploy(instanceName, ref.Name, out, broadcaster)
+	case KindApp:
+		appsMgr := m.appsManager()
+		out.Printf("🔐 Updating secrets for app %s\n", ref.Name)
+		if _, err := appsMgr.ApplySecrets(instanceName, ref.Name); err != nil {
+			return err
+		}
+		out.Printf("🔄 Restarting app %s\n", ref.Name)
+		return appsMgr.Restart(instanceName, ref.Name)
+	}
+	return fmt.Errorf("unknown reference kind %s", ref.Kind)
+}
+
+// deploy redeploys a service under an operation of its own. Deploy owns the
+// log and stream of the operation it runs under, so it must not be given
+// the rotation's.
+func (m *Manager) deploy(instanceName, serviceName string, out *operations.Output, broadcaster *operations.Broadcaster) error {
+	opsMgr := operations.NewManager(m.dataDir)
+	opID, err := opsMgr.Start(instanceName, "deploy_service", serviceName)
+	if err != nil {
+		return fmt.Errorf("failed to start deploy operation: %w", err)
+	}
+	out.Printf("    Deploying in operation %s\n", opID)
+
+	opsMgr.UpdateStatus(instanceName, opID, "running")
+	if err := m.servicesManager().Deploy(instanceName, serviceName, opID, broadcaster); err != nil {
+		opsMgr.Update(instanceName, opID, "failed", err.Error(), 0)
+		return fmt.Errorf("%w (see operation %s)", err, opID)
+	}
+	opsMgr.Update(instanceName, opID, "completed", fmt.Sprintf("Service %s deployed", serviceName), 100)
+	return nil
+}
+
+// runHook executes a rotation hook script from the declaring service or app
+// directory. The old and new values are written to its stdin, one per line;
+// the environment only names the key, so values never show in the process
+// table or reach the children of the hook.
+func (m *Manager) runHook(instanceName, key, oldValue, newValue string, ref Reference, hook secrets.RotationHook, out *operations.Output) error {
+	script := filepath.Join(ref.dir, hook.Script)
+	if rel, err := filepath.Rel(ref.dir, script); err != nil || strings.HasPrefix(rel, "..") {
+		return fmt.Errorf("hook script %s is outside of %s", hook.Script, ref.Name)
+	}
+	if _, err := os.Stat(script); err != nil {
+		return fmt.Errorf("hook script not found: %w", err)
+	}
+
+	out.Printf("🪝 Running %s for %s %s\n", hook.Script, ref.Kind, ref.Name)
+
+	kubeconfigPath := tools.GetKubeconfigPath(m.dataDir, instanceName)
+	env := append(os.Environ(),
+		fmt.Sprintf("WILD_INSTANCE=%s", instanceName),
+		fmt.Sprintf("WILD_CENTRAL_DATA=%s", m.dataDir),
+		fmt.Sprintf("KUBECONFIG=%s", kubeconfigPath),
+		fmt.Sprintf("WILD_SECRET_KEY=%s", key),
+	)
+	if store, err := m.secrets.Open(m.instancePath(instanceName)); err == nil {
+		if data, err := store.Read(); err == nil {
+			secretsFile, removeSecrets, err := secrets.WriteTempFile(m.dataDir, data)
+			if err != nil {
+				return err
+			}
+			defer removeSecrets()
+			env = append(env, fmt.Sprintf("%s=%s", secrets.EnvSecretsFile, secretsFile))
+		}
+	}
+
+	cmd := exec.Command("bash", script)
+	cmd.Dir = ref.dir
+	cmd.Env = env
+	cmd.Stdin = strings.NewReader(oldValue + "\n" + newValue + "\n")
+	cmd.Stdout = out
+	cmd.Stderr = out
+	return cmd.Run()
+}
+
+// hooksFor returns the hooks declared for a secret key
+func hooksFor(hooks []secrets.RotationHook, key string) []secrets.RotationHook {
+	var result []secrets.RotationHook
+	for _, hook := range hooks {
+		if hook.Secret == key {
+			result = append(result, hook)
+		}
+	}
+	return result
+}
+
+// mapsKey reports whether any Kubernetes Secret mapping is filled from a key
+func mapsKey(mappings []secrets.SecretMapping, key string) bool {
+	for _, mapping := range mappings {
+		for _, path := range mapping.Data {
+			if path == key {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// scanFiles returns the files under the given roots that mention a secret
+// key, relative to baseDir. Missing roots are skipped.
+func scanFiles(baseDir, key string, roots ...string) ([]string, error) {
+	var files []string
+	for _, root := range roots {
+		err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) {
+					return nil
+				}
+				return err
+			}
+			if info.IsDir() {
+				return nil
+			}
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			if mentionsKey(string(data), key) {
+				rel, _ := filepath.Rel(baseDir, path)
+				files = append(files, rel)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan %s: %w", root, err)
+		}
+	}
+	return files, nil
+}
+
+// mentionsKey reports whether text contains a secret key as a whole path.
+// The key may appear bare (secretKeyRef keys), with a leading dot (yq
+// expressions) or under .secrets (gomplate templates), but not as part of a
+// longer path such as other.apps.db.password or apps.db.passwordOld.
+func mentionsKey(text, key string) bool {
+	for offset := 0; ; {
+		i := strings.Index(text[offset:], key)
+		if i < 0 {
+			return false
+		}
+		start := offset + i
+		end := start + len(key)
+		offset = start + 1
+
+		if end < len(text) && (isPathChar(text[end]) || text[end] == '.' && end+1 < len(text) && isPathChar(text[end+1])) {
+			continue
+		}
+
+		if start == 0 || !isPathChar(text[start-1]) && text[start-1] != '.' {
+			return true
+		}
+		if text[start-1] == '.' {
+			prefix := text[:start-1]
+			if strings.HasSuffix(prefix, "secrets") {
+				prefix = strings.TrimSuffix(prefix, "secrets")
+				if prefix == "" || !isPathChar(prefix[len(prefix)-1]) {
+					return true
+				}
+			}
+			if prefix == "" || !isPathChar(prefix[len(prefix)-1]) && prefix[len(prefix)-1] != '.' {
+				return true
+			}
+		}
+	}
+}
+
+func isPathChar(c byte) bool {
+	return c == '_' || c == '-' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
@@ -0,0 +1,112 @@
+package rotation
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/wild-cloud/wild-central/daemon/internal/operations"
+	"github.com/wild-cloud/wild-central/daemon/internal/secrets"
+)
+
+func TestMentionsKey(t *testing.T) {
+	key := "apps.db.password"
+	tests := []struct {
+		text string
+		want bool
+	}{
+		{"key: apps.db.password\n", true},
+		{"yq '.apps.db.password' \"$WILD_SECRETS_FILE\"", true},
+		{"{{ .secrets.apps.db.password }}", true},
+		{"key: apps.db.passwordOld\n", false},
+		{"key: apps.db.password.extra\n", false},
+		{"path: other.apps.db.password\n", false},
+		{"key: myapps.db.password\n", false},
+		{"sentence ending in apps.db.password.", true},
+		{"nothing here", false},
+	}
+
+	for _, tt := range tests {
+		if got := mentionsKey(tt.text, key); got != tt.want {
+			t.Errorf("mentionsKey(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestFindReferences(t *testing.T) {
+	dataDir := t.TempDir()
+	directoryPath := t.TempDir()
+	instanceDir := filepath.Join(dataDir, "instances", "test")
+
+	write := func(path, content string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write(filepath.Join(instanceDir, "config.yaml"), "apps:\n  blog:\n    dbUser: blog\n")
+
+	// Installed service using the secret in its install script
+	serviceDir := filepath.Join(instanceDir, "setup", "cluster-services", "dns")
+	write(filepath.Join(serviceDir, "wild-manifest.yaml"), "name: dns\n")
+	write(filepath.Join(serviceDir, "install.sh"), "TOKEN=$(yq '.dns.token' \"$WILD_SECRETS_FILE\")\n")
+	write(filepath.Join(serviceDir, "kustomize.template", "secret.yaml"), "token: {{ .secrets.dns.token }}\n")
+
+	// Configured app with a hook, and an unconfigured app using the same secret
+	write(filepath.Join(directoryPath, "apps", "blog", "manifest.yaml"),
+		"name: blog\nrequiredSecrets:\n  - apps.blog.dbPassword\nrotationHooks:\n  - secret: apps.blog.dbPassword\n    script: hooks/rotate.sh\n")
+	write(filepath.Join(directoryPath, "apps", "blog", "deployment.yaml"), "key: apps.blog.dbPassword\n")
+	write(filepath.Join(directoryPath, "apps", "other", "manifest.yaml"), "name: other\nrequiredSecrets:\n  - apps.blog.dbPassword\n")
+
+	m := NewManager(dataDir, directoryPath)
+
+	refs, err := m.FindReferences("test", "dns.token")
+	if err != nil {
+		t.Fatalf("FindReferences failed: %v", err)
+	}
+	if len(refs) != 1 || refs[0].Kind != KindService || refs[0].Name != "dns" {
+		t.Fatalf("Expected dns service reference, got %+v", refs)
+	}
+	if len(refs[0].Files) != 2 {
+		t.Errorf("Expected 2 referencing files, got %v", refs[0].Files)
+	}
+
+	refs, err = m.FindReferences("test", "apps.blog.dbPassword")
+	if err != nil {
+		t.Fatalf("FindReferences failed: %v", err)
+	}
+	if len(refs) != 1 || refs[0].Kind != KindApp || refs[0].Name != "blog" {
+		t.Fatalf("Expected only the configured blog app, got %+v", refs)
+	}
+	if len(refs[0].Hooks) != 1 || refs[0].Hooks[0].Script != "hooks/rotate.sh" {
+		t.Errorf("Expected rotation hook, got %+v", refs[0].Hooks)
+	}
+}
+
+func TestRunHook_ValuesOnStdin(t *testing.T) {
+	appDir := t.TempDir()
+	script := "read -r OLD; read -r NEW\n" +
+		"echo \"$WILD_SECRET_KEY $OLD $NEW ${WILD_SECRET_OLD_VALUE:-unset} ${WILD_SECRET_NEW_VALUE:-unset}\" > result\n"
+	if err := os.WriteFile(filepath.Join(appDir, "rotate.sh"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	m := NewManager(t.TempDir(), t.TempDir())
+	ref := Reference{Kind: KindApp, Name: "blog", dir: appDir}
+	hook := secrets.RotationHook{Secret: "apps.blog.dbPassword", Script: "rotate.sh"}
+	if err := m.runHook("test", hook.Secret, "old-value", "new-value", ref, hook, operations.NewOutput("", nil)); err != nil {
+		t.Fatalf("runHook failed: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(appDir, "result"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(data), "apps.blog.dbPassword old-value new-value unset unset\n"; got != want {
+		t.Errorf("hook saw %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,483 @@
+package secrets
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/wild-cloud/wild-central/daemon/internal/storage"
+)
+
+// Secrets encryption uses an envelope: each file's contents are sealed with a
+// random data key (AES-256-GCM), and the data key is sealed with the master key.
+// Rotating the master key only re-wraps data keys.
+
+const (
+	// KeySize is the size of master and data keys in bytes (AES-256)
+	KeySize = 32
+
+	// EnvPassphrase unlocks secrets with a passphrase instead of a key file
+	EnvPassphrase = "WILD_CENTRAL_SECRETS_PASSPHRASE"
+	// EnvKeyFile overrides the location of the master key file
+	EnvKeyFile = "WILD_CENTRAL_SECRETS_KEY_FILE"
+	// EnvSecretsFile names the private temporary file holding an instance's
+	// decrypted secrets for install scripts and hooks
+	EnvSecretsFile = "WILD_SECRETS_FILE"
+
+	envelopeVersion = 1
+	envelopeCipher  = "aes-256-gcm"
+	pbkdf2Iter      = 600000
+)
+
+// Key is a master key used to wrap per-file data keys
+type Key struct {
+	ID  string
+	key []byte
+}
+
+// Envelope is the on-disk format of an encrypted secrets file
+type Envelope struct {
+	Encrypted struct {
+		Version    int    `yaml:"version"`
+		Cipher     string `yaml:"cipher"`
+		KeyID      string `yaml:"keyId"`
+		WrappedKey string `yaml:"wrappedKey"` // Data key sealed with the master key
+		Data       string `yaml:"data"`       // File contents sealed with the data key
+	} `yaml:"wildCloudEncrypted"`
+}
+
+var (
+	keyringMu sync.RWMutex
+	keyring   []*Key // keyring[0] encrypts; all keys decrypt
+)
+
+// NewKey creates a key from raw key material
+func NewKey(raw []byte) (*Key, error) {
+	if len(raw) != KeySize {
+		return nil, fmt.Errorf("invalid key size: expected %d bytes, got %d", KeySize, len(raw))
+	}
+	sum := sha256.Sum256(raw)
+	return &Key{ID: hex.EncodeToString(sum[:8]), key: raw}, nil
+}
+
+// GenerateKey creates a new random master key
+func GenerateKey() (*Key, error) {
+	raw := make([]byte, KeySize)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, fmt.Errorf("generating key: %w", err)
+	}
+	return NewKey(raw)
+}
+
+// KeyFromPassphrase derives a master key from a passphrase and salt
+func KeyFromPassphrase(passphrase string, salt []byte) (*Key, error) {
+	if passphrase == "" {
+		return nil, fmt.Errorf("passphrase cannot be empty")
+	}
+	raw, err := pbkdf2.Key(sha256.New, passphrase, salt, pbkdf2Iter, KeySize)
+	if err != nil {
+		return nil, fmt.Errorf("deriving key: %w", err)
+	}
+	return NewKey(raw)
+}
+
+// ReadKeyFile reads a base64-encoded master key from a file
+func ReadKeyFile(path string) (*Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading key file: %w", err)
+	}
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, fmt.Errorf("decoding key file %s: %w", path, err)
+	}
+	return NewKey(raw)
+}
+
+// WriteKeyFile writes a master key to a file readable only by the owner
+func WriteKeyFile(path string, key *Key) error {
+	if err := storage.EnsureDir(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	encoded := base64.StdEncoding.EncodeToString(key.key) + "\n"
+	return storage.WriteFile(path, []byte(encoded), 0600)
+}
+
+// GetKeyFilePath returns the master key file location
+func GetKeyFilePath(dataDir string) string {
+	if path := os.Getenv(EnvKeyFile); path != "" {
+		return path
+	}
+	return filepath.Join(dataDir, "secrets.key")
+}
+
+// getSaltPath returns the location of the passphrase salt
+func getSaltPath(dataDir string) string {
+	return filepath.Join(dataDir, "secrets.salt")
+}
+
+// getRotationJournalPath returns where an unfinished passphrase rotation is
+// recorded
+func getRotationJournalPath(dataDir string) string {
+	return getSaltPath(dataDir) + ".new"
+}
+
+// rotationJournal records a passphrase rotation in progress. Each key is
+// sealed with the other, so whichever passphrase the daemon restarts with
+// recovers both and files re-wrapped before an interruption stay readable.
+type rotationJournal struct {
+	OldSalt  string `yaml:"oldSalt"`
+	NewSalt  string `yaml:"newSalt"`
+	OldKeyID string `yaml:"oldKeyId"`
+	NewKeyID string `yaml:"newKeyId"`
+	OldKey   string `yaml:"oldKey"` // Old key sealed with the new key
+	NewKey   string `yaml:"newKey"` // New key sealed with the old key
+}
+
+// LoadKeys loads the master keys configured for the data directory.
+// A passphrase in the environment takes precedence over the key file. A key
+// staged by an interrupted rotation is loaded as well so that files already
+// re-wrapped with it stay readable. Returns no keys when encryption is not configured.
+func LoadKeys(dataDir string) ([]*Key, error) {
+	if passphrase := os.Getenv(EnvPassphrase); passphrase != "" {
+		salt, err := ensureSalt(getSaltPath(dataDir))
+		if err != nil {
+			return nil, err
+		}
+		key, err := KeyFromPassphrase(passphrase, salt)
+		if err != nil {
+			return nil, err
+		}
+
+		journalPath := getRotationJournalPath(dataDir)
+		if !storage.FileExists(journalPath) {
+			return []*Key{key}, nil
+		}
+		keys, err := recoverRotation(journalPath, passphrase, key)
+		if err != nil {
+			return nil, fmt.Errorf("recovering interrupted key rotation: %w", err)
+		}
+		return keys, nil
+	}
+
+	keyPath := GetKeyFilePath(dataDir)
+	if !storage.FileExists(keyPath) {
+		return nil, nil
+	}
+
+	key, err := ReadKeyFile(keyPath)
+	if err != nil {
+		return nil, err
+	}
+	keys := []*Key{key}
+
+	if storage.FileExists(keyPath + ".new") {
+		staged, err := ReadKeyFile(keyPath + ".new")
+		if err != nil {
+			return nil, fmt.Errorf("reading staged key: %w", err)
+		}
+		keys = append(keys, staged)
+	}
+
+	return keys, nil
+}
+
+// recoverRotation returns both keys of an interrupted passphrase rotation,
+// the one derived from passphrase first. key is derived from passphrase and
+// the current salt, which is the old or new salt depending on where the
+// rotation stopped.
+func recoverRotation(journalPath, passphrase string, key *Key) ([]*Key, error) {
+	data, err := os.ReadFile(journalPath)
+	if err != nil {
+		return nil, err
+	}
+	var journal rotationJournal
+	if err := yaml.Unmarshal(data, &journal); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", journalPath, err)
+	}
+
+	// Derive with the other salt only when the current one does not match
+	for _, encodedSalt := range []string{"", journal.OldSalt, journal.NewSalt} {
+		if encodedSalt != "" {
+			salt, err := base64.StdEncoding.DecodeString(encodedSalt)
+			if err != nil {
+				return nil, fmt.Errorf("decoding salt: %w", err)
+			}
+			if key, err = KeyFromPassphrase(passphrase, salt); err != nil {
+				return nil, err
+			}
+		}
+
+		var sealed string
+		switch key.ID {
+		case journal.OldKeyID:
+			sealed = journal.NewKey
+		case journal.NewKeyID:
+			sealed = journal.OldKey
+		default:
+			continue
+		}
+
+		raw, err := base64.StdEncoding.DecodeString(sealed)
+		if err != nil {
+			return nil, fmt.Errorf("decoding key: %w", err)
+		}
+		if raw, err = open(key.key, raw); err != nil {
+			return nil, fmt.Errorf("unsealing key: %w", err)
+		}
+		other, err := NewKey(raw)
+		if err != nil {
+			return nil, err
+		}
+		return []*Key{key, other}, nil
+	}
+
+	return nil, fmt.Errorf("the passphrase is neither the old nor the new one")
+}
+
+// ensureSalt reads the passphrase salt, creating it on first use
+func ensureSalt(path string) ([]byte, error) {
+	if storage.FileExists(path) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading salt: %w", err)
+		}
+		return base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	}
+
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("generating salt: %w", err)
+	}
+	if err := storage.WriteFile(path, []byte(base64.StdEncoding.EncodeToString(salt)+"\n"), 0600); err != nil {
+		return nil, err
+	}
+	return salt, nil
+}
+
+// Unlock makes keys available to all secrets managers in this process.
+// The first key is used for encryption; all keys can decrypt.
+func Unlock(keys ...*Key) {
+	keyringMu.Lock()
+	defer keyringMu.Unlock()
+	keyring = nil
+	for _, k := range keys {
+		if k != nil {
+			keyring = append(keyring, k)
+		}
+	}
+}
+
+// Lock removes all keys from memory
+func Lock() {
+	Unlock()
+}
+
+// ActiveKey returns the key used for encryption, or nil when encryption is disabled
+func ActiveKey() *Key {
+	keyringMu.RLock()
+	defer keyringMu.RUnlock()
+	if len(keyring) == 0 {
+		return nil
+	}
+	return keyring[0]
+}
+
+// unlockedKeys returns the keyring, active key first
+func unlockedKeys() []*Key {
+	keyringMu.RLock()
+	defer keyringMu.RUnlock()
+	return append([]*Key(nil), keyring...)
+}
+
+// findKey returns the unlocked key with the given ID
+func findKey(id string) *Key {
+	keyringMu.RLock()
+	defer keyringMu.RUnlock()
+	for _, k := range keyring {
+		if k.ID == id {
+			return k
+		}
+	}
+	return nil
+}
+
+// IsEncrypted reports whether data is an encrypted secrets envelope
+func IsEncrypted(data []byte) bool {
+	if !bytes.Contains(data, []byte("wildCloudEncrypted:")) {
+		return false
+	}
+	var env Envelope
+	if err := yaml.Unmarshal(data, &env); err != nil {
+		return false
+	}
+	return env.Encrypted.Version > 0 && env.Encrypted.Data != ""
+}
+
+// Encrypt seals plaintext in an envelope using the given master key
+func Encrypt(plaintext []byte, key *Key) ([]byte, error) {
+	dataKey := make([]byte, KeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, fmt.Errorf("generating data key: %w", err)
+	}
+
+	sealedData, err := seal(dataKey, plaintext)
+	if err != nil {
+		return nil, err
+	}
+	wrappedKey, err := seal(key.key, dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var env Envelope
+	env.Encrypted.Version = envelopeVersion
+	env.Encrypted.Cipher = envelopeCipher
+	env.Encrypted.KeyID = key.ID
+	env.Encrypted.WrappedKey = base64.StdEncoding.EncodeToString(wrappedKey)
+	env.Encrypted.Data = base64.StdEncoding.EncodeToString(sealedData)
+
+	out, err := yaml.Marshal(&env)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling envelope: %w", err)
+	}
+	return append([]byte("# Wild Cloud encrypted secrets - do not edit\n"), out...), nil
+}
+
+// Decrypt opens an envelope with whichever unlocked key wrapped it
+func Decrypt(data []byte) ([]byte, error) {
+	env, err := parseEnvelope(data)
+	if err != nil {
+		return nil, err
+	}
+
+	key := findKey(env.Encrypted.KeyID)
+	if key == nil {
+		return nil, fmt.Errorf("secrets are locked: key %s is not available", env.Encrypted.KeyID)
+	}
+
+	dataKey, err := unwrapKey(env, key)
+	if err != nil {
+		return nil, err
+	}
+
+	sealedData, err := base64.StdEncoding.DecodeString(env.Encrypted.Data)
+	if err != nil {
+		return nil, fmt.Errorf("decoding data: %w", err)
+	}
+	return open(dataKey, sealedData)
+}
+
+// Rewrap re-seals an envelope's data key with a new master key without
+// touching the encrypted contents
+func Rewrap(data []byte, newKey *Key) ([]byte, error) {
+	env, err := parseEnvelope(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if env.Encrypted.KeyID == newKey.ID {
+		return data, nil
+	}
+
+	key := findKey(env.Encrypted.KeyID)
+	if key == nil {
+		return nil, fmt.Errorf("secrets are locked: key %s is not available", env.Encrypted.KeyID)
+	}
+
+	dataKey, err := unwrapKey(env, key)
+	if err != nil {
+		return nil, err
+	}
+
+	wrappedKey, err := seal(newKey.key, dataKey)
+	if err != nil {
+		return nil, err
+	}
+	env.Encrypted.KeyID = newKey.ID
+	env.Encrypted.WrappedKey = base64.StdEncoding.EncodeToString(wrappedKey)
+
+	out, err := yaml.Marshal(env)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling envelope: %w", err)
+	}
+	return append([]byte("# Wild Cloud encrypted secrets - do not edit\n"), out...), nil
+}
+
+// parseEnvelope decodes and checks an envelope
+func parseEnvelope(data []byte) (*Envelope, error) {
+	var env Envelope
+	if err := yaml.Unmarshal(data, &env); err != nil {
+		return nil, fmt.Errorf("parsing envelope: %w", err)
+	}
+	if env.Encrypted.Version != envelopeVersion {
+		return nil, fmt.Errorf("unsupported envelope version %d", env.Encrypted.Version)
+	}
+	if env.Encrypted.Cipher != envelopeCipher {
+		return nil, fmt.Errorf("unsupported cipher %s", env.Encrypted.Cipher)
+	}
+	return &env, nil
+}
+
+// unwrapKey opens the envelope's data key with the master key
+func unwrapKey(env *Envelope, key *Key) ([]byte, error) {
+	wrappedKey, err := base64.StdEncoding.DecodeString(env.Encrypted.WrappedKey)
+	if err != nil {
+		return nil, fmt.Errorf("decoding wrapped key: %w", err)
+	}
+	dataKey, err := open(key.key, wrappedKey)
+	if err != nil {
+		return nil, fmt.Errorf("unwrapping data key: %w", err)
+	}
+	return dataKey, nil
+}
+
+// seal encrypts with AES-GCM, prefixing the random nonce
+func seal(key, plaintext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("generating nonce: %w", err)
+	}
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// open decrypts nonce-prefixed AES-GCM ciphertext
+func open(key, ciphertext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < gcm.NonceSize() {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+	nonce, sealed := ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return nil, fmt.Errorf("decrypting: %w", err)
+	}
+	return plaintext, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("creating cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
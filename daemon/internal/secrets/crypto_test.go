@@ -0,0 +1,329 @@
+package secrets
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/wild-cloud/wild-central/daemon/internal/storage"
+)
+
+func unlockTestKey(t *testing.T) *Key {
+	t.Helper()
+	key, err := GenerateKey()
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+	Unlock(key)
+	t.Cleanup(Lock)
+	return key
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	key := unlockTestKey(t)
+	plaintext := []byte("cluster:\n  kubeconfig: secret-value\n")
+
+	encrypted, err := Encrypt(plaintext, key)
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+
+	if bytes.Contains(encrypted, []byte("secret-value")) {
+		t.Error("Encrypted data contains plaintext")
+	}
+	if !IsEncrypted(encrypted) {
+		t.Error("IsEncrypted should report true for envelope")
+	}
+	if IsEncrypted(plaintext) {
+		t.Error("IsEncrypted should report false for plaintext")
+	}
+
+	decrypted, err := Decrypt(encrypted)
+	if err != nil {
+		t.Fatalf("Decrypt failed: %v", err)
+	}
+	if !bytes.Equal(decrypted, plaintext) {
+		t.Errorf("Decrypted data mismatch: got %q", decrypted)
+	}
+
+	// Without the key, decryption must fail
+	Lock()
+	if _, err := Decrypt(encrypted); err == nil {
+		t.Error("Expected error decrypting with no key unlocked")
+	}
+}
+
+func TestKeyFromPassphrase(t *testing.T) {
+	salt := []byte("0123456789abcdef")
+
+	k1, err := KeyFromPassphrase("correct horse", salt)
+	if err != nil {
+		t.Fatalf("KeyFromPassphrase failed: %v", err)
+	}
+	k2, _ := KeyFromPassphrase("correct horse", salt)
+	k3, _ := KeyFromPassphrase("battery staple", salt)
+
+	if k1.ID != k2.ID {
+		t.Error("Same passphrase and salt should derive the same key")
+	}
+	if k1.ID == k3.ID {
+		t.Error("Different passphrases should derive different keys")
+	}
+
+	if _, err := KeyFromPassphrase("", salt); err == nil {
+		t.Error("Expected error for empty passphrase")
+	}
+}
+
+func TestManager_EncryptedFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	m := NewManager()
+
+	// Create plaintext, then migrate
+	if err := m.EnsureSecretsFile(tmpDir); err != nil {
+		t.Fatalf("EnsureSecretsFile failed: %v", err)
+	}
+	secretsPath := filepath.Join(tmpDir, "secrets.yaml")
+
+	unlockTestKey(t)
+
+	encrypted, err := m.EncryptInstance(tmpDir)
+	if err != nil {
+		t.Fatalf("EncryptInstance failed: %v", err)
+	}
+	if len(encrypted) != 1 || encrypted[0] != secretsPath {
+		t.Fatalf("Expected secrets.yaml to be encrypted, got %v", encrypted)
+	}
+	if !m.IsFileEncrypted(secretsPath) {
+		t.Fatal("Secrets file should be encrypted")
+	}
+
+	// Set, get, and delete work on the encrypted file
+	if err := m.SetSecret(secretsPath, "apps.db.password", "hunter2"); err != nil {
+		t.Fatalf("SetSecret failed: %v", err)
+	}
+
+	raw, _ := os.ReadFile(secretsPath)
+	if strings.Contains(string(raw), "hunter2") {
+		t.Error("Secret value written in plaintext")
+	}
+
+	value, err := m.GetSecret(secretsPath, "apps.db.password")
+	if err != nil {
+		t.Fatalf("GetSecret failed: %v", err)
+	}
+	if value != "hunter2" {
+		t.Errorf("Expected hunter2, got %q", value)
+	}
+
+	if err := m.DeleteSecret(secretsPath, "apps.db.password"); err != nil {
+		t.Fatalf("DeleteSecret failed: %v", err)
+	}
+	if value, _ := m.GetSecret(secretsPath, "apps.db.password"); value != "null" {
+		t.Errorf("Expected deleted secret to be null, got %q", value)
+	}
+
+	info, _ := os.Stat(secretsPath)
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected 0600 permissions, got %o", info.Mode().Perm())
+	}
+}
+
+func TestManager_RotateKey(t *testing.T) {
+	dataDir := t.TempDir()
+	instancePath := filepath.Join(dataDir, "instances", "test")
+	t.Setenv(EnvPassphrase, "")
+	t.Setenv(EnvKeyFile, "")
+	t.Cleanup(Lock)
+
+	m := NewManager()
+	if err := m.EnsureSecretsFile(instancePath); err != nil {
+		t.Fatalf("EnsureSecretsFile failed: %v", err)
+	}
+
+	oldKey, err := InitKey(dataDir)
+	if err != nil {
+		t.Fatalf("InitKey failed: %v", err)
+	}
+	if _, err := m.EncryptInstance(instancePath); err != nil {
+		t.Fatalf("EncryptInstance failed: %v", err)
+	}
+
+	secretsPath := filepath.Join(instancePath, "secrets.yaml")
+	if err := m.SetSecret(secretsPath, "token", "abc123"); err != nil {
+		t.Fatalf("SetSecret failed: %v", err)
+	}
+
+	newKey, err := m.RotateKey(dataDir, []string{instancePath}, "")
+	if err != nil {
+		t.Fatalf("RotateKey failed: %v", err)
+	}
+	if newKey.ID == oldKey.ID {
+		t.Fatal("Rotation should produce a new key")
+	}
+
+	// Only the new key is loaded from disk and it decrypts the file
+	keys, err := LoadKeys(dataDir)
+	if err != nil {
+		t.Fatalf("LoadKeys failed: %v", err)
+	}
+	if len(keys) != 1 || keys[0].ID != newKey.ID {
+		t.Fatalf("Expected only new key on disk, got %d key(s)", len(keys))
+	}
+
+	Unlock(keys...)
+	value, err := m.GetSecret(secretsPath, "token")
+	if err != nil {
+		t.Fatalf("GetSecret after rotation failed: %v", err)
+	}
+	if value != "abc123" {
+		t.Errorf("Expected abc123 after rotation, got %q", value)
+	}
+}
+
+func TestManager_RotateKeyInterrupted(t *testing.T) {
+	dataDir := t.TempDir()
+	t.Setenv(EnvKeyFile, "")
+	t.Setenv(EnvPassphrase, "old passphrase")
+	t.Cleanup(Lock)
+
+	keys, err := LoadKeys(dataDir)
+	if err != nil {
+		t.Fatalf("LoadKeys failed: %v", err)
+	}
+	Unlock(keys...)
+
+	m := NewManager()
+	var instancePaths []string
+	for _, name := range []string{"first", "second"} {
+		instancePath := filepath.Join(dataDir, "instances", name)
+		if err := m.EnsureSecretsFile(instancePath); err != nil {
+			t.Fatalf("EnsureSecretsFile failed: %v", err)
+		}
+		if err := m.SetSecret(filepath.Join(instancePath, "secrets.yaml"), "token", name); err != nil {
+			t.Fatalf("SetSecret failed: %v", err)
+		}
+		instancePaths = append(instancePaths, instancePath)
+	}
+
+	// Stop the rotation after the first file, as a crash would
+	rewrapped := 0
+	rewrapFile = func(m *Manager, path string, key *Key) error {
+		if rewrapped++; rewrapped > 1 {
+			panic("crash")
+		}
+		return m.RewrapFile(path, key)
+	}
+	t.Cleanup(func() { rewrapFile = (*Manager).RewrapFile })
+	func() {
+		defer func() { recover() }()
+		m.RotateKey(dataDir, instancePaths, "new passphrase")
+	}()
+	rewrapFile = (*Manager).RewrapFile
+
+	checkSecrets := func(passphrase string) {
+		t.Helper()
+		t.Setenv(EnvPassphrase, passphrase)
+		keys, err := LoadKeys(dataDir)
+		if err != nil {
+			t.Fatalf("LoadKeys with %q failed: %v", passphrase, err)
+		}
+		Unlock(keys...)
+		for _, instancePath := range instancePaths {
+			value, err := m.GetSecret(filepath.Join(instancePath, "secrets.yaml"), "token")
+			if err != nil {
+				t.Fatalf("%s unreadable with %q: %v", instancePath, passphrase, err)
+			}
+			if value != filepath.Base(instancePath) {
+				t.Errorf("%s: got token %q", instancePath, value)
+			}
+		}
+	}
+
+	// Either passphrase recovers both keys
+	checkSecrets("old passphrase")
+	checkSecrets("new passphrase")
+
+	// Rotating again finishes with a single key
+	if _, err := m.RotateKey(dataDir, instancePaths, "newer passphrase"); err != nil {
+		t.Fatalf("RotateKey failed: %v", err)
+	}
+	checkSecrets("newer passphrase")
+	if keys, _ := LoadKeys(dataDir); len(keys) != 1 {
+		t.Errorf("got %d keys after the rotation finished, want 1", len(keys))
+	}
+	if storage.FileExists(getRotationJournalPath(dataDir)) {
+		t.Error("rotation journal left behind")
+	}
+}
+
+func TestWriteTempFile(t *testing.T) {
+	dataDir := t.TempDir()
+	path, remove, err := WriteTempFile(dataDir, []byte("token: abc123\n"))
+	if err != nil {
+		t.Fatalf("WriteTempFile failed: %v", err)
+	}
+	if filepath.Dir(path) != GetRuntimeDir(dataDir) {
+		t.Errorf("secrets file %s outside of the runtime directory", path)
+	}
+	if info, _ := os.Stat(GetRuntimeDir(dataDir)); info.Mode().Perm() != 0700 {
+		t.Errorf("runtime directory mode %04o, want 0700", info.Mode().Perm())
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := info.Mode().Perm(); mode != 0600 {
+		t.Errorf("secrets file mode %04o, want 0600", mode)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "token: abc123\n" {
+		t.Errorf("secrets file holds %q", data)
+	}
+
+	remove()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("secrets file not removed")
+	}
+
+	// A file left by a crash is swept at start
+	path, _, _ = WriteTempFile(dataDir, []byte("token: abc123\n"))
+	if err := SweepRuntimeDir(dataDir); err != nil {
+		t.Fatalf("SweepRuntimeDir failed: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("leftover secrets file not swept")
+	}
+}
+
+func TestRestrictCredentialFiles(t *testing.T) {
+	instancePath := t.TempDir()
+	generated := filepath.Join(instancePath, "talos", "generated")
+	if err := os.MkdirAll(generated, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{
+		filepath.Join(generated, "controlplane.yaml"),
+		filepath.Join(generated, "talosconfig"),
+		filepath.Join(instancePath, "kubeconfig"),
+	} {
+		if err := os.WriteFile(path, []byte("key: value\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := RestrictCredentialFiles(instancePath); err != nil {
+		t.Fatalf("RestrictCredentialFiles failed: %v", err)
+	}
+	files := CredentialFiles(instancePath)
+	if len(files) != 3 {
+		t.Fatalf("got credential files %v, want 3", files)
+	}
+	for _, path := range files {
+		if info, _ := os.Stat(path); info.Mode().Perm() != 0600 {
+			t.Errorf("%s: mode %04o, want 0600", path, info.Mode().Perm())
+		}
+	}
+}
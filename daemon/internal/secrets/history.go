@@ -0,0 +1,148 @@
+package secrets
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/wild-cloud/wild-central/daemon/internal/storage"
+)
+
+// maxHistoryEntries is how many previous values are kept per secret
+const maxHistoryEntries = 5
+
+// RotationHook declares a script that runs when a secret is rotated, e.g. to
+// change a database user's password before dependents restart with the new value.
+// Script paths are relative to the declaring app or service directory.
+type RotationHook struct {
+	Secret      string `yaml:"secret" json:"secret"`
+	Script      string `yaml:"script" json:"script"`
+	Description string `yaml:"description,omitempty" json:"description,omitempty"`
+}
+
+// HistoryEntry records a previous secret value so a rotation can be rolled back
+type HistoryEntry struct {
+	Key         string    `yaml:"key" json:"key"`
+	Value       string    `yaml:"value" json:"-"`
+	ReplacedAt  time.Time `yaml:"replacedAt" json:"replaced_at"`
+	OperationID string    `yaml:"operationId,omitempty" json:"operation_id,omitempty"`
+}
+
+// GetHistoryPath returns the location of an instance's secret history.
+// It is protected like secrets.yaml: mode 0600, encrypted when a key is unlocked.
+func GetHistoryPath(instancePath string) string {
+	return filepath.Join(instancePath, "secrets-history.yaml")
+}
+
+// RecordHistory stores the previous value of a secret
+func (m *Manager) RecordHistory(instancePath, key, oldValue, opID string) error {
+	historyPath := GetHistoryPath(instancePath)
+	return storage.WithLock(historyPath+".lock", func() error {
+		entries, err := readHistory(historyPath)
+		if err != nil {
+			return err
+		}
+
+		entries = append(entries, HistoryEntry{
+			Key:         key,
+			Value:       oldValue,
+			ReplacedAt:  time.Now(),
+			OperationID: opID,
+		})
+
+		// Trim oldest entries for this key
+		count := 0
+		kept := make([]HistoryEntry, 0, len(entries))
+		for i := len(entries) - 1; i >= 0; i-- {
+			if entries[i].Key == key {
+				count++
+				if count > maxHistoryEntries {
+					continue
+				}
+			}
+			kept = append([]HistoryEntry{entries[i]}, kept...)
+		}
+
+		return writeHistory(historyPath, kept)
+	})
+}
+
+// History returns the recorded previous values of a secret, newest first
+func (m *Manager) History(instancePath, key string) ([]HistoryEntry, error) {
+	entries, err := readHistory(GetHistoryPath(instancePath))
+	if err != nil {
+		return nil, err
+	}
+
+	var result []HistoryEntry
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].Key == key {
+			result = append(result, entries[i])
+		}
+	}
+	return result, nil
+}
+
+// PopHistory removes and returns the most recent previous value of a secret
+func (m *Manager) PopHistory(instancePath, key string) (*HistoryEntry, error) {
+	historyPath := GetHistoryPath(instancePath)
+
+	var popped *HistoryEntry
+	err := storage.WithLock(historyPath+".lock", func() error {
+		entries, err := readHistory(historyPath)
+		if err != nil {
+			return err
+		}
+
+		for i := len(entries) - 1; i >= 0; i-- {
+			if entries[i].Key == key {
+				entry := entries[i]
+				popped = &entry
+				entries = append(entries[:i], entries[i+1:]...)
+				return writeHistory(historyPath, entries)
+			}
+		}
+		return fmt.Errorf("no previous value recorded for %s", key)
+	})
+
+	return popped, err
+}
+
+// readHistory loads history entries, decrypting in memory if needed
+func readHistory(historyPath string) ([]HistoryEntry, error) {
+	data, err := os.ReadFile(historyPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("reading secret history: %w", err)
+	}
+
+	if IsEncrypted(data) {
+		data, err = Decrypt(data)
+		if err != nil {
+			return nil, fmt.Errorf("reading secret history: %w", err)
+		}
+	}
+
+	var history struct {
+		Entries []HistoryEntry `yaml:"entries"`
+	}
+	if err := yaml.Unmarshal(data, &history); err != nil {
+		return nil, fmt.Errorf("parsing secret history: %w", err)
+	}
+
+	return history.Entries, nil
+}
+
+// writeHistory saves history entries with the same protection as secrets.yaml
+func writeHistory(historyPath string, entries []HistoryEntry) error {
+	data, err := yaml.Marshal(map[string]interface{}{"entries": entries})
+	if err != nil {
+		return fmt.Errorf("marshaling secret history: %w", err)
+	}
+	return writeSecrets(historyPath, data)
+}
@@ -0,0 +1,56 @@
+package secrets
+
+import (
+	"fmt"
+	"os"
+	"testing"
+)
+
+func TestHistory(t *testing.T) {
+	instancePath := t.TempDir()
+	m := NewManager()
+
+	if _, err := m.PopHistory(instancePath, "apps.db.password"); err == nil {
+		t.Error("Expected error popping empty history")
+	}
+
+	for i := 0; i < maxHistoryEntries+2; i++ {
+		if err := m.RecordHistory(instancePath, "apps.db.password", fmt.Sprintf("value-%d", i), "op"); err != nil {
+			t.Fatalf("RecordHistory failed: %v", err)
+		}
+	}
+	if err := m.RecordHistory(instancePath, "apps.other.token", "other", ""); err != nil {
+		t.Fatalf("RecordHistory failed: %v", err)
+	}
+
+	history, err := m.History(instancePath, "apps.db.password")
+	if err != nil {
+		t.Fatalf("History failed: %v", err)
+	}
+	if len(history) != maxHistoryEntries {
+		t.Fatalf("Expected %d entries, got %d", maxHistoryEntries, len(history))
+	}
+	if history[0].Value != fmt.Sprintf("value-%d", maxHistoryEntries+1) {
+		t.Errorf("Expected newest entry first, got %q", history[0].Value)
+	}
+
+	entry, err := m.PopHistory(instancePath, "apps.db.password")
+	if err != nil {
+		t.Fatalf("PopHistory failed: %v", err)
+	}
+	if entry.Value != fmt.Sprintf("value-%d", maxHistoryEntries+1) {
+		t.Errorf("Expected most recent value, got %q", entry.Value)
+	}
+
+	if other, _ := m.History(instancePath, "apps.other.token"); len(other) != 1 {
+		t.Errorf("Expected other key's history untouched, got %d entries", len(other))
+	}
+
+	info, err := os.Stat(GetHistoryPath(instancePath))
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected mode 0600, got %o", info.Mode().Perm())
+	}
+}
@@ -0,0 +1,247 @@
+package secrets
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"github.com/wild-cloud/wild-central/daemon/internal/tools"
+)
+
+// SecretMapping declares a Kubernetes Secret whose keys are filled from
+// Wild Cloud secret paths. It is declared in service and app manifests:
+//
+//	kubernetesSecrets:
+//	  - name: cloudflare-api-token
+//	    namespace: cert-manager
+//	    data:
+//	      api-token: cloudflare.token
+type SecretMapping struct {
+	Name      string            `yaml:"name" json:"name"`
+	Namespace string            `yaml:"namespace,omitempty" json:"namespace,omitempty"`
+	Type      string            `yaml:"type,omitempty" json:"type,omitempty"` // Defaults to Opaque
+	Data      map[string]string `yaml:"data" json:"data"`                     // Kubernetes key -> secret path
+}
+
+// Sync statuses of a mapped Kubernetes Secret
+const (
+	SyncInSync  = "in-sync"
+	SyncMissing = "missing" // Secret does not exist in the cluster
+	SyncDrifted = "drifted" // Secret exists but keys differ
+	SyncCreated = "created"
+	SyncUpdated = "updated"
+	SyncError   = "error"
+)
+
+// SyncResult reports the state of one mapped Kubernetes Secret. Drift lists
+// keys only, never values.
+type SyncResult struct {
+	Name      string   `json:"name"`
+	Namespace string   `json:"namespace"`
+	Source    string   `json:"source,omitempty"` // Declaring service or app, e.g. service/cert-manager
+	Status    string   `json:"status"`
+	Drift     []string `json:"drift,omitempty"`
+	Error     string   `json:"error,omitempty"`
+}
+
+// SyncOptions controls a sync
+type SyncOptions struct {
+	DryRun           bool // Only detect drift
+	CreateNamespaces bool // Create target namespaces that do not exist yet
+}
+
+// managedByLabel marks Secrets written by the sync
+const managedByLabel = "wild-cloud.io/managed-by"
+
+// lastAppliedAnnotation is where client-side kubectl apply keeps a copy of
+// the applied manifest, values included
+const lastAppliedAnnotation = "kubectl.kubernetes.io/last-applied-configuration"
+
+// kubectl runs kubectl against a kubeconfig; replaced in tests
+var kubectl = func(kubeconfigPath string, stdin []byte, args ...string) ([]byte, error) {
+	cmd := exec.Command("kubectl", args...)
+	tools.WithKubeconfig(cmd, kubeconfigPath)
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return output, fmt.Errorf("kubectl %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return output, nil
+}
+
+// SyncKubernetesSecrets makes the mapped Kubernetes Secrets match the values
+// in store. Each mapping gets its own result; a failure on one does not stop
+// the others. The returned error is set if any mapping failed.
+func SyncKubernetesSecrets(store Store, kubeconfigPath string, mappings []SecretMapping, opts SyncOptions) ([]SyncResult, error) {
+	var results []SyncResult
+	failed := 0
+
+	for _, mapping := range mappings {
+		result := syncMapping(store, kubeconfigPath, mapping, opts)
+		if result.Status == SyncError {
+			failed++
+		}
+		results = append(results, result)
+	}
+
+	if failed > 0 {
+		return results, fmt.Errorf("%d of %d Kubernetes secrets failed to sync", failed, len(mappings))
+	}
+	return results, nil
+}
+
+func syncMapping(store Store, kubeconfigPath string, mapping SecretMapping, opts SyncOptions) SyncResult {
+	result := SyncResult{Name: mapping.Name, Namespace: mapping.Namespace}
+	fail := func(err error) SyncResult {
+		result.Status = SyncError
+		result.Error = err.Error()
+		return result
+	}
+
+	if mapping.Name == "" || mapping.Namespace == "" {
+		return fail(fmt.Errorf("mapping needs a name and namespace"))
+	}
+
+	desired := make(map[string]string, len(mapping.Data))
+	for k8sKey, path := range mapping.Data {
+		value, err := store.Get(path)
+		if err != nil {
+			return fail(err)
+		}
+		if value == "" || value == "null" {
+			return fail(fmt.Errorf("secret %s is not set", path))
+		}
+		desired[k8sKey] = value
+	}
+
+	current, exists, lastApplied, err := getKubernetesSecret(kubeconfigPath, mapping.Namespace, mapping.Name)
+	if err != nil {
+		return fail(err)
+	}
+
+	if !exists {
+		result.Status = SyncMissing
+	} else {
+		for k8sKey, value := range desired {
+			live, ok := current[k8sKey]
+			switch {
+			case !ok:
+				result.Drift = append(result.Drift, k8sKey+" (missing)")
+			case live != value:
+				result.Drift = append(result.Drift, k8sKey+" (changed)")
+			}
+		}
+		sort.Strings(result.Drift)
+		result.Status = SyncInSync
+		if len(result.Drift) > 0 {
+			result.Status = SyncDrifted
+		}
+	}
+
+	if opts.DryRun || result.Status == SyncInSync && !lastApplied {
+		return result
+	}
+
+	if opts.CreateNamespaces && !exists {
+		if err := applyJSON(kubeconfigPath, map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "Namespace",
+			"metadata":   map[string]string{"name": mapping.Namespace},
+		}); err != nil {
+			return fail(fmt.Errorf("creating namespace %s: %w", mapping.Namespace, err))
+		}
+	}
+
+	secretType := mapping.Type
+	if secretType == "" {
+		secretType = "Opaque"
+	}
+	encoded := make(map[string]string, len(desired))
+	for k8sKey, value := range desired {
+		encoded[k8sKey] = base64.StdEncoding.EncodeToString([]byte(value))
+	}
+	if err := applyJSON(kubeconfigPath, map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Secret",
+		"metadata": map[string]interface{}{
+			"name":      mapping.Name,
+			"namespace": mapping.Namespace,
+			"labels":    map[string]string{managedByLabel: "wild-central"},
+		},
+		"type": secretType,
+		"data": encoded,
+	}); err != nil {
+		return fail(err)
+	}
+
+	// Secrets written by an earlier client-side apply carry their values in
+	// an annotation
+	if lastApplied {
+		if _, err := kubectl(kubeconfigPath, nil, "annotate", "secret", mapping.Name, "-n", mapping.Namespace, lastAppliedAnnotation+"-"); err != nil {
+			return fail(fmt.Errorf("removing %s: %w", lastAppliedAnnotation, err))
+		}
+	}
+
+	switch result.Status {
+	case SyncInSync:
+		return result
+	case SyncMissing:
+		result.Status = SyncCreated
+	default:
+		result.Status = SyncUpdated
+	}
+	return result
+}
+
+// getKubernetesSecret returns the decoded data of a Secret, or exists=false
+// if it is absent. lastApplied reports whether it carries lastAppliedAnnotation.
+func getKubernetesSecret(kubeconfigPath, namespace, name string) (data map[string]string, exists, lastApplied bool, err error) {
+	output, err := kubectl(kubeconfigPath, nil, "get", "secret", name, "-n", namespace, "-o", "json", "--ignore-not-found")
+	if err != nil {
+		return nil, false, false, err
+	}
+	if len(bytes.TrimSpace(output)) == 0 {
+		return nil, false, false, nil
+	}
+
+	var secret struct {
+		Metadata struct {
+			Annotations map[string]string `json:"annotations"`
+		} `json:"metadata"`
+		Data map[string]string `json:"data"`
+	}
+	if err := json.Unmarshal(output, &secret); err != nil {
+		return nil, false, false, fmt.Errorf("parsing secret %s/%s: %w", namespace, name, err)
+	}
+
+	data = make(map[string]string, len(secret.Data))
+	for k, v := range secret.Data {
+		decoded, err := base64.StdEncoding.DecodeString(v)
+		if err != nil {
+			return nil, false, false, fmt.Errorf("decoding secret %s/%s key %s: %w", namespace, name, k, err)
+		}
+		data[k] = string(decoded)
+	}
+	_, lastApplied = secret.Metadata.Annotations[lastAppliedAnnotation]
+	return data, true, lastApplied, nil
+}
+
+// applyJSON applies a manifest passed on stdin so secret values never touch
+// disk. The apply is server-side: client-side apply would copy the manifest,
+// values included, into lastAppliedAnnotation.
+func applyJSON(kubeconfigPath string, manifest map[string]interface{}) error {
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return fmt.Errorf("marshaling manifest: %w", err)
+	}
+	_, err = kubectl(kubeconfigPath, data, "apply", "--server-side", "--field-manager=wild-central", "--force-conflicts", "-f", "-")
+	return err
+}
@@ -0,0 +1,152 @@
+package secrets
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeCluster stands in for kubectl get/apply of Secrets and Namespaces
+type fakeCluster struct {
+	secrets     map[string]map[string]string // namespace/name -> data
+	lastApplied map[string]bool              // namespace/name -> annotated by client-side apply
+	namespaces  map[string]bool
+	applies     int
+}
+
+func (f *fakeCluster) run(kubeconfigPath string, stdin []byte, args ...string) ([]byte, error) {
+	switch args[0] {
+	case "get":
+		data, ok := f.secrets[args[4]+"/"+args[2]]
+		if !ok {
+			return nil, nil
+		}
+		encoded := make(map[string]string)
+		for k, v := range data {
+			encoded[k] = base64.StdEncoding.EncodeToString([]byte(v))
+		}
+		secret := map[string]interface{}{"data": encoded}
+		if f.lastApplied[args[4]+"/"+args[2]] {
+			secret["metadata"] = map[string]interface{}{"annotations": map[string]string{lastAppliedAnnotation: "{}"}}
+		}
+		return json.Marshal(secret)
+	case "annotate":
+		if args[5] != lastAppliedAnnotation+"-" {
+			return nil, fmt.Errorf("unexpected annotation %s", args[5])
+		}
+		delete(f.lastApplied, args[4]+"/"+args[2])
+		return nil, nil
+	case "apply":
+		if args[1] != "--server-side" {
+			return nil, fmt.Errorf("client-side apply")
+		}
+		f.applies++
+		var manifest struct {
+			Kind     string `json:"kind"`
+			Metadata struct {
+				Name      string `json:"name"`
+				Namespace string `json:"namespace"`
+			} `json:"metadata"`
+			StringData map[string]string `json:"stringData"`
+			Data       map[string]string `json:"data"`
+		}
+		if err := json.Unmarshal(stdin, &manifest); err != nil {
+			return nil, err
+		}
+		if manifest.Kind == "Namespace" {
+			f.namespaces[manifest.Metadata.Name] = true
+			return nil, nil
+		}
+		if !f.namespaces[manifest.Metadata.Namespace] {
+			return nil, fmt.Errorf("namespaces %q not found", manifest.Metadata.Namespace)
+		}
+		if manifest.StringData != nil {
+			return nil, fmt.Errorf("values in stringData")
+		}
+		data := make(map[string]string)
+		for k, v := range manifest.Data {
+			decoded, err := base64.StdEncoding.DecodeString(v)
+			if err != nil {
+				return nil, err
+			}
+			data[k] = string(decoded)
+		}
+		f.secrets[manifest.Metadata.Namespace+"/"+manifest.Metadata.Name] = data
+		return nil, nil
+	}
+	return nil, fmt.Errorf("unexpected kubectl %s", strings.Join(args, " "))
+}
+
+func TestSyncKubernetesSecrets(t *testing.T) {
+	cluster := &fakeCluster{secrets: map[string]map[string]string{}, lastApplied: map[string]bool{}, namespaces: map[string]bool{"dns": true}}
+	original := kubectl
+	kubectl = cluster.run
+	t.Cleanup(func() { kubectl = original })
+
+	instancePath := t.TempDir()
+	unlockTestKey(t)
+	m := NewManager()
+	if err := m.EnsureSecretsFile(instancePath); err != nil {
+		t.Fatalf("EnsureSecretsFile failed: %v", err)
+	}
+	m.SetSecret(filepath.Join(instancePath, "secrets.yaml"), "cloudflare.token", "abc")
+	store, _ := m.Open(instancePath)
+
+	mappings := []SecretMapping{
+		{Name: "cloudflare-api-token", Namespace: "dns", Data: map[string]string{"api-token": "cloudflare.token"}},
+		{Name: "cloudflare-api-token", Namespace: "certs", Data: map[string]string{"api-token": "cloudflare.token"}},
+	}
+
+	// Drift detection does not write
+	results, err := SyncKubernetesSecrets(store, "", mappings, SyncOptions{DryRun: true})
+	if err != nil {
+		t.Fatalf("Dry run failed: %v", err)
+	}
+	if results[0].Status != SyncMissing || cluster.applies != 0 {
+		t.Fatalf("Expected missing secret and no writes, got %+v", results)
+	}
+
+	// Without namespace creation the second mapping fails, the first still syncs
+	results, err = SyncKubernetesSecrets(store, "", mappings, SyncOptions{})
+	if err == nil || results[0].Status != SyncCreated || results[1].Status != SyncError {
+		t.Fatalf("Unexpected results %+v, err %v", results, err)
+	}
+
+	results, err = SyncKubernetesSecrets(store, "", mappings, SyncOptions{CreateNamespaces: true})
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if results[0].Status != SyncInSync || results[1].Status != SyncCreated {
+		t.Fatalf("Unexpected results %+v", results)
+	}
+
+	// A changed value in the cluster is reported by key, without values
+	cluster.secrets["dns/cloudflare-api-token"]["api-token"] = "tampered"
+	results, _ = SyncKubernetesSecrets(store, "", mappings[:1], SyncOptions{DryRun: true})
+	if results[0].Status != SyncDrifted || len(results[0].Drift) != 1 || results[0].Drift[0] != "api-token (changed)" {
+		t.Fatalf("Expected drift on api-token, got %+v", results[0])
+	}
+
+	results, _ = SyncKubernetesSecrets(store, "", mappings[:1], SyncOptions{})
+	if results[0].Status != SyncUpdated || cluster.secrets["dns/cloudflare-api-token"]["api-token"] != "abc" {
+		t.Fatalf("Expected secret updated, got %+v", results[0])
+	}
+
+	// Values left in the annotation of an earlier client-side apply are removed
+	cluster.lastApplied["dns/cloudflare-api-token"] = true
+	applies := cluster.applies
+	results, _ = SyncKubernetesSecrets(store, "", mappings[:1], SyncOptions{})
+	if results[0].Status != SyncInSync || cluster.lastApplied["dns/cloudflare-api-token"] || cluster.applies != applies+1 {
+		t.Fatalf("Expected annotation removed, got %+v", results[0])
+	}
+
+	// Unset source secrets are reported per mapping
+	unset := []SecretMapping{{Name: "x", Namespace: "dns", Data: map[string]string{"k": "missing.key"}}}
+	results, err = SyncKubernetesSecrets(store, "", unset, SyncOptions{})
+	if err == nil || !strings.Contains(results[0].Error, "missing.key is not set") {
+		t.Fatalf("Expected unset secret error, got %+v", results)
+	}
+}
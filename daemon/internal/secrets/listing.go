@@ -0,0 +1,180 @@
+package secrets
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/wild-cloud/wild-central/daemon/internal/storage"
+)
+
+// EnvReveal allows raw secret values to be returned over the API when set
+// to "allow". Revealing is refused by default.
+const EnvReveal = "WILD_CENTRAL_SECRETS_REVEAL"
+
+// RevealAllowed reports whether the daemon was started with revealing raw
+// secret values allowed
+func RevealAllowed() (bool, error) {
+	switch value := strings.TrimSpace(os.Getenv(EnvReveal)); value {
+	case "", "deny":
+		return false, nil
+	case "allow":
+		return true, nil
+	default:
+		return false, fmt.Errorf("invalid %s %q: must be allow or deny", EnvReveal, value)
+	}
+}
+
+// SecretInfo describes a secret without revealing its value
+type SecretInfo struct {
+	Set      bool       `json:"set"`
+	Length   int        `json:"length"`
+	Modified *time.Time `json:"modified,omitempty"`
+}
+
+// GetMetadataPath returns the file recording when each secret was last changed.
+// It holds key names and times only, never values.
+func GetMetadataPath(instancePath string) string {
+	return filepath.Join(instancePath, "secrets-meta.yaml")
+}
+
+// trackedStore records modification times for writes to the wrapped store
+type trackedStore struct {
+	Store
+	metaPath string
+}
+
+// Set stores a value and records when it changed
+func (s *trackedStore) Set(key, value string) error {
+	if err := s.Store.Set(key, value); err != nil {
+		return err
+	}
+	return s.touch(key, false)
+}
+
+// Delete removes a value and forgets its modification times
+func (s *trackedStore) Delete(key string) error {
+	if err := s.Store.Delete(key); err != nil {
+		return err
+	}
+	return s.touch(key, true)
+}
+
+// touch updates the modification time of key, dropping entries below it
+func (s *trackedStore) touch(key string, deleted bool) error {
+	return storage.WithLock(s.metaPath+".lock", func() error {
+		modified, err := readMetadata(s.metaPath)
+		if err != nil {
+			return err
+		}
+
+		for k := range modified {
+			if k == key || strings.HasPrefix(k, key+".") {
+				delete(modified, k)
+			}
+		}
+		if !deleted {
+			modified[key] = time.Now().UTC()
+		}
+
+		data, err := yaml.Marshal(map[string]interface{}{"modified": modified})
+		if err != nil {
+			return fmt.Errorf("marshaling secrets metadata: %w", err)
+		}
+		return storage.WriteFile(s.metaPath, data, 0600)
+	})
+}
+
+// readMetadata loads recorded modification times. A missing file is empty.
+func readMetadata(metaPath string) (map[string]time.Time, error) {
+	var meta struct {
+		Modified map[string]time.Time `yaml:"modified"`
+	}
+
+	data, err := os.ReadFile(metaPath)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("reading secrets metadata: %w", err)
+	}
+	if err := yaml.Unmarshal(data, &meta); err != nil {
+		return nil, fmt.Errorf("parsing secrets metadata: %w", err)
+	}
+
+	if meta.Modified == nil {
+		meta.Modified = make(map[string]time.Time)
+	}
+	return meta.Modified, nil
+}
+
+// List returns an instance's secrets tree with every leaf replaced by a SecretInfo
+func (m *Manager) List(instancePath string) (map[string]interface{}, error) {
+	store, err := m.Open(instancePath)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := store.Read()
+	if err != nil {
+		if os.IsNotExist(err) {
+			return map[string]interface{}{}, nil
+		}
+		return nil, fmt.Errorf("reading secrets: %w", err)
+	}
+
+	var tree map[string]interface{}
+	if err := yaml.Unmarshal(data, &tree); err != nil {
+		return nil, fmt.Errorf("parsing secrets: %w", err)
+	}
+
+	modified, err := readMetadata(GetMetadataPath(instancePath))
+	if err != nil {
+		return nil, err
+	}
+
+	return Redact(tree, modified), nil
+}
+
+// Redact replaces every leaf of a secrets tree with a SecretInfo, keeping the
+// tree's shape. A leaf's modification time is its own, or that of the nearest
+// ancestor written as a whole.
+func Redact(tree map[string]interface{}, modified map[string]time.Time) map[string]interface{} {
+	return redactMap(tree, "", modified)
+}
+
+func redactMap(tree map[string]interface{}, prefix string, modified map[string]time.Time) map[string]interface{} {
+	result := make(map[string]interface{}, len(tree))
+	for key, value := range tree {
+		path := key
+		if prefix != "" {
+			path = prefix + "." + key
+		}
+
+		if nested, ok := value.(map[string]interface{}); ok {
+			result[key] = redactMap(nested, path, modified)
+			continue
+		}
+
+		info := SecretInfo{}
+		if value != nil {
+			str := fmt.Sprintf("%v", value)
+			info.Set = str != ""
+			info.Length = len(str)
+		}
+		for p := path; p != ""; {
+			if t, ok := modified[p]; ok {
+				info.Modified = &t
+				break
+			}
+			i := strings.LastIndex(p, ".")
+			if i < 0 {
+				break
+			}
+			p = p[:i]
+		}
+		result[key] = info
+	}
+	return result
+}
@@ -0,0 +1,102 @@
+package secrets
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRedact(t *testing.T) {
+	modified := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	tree := map[string]interface{}{
+		"cloudflare": map[string]interface{}{"token": "abc123"},
+		"apps": map[string]interface{}{
+			"immich": map[string]interface{}{
+				"dbPassword": "secret",
+				"apiKey":     nil,
+			},
+		},
+	}
+
+	redacted := Redact(tree, map[string]time.Time{"apps.immich": modified})
+
+	immich := redacted["apps"].(map[string]interface{})["immich"].(map[string]interface{})
+	password := immich["dbPassword"].(SecretInfo)
+	if !password.Set || password.Length != 6 {
+		t.Errorf("Unexpected info for dbPassword: %+v", password)
+	}
+	if password.Modified == nil || !password.Modified.Equal(modified) {
+		t.Errorf("Expected modified time inherited from apps.immich, got %v", password.Modified)
+	}
+	if apiKey := immich["apiKey"].(SecretInfo); apiKey.Set {
+		t.Error("Expected unset apiKey")
+	}
+
+	token := redacted["cloudflare"].(map[string]interface{})["token"].(SecretInfo)
+	if !token.Set || token.Modified != nil {
+		t.Errorf("Unexpected info for token: %+v", token)
+	}
+}
+
+func TestManager_ListTracksModified(t *testing.T) {
+	instancePath := t.TempDir()
+	os.WriteFile(filepath.Join(instancePath, "config.yaml"), []byte("secretsBackend:\n  type: encrypted-file\n"), 0644)
+	unlockTestKey(t)
+
+	m := NewManager()
+	if err := m.EnsureSecretsFile(instancePath); err != nil {
+		t.Fatalf("EnsureSecretsFile failed: %v", err)
+	}
+	secretsPath := filepath.Join(instancePath, "secrets.yaml")
+	if err := m.SetSecret(secretsPath, "apps.db.password", "hunter2"); err != nil {
+		t.Fatalf("SetSecret failed: %v", err)
+	}
+	if err := m.SetSecret(secretsPath, "apps.db.user", "admin"); err != nil {
+		t.Fatalf("SetSecret failed: %v", err)
+	}
+
+	tree, err := m.List(instancePath)
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	db := tree["apps"].(map[string]interface{})["db"].(map[string]interface{})
+	password := db["password"].(SecretInfo)
+	if !password.Set || password.Length != 7 || password.Modified == nil {
+		t.Errorf("Unexpected info for password: %+v", password)
+	}
+
+	meta, _ := os.ReadFile(GetMetadataPath(instancePath))
+	if strings.Contains(string(meta), "hunter2") {
+		t.Error("Metadata must not contain secret values")
+	}
+
+	if err := m.DeleteSecret(secretsPath, "apps.db"); err != nil {
+		t.Fatalf("DeleteSecret failed: %v", err)
+	}
+	if modified, _ := readMetadata(GetMetadataPath(instancePath)); len(modified) != 0 {
+		t.Errorf("Expected metadata below apps.db to be dropped, got %v", modified)
+	}
+}
+
+func TestRevealAllowed(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    bool
+		wantErr bool
+	}{
+		{"", false, false},
+		{"deny", false, false},
+		{"allow", true, false},
+		{"yes", false, true},
+	}
+
+	for _, tt := range tests {
+		t.Setenv(EnvReveal, tt.value)
+		got, err := RevealAllowed()
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("RevealAllowed() with %q = %v, %v", tt.value, got, err)
+		}
+	}
+}
@@ -0,0 +1,292 @@
+package secrets
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/wild-cloud/wild-central/daemon/internal/storage"
+)
+
+// SecretFiles returns the files under an instance that hold secret material
+// and are encrypted when encryption is enabled
+func SecretFiles(instancePath string) []string {
+	candidates := []string{
+		filepath.Join(instancePath, "secrets.yaml"),
+		filepath.Join(instancePath, "talos", "generated", "secrets.yaml"),
+		GetHistoryPath(instancePath),
+	}
+
+	var files []string
+	for _, path := range candidates {
+		if storage.FileExists(path) {
+			files = append(files, path)
+		}
+	}
+	return files
+}
+
+// CredentialFiles returns the generated Talos machine configs, talosconfigs
+// and kubeconfig of an instance. They hold cluster CA keys and admin
+// credentials but are read directly by talosctl and kubectl, so they are not
+// encrypted; they are kept at mode 0600 instead.
+func CredentialFiles(instancePath string) []string {
+	var files []string
+	for _, dir := range []string{
+		filepath.Join(instancePath, "talos", "generated"),
+		filepath.Join(instancePath, "setup", "cluster-nodes", "generated"),
+	} {
+		for _, name := range []string{"controlplane.yaml", "worker.yaml", "talosconfig"} {
+			if path := filepath.Join(dir, name); storage.FileExists(path) {
+				files = append(files, path)
+			}
+		}
+	}
+	if path := filepath.Join(instancePath, "kubeconfig"); storage.FileExists(path) {
+		files = append(files, path)
+	}
+	return files
+}
+
+// RestrictCredentialFiles sets the credential files of an instance to mode 0600
+func RestrictCredentialFiles(instancePath string) error {
+	for _, path := range CredentialFiles(instancePath) {
+		if err := storage.EnsureFilePermissions(path, 0600); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetRuntimeDir returns the directory holding decrypted secrets handed to
+// child processes. Only the daemon user can open it.
+func GetRuntimeDir(dataDir string) string {
+	return filepath.Join(dataDir, "run", "secrets")
+}
+
+// SweepRuntimeDir removes secrets files left behind by a daemon that stopped
+// while a child process was running. Call it at start.
+func SweepRuntimeDir(dataDir string) error {
+	if err := os.RemoveAll(GetRuntimeDir(dataDir)); err != nil {
+		return fmt.Errorf("removing leftover secrets files: %w", err)
+	}
+	return nil
+}
+
+// WriteTempFile writes decrypted secrets to a file in the runtime directory
+// for a child process named in EnvSecretsFile. The returned function
+// removes the file.
+func WriteTempFile(dataDir string, data []byte) (string, func(), error) {
+	dir := GetRuntimeDir(dataDir)
+	if err := storage.EnsureDir(dir, 0700); err != nil {
+		return "", nil, err
+	}
+	if err := storage.EnsureFilePermissions(dir, 0700); err != nil {
+		return "", nil, err
+	}
+
+	file, err := os.CreateTemp(dir, "secrets-*.yaml")
+	if err != nil {
+		return "", nil, fmt.Errorf("creating secrets file: %w", err)
+	}
+	remove := func() { os.Remove(file.Name()) }
+
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		remove()
+		return "", nil, fmt.Errorf("writing secrets file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		remove()
+		return "", nil, fmt.Errorf("writing secrets file: %w", err)
+	}
+	return file.Name(), remove, nil
+}
+
+// InitKey creates and unlocks a new key file when encryption is not yet configured.
+// Returns the active key if one is already unlocked.
+func InitKey(dataDir string) (*Key, error) {
+	if key := ActiveKey(); key != nil {
+		return key, nil
+	}
+
+	if os.Getenv(EnvPassphrase) != "" {
+		return nil, fmt.Errorf("passphrase is set but secrets are not unlocked; restart the daemon")
+	}
+
+	keyPath := GetKeyFilePath(dataDir)
+	if storage.FileExists(keyPath) {
+		return nil, fmt.Errorf("key file %s exists but is not loaded; restart the daemon", keyPath)
+	}
+
+	key, err := GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+	if err := WriteKeyFile(keyPath, key); err != nil {
+		return nil, fmt.Errorf("writing key file: %w", err)
+	}
+
+	Unlock(key)
+	return key, nil
+}
+
+// EncryptInstance encrypts all plaintext secret files of an instance with the active key
+func (m *Manager) EncryptInstance(instancePath string) ([]string, error) {
+	var encrypted []string
+	for _, path := range SecretFiles(instancePath) {
+		if m.IsFileEncrypted(path) {
+			continue
+		}
+		if err := m.EncryptFile(path); err != nil {
+			return encrypted, fmt.Errorf("encrypting %s: %w", path, err)
+		}
+		encrypted = append(encrypted, path)
+	}
+	return encrypted, nil
+}
+
+// rewrapFile re-wraps one file during key rotation; a variable so tests can
+// interrupt a rotation
+var rewrapFile = (*Manager).RewrapFile
+
+// RotateKey replaces the master key and re-wraps the data keys of every
+// encrypted file in the given instances.
+//
+// With a key file, the new key is staged next to the current one before any
+// file is touched and only replaces it once every file is re-wrapped. With a
+// passphrase, newPassphrase is required and a new salt is written; the daemon
+// must be restarted with the new passphrase. Until the salt is replaced, a
+// rotation journal holding each key sealed with the other lets LoadKeys
+// recover both from either passphrase. On failure, files already re-wrapped
+// are switched back to the old key.
+func (m *Manager) RotateKey(dataDir string, instancePaths []string, newPassphrase string) (*Key, error) {
+	oldKey := ActiveKey()
+	if oldKey == nil {
+		return nil, fmt.Errorf("secrets encryption is not configured")
+	}
+
+	usePassphrase := os.Getenv(EnvPassphrase) != ""
+
+	var newKey *Key
+	var stagedPath string
+	var newSalt []byte
+
+	if usePassphrase {
+		if newPassphrase == "" {
+			return nil, fmt.Errorf("new passphrase is required when secrets are unlocked with a passphrase")
+		}
+		oldSalt, err := activeSalt(dataDir, oldKey)
+		if err != nil {
+			return nil, err
+		}
+		newSalt = make([]byte, 16)
+		if _, err := rand.Read(newSalt); err != nil {
+			return nil, fmt.Errorf("generating salt: %w", err)
+		}
+		if newKey, err = KeyFromPassphrase(newPassphrase, newSalt); err != nil {
+			return nil, err
+		}
+		journal, err := newRotationJournal(oldKey, newKey, oldSalt, newSalt)
+		if err != nil {
+			return nil, err
+		}
+		stagedPath = getRotationJournalPath(dataDir)
+		if err := storage.WriteFile(stagedPath, journal, 0600); err != nil {
+			return nil, fmt.Errorf("staging new key: %w", err)
+		}
+	} else {
+		key, err := GenerateKey()
+		if err != nil {
+			return nil, err
+		}
+		newKey = key
+		stagedPath = GetKeyFilePath(dataDir) + ".new"
+		if err := WriteKeyFile(stagedPath, key); err != nil {
+			return nil, fmt.Errorf("staging new key: %w", err)
+		}
+	}
+
+	// Every key must decrypt while files are mixed, including the other key
+	// of an earlier interrupted rotation
+	previous := unlockedKeys()
+	Unlock(append([]*Key{newKey}, previous...)...)
+
+	var done []string
+	rollback := func(cause error) (*Key, error) {
+		for _, path := range done {
+			m.RewrapFile(path, oldKey)
+		}
+		Unlock(previous...)
+		os.Remove(stagedPath)
+		return nil, cause
+	}
+
+	for _, instancePath := range instancePaths {
+		for _, path := range SecretFiles(instancePath) {
+			if !m.IsFileEncrypted(path) {
+				continue
+			}
+			if err := rewrapFile(m, path, newKey); err != nil {
+				return rollback(fmt.Errorf("re-wrapping %s: %w", path, err))
+			}
+			done = append(done, path)
+		}
+	}
+
+	if usePassphrase {
+		// The journal stays until the new salt is in place
+		encoded := base64.StdEncoding.EncodeToString(newSalt) + "\n"
+		if err := storage.WriteFile(getSaltPath(dataDir), []byte(encoded), 0600); err != nil {
+			return rollback(fmt.Errorf("replacing salt: %w", err))
+		}
+		os.Remove(stagedPath)
+	} else if err := os.Rename(stagedPath, GetKeyFilePath(dataDir)); err != nil {
+		return rollback(fmt.Errorf("replacing key: %w", err))
+	}
+
+	Unlock(newKey)
+	return newKey, nil
+}
+
+// activeSalt returns the salt the active key was derived with. After an
+// interrupted rotation it is in the journal, as secrets.salt may hold the
+// other passphrase's salt.
+func activeSalt(dataDir string, active *Key) ([]byte, error) {
+	if data, err := os.ReadFile(getRotationJournalPath(dataDir)); err == nil {
+		var journal rotationJournal
+		if err := yaml.Unmarshal(data, &journal); err == nil {
+			switch active.ID {
+			case journal.OldKeyID:
+				return base64.StdEncoding.DecodeString(journal.OldSalt)
+			case journal.NewKeyID:
+				return base64.StdEncoding.DecodeString(journal.NewSalt)
+			}
+		}
+	}
+	return ensureSalt(getSaltPath(dataDir))
+}
+
+// newRotationJournal seals each key of a passphrase rotation with the other
+func newRotationJournal(oldKey, newKey *Key, oldSalt, newSalt []byte) ([]byte, error) {
+	sealedOld, err := seal(newKey.key, oldKey.key)
+	if err != nil {
+		return nil, err
+	}
+	sealedNew, err := seal(oldKey.key, newKey.key)
+	if err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(&rotationJournal{
+		OldSalt:  base64.StdEncoding.EncodeToString(oldSalt),
+		NewSalt:  base64.StdEncoding.EncodeToString(newSalt),
+		OldKeyID: oldKey.ID,
+		NewKeyID: newKey.ID,
+		OldKey:   base64.StdEncoding.EncodeToString(sealedOld),
+		NewKey:   base64.StdEncoding.EncodeToString(sealedNew),
+	})
+}
@@ -4,7 +4,11 @@ import (
 	"crypto/rand"
 	"fmt"
 	"math/big"
+	"os"
 	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
 
 	"github.com/wild-cloud/wild-central/daemon/internal/storage"
 	"github.com/wild-cloud/wild-central/daemon/internal/tools"
@@ -76,54 +80,104 @@ certManager:
 		return err
 	}
 
-	// Write secrets file with restrictive permissions (0600)
-	if err := storage.WriteFile(secretsPath, []byte(initialSecrets), 0600); err != nil {
-		return err
-	}
-
-	return nil
+	// Write secrets file with restrictive permissions (0600), encrypted when a key is unlocked
+	return writeSecrets(secretsPath, []byte(initialSecrets))
 }
 
-// GetSecret retrieves a secret value from a secrets file
-func (m *Manager) GetSecret(secretsPath, key string) (string, error) {
-	if !storage.FileExists(secretsPath) {
-		return "", fmt.Errorf("secrets file not found: %s", secretsPath)
-	}
-
-	value, err := m.yq.Get(secretsPath, fmt.Sprintf(".%s", key))
+// IsFileEncrypted reports whether a secrets file is stored encrypted
+func (m *Manager) IsFileEncrypted(secretsPath string) bool {
+	data, err := os.ReadFile(secretsPath)
 	if err != nil {
-		return "", fmt.Errorf("getting secret %s: %w", key, err)
+		return false
 	}
-
-	return value, nil
+	return IsEncrypted(data)
 }
 
-// SetSecret sets a secret value in a secrets file
-func (m *Manager) SetSecret(secretsPath, key, value string) error {
-	if !storage.FileExists(secretsPath) {
-		return fmt.Errorf("secrets file not found: %s", secretsPath)
+// EncryptFile encrypts a plaintext secrets file in place with the active key
+func (m *Manager) EncryptFile(secretsPath string) error {
+	key := ActiveKey()
+	if key == nil {
+		return fmt.Errorf("secrets encryption is not configured")
 	}
 
-	// Acquire lock before modifying
 	lockPath := secretsPath + ".lock"
 	return storage.WithLock(lockPath, func() error {
-		// Don't wrap value in quotes - yq handles YAML quoting automatically
-		if err := m.yq.Set(secretsPath, fmt.Sprintf(".%s", key), value); err != nil {
+		data, err := os.ReadFile(secretsPath)
+		if err != nil {
+			return fmt.Errorf("reading secrets file: %w", err)
+		}
+		if IsEncrypted(data) {
+			return nil
+		}
+
+		encrypted, err := Encrypt(data, key)
+		if err != nil {
+			return err
+		}
+		return storage.WriteFile(secretsPath, encrypted, 0600)
+	})
+}
+
+// RewrapFile re-wraps an encrypted secrets file's data key with a new master key
+func (m *Manager) RewrapFile(secretsPath string, newKey *Key) error {
+	lockPath := secretsPath + ".lock"
+	return storage.WithLock(lockPath, func() error {
+		data, err := os.ReadFile(secretsPath)
+		if err != nil {
+			return fmt.Errorf("reading secrets file: %w", err)
+		}
+		if !IsEncrypted(data) {
+			return nil
+		}
+
+		rewrapped, err := Rewrap(data, newKey)
+		if err != nil {
 			return err
 		}
-		// Ensure permissions remain secure after modification
-		return storage.EnsureFilePermissions(secretsPath, 0600)
+		return storage.WriteFile(secretsPath, rewrapped, 0600)
 	})
 }
 
+// writeSecrets writes plaintext secrets, encrypting them when a key is unlocked
+func writeSecrets(secretsPath string, plaintext []byte) error {
+	data := plaintext
+	if key := ActiveKey(); key != nil {
+		encrypted, err := Encrypt(plaintext, key)
+		if err != nil {
+			return err
+		}
+		data = encrypted
+	}
+	return storage.WriteFile(secretsPath, data, 0600)
+}
+
+// GetSecret retrieves a secret value from an instance's secrets
+func (m *Manager) GetSecret(secretsPath, key string) (string, error) {
+	store, err := m.openPath(secretsPath)
+	if err != nil {
+		return "", err
+	}
+	return store.Get(key)
+}
+
+// SetSecret sets a secret value in an instance's secrets
+func (m *Manager) SetSecret(secretsPath, key, value string) error {
+	store, err := m.openPath(secretsPath)
+	if err != nil {
+		return err
+	}
+	return store.Set(key, value)
+}
+
 // EnsureSecret generates and sets a secret only if it doesn't exist (idempotent)
 func (m *Manager) EnsureSecret(secretsPath, key string, length int) (string, error) {
-	if !storage.FileExists(secretsPath) {
-		return "", fmt.Errorf("secrets file not found: %s", secretsPath)
+	store, err := m.openPath(secretsPath)
+	if err != nil {
+		return "", err
 	}
 
 	// Check if secret already exists
-	existingSecret, err := m.GetSecret(secretsPath, key)
+	existingSecret, err := store.Get(key)
 	if err == nil && existingSecret != "" && existingSecret != "null" {
 		// Secret already exists, return it
 		return existingSecret, nil
@@ -136,7 +190,7 @@ func (m *Manager) EnsureSecret(secretsPath, key string, length int) (string, err
 	}
 
 	// Set the secret
-	if err := m.SetSecret(secretsPath, key, secret); err != nil {
+	if err := store.Set(key, secret); err != nil {
 		return "", err
 	}
 
@@ -148,19 +202,131 @@ func (m *Manager) GenerateAndStoreSecret(secretsPath, key string) (string, error
 	return m.EnsureSecret(secretsPath, key, DefaultSecretLength)
 }
 
-// DeleteSecret removes a secret from a secrets file
+// DeleteSecret removes a secret from an instance's secrets
 func (m *Manager) DeleteSecret(secretsPath, key string) error {
-	if !storage.FileExists(secretsPath) {
-		return fmt.Errorf("secrets file not found: %s", secretsPath)
+	store, err := m.openPath(secretsPath)
+	if err != nil {
+		return err
 	}
+	return store.Delete(key)
+}
 
-	// Acquire lock before modifying
-	lockPath := secretsPath + ".lock"
-	return storage.WithLock(lockPath, func() error {
-		if err := m.yq.Delete(secretsPath, fmt.Sprintf(".%s", key)); err != nil {
-			return err
+// openPath opens the store of the instance owning a secrets file
+func (m *Manager) openPath(secretsPath string) (Store, error) {
+	return m.Open(filepath.Dir(secretsPath))
+}
+
+// updateYAML applies fn to the root of a YAML document and returns the result
+func updateYAML(data []byte, fn func(root *yaml.Node) error) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("parsing secrets: %w", err)
+	}
+	if doc.Kind == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	}
+
+	if err := fn(doc.Content[0]); err != nil {
+		return nil, err
+	}
+
+	out, err := yaml.Marshal(&doc)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling secrets: %w", err)
+	}
+	return out, nil
+}
+
+// getYAMLValue returns the value at a dot-notation path, matching yq output:
+// scalars as-is, "null" when missing, and YAML for nested values
+func getYAMLValue(data []byte, path string) (string, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return "", fmt.Errorf("parsing secrets: %w", err)
+	}
+	if doc.Kind == 0 {
+		return "null", nil
+	}
+
+	node := doc.Content[0]
+	for _, key := range strings.Split(path, ".") {
+		node = mappingValue(node, key)
+		if node == nil {
+			return "null", nil
 		}
-		// Ensure permissions remain secure after modification
-		return storage.EnsureFilePermissions(secretsPath, 0600)
-	})
+	}
+
+	if node.Kind == yaml.ScalarNode {
+		return node.Value, nil
+	}
+
+	out, err := yaml.Marshal(node)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// setYAMLValue sets a string value at a dot-notation path, creating maps as needed
+func setYAMLValue(root *yaml.Node, path, value string) error {
+	keys := strings.Split(path, ".")
+	node := root
+	for i, key := range keys {
+		if node.Kind != yaml.MappingNode {
+			return fmt.Errorf("cannot set %s: %s is not a map", path, strings.Join(keys[:i], "."))
+		}
+
+		child := mappingValue(node, key)
+		if i == len(keys)-1 {
+			if child == nil {
+				child = &yaml.Node{}
+				node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, child)
+			}
+			*child = yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+			return nil
+		}
+
+		if child == nil {
+			child = &yaml.Node{Kind: yaml.MappingNode}
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, child)
+		}
+		node = child
+	}
+	return nil
+}
+
+// deleteYAMLValue removes the value at a dot-notation path if present
+func deleteYAMLValue(root *yaml.Node, path string) {
+	keys := strings.Split(path, ".")
+	node := root
+	for _, key := range keys[:len(keys)-1] {
+		node = mappingValue(node, key)
+		if node == nil {
+			return
+		}
+	}
+
+	last := keys[len(keys)-1]
+	if node.Kind != yaml.MappingNode {
+		return
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == last {
+			node.Content = append(node.Content[:i], node.Content[i+2:]...)
+			return
+		}
+	}
+}
+
+// mappingValue returns the value node for key in a mapping node
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
 }
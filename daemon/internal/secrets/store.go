@@ -0,0 +1,252 @@
+package secrets
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/wild-cloud/wild-central/daemon/internal/storage"
+	"github.com/wild-cloud/wild-central/daemon/internal/tools"
+)
+
+// Backend types selectable per instance with secretsBackend.type in config.yaml
+const (
+	BackendFile          = "file"           // Plaintext secrets.yaml
+	BackendEncryptedFile = "encrypted-file" // secrets.yaml sealed with the master key
+	BackendVault         = "vault"          // Vault-compatible KV v2 HTTP API
+)
+
+// Store holds the secrets of one instance
+type Store interface {
+	// Get returns the value at a dot-notation key, or "null" when unset
+	Get(key string) (string, error)
+	// Set stores a value at a dot-notation key
+	Set(key, value string) error
+	// Delete removes the value at a dot-notation key
+	Delete(key string) error
+	// Read returns the whole secrets tree as a YAML document
+	Read() ([]byte, error)
+	// Type returns the backend type
+	Type() string
+}
+
+// BackendConfig selects and configures the secrets backend of an instance
+type BackendConfig struct {
+	Type  string      `yaml:"type" json:"type"`
+	Vault VaultConfig `yaml:"vault,omitempty" json:"vault,omitempty"`
+}
+
+// LoadBackendConfig reads the secretsBackend section of an instance's config.yaml.
+// A missing file or section selects the default file backend.
+func LoadBackendConfig(instancePath string) (*BackendConfig, error) {
+	var cfg struct {
+		SecretsBackend BackendConfig `yaml:"secretsBackend"`
+	}
+
+	data, err := os.ReadFile(filepath.Join(instancePath, "config.yaml"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &BackendConfig{}, nil
+		}
+		return nil, fmt.Errorf("reading instance config: %w", err)
+	}
+
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parsing instance config: %w", err)
+	}
+
+	return &cfg.SecretsBackend, nil
+}
+
+// Open returns the secrets store configured for an instance. Without an
+// explicit backend type, secrets.yaml is treated as encrypted if it already
+// is, or if it does not exist yet and a key is unlocked.
+//
+// Writes through the returned store record per-key modification times.
+func (m *Manager) Open(instancePath string) (Store, error) {
+	store, err := m.openBackend(instancePath)
+	if err != nil {
+		return nil, err
+	}
+	return &trackedStore{Store: store, metaPath: GetMetadataPath(instancePath)}, nil
+}
+
+// openBackend creates the backend store selected for an instance
+func (m *Manager) openBackend(instancePath string) (Store, error) {
+	cfg, err := LoadBackendConfig(instancePath)
+	if err != nil {
+		return nil, err
+	}
+
+	secretsPath := filepath.Join(instancePath, "secrets.yaml")
+
+	switch cfg.Type {
+	case "":
+		if m.IsFileEncrypted(secretsPath) || (!storage.FileExists(secretsPath) && ActiveKey() != nil) {
+			return &EncryptedFileStore{path: secretsPath}, nil
+		}
+		return &FileStore{path: secretsPath, yq: m.yq}, nil
+	case BackendFile:
+		return &FileStore{path: secretsPath, yq: m.yq}, nil
+	case BackendEncryptedFile:
+		return &EncryptedFileStore{path: secretsPath}, nil
+	case BackendVault:
+		return NewVaultStore(cfg.Vault)
+	default:
+		return nil, fmt.Errorf("unknown secrets backend %q", cfg.Type)
+	}
+}
+
+// FileStore keeps secrets in a plaintext YAML file edited with yq
+type FileStore struct {
+	path string
+	yq   *tools.YQ
+}
+
+// Type returns the backend type
+func (s *FileStore) Type() string {
+	return BackendFile
+}
+
+// Get retrieves a secret value
+func (s *FileStore) Get(key string) (string, error) {
+	if !storage.FileExists(s.path) {
+		return "", fmt.Errorf("secrets file not found: %s", s.path)
+	}
+
+	value, err := s.yq.Get(s.path, fmt.Sprintf(".%s", key))
+	if err != nil {
+		return "", fmt.Errorf("getting secret %s: %w", key, err)
+	}
+
+	return value, nil
+}
+
+// Set sets a secret value
+func (s *FileStore) Set(key, value string) error {
+	if !storage.FileExists(s.path) {
+		return fmt.Errorf("secrets file not found: %s", s.path)
+	}
+
+	// Acquire lock before modifying
+	lockPath := s.path + ".lock"
+	return storage.WithLock(lockPath, func() error {
+		// Don't wrap value in quotes - yq handles YAML quoting automatically
+		err := storage.EditFile(s.path, func(copyPath string) error {
+			return s.yq.Set(copyPath, fmt.Sprintf(".%s", key), value)
+		})
+		if err != nil {
+			return err
+		}
+		// Ensure permissions remain secure after modification
+		return storage.EnsureFilePermissions(s.path, 0600)
+	})
+}
+
+// Delete removes a secret
+func (s *FileStore) Delete(key string) error {
+	if !storage.FileExists(s.path) {
+		return fmt.Errorf("secrets file not found: %s", s.path)
+	}
+
+	// Acquire lock before modifying
+	lockPath := s.path + ".lock"
+	return storage.WithLock(lockPath, func() error {
+		err := storage.EditFile(s.path, func(copyPath string) error {
+			return s.yq.Delete(copyPath, fmt.Sprintf(".%s", key))
+		})
+		if err != nil {
+			return err
+		}
+		// Ensure permissions remain secure after modification
+		return storage.EnsureFilePermissions(s.path, 0600)
+	})
+}
+
+// Read returns the secrets file contents
+func (s *FileStore) Read() ([]byte, error) {
+	return os.ReadFile(s.path)
+}
+
+// EncryptedFileStore keeps secrets in an encrypted YAML file. Values are only
+// decrypted in memory.
+type EncryptedFileStore struct {
+	path string
+}
+
+// Type returns the backend type
+func (s *EncryptedFileStore) Type() string {
+	return BackendEncryptedFile
+}
+
+// Get retrieves a secret value
+func (s *EncryptedFileStore) Get(key string) (string, error) {
+	plaintext, err := s.Read()
+	if err != nil {
+		return "", fmt.Errorf("getting secret %s: %w", key, err)
+	}
+	return getYAMLValue(plaintext, key)
+}
+
+// Set sets a secret value
+func (s *EncryptedFileStore) Set(key, value string) error {
+	return s.update(func(root *yaml.Node) error {
+		return setYAMLValue(root, key, value)
+	})
+}
+
+// Delete removes a secret
+func (s *EncryptedFileStore) Delete(key string) error {
+	return s.update(func(root *yaml.Node) error {
+		deleteYAMLValue(root, key)
+		return nil
+	})
+}
+
+// Read returns the decrypted secrets document
+func (s *EncryptedFileStore) Read() ([]byte, error) {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !IsEncrypted(data) {
+		return data, nil
+	}
+
+	return Decrypt(data)
+}
+
+// update decrypts the file, applies fn to the document in memory, and writes
+// it back encrypted under the file lock
+func (s *EncryptedFileStore) update(fn func(root *yaml.Node) error) error {
+	if !storage.FileExists(s.path) {
+		return fmt.Errorf("secrets file not found: %s", s.path)
+	}
+
+	key := ActiveKey()
+	if key == nil {
+		return fmt.Errorf("secrets are locked: no encryption key is unlocked")
+	}
+
+	lockPath := s.path + ".lock"
+	return storage.WithLock(lockPath, func() error {
+		plaintext, err := s.Read()
+		if err != nil {
+			return err
+		}
+
+		out, err := updateYAML(plaintext, fn)
+		if err != nil {
+			return err
+		}
+
+		encrypted, err := Encrypt(out, key)
+		if err != nil {
+			return err
+		}
+		return storage.WriteFile(s.path, encrypted, 0600)
+	})
+}
@@ -0,0 +1,298 @@
+package secrets
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// VaultConfig configures a Vault-compatible KV v2 secrets backend.
+// The token is read from TokenFile, or from VAULT_TOKEN when no file is set.
+type VaultConfig struct {
+	Address   string `yaml:"address" json:"address"`                         // e.g. https://vault.local:8200
+	Mount     string `yaml:"mount,omitempty" json:"mount,omitempty"`         // KV v2 mount, defaults to "secret"
+	Path      string `yaml:"path" json:"path"`                               // Secret path holding the instance's secrets
+	Namespace string `yaml:"namespace,omitempty" json:"namespace,omitempty"` // Optional Vault namespace
+	TokenFile string `yaml:"tokenFile,omitempty" json:"tokenFile,omitempty"`
+}
+
+// vaultCASRetries bounds retries when a concurrent writer changes the secret
+const vaultCASRetries = 3
+
+// VaultStore keeps an instance's secrets as a single KV v2 secret whose data
+// is the nested secrets tree. Writes use check-and-set so concurrent updates
+// are not lost.
+type VaultStore struct {
+	cfg    VaultConfig
+	token  string
+	client *http.Client
+}
+
+// vaultError is returned for non-success responses from the KV API
+type vaultError struct {
+	status int
+	body   string
+}
+
+func (e *vaultError) Error() string {
+	return fmt.Sprintf("vault returned %d: %s", e.status, strings.TrimSpace(e.body))
+}
+
+// NewVaultStore creates a KV v2 store from configuration
+func NewVaultStore(cfg VaultConfig) (*VaultStore, error) {
+	if cfg.Address == "" {
+		return nil, fmt.Errorf("vault address is required")
+	}
+	if cfg.Path == "" {
+		return nil, fmt.Errorf("vault secret path is required")
+	}
+	if cfg.Mount == "" {
+		cfg.Mount = "secret"
+	}
+
+	token := os.Getenv("VAULT_TOKEN")
+	if cfg.TokenFile != "" {
+		data, err := os.ReadFile(cfg.TokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading vault token: %w", err)
+		}
+		token = strings.TrimSpace(string(data))
+	}
+	if token == "" {
+		return nil, fmt.Errorf("vault token not configured (set tokenFile or VAULT_TOKEN)")
+	}
+
+	return &VaultStore{
+		cfg:    cfg,
+		token:  token,
+		client: &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+// Type returns the backend type
+func (s *VaultStore) Type() string {
+	return BackendVault
+}
+
+// Get retrieves a secret value
+func (s *VaultStore) Get(key string) (string, error) {
+	data, _, err := s.read()
+	if err != nil {
+		return "", fmt.Errorf("getting secret %s: %w", key, err)
+	}
+
+	value, ok := lookupMap(data, key)
+	if !ok || value == nil {
+		return "null", nil
+	}
+
+	if nested, ok := value.(map[string]interface{}); ok {
+		out, err := yaml.Marshal(nested)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(string(out)), nil
+	}
+
+	return fmt.Sprintf("%v", value), nil
+}
+
+// Set sets a secret value
+func (s *VaultStore) Set(key, value string) error {
+	return s.update(func(data map[string]interface{}) error {
+		return setMapValue(data, key, value)
+	})
+}
+
+// Delete removes a secret
+func (s *VaultStore) Delete(key string) error {
+	return s.update(func(data map[string]interface{}) error {
+		deleteMapValue(data, key)
+		return nil
+	})
+}
+
+// Read returns the secrets tree as a YAML document
+func (s *VaultStore) Read() ([]byte, error) {
+	data, _, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(data)
+}
+
+// update applies fn to the current secret data and writes it back with
+// check-and-set, retrying if another writer got there first
+func (s *VaultStore) update(fn func(data map[string]interface{}) error) error {
+	for attempt := 0; ; attempt++ {
+		data, version, err := s.read()
+		if err != nil {
+			return err
+		}
+
+		if err := fn(data); err != nil {
+			return err
+		}
+
+		err = s.write(data, version)
+		if err == nil {
+			return nil
+		}
+
+		if verr, ok := err.(*vaultError); ok && verr.status == http.StatusBadRequest &&
+			strings.Contains(verr.body, "check-and-set") && attempt < vaultCASRetries {
+			continue
+		}
+		return err
+	}
+}
+
+// read fetches the latest secret data and version. A missing secret reads as empty.
+func (s *VaultStore) read() (map[string]interface{}, int, error) {
+	var resp struct {
+		Data struct {
+			Data     map[string]interface{} `json:"data"`
+			Metadata struct {
+				Version int `json:"version"`
+			} `json:"metadata"`
+		} `json:"data"`
+	}
+
+	status, err := s.do("GET", s.dataURL(), nil, &resp)
+	if err != nil {
+		return nil, 0, err
+	}
+	if status == http.StatusNotFound {
+		return map[string]interface{}{}, 0, nil
+	}
+
+	data := resp.Data.Data
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+	return data, resp.Data.Metadata.Version, nil
+}
+
+// write stores secret data, requiring the current version to match
+func (s *VaultStore) write(data map[string]interface{}, version int) error {
+	body := map[string]interface{}{
+		"options": map[string]interface{}{"cas": version},
+		"data":    data,
+	}
+	_, err := s.do("POST", s.dataURL(), body, nil)
+	return err
+}
+
+// dataURL returns the KV v2 data endpoint for the configured secret
+func (s *VaultStore) dataURL() string {
+	return fmt.Sprintf("%s/v1/%s/data/%s",
+		strings.TrimRight(s.cfg.Address, "/"),
+		strings.Trim(s.cfg.Mount, "/"),
+		strings.Trim(s.cfg.Path, "/"))
+}
+
+// do sends a request to the KV API. 404 is returned as a status, not an error.
+func (s *VaultStore) do(method, url string, body, out interface{}) (int, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return 0, fmt.Errorf("marshaling request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, url, reader)
+	if err != nil {
+		return 0, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("X-Vault-Token", s.token)
+	if s.cfg.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", s.cfg.Namespace)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("vault request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return resp.StatusCode, fmt.Errorf("reading vault response: %w", err)
+	}
+
+	if resp.StatusCode == http.StatusNotFound {
+		return resp.StatusCode, nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, &vaultError{status: resp.StatusCode, body: string(respBody)}
+	}
+
+	if out != nil && len(respBody) > 0 {
+		if err := json.Unmarshal(respBody, out); err != nil {
+			return resp.StatusCode, fmt.Errorf("parsing vault response: %w", err)
+		}
+	}
+
+	return resp.StatusCode, nil
+}
+
+// lookupMap returns the value at a dot-notation path in a nested map
+func lookupMap(data map[string]interface{}, path string) (interface{}, bool) {
+	var current interface{} = data
+	for _, key := range strings.Split(path, ".") {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		current, ok = m[key]
+		if !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// setMapValue sets a value at a dot-notation path, creating maps as needed
+func setMapValue(data map[string]interface{}, path, value string) error {
+	keys := strings.Split(path, ".")
+	current := data
+	for i, key := range keys[:len(keys)-1] {
+		next, ok := current[key].(map[string]interface{})
+		if !ok {
+			if _, exists := current[key]; exists {
+				return fmt.Errorf("cannot set %s: %s is not a map", path, strings.Join(keys[:i+1], "."))
+			}
+			next = map[string]interface{}{}
+			current[key] = next
+		}
+		current = next
+	}
+	current[keys[len(keys)-1]] = value
+	return nil
+}
+
+// deleteMapValue removes the value at a dot-notation path if present
+func deleteMapValue(data map[string]interface{}, path string) {
+	keys := strings.Split(path, ".")
+	current := data
+	for _, key := range keys[:len(keys)-1] {
+		next, ok := current[key].(map[string]interface{})
+		if !ok {
+			return
+		}
+		current = next
+	}
+	delete(current, keys[len(keys)-1])
+}
@@ -0,0 +1,198 @@
+package secrets
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeVault is a minimal stand-in for the Vault KV v2 HTTP API
+type fakeVault struct {
+	mu       sync.Mutex
+	token    string
+	secrets  map[string]map[string]interface{}
+	versions map[string]int
+}
+
+func newFakeVault(token string) *fakeVault {
+	return &fakeVault{
+		token:    token,
+		secrets:  make(map[string]map[string]interface{}),
+		versions: make(map[string]int),
+	}
+}
+
+func (f *fakeVault) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if r.Header.Get("X-Vault-Token") != f.token {
+		http.Error(w, `{"errors":["permission denied"]}`, http.StatusForbidden)
+		return
+	}
+
+	// Only the data endpoint of the "secret" mount is supported
+	path, ok := strings.CutPrefix(r.URL.Path, "/v1/secret/data/")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		data, exists := f.secrets[path]
+		if !exists {
+			http.Error(w, `{"errors":[]}`, http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"data": map[string]interface{}{
+				"data":     data,
+				"metadata": map[string]interface{}{"version": f.versions[path]},
+			},
+		})
+	case "POST", "PUT":
+		var req struct {
+			Options struct {
+				CAS *int `json:"cas"`
+			} `json:"options"`
+			Data map[string]interface{} `json:"data"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, `{"errors":["invalid body"]}`, http.StatusBadRequest)
+			return
+		}
+		if req.Options.CAS != nil && *req.Options.CAS != f.versions[path] {
+			http.Error(w, `{"errors":["check-and-set parameter did not match the current version"]}`, http.StatusBadRequest)
+			return
+		}
+		f.secrets[path] = req.Data
+		f.versions[path]++
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"data": map[string]interface{}{"version": f.versions[path]},
+		})
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func TestVaultStore(t *testing.T) {
+	fake := newFakeVault("test-token")
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	t.Setenv("VAULT_TOKEN", "test-token")
+
+	store, err := NewVaultStore(VaultConfig{Address: server.URL, Path: "wild-cloud/test"})
+	if err != nil {
+		t.Fatalf("NewVaultStore failed: %v", err)
+	}
+
+	// Missing secret reads as unset
+	value, err := store.Get("cluster.kubeconfig")
+	if err != nil {
+		t.Fatalf("Get on empty store failed: %v", err)
+	}
+	if value != "null" {
+		t.Errorf("Expected null for unset key, got %q", value)
+	}
+
+	if err := store.Set("apps.db.password", "hunter2"); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := store.Set("apps.db.user", "admin"); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+
+	value, err = store.Get("apps.db.password")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if value != "hunter2" {
+		t.Errorf("Expected hunter2, got %q", value)
+	}
+
+	if fake.versions["wild-cloud/test"] != 2 {
+		t.Errorf("Expected 2 versions written, got %d", fake.versions["wild-cloud/test"])
+	}
+
+	if err := store.Delete("apps.db.user"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+
+	data, err := store.Read()
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if !strings.Contains(string(data), "password: hunter2") || strings.Contains(string(data), "user:") {
+		t.Errorf("Unexpected secrets document:\n%s", data)
+	}
+}
+
+func TestVaultStore_BadToken(t *testing.T) {
+	server := httptest.NewServer(newFakeVault("right-token"))
+	defer server.Close()
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	os.WriteFile(tokenFile, []byte("wrong-token\n"), 0600)
+
+	store, err := NewVaultStore(VaultConfig{Address: server.URL, Path: "wild-cloud/test", TokenFile: tokenFile})
+	if err != nil {
+		t.Fatalf("NewVaultStore failed: %v", err)
+	}
+
+	if _, err := store.Get("anything"); err == nil {
+		t.Error("Expected error with wrong token")
+	}
+}
+
+func TestManager_OpenSelectsBackend(t *testing.T) {
+	server := httptest.NewServer(newFakeVault("test-token"))
+	defer server.Close()
+	t.Setenv("VAULT_TOKEN", "test-token")
+
+	instancePath := t.TempDir()
+	m := NewManager()
+	if err := m.EnsureSecretsFile(instancePath); err != nil {
+		t.Fatalf("EnsureSecretsFile failed: %v", err)
+	}
+
+	store, err := m.Open(instancePath)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if store.Type() != BackendFile {
+		t.Errorf("Expected default file backend, got %s", store.Type())
+	}
+
+	config := "secretsBackend:\n  type: vault\n  vault:\n    address: " + server.URL + "\n    path: wild-cloud/test\n"
+	os.WriteFile(filepath.Join(instancePath, "config.yaml"), []byte(config), 0644)
+
+	store, err = m.Open(instancePath)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if store.Type() != BackendVault {
+		t.Fatalf("Expected vault backend, got %s", store.Type())
+	}
+
+	// Path-based manager methods route to the instance's backend
+	secretsPath := filepath.Join(instancePath, "secrets.yaml")
+	secret, err := m.EnsureSecret(secretsPath, "apps.db.password", 16)
+	if err != nil {
+		t.Fatalf("EnsureSecret failed: %v", err)
+	}
+	if value, _ := store.Get("apps.db.password"); value != secret {
+		t.Errorf("Expected secret in vault, got %q", value)
+	}
+
+	os.WriteFile(filepath.Join(instancePath, "config.yaml"), []byte("secretsBackend:\n  type: bogus\n"), 0644)
+	if _, err := m.Open(instancePath); err == nil {
+		t.Error("Expected error for unknown backend")
+	}
+}
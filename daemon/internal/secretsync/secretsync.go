@@ -0,0 +1,107 @@
+// Package secretsync keeps the Kubernetes Secrets declared by an instance's
+// services and apps in step with its Wild Cloud secrets.
+package secretsync
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/wild-cloud/wild-central/daemon/internal/apps"
+	"github.com/wild-cloud/wild-central/daemon/internal/secrets"
+	"github.com/wild-cloud/wild-central/daemon/internal/services"
+	"github.com/wild-cloud/wild-central/daemon/internal/tools"
+)
+
+// Manager syncs declared Kubernetes Secrets for instances
+type Manager struct {
+	dataDir       string
+	directoryPath string
+}
+
+// NewManager creates a new secret sync manager
+func NewManager(dataDir, directoryPath string) *Manager {
+	return &Manager{
+		dataDir:       dataDir,
+		directoryPath: directoryPath,
+	}
+}
+
+// Source is a service or app with its Kubernetes Secret mappings
+type Source struct {
+	Name     string                  `json:"name"` // e.g. service/cert-manager, app/ghost
+	Mappings []secrets.SecretMapping `json:"mappings"`
+}
+
+// Sources returns the mappings declared by installed services and configured apps
+func (m *Manager) Sources(instanceName string) ([]Source, error) {
+	var sources []Source
+
+	servicesDir := filepath.Join(m.dataDir, "instances", instanceName, "setup", "cluster-services")
+	entries, err := os.ReadDir(servicesDir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read services: %w", err)
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		manifest, err := services.LoadManifest(filepath.Join(servicesDir, entry.Name()))
+		if err != nil {
+			continue
+		}
+		if mappings := manifest.SecretMappings(); len(mappings) > 0 {
+			sources = append(sources, Source{Name: "service/" + entry.Name(), Mappings: mappings})
+		}
+	}
+
+	appsMgr := apps.NewManager(m.dataDir, filepath.Join(m.directoryPath, "apps"))
+	configured, err := appsMgr.ListConfigured(instanceName)
+	if err != nil {
+		return nil, err
+	}
+	for _, appName := range configured {
+		app, err := appsMgr.Get(appName)
+		if err != nil {
+			continue
+		}
+		if mappings := app.SecretMappings(); len(mappings) > 0 {
+			sources = append(sources, Source{Name: "app/" + appName, Mappings: mappings})
+		}
+	}
+
+	return sources, nil
+}
+
+// Sync creates or updates every declared Kubernetes Secret of an instance.
+// With DryRun set it only reports which Secrets are missing or have drifted.
+func (m *Manager) Sync(instanceName string, opts secrets.SyncOptions) ([]secrets.SyncResult, error) {
+	sources, err := m.Sources(instanceName)
+	if err != nil {
+		return nil, err
+	}
+
+	store, err := secrets.NewManager().Open(filepath.Join(m.dataDir, "instances", instanceName))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open secrets: %w", err)
+	}
+	kubeconfigPath := tools.GetKubeconfigPath(m.dataDir, instanceName)
+
+	results := []secrets.SyncResult{}
+	failed := 0
+	for _, source := range sources {
+		sourceResults, err := secrets.SyncKubernetesSecrets(store, kubeconfigPath, source.Mappings, opts)
+		if err != nil {
+			failed++
+		}
+		for _, result := range sourceResults {
+			result.Source = source.Name
+			results = append(results, result)
+		}
+	}
+
+	if failed > 0 {
+		return results, fmt.Errorf("secrets of %d source(s) failed to sync", failed)
+	}
+	return results, nil
+}
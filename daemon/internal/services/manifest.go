@@ -7,6 +7,7 @@ import (
 
 	"gopkg.in/yaml.v3"
 
+	"github.com/wild-cloud/wild-central/daemon/internal/secrets"
 	"github.com/wild-cloud/wild-central/daemon/internal/storage"
 )
 
@@ -20,6 +21,9 @@ type ServiceManifest struct {
 	Dependencies     []string                    `yaml:"dependencies,omitempty" json:"dependencies,omitempty"`
 	ConfigReferences []string                    `yaml:"configReferences,omitempty" json:"configReferences,omitempty"`
 	ServiceConfig    map[string]ConfigDefinition `yaml:"serviceConfig,omitempty" json:"serviceConfig,omitempty"`
+	RotationHooks    []secrets.RotationHook      `yaml:"rotationHooks,omitempty" json:"rotationHooks,omitempty"`
+
+	KubernetesSecrets []secrets.SecretMapping `yaml:"kubernetesSecrets,omitempty" json:"kubernetesSecrets,omitempty"`
 }
 
 // ConfigDefinition defines config that should be prompted during service setup
@@ -94,6 +98,19 @@ func (m *ServiceManifest) GetDeploymentName() string {
 	return m.Name
 }
 
+// SecretMappings returns the declared Kubernetes Secret mappings, with the
+// namespace defaulting to the service's namespace
+func (m *ServiceManifest) SecretMappings() []secrets.SecretMapping {
+	mappings := make([]secrets.SecretMapping, 0, len(m.KubernetesSecrets))
+	for _, mapping := range m.KubernetesSecrets {
+		if mapping.Namespace == "" {
+			mapping.Namespace = m.Namespace
+		}
+		mappings = append(mappings, mapping)
+	}
+	return mappings
+}
+
 // GetRequiredConfig returns all config paths that must be set
 func (m *ServiceManifest) GetRequiredConfig() []string {
 	var required []string
@@ -1,6 +1,7 @@
 package services
 
 import (
+	"bytes"
 	"fmt"
 	"os"
 	"os/exec"
@@ -10,6 +11,7 @@ import (
 	"gopkg.in/yaml.v3"
 
 	"github.com/wild-cloud/wild-central/daemon/internal/operations"
+	"github.com/wild-cloud/wild-central/daemon/internal/secrets"
 	"github.com/wild-cloud/wild-central/daemon/internal/storage"
 	"github.com/wild-cloud/wild-central/daemon/internal/tools"
 )
@@ -363,7 +365,7 @@ func copyFile(src, dst string) error {
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(dst, input, 0644)
+	return storage.WriteFile(dst, input, 0644)
 }
 
 func copyFileIfExists(src, dst string) error {
@@ -419,7 +421,6 @@ func (m *Manager) Compile(instanceName, serviceName string) error {
 
 	// 2. Load config and secrets files
 	configFile := filepath.Join(instanceDir, "config.yaml")
-	secretsFile := filepath.Join(instanceDir, "secrets.yaml")
 
 	if !fileExists(configFile) {
 		return fmt.Errorf("config.yaml not found for instance %s", instanceName)
@@ -436,13 +437,18 @@ func (m *Manager) Compile(instanceName, serviceName string) error {
 		"-c", fmt.Sprintf(".=%s", configFile),
 	}
 
-	// Add secrets context if file exists
-	if fileExists(secretsFile) {
-		gomplateArgs = append(gomplateArgs, "-c", fmt.Sprintf("secrets=%s", secretsFile))
+	// Add secrets context if the instance has secrets. They are resolved through
+	// the instance's backend and passed to gomplate on stdin so plaintext never touches disk.
+	secretsData, err := readInstanceSecrets(instanceDir)
+	if err != nil {
+		return err
+	}
+	if secretsData != nil {
+		gomplateArgs = append(gomplateArgs, "-c", "secrets=stdin:///secrets.yaml")
 	}
 
 	// Process each template file recursively
-	err := filepath.Walk(templateDir, func(srcPath string, info os.FileInfo, err error) error {
+	err = filepath.Walk(templateDir, func(srcPath string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
@@ -464,6 +470,9 @@ func (m *Manager) Compile(instanceName, serviceName string) error {
 		// Run gomplate on this file
 		args := append(gomplateArgs, "-f", srcPath, "-o", dstPath)
 		cmd := exec.Command("gomplate", args...)
+		if secretsData != nil {
+			cmd.Stdin = bytes.NewReader(secretsData)
+		}
 		output, err := cmd.CombinedOutput()
 		if err != nil {
 			return fmt.Errorf("gomplate failed for %s: %w\nOutput: %s", relPath, err, output)
@@ -516,6 +525,22 @@ func (m *Manager) Deploy(instanceName, serviceName, opID string, broadcaster *op
 	)
 	fmt.Printf("[DEBUG] Environment configured: WILD_INSTANCE=%s, KUBECONFIG=%s\n", instanceName, kubeconfigPath)
 
+	// Pass secrets resolved in memory in a private file under the data
+	// directory, never in the environment; install scripts read them from
+	// WILD_SECRETS_FILE
+	secretsData, err := readInstanceSecrets(instanceDir)
+	if err != nil {
+		return err
+	}
+	if secretsData != nil {
+		secretsFile, removeSecrets, err := secrets.WriteTempFile(m.dataDir, secretsData)
+		if err != nil {
+			return err
+		}
+		defer removeSecrets()
+		env = append(env, fmt.Sprintf("%s=%s", secrets.EnvSecretsFile, secretsFile))
+	}
+
 	// 3. Set up output streaming
 	var outputWriter *broadcastWriter
 	if opID != "" {
@@ -543,7 +568,19 @@ func (m *Manager) Deploy(instanceName, serviceName, opID string, broadcaster *op
 		}
 	}
 
-	// 4. Execute install.sh
+	// 4. Sync Kubernetes Secrets declared in the manifest before install.sh needs them
+	results, err := m.SyncSecrets(instanceName, serviceName, true)
+	for _, result := range results {
+		msg := fmt.Sprintf("🔐 Secret %s/%s: %s\n", result.Namespace, result.Name, result.Status)
+		if outputWriter != nil {
+			outputWriter.Write([]byte(msg))
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	// 5. Execute install.sh
 	fmt.Printf("[DEBUG] Executing: /bin/bash %s\n", installScript)
 	cmd := exec.Command("/bin/bash", installScript)
 	cmd.Dir = serviceDir
@@ -572,6 +609,33 @@ func (m *Manager) Deploy(instanceName, serviceName, opID string, broadcaster *op
 	}
 }
 
+// SyncSecrets creates or updates the Kubernetes Secrets declared in an
+// installed service's manifest from the instance secrets
+func (m *Manager) SyncSecrets(instanceName, serviceName string, createNamespaces bool) ([]secrets.SyncResult, error) {
+	instanceDir := filepath.Join(m.dataDir, "instances", instanceName)
+	manifest, err := LoadManifest(filepath.Join(instanceDir, "setup", "cluster-services", serviceName))
+	if err != nil {
+		return nil, err
+	}
+
+	mappings := manifest.SecretMappings()
+	if len(mappings) == 0 {
+		return nil, nil
+	}
+
+	store, err := secrets.NewManager().Open(instanceDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open secrets: %w", err)
+	}
+
+	results, err := secrets.SyncKubernetesSecrets(store, tools.GetKubeconfigPath(m.dataDir, instanceName),
+		mappings, secrets.SyncOptions{CreateNamespaces: createNamespaces})
+	for i := range results {
+		results[i].Source = "service/" + serviceName
+	}
+	return results, err
+}
+
 // validateConfig checks that all required config is set for a service
 func (m *Manager) validateConfig(instanceName, serviceName string) error {
 	manifest, err := m.GetManifest(serviceName)
@@ -629,3 +693,22 @@ func getNestedValue(data map[string]interface{}, path string) interface{} {
 
 	return nil
 }
+
+// readInstanceSecrets resolves an instance's secrets through its configured
+// backend. Returns nil when the instance has no secrets yet.
+func readInstanceSecrets(instanceDir string) ([]byte, error) {
+	store, err := secrets.NewManager().Open(instanceDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open secrets: %w", err)
+	}
+
+	data, err := store.Read()
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read secrets: %w", err)
+	}
+
+	return data, nil
+}
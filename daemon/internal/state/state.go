@@ -0,0 +1,411 @@
+// Package state is a small embedded store for the daemon's runtime state:
+// operation records, discovery status and backup metadata. User-editable
+// files (config.yaml, secrets.yaml) are not kept here.
+//
+// A store is a bbolt database file. Every transaction is atomic and durable
+// once it returns, so a crash never leaves a half-applied change. The file is
+// opened for each transaction and closed after it, so a store can move with
+// its instance to the trash and back while the daemon runs.
+//
+// Records are JSON values in named buckets. Each record can carry index
+// fields (for example type and status) that Find queries without decoding
+// every record.
+package state
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/wild-cloud/wild-central/daemon/internal/storage"
+)
+
+// Nested buckets of each record bucket
+var (
+	recordsBucket = []byte("records")
+	indexBucket   = []byte("index")
+)
+
+// Index holds a record's index fields
+type Index map[string]string
+
+// Query matches records whose index fields have all the given values
+type Query map[string]string
+
+// Record is a stored value with its key and index fields
+type Record struct {
+	Key   string          `json:"k"`
+	Value json.RawMessage `json:"v"`
+	Index Index           `json:"i,omitempty"`
+}
+
+// Decode unmarshals the record's value into v
+func (r Record) Decode(v interface{}) error {
+	if err := json.Unmarshal(r.Value, v); err != nil {
+		return fmt.Errorf("decoding %s: %w", r.Key, err)
+	}
+	return nil
+}
+
+// indexKey is the key of an index entry: the field, value and record key,
+// so the keys with a given field value share a prefix and sort by key
+func indexKey(field, value, key string) []byte {
+	return append(indexPrefix(field, value), key...)
+}
+
+func indexPrefix(field, value string) []byte {
+	return []byte(field + "=" + value + "\x00")
+}
+
+// DB is a store
+type DB struct {
+	path string
+
+	// Transactions in this process wait here rather than polling the file lock
+	mu sync.RWMutex
+
+	onceMu sync.Mutex
+	done   map[string]bool // Names of the Once calls that succeeded
+}
+
+var (
+	openMu sync.Mutex
+	open   = make(map[string]*DB)
+)
+
+// Open returns the store at path. The file is created on the first write.
+func Open(path string) (*DB, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("resolving state path %s: %w", path, err)
+	}
+
+	openMu.Lock()
+	defer openMu.Unlock()
+
+	if db, ok := open[abs]; ok {
+		return db, nil
+	}
+	db := &DB{path: abs}
+	open[abs] = db
+	return db, nil
+}
+
+// InstancePath returns the state store of an instance. It lives in the
+// instance directory so it moves with the instance to the trash and back.
+func InstancePath(dataDir, instanceName string) string {
+	return filepath.Join(dataDir, "instances", instanceName, "state.db")
+}
+
+// Path returns the store's file
+func (db *DB) Path() string {
+	return db.path
+}
+
+// openFile opens the database file, waiting at most storage.LockTimeout for
+// another process using it. A read-only open of a missing file returns nil.
+func (db *DB) openFile(writable bool) (*bolt.DB, error) {
+	timeout, err := storage.LockTimeout()
+	if err != nil {
+		timeout = storage.DefaultLockTimeout
+	}
+
+	created := false
+	if writable {
+		if err := storage.EnsureDir(filepath.Dir(db.path), 0755); err != nil {
+			return nil, err
+		}
+		if _, err := os.Stat(db.path); os.IsNotExist(err) {
+			created = true
+		}
+	}
+
+	bdb, err := bolt.Open(db.path, 0600, &bolt.Options{Timeout: timeout, ReadOnly: !writable})
+	if err != nil {
+		if !writable && errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("opening state %s: %w", db.path, err)
+	}
+	if created {
+		if err := storage.SyncDir(filepath.Dir(db.path)); err != nil {
+			bdb.Close()
+			return nil, err
+		}
+	}
+	return bdb, nil
+}
+
+// View runs fn with a read-only transaction
+func (db *DB) View(fn func(tx *Tx) error) error {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	bdb, err := db.openFile(false)
+	if err != nil {
+		return err
+	}
+	if bdb == nil {
+		return fn(&Tx{})
+	}
+	defer bdb.Close()
+
+	return bdb.View(func(btx *bolt.Tx) error {
+		return fn(&Tx{tx: btx})
+	})
+}
+
+// Update runs fn with a read-write transaction. Its writes are committed
+// together when fn returns nil and discarded when it returns an error.
+func (db *DB) Update(fn func(tx *Tx) error) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	bdb, err := db.openFile(true)
+	if err != nil {
+		return err
+	}
+
+	err = bdb.Update(func(btx *bolt.Tx) error {
+		return fn(&Tx{tx: btx})
+	})
+	if closeErr := bdb.Close(); err == nil && closeErr != nil {
+		return fmt.Errorf("closing state %s: %w", db.path, closeErr)
+	}
+	return err
+}
+
+// Migrate runs fn once for the store, recording name so later calls skip it.
+// It reports whether fn ran and was committed.
+func (db *DB) Migrate(name string, fn func(tx *Tx) error) (bool, error) {
+	ran := false
+	err := db.Update(func(tx *Tx) error {
+		if tx.Exists(migrationsBucket, name) {
+			return nil
+		}
+		if err := fn(tx); err != nil {
+			return fmt.Errorf("migration %s: %w", name, err)
+		}
+		ran = true
+		return tx.Put(migrationsBucket, name, true, nil)
+	})
+	if err != nil {
+		return false, err
+	}
+	return ran, nil
+}
+
+// Once runs fn the first time it is called with name on the open store, for
+// work such as importing files left by earlier versions that only needs
+// checking once per process. A failed fn runs again on the next call.
+func (db *DB) Once(name string, fn func() error) error {
+	db.onceMu.Lock()
+	defer db.onceMu.Unlock()
+
+	if db.done[name] {
+		return nil
+	}
+	if err := fn(); err != nil {
+		return err
+	}
+	if db.done == nil {
+		db.done = make(map[string]bool)
+	}
+	db.done[name] = true
+	return nil
+}
+
+// migrationsBucket records the migrations that have run
+const migrationsBucket = "_migrations"
+
+// Tx is a transaction. Reads see the transaction's own writes.
+type Tx struct {
+	tx *bolt.Tx // nil when the store has no file yet
+}
+
+// bucket returns the nested records and index buckets of a bucket, nil when
+// it does not exist
+func (tx *Tx) bucket(bucketName string) (records, index *bolt.Bucket) {
+	if tx.tx == nil {
+		return nil, nil
+	}
+	b := tx.tx.Bucket([]byte(bucketName))
+	if b == nil {
+		return nil, nil
+	}
+	return b.Bucket(recordsBucket), b.Bucket(indexBucket)
+}
+
+func (tx *Tx) writableBucket(bucketName string) (records, index *bolt.Bucket, err error) {
+	b, err := tx.tx.CreateBucketIfNotExists([]byte(bucketName))
+	if err != nil {
+		return nil, nil, fmt.Errorf("creating bucket %s: %w", bucketName, err)
+	}
+	if records, err = b.CreateBucketIfNotExists(recordsBucket); err != nil {
+		return nil, nil, fmt.Errorf("creating bucket %s: %w", bucketName, err)
+	}
+	if index, err = b.CreateBucketIfNotExists(indexBucket); err != nil {
+		return nil, nil, fmt.Errorf("creating bucket %s: %w", bucketName, err)
+	}
+	return records, index, nil
+}
+
+func (tx *Tx) writable() bool {
+	return tx.tx != nil && tx.tx.Writable()
+}
+
+func decodeRecord(data []byte) (Record, bool) {
+	var rec Record
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return Record{}, false
+	}
+	return rec, true
+}
+
+// Get decodes the record at key into v and reports whether it exists
+func (tx *Tx) Get(bucketName, key string, v interface{}) (bool, error) {
+	records, _ := tx.bucket(bucketName)
+	if records == nil {
+		return false, nil
+	}
+	data := records.Get([]byte(key))
+	if data == nil {
+		return false, nil
+	}
+	rec, ok := decodeRecord(data)
+	if !ok {
+		return true, fmt.Errorf("decoding %s: corrupt record", key)
+	}
+	return true, rec.Decode(v)
+}
+
+// Exists reports whether a record exists
+func (tx *Tx) Exists(bucketName, key string) bool {
+	records, _ := tx.bucket(bucketName)
+	return records != nil && records.Get([]byte(key)) != nil
+}
+
+// Put stores v as JSON at key with the given index fields
+func (tx *Tx) Put(bucketName, key string, v interface{}, index Index) error {
+	if !tx.writable() {
+		return fmt.Errorf("put in a read-only transaction")
+	}
+	if key == "" {
+		return fmt.Errorf("key cannot be empty")
+	}
+	value, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("encoding %s: %w", key, err)
+	}
+	data, err := json.Marshal(Record{Key: key, Value: value, Index: index})
+	if err != nil {
+		return fmt.Errorf("encoding %s: %w", key, err)
+	}
+
+	records, idx, err := tx.writableBucket(bucketName)
+	if err != nil {
+		return err
+	}
+	if err := removeIndex(records, idx, key); err != nil {
+		return err
+	}
+	if err := records.Put([]byte(key), data); err != nil {
+		return fmt.Errorf("writing %s: %w", key, err)
+	}
+	for field, value := range index {
+		if err := idx.Put(indexKey(field, value, key), []byte{}); err != nil {
+			return fmt.Errorf("indexing %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// Delete removes the record at key, if any
+func (tx *Tx) Delete(bucketName, key string) error {
+	if !tx.writable() {
+		return fmt.Errorf("delete in a read-only transaction")
+	}
+	records, idx := tx.bucket(bucketName)
+	if records == nil {
+		return nil
+	}
+	if err := removeIndex(records, idx, key); err != nil {
+		return err
+	}
+	if err := records.Delete([]byte(key)); err != nil {
+		return fmt.Errorf("deleting %s: %w", key, err)
+	}
+	return nil
+}
+
+// removeIndex removes the index entries of the record at key, if any
+func removeIndex(records, idx *bolt.Bucket, key string) error {
+	data := records.Get([]byte(key))
+	if data == nil {
+		return nil
+	}
+	old, ok := decodeRecord(data)
+	if !ok {
+		return nil
+	}
+	for field, value := range old.Index {
+		if err := idx.Delete(indexKey(field, value, key)); err != nil {
+			return fmt.Errorf("unindexing %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// Find returns the records matching q, ordered by key. An empty query
+// returns every record in the bucket.
+func (tx *Tx) Find(bucketName string, q Query) []Record {
+	found := []Record{}
+	records, idx := tx.bucket(bucketName)
+	if records == nil {
+		return found
+	}
+
+	if len(q) == 0 {
+		records.ForEach(func(_, data []byte) error {
+			if rec, ok := decodeRecord(data); ok {
+				found = append(found, rec)
+			}
+			return nil
+		})
+		return found
+	}
+
+	// Walk the index entries of one field and check the others on the record
+	fields := make([]string, 0, len(q))
+	for field := range q {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	prefix := indexPrefix(fields[0], q[fields[0]])
+	c := idx.Cursor()
+	for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+		rec, ok := decodeRecord(records.Get(k[len(prefix):]))
+		if !ok {
+			continue
+		}
+		match := true
+		for _, field := range fields[1:] {
+			if rec.Index[field] != q[field] {
+				match = false
+				break
+			}
+		}
+		if match {
+			found = append(found, rec)
+		}
+	}
+	return found
+}
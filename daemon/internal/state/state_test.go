@@ -0,0 +1,199 @@
+package state
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type item struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+}
+
+// reopen returns a new handle on the store, as a restarted daemon would
+func reopen(t *testing.T, db *DB) *DB {
+	t.Helper()
+	return &DB{path: db.path}
+}
+
+func putItems(t *testing.T, db *DB, items ...item) {
+	t.Helper()
+	err := db.Update(func(tx *Tx) error {
+		for _, it := range items {
+			if err := tx.Put("items", it.Name, it, Index{"status": it.Status}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+}
+
+func TestDB_PutFindPersist(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "state.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	putItems(t, db, item{"a", "running"}, item{"b", "completed"}, item{"c", "running"})
+	putItems(t, db, item{"a", "completed"})
+	db.Update(func(tx *Tx) error { return tx.Delete("items", "b") })
+
+	for _, d := range []*DB{db, reopen(t, db)} {
+		d.View(func(tx *Tx) error {
+			running := tx.Find("items", Query{"status": "running"})
+			if len(running) != 1 || running[0].Key != "c" {
+				t.Errorf("running = %+v, want only c", running)
+			}
+			completed := tx.Find("items", Query{"status": "completed"})
+			if len(completed) != 1 || completed[0].Key != "a" {
+				t.Errorf("completed = %+v, want only a", completed)
+			}
+
+			var got item
+			if ok, err := tx.Get("items", "a", &got); !ok || err != nil || got.Status != "completed" {
+				t.Errorf("Get(a) = %+v, %v, %v", got, ok, err)
+			}
+			if tx.Exists("items", "b") {
+				t.Error("deleted record still exists")
+			}
+			return nil
+		})
+	}
+}
+
+func TestDB_FailedTransactionDiscarded(t *testing.T) {
+	db, _ := Open(filepath.Join(t.TempDir(), "state.db"))
+	putItems(t, db, item{"a", "running"})
+
+	err := db.Update(func(tx *Tx) error {
+		tx.Put("items", "b", item{"b", "running"}, nil)
+		return errors.New("abort")
+	})
+	if err == nil {
+		t.Fatal("expected the transaction error")
+	}
+
+	db.View(func(tx *Tx) error {
+		if tx.Exists("items", "b") {
+			t.Error("write from a failed transaction is visible")
+		}
+		return nil
+	})
+}
+
+func TestDB_FindMultipleFields(t *testing.T) {
+	db, _ := Open(filepath.Join(t.TempDir(), "state.db"))
+	err := db.Update(func(tx *Tx) error {
+		tx.Put("items", "a", item{"a", "running"}, Index{"status": "running", "type": "backup"})
+		tx.Put("items", "b", item{"b", "running"}, Index{"status": "running", "type": "deploy"})
+		tx.Put("items", "c", item{"c", "failed"}, Index{"status": "failed", "type": "backup"})
+
+		// Reads in the transaction see its writes
+		if got := tx.Find("items", Query{"type": "backup"}); len(got) != 2 {
+			t.Errorf("backups in transaction = %+v, want a and c", got)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db.View(func(tx *Tx) error {
+		got := tx.Find("items", Query{"status": "running", "type": "backup"})
+		if len(got) != 1 || got[0].Key != "a" {
+			t.Errorf("running backups = %+v, want only a", got)
+		}
+		if got := tx.Find("items", Query{"status": "completed"}); len(got) != 0 {
+			t.Errorf("completed = %+v, want none", got)
+		}
+		return nil
+	})
+}
+
+func TestDB_ViewWithoutFile(t *testing.T) {
+	db, _ := Open(filepath.Join(t.TempDir(), "state.db"))
+
+	err := db.View(func(tx *Tx) error {
+		if len(tx.Find("items", nil)) != 0 || tx.Exists("items", "a") {
+			t.Error("records found in a missing store")
+		}
+		if tx.Put("items", "a", item{"a", "running"}, nil) == nil {
+			t.Error("put succeeded in a read-only transaction")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(db.Path()); !os.IsNotExist(err) {
+		t.Errorf("View created the store file: %v", err)
+	}
+}
+
+func TestDB_Once(t *testing.T) {
+	db, _ := Open(filepath.Join(t.TempDir(), "state.db"))
+
+	calls := 0
+	fail := errors.New("import failed")
+	if err := db.Once("import", func() error { calls++; return fail }); err != fail {
+		t.Fatalf("Once = %v, want the error", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := db.Once("import", func() error { calls++; return nil }); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if calls != 2 {
+		t.Errorf("fn ran %d times, want a retry after the failure and then none", calls)
+	}
+}
+
+func TestDB_FollowsMovedFile(t *testing.T) {
+	dir := t.TempDir()
+	db, _ := Open(filepath.Join(dir, "state.db"))
+	putItems(t, db, item{"a", "running"})
+
+	// The file moves away (instance trashed), then comes back (restored)
+	moved := filepath.Join(dir, "moved.db")
+	os.Rename(db.path, moved)
+	db.View(func(tx *Tx) error {
+		if tx.Exists("items", "a") {
+			t.Error("record still visible after the file was moved")
+		}
+		return nil
+	})
+
+	os.Rename(moved, db.path)
+	db.View(func(tx *Tx) error {
+		if !tx.Exists("items", "a") {
+			t.Error("record not visible after the file was restored")
+		}
+		return nil
+	})
+}
+
+func TestDB_Migrate(t *testing.T) {
+	db, _ := Open(filepath.Join(t.TempDir(), "state.db"))
+
+	runs := 0
+	for i := 0; i < 2; i++ {
+		ran, err := db.Migrate("import", func(tx *Tx) error {
+			runs++
+			return tx.Put("items", "a", item{"a", "completed"}, nil)
+		})
+		if err != nil {
+			t.Fatalf("Migrate failed: %v", err)
+		}
+		if ran != (i == 0) {
+			t.Errorf("run %d: ran = %v", i, ran)
+		}
+	}
+	if runs != 1 {
+		t.Errorf("migration ran %d times, want 1", runs)
+	}
+}
@@ -0,0 +1,304 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// EnvLockTimeout overrides how long WithLock waits for a lock, as a Go duration
+const EnvLockTimeout = "WILD_CENTRAL_LOCK_TIMEOUT"
+
+// DefaultLockTimeout is how long WithLock waits for a lock by default. It is
+// shorter than the CLI's request timeout so a stuck holder surfaces as a lock
+// error rather than a hung request.
+const DefaultLockTimeout = 15 * time.Second
+
+// ErrLockTimeout is returned, wrapped in a LockError, when a lock is not
+// released in time
+var ErrLockTimeout = errors.New("timed out waiting for lock")
+
+// LockHolder describes who holds a lock. It is written into the lock file
+// while the lock is held.
+type LockHolder struct {
+	Path        string    `json:"path"`
+	PID         int       `json:"pid"`
+	OperationID string    `json:"operation_id,omitempty"`
+	AcquiredAt  time.Time `json:"acquired_at"`
+}
+
+// LockError is returned when a lock cannot be taken. Holder is nil when the
+// holder did not record itself.
+type LockError struct {
+	Path   string
+	Holder *LockHolder
+	Err    error // ErrLockTimeout or the context's error
+}
+
+func (e *LockError) Error() string {
+	msg := fmt.Sprintf("lock %s: %v", e.Path, e.Err)
+	if h := e.Holder; h != nil {
+		msg += fmt.Sprintf(" (held by pid %d", h.PID)
+		if h.OperationID != "" {
+			msg += ", operation " + h.OperationID
+		}
+		msg += fmt.Sprintf(" since %s)", h.AcquiredAt.Format(time.RFC3339))
+	}
+	return msg
+}
+
+func (e *LockError) Unwrap() error {
+	return e.Err
+}
+
+// Lock represents a file lock
+type Lock struct {
+	file *os.File
+	path string
+}
+
+// held tracks the locks this process holds, for introspection
+var (
+	heldMu sync.Mutex
+	held   = make(map[string]LockHolder)
+)
+
+type operationKey struct{}
+
+// ContextWithOperation returns a context whose locks are recorded as held by
+// an operation
+func ContextWithOperation(ctx context.Context, operationID string) context.Context {
+	return context.WithValue(ctx, operationKey{}, operationID)
+}
+
+// LockTimeout returns how long WithLock waits for a lock
+func LockTimeout() (time.Duration, error) {
+	value := strings.TrimSpace(os.Getenv(EnvLockTimeout))
+	if value == "" {
+		return DefaultLockTimeout, nil
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid %s %q", EnvLockTimeout, value)
+	}
+	return d, nil
+}
+
+// AcquireLock acquires an exclusive lock on a file, waiting at most LockTimeout
+func AcquireLock(lockPath string) (*Lock, error) {
+	timeout, err := LockTimeout()
+	if err != nil {
+		timeout = DefaultLockTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return AcquireLockContext(ctx, lockPath)
+}
+
+// AcquireOperationLock acquires an exclusive lock on behalf of an operation,
+// waiting at most LockTimeout. The holder record names the operation, so
+// whoever times out on the lock learns which operation to wait for.
+func AcquireOperationLock(lockPath, operationID string) (*Lock, error) {
+	timeout, err := LockTimeout()
+	if err != nil {
+		timeout = DefaultLockTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(ContextWithOperation(context.Background(), operationID), timeout)
+	defer cancel()
+	return AcquireLockContext(ctx, lockPath)
+}
+
+// AcquireLockContext acquires an exclusive lock on a file, giving up with a
+// LockError when ctx is done
+func AcquireLockContext(ctx context.Context, lockPath string) (*Lock, error) {
+	// Ensure lock directory exists
+	if err := EnsureDir(filepath.Dir(lockPath), 0755); err != nil {
+		return nil, err
+	}
+
+	// Open or create lock file
+	file, err := os.OpenFile(lockPath, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("opening lock file %s: %w", lockPath, err)
+	}
+
+	// flock cannot be interrupted, so poll without blocking until ctx is done
+	wait := 5 * time.Millisecond
+	for {
+		err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, syscall.EWOULDBLOCK) && !errors.Is(err, syscall.EINTR) {
+			file.Close()
+			return nil, fmt.Errorf("acquiring lock on %s: %w", lockPath, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			file.Close()
+			cause := ctx.Err()
+			if errors.Is(cause, context.DeadlineExceeded) {
+				cause = ErrLockTimeout
+			}
+			holder, _ := ReadLockHolder(lockPath)
+			return nil, &LockError{Path: lockPath, Holder: holder, Err: cause}
+		case <-time.After(wait):
+		}
+		if wait < 100*time.Millisecond {
+			wait *= 2
+		}
+	}
+
+	holder := LockHolder{Path: lockPath, PID: os.Getpid(), AcquiredAt: time.Now().UTC()}
+	if opID, ok := ctx.Value(operationKey{}).(string); ok {
+		holder.OperationID = opID
+	}
+	// The record is informational; the lock is held even if it cannot be written
+	if data, err := json.Marshal(holder); err == nil {
+		if file.Truncate(0) == nil {
+			file.WriteAt(data, 0)
+		}
+	}
+
+	heldMu.Lock()
+	held[lockPath] = holder
+	heldMu.Unlock()
+
+	return &Lock{
+		file: file,
+		path: lockPath,
+	}, nil
+}
+
+// Release releases the file lock
+func (l *Lock) Release() error {
+	if l.file == nil {
+		return nil
+	}
+
+	heldMu.Lock()
+	delete(held, l.path)
+	heldMu.Unlock()
+
+	// Clear the holder record before unlocking so it never outlives the lock
+	l.file.Truncate(0)
+
+	// Release flock
+	if err := syscall.Flock(int(l.file.Fd()), syscall.LOCK_UN); err != nil {
+		l.file.Close()
+		return fmt.Errorf("releasing lock on %s: %w", l.path, err)
+	}
+
+	// Close file
+	if err := l.file.Close(); err != nil {
+		return fmt.Errorf("closing lock file %s: %w", l.path, err)
+	}
+
+	l.file = nil
+	return nil
+}
+
+// WithLock executes a function while holding a lock, waiting at most
+// LockTimeout for it
+func WithLock(lockPath string, fn func() error) error {
+	lock, err := AcquireLock(lockPath)
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+
+	return fn()
+}
+
+// WithLockContext executes a function while holding a lock, waiting for it
+// until ctx is done
+func WithLockContext(ctx context.Context, lockPath string, fn func() error) error {
+	lock, err := AcquireLockContext(ctx, lockPath)
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+
+	return fn()
+}
+
+// HeldLocks returns the locks this process holds, oldest first
+func HeldLocks() []LockHolder {
+	heldMu.Lock()
+	defer heldMu.Unlock()
+
+	locks := make([]LockHolder, 0, len(held))
+	for _, holder := range held {
+		locks = append(locks, holder)
+	}
+	sort.Slice(locks, func(i, j int) bool {
+		return locks[i].AcquiredAt.Before(locks[j].AcquiredAt)
+	})
+	return locks
+}
+
+// ReadLockHolder returns the holder recorded in a lock file, or nil if none
+// is recorded
+func ReadLockHolder(lockPath string) (*LockHolder, error) {
+	data, err := os.ReadFile(lockPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("reading lock file %s: %w", lockPath, err)
+	}
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	var holder LockHolder
+	if err := json.Unmarshal(data, &holder); err != nil {
+		return nil, fmt.Errorf("parsing lock file %s: %w", lockPath, err)
+	}
+	return &holder, nil
+}
+
+// ClearStaleLock clears the holder recorded in a lock file that nobody
+// holds, left behind by a process that exited without releasing it. It
+// reports whether there was (or, with dryRun, is) a record to clear. The
+// record is cleared while holding the lock. The file itself is never removed,
+// as a process waiting on it would then hold a lock nobody else can see.
+func ClearStaleLock(lockPath string, dryRun bool) (bool, error) {
+	file, err := os.OpenFile(lockPath, os.O_RDWR, 0)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("opening lock file %s: %w", lockPath, err)
+	}
+	defer file.Close()
+
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		// Held by someone
+		return false, nil
+	}
+	defer syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+
+	if holder, err := ReadLockHolder(lockPath); err == nil && holder == nil {
+		return false, nil
+	}
+	if dryRun {
+		return true, nil
+	}
+	if err := file.Truncate(0); err != nil {
+		return false, fmt.Errorf("clearing lock file %s: %w", lockPath, err)
+	}
+	return true, nil
+}
@@ -0,0 +1,119 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAcquireLockContext_Timeout(t *testing.T) {
+	lockPath := filepath.Join(t.TempDir(), "config.yaml.lock")
+
+	lock, err := AcquireOperationLock(lockPath, "op_123")
+	if err != nil {
+		t.Fatalf("AcquireOperationLock failed: %v", err)
+	}
+
+	held := HeldLocks()
+	if len(held) != 1 || held[0].Path != lockPath || held[0].OperationID != "op_123" || held[0].PID != os.Getpid() {
+		t.Errorf("unexpected held locks: %+v", held)
+	}
+
+	waitCtx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = AcquireLockContext(waitCtx, lockPath)
+
+	var lockErr *LockError
+	if !errors.As(err, &lockErr) {
+		t.Fatalf("expected a LockError, got %v", err)
+	}
+	if !errors.Is(err, ErrLockTimeout) {
+		t.Errorf("expected ErrLockTimeout, got %v", err)
+	}
+	if lockErr.Holder == nil || lockErr.Holder.OperationID != "op_123" {
+		t.Errorf("holder not reported: %+v", lockErr.Holder)
+	}
+
+	if err := lock.Release(); err != nil {
+		t.Fatalf("Release failed: %v", err)
+	}
+	if holder, _ := ReadLockHolder(lockPath); holder != nil {
+		t.Errorf("holder record left after release: %+v", holder)
+	}
+	if len(HeldLocks()) != 0 {
+		t.Errorf("lock still listed after release: %+v", HeldLocks())
+	}
+
+	// Free again
+	if err := WithLock(lockPath, func() error { return nil }); err != nil {
+		t.Errorf("WithLock after release failed: %v", err)
+	}
+}
+
+func TestAcquireLock_WaitsForRelease(t *testing.T) {
+	lockPath := filepath.Join(t.TempDir(), "test.lock")
+
+	lock, err := AcquireLock(lockPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		time.Sleep(30 * time.Millisecond)
+		lock.Release()
+	}()
+
+	if err := WithLock(lockPath, func() error { return nil }); err != nil {
+		t.Errorf("WithLock did not get the released lock: %v", err)
+	}
+}
+
+func TestLockTimeout(t *testing.T) {
+	t.Setenv(EnvLockTimeout, "")
+	if d, err := LockTimeout(); err != nil || d != DefaultLockTimeout {
+		t.Errorf("LockTimeout() = %v, %v; want default", d, err)
+	}
+	t.Setenv(EnvLockTimeout, "2s")
+	if d, err := LockTimeout(); err != nil || d != 2*time.Second {
+		t.Errorf("LockTimeout() = %v, %v; want 2s", d, err)
+	}
+	t.Setenv(EnvLockTimeout, "0s")
+	if _, err := LockTimeout(); err == nil {
+		t.Error("expected an error for a zero timeout")
+	}
+}
+
+func TestClearStaleLock(t *testing.T) {
+	lockPath := filepath.Join(t.TempDir(), "test.lock")
+
+	// A record left by a holder that exited without releasing is stale
+	data, _ := json.Marshal(LockHolder{Path: lockPath, PID: 1 << 30, AcquiredAt: time.Now()})
+	os.WriteFile(lockPath, data, 0644)
+
+	stale, err := ClearStaleLock(lockPath, false)
+	if err != nil || !stale {
+		t.Fatalf("ClearStaleLock = %v, %v; want cleared", stale, err)
+	}
+	if !FileExists(lockPath) {
+		t.Error("lock file removed")
+	}
+	if holder, _ := ReadLockHolder(lockPath); holder != nil {
+		t.Errorf("holder record not cleared: %+v", holder)
+	}
+
+	// A held lock is never touched
+	lock, err := AcquireLock(lockPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lock.Release()
+	if stale, _ := ClearStaleLock(lockPath, false); stale {
+		t.Error("held lock reported stale")
+	}
+	if holder, _ := ReadLockHolder(lockPath); holder == nil {
+		t.Error("record of a held lock cleared")
+	}
+}
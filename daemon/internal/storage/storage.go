@@ -2,9 +2,9 @@ package storage
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
-	"syscall"
 )
 
 // EnsureDir creates a directory with specified permissions if it doesn't exist
@@ -21,84 +21,154 @@ func FileExists(path string) bool {
 	return err == nil
 }
 
-// WriteFile writes content to a file with specified permissions
+// Hooks for the steps of an atomic write, replaced in tests to simulate failures
+var (
+	writeData  = func(f *os.File, data []byte) (int, error) { return f.Write(data) }
+	syncFile   = func(f *os.File) error { return f.Sync() }
+	renameFile = os.Rename
+)
+
+// WriteFile atomically replaces a file with content. The content is written
+// to a temporary file in the same directory, synced, and renamed over path,
+// so after a crash or a failed write path holds either its old or its new
+// content in full, never a truncated mix. An existing file keeps its mode;
+// perm only applies to new files, as with os.WriteFile.
 func WriteFile(path string, content []byte, perm os.FileMode) error {
-	if err := os.WriteFile(path, content, perm); err != nil {
-		return fmt.Errorf("writing file %s: %w", path, err)
-	}
-	return nil
+	return writeAtomic(path, perm, func(f *os.File) error {
+		n, err := writeData(f, content)
+		if err == nil && n < len(content) {
+			err = io.ErrShortWrite
+		}
+		return err
+	})
 }
 
-// ReadFile reads content from a file
-func ReadFile(path string) ([]byte, error) {
-	content, err := os.ReadFile(path)
-	if err != nil {
-		return nil, fmt.Errorf("reading file %s: %w", path, err)
-	}
-	return content, nil
+// WriteFileFrom atomically replaces a file with everything read from r, with
+// the same guarantees as WriteFile
+func WriteFileFrom(path string, r io.Reader, perm os.FileMode) error {
+	return writeAtomic(path, perm, func(f *os.File) error {
+		_, err := io.Copy(f, r)
+		return err
+	})
 }
 
-// Lock represents a file lock
-type Lock struct {
-	file *os.File
-	path string
-}
+// EditFile atomically applies an in-place edit, such as 'yq -i', to a file.
+// edit is run on a private copy in the same directory and the result
+// replaces path as WriteFile does, so readers and crashes never see a file
+// the editor has only partly rewritten.
+func EditFile(path string, edit func(copyPath string) error) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading file %s: %w", path, err)
+	}
 
-// AcquireLock acquires an exclusive lock on a file
-func AcquireLock(lockPath string) (*Lock, error) {
-	// Ensure lock directory exists
-	if err := EnsureDir(filepath.Dir(lockPath), 0755); err != nil {
-		return nil, err
+	tmp, err := os.CreateTemp(filepath.Dir(path), TempFilePrefix(filepath.Base(path))+"*")
+	if err != nil {
+		return fmt.Errorf("copying file %s: %w", path, err)
 	}
+	copyPath := tmp.Name()
+	defer os.Remove(copyPath)
 
-	// Open or create lock file
-	file, err := os.OpenFile(lockPath, os.O_CREATE|os.O_RDWR, 0644)
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
 	if err != nil {
-		return nil, fmt.Errorf("opening lock file %s: %w", lockPath, err)
+		return fmt.Errorf("copying file %s: %w", path, err)
 	}
 
-	// Acquire exclusive lock with flock
-	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX); err != nil {
-		file.Close()
-		return nil, fmt.Errorf("acquiring lock on %s: %w", lockPath, err)
+	if err := edit(copyPath); err != nil {
+		return err
 	}
 
-	return &Lock{
-		file: file,
-		path: lockPath,
-	}, nil
+	edited, err := os.ReadFile(copyPath)
+	if err != nil {
+		return fmt.Errorf("reading edited copy of %s: %w", path, err)
+	}
+	return WriteFile(path, edited, 0600)
 }
 
-// Release releases the file lock
-func (l *Lock) Release() error {
-	if l.file == nil {
-		return nil
+// writeAtomic writes a file through a synced temporary file and a rename,
+// then syncs the directory so the rename itself survives a crash
+func writeAtomic(path string, perm os.FileMode, write func(*os.File) error) error {
+	// Replace the file a symlink points to, not the symlink
+	target := path
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		target = resolved
 	}
 
-	// Release flock
-	if err := syscall.Flock(int(l.file.Fd()), syscall.LOCK_UN); err != nil {
-		l.file.Close()
-		return fmt.Errorf("releasing lock on %s: %w", l.path, err)
+	mode := perm
+	if info, err := os.Stat(target); err == nil {
+		if !info.Mode().IsRegular() {
+			return fmt.Errorf("writing file %s: not a regular file", path)
+		}
+		mode = info.Mode().Perm()
 	}
 
-	// Close file
-	if err := l.file.Close(); err != nil {
-		return fmt.Errorf("closing lock file %s: %w", l.path, err)
+	dir := filepath.Dir(target)
+	tmp, err := os.CreateTemp(dir, TempFilePrefix(filepath.Base(target))+"*")
+	if err != nil {
+		return fmt.Errorf("writing file %s: %w", path, err)
 	}
+	tmpPath := tmp.Name()
 
-	l.file = nil
+	fail := func(err error) error {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("writing file %s: %w", path, err)
+	}
+
+	if err := tmp.Chmod(mode); err != nil {
+		return fail(err)
+	}
+	if err := write(tmp); err != nil {
+		return fail(err)
+	}
+	if err := syncFile(tmp); err != nil {
+		return fail(err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("writing file %s: %w", path, err)
+	}
+	if err := renameFile(tmpPath, target); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("writing file %s: %w", path, err)
+	}
+
+	if err := SyncDir(dir); err != nil {
+		return fmt.Errorf("writing file %s: %w", path, err)
+	}
 	return nil
 }
 
-// WithLock executes a function while holding a lock
-func WithLock(lockPath string, fn func() error) error {
-	lock, err := AcquireLock(lockPath)
+// TempFilePrefix returns the name prefix of the temporary files WriteFile
+// creates for a file. Leftovers with this prefix are from interrupted writes.
+func TempFilePrefix(name string) string {
+	return "." + name + ".tmp-"
+}
+
+// SyncDir flushes a directory's entries, making renames and new files in it durable
+func SyncDir(dir string) error {
+	d, err := os.Open(dir)
 	if err != nil {
-		return err
+		return fmt.Errorf("opening directory %s: %w", dir, err)
+	}
+	defer d.Close()
+
+	if err := d.Sync(); err != nil {
+		return fmt.Errorf("syncing directory %s: %w", dir, err)
 	}
-	defer lock.Release()
+	return nil
+}
 
-	return fn()
+// ReadFile reads content from a file
+func ReadFile(path string) ([]byte, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading file %s: %w", path, err)
+	}
+	return content, nil
 }
 
 // EnsureFilePermissions ensures a file has the correct permissions
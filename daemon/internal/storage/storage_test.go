@@ -1,9 +1,13 @@
 package storage
 
 import (
+	"errors"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"testing/iotest"
 )
 
 func TestEnsureDir(t *testing.T) {
@@ -53,6 +57,163 @@ func TestWriteFile(t *testing.T) {
 	}
 }
 
+func TestWriteFile_PreservesMode(t *testing.T) {
+	tmpDir := t.TempDir()
+	testFile := filepath.Join(tmpDir, "secrets.yaml")
+
+	if err := WriteFile(testFile, []byte("new"), 0600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if info, _ := os.Stat(testFile); info.Mode().Perm() != 0600 {
+		t.Errorf("new file mode = %04o, want 0600", info.Mode().Perm())
+	}
+
+	os.Chmod(testFile, 0640)
+	if err := WriteFile(testFile, []byte("replaced"), 0600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if info, _ := os.Stat(testFile); info.Mode().Perm() != 0640 {
+		t.Errorf("existing file mode = %04o, want 0640 kept", info.Mode().Perm())
+	}
+}
+
+func TestWriteFile_FollowsSymlink(t *testing.T) {
+	tmpDir := t.TempDir()
+	target := filepath.Join(tmpDir, "target.yaml")
+	link := filepath.Join(tmpDir, "link.yaml")
+	os.WriteFile(target, []byte("old"), 0644)
+	os.Symlink(target, link)
+
+	if err := WriteFile(link, []byte("new"), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if info, _ := os.Lstat(link); info.Mode()&os.ModeSymlink == 0 {
+		t.Error("symlink was replaced by a file")
+	}
+	if data, _ := os.ReadFile(target); string(data) != "new" {
+		t.Errorf("target = %q, want new", data)
+	}
+}
+
+func TestWriteFile_FailureKeepsOriginal(t *testing.T) {
+	failures := []struct {
+		name  string
+		setup func() func()
+	}{
+		{"partial write", func() func() {
+			orig := writeData
+			writeData = func(f *os.File, data []byte) (int, error) {
+				n, _ := f.Write(data[:len(data)/2])
+				return n, errors.New("no space left on device")
+			}
+			return func() { writeData = orig }
+		}},
+		{"short write", func() func() {
+			orig := writeData
+			writeData = func(f *os.File, data []byte) (int, error) {
+				return f.Write(data[:1])
+			}
+			return func() { writeData = orig }
+		}},
+		{"sync", func() func() {
+			orig := syncFile
+			syncFile = func(f *os.File) error { return errors.New("input/output error") }
+			return func() { syncFile = orig }
+		}},
+		{"rename", func() func() {
+			orig := renameFile
+			renameFile = func(from, to string) error { return errors.New("crash before rename") }
+			return func() { renameFile = orig }
+		}},
+	}
+
+	for _, tt := range failures {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			testFile := filepath.Join(tmpDir, "config.yaml")
+			original := []byte("cluster:\n  name: home\n")
+			if err := os.WriteFile(testFile, original, 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			restore := tt.setup()
+			err := WriteFile(testFile, []byte("cluster:\n  name: replaced-with-a-longer-value\n"), 0644)
+			restore()
+			if err == nil {
+				t.Fatal("expected WriteFile to fail")
+			}
+
+			data, _ := os.ReadFile(testFile)
+			if string(data) != string(original) {
+				t.Errorf("original changed after failed write: %q", data)
+			}
+			entries, _ := os.ReadDir(tmpDir)
+			if len(entries) != 1 {
+				t.Errorf("temporary file left behind: %v", entries)
+			}
+		})
+	}
+}
+
+func TestWriteFileFrom(t *testing.T) {
+	tmpDir := t.TempDir()
+	testFile := filepath.Join(tmpDir, "asset.bin")
+
+	if err := WriteFileFrom(testFile, strings.NewReader("streamed"), 0644); err != nil {
+		t.Fatalf("WriteFileFrom failed: %v", err)
+	}
+	if data, _ := os.ReadFile(testFile); string(data) != "streamed" {
+		t.Errorf("content = %q, want streamed", data)
+	}
+
+	failing := io.MultiReader(strings.NewReader("part"), iotest.ErrReader(errors.New("connection reset")))
+	if err := WriteFileFrom(testFile, failing, 0644); err == nil {
+		t.Fatal("expected WriteFileFrom to fail")
+	}
+	if data, _ := os.ReadFile(testFile); string(data) != "streamed" {
+		t.Errorf("content after failed download = %q, want streamed", data)
+	}
+}
+
+func TestEditFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	testFile := filepath.Join(tmpDir, "config.yaml")
+	os.WriteFile(testFile, []byte("a: 1\n"), 0640)
+
+	err := EditFile(testFile, func(copyPath string) error {
+		if copyPath == testFile {
+			t.Error("edit given the file itself")
+		}
+		return os.WriteFile(copyPath, []byte("a: 2\n"), 0644)
+	})
+	if err != nil {
+		t.Fatalf("EditFile failed: %v", err)
+	}
+	if data, _ := os.ReadFile(testFile); string(data) != "a: 2\n" {
+		t.Errorf("content = %q, want the edit", data)
+	}
+	if info, _ := os.Stat(testFile); info.Mode().Perm() != 0640 {
+		t.Errorf("mode = %04o, want 0640", info.Mode().Perm())
+	}
+
+	// A failed edit leaves the file as it was
+	err = EditFile(testFile, func(copyPath string) error {
+		os.WriteFile(copyPath, []byte("a:"), 0644)
+		return errors.New("yq failed")
+	})
+	if err == nil {
+		t.Fatal("expected EditFile to fail")
+	}
+	if data, _ := os.ReadFile(testFile); string(data) != "a: 2\n" {
+		t.Errorf("content after failed edit = %q", data)
+	}
+
+	entries, _ := os.ReadDir(tmpDir)
+	if len(entries) != 1 {
+		t.Errorf("temporary file left behind: %v", entries)
+	}
+}
+
 func TestFileExists(t *testing.T) {
 	tmpDir := t.TempDir()
 	testFile := filepath.Join(tmpDir, "test.txt")
@@ -5,6 +5,7 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"os"
 	"os/exec"
 	"strings"
 )
@@ -229,6 +230,9 @@ func GetControlPlaneIP() (string, error) {
 }
 
 // CopySecretBetweenNamespaces copies a secret from one namespace to another
+//
+// Deprecated: declare kubernetesSecrets in a service or app manifest and
+// sync them with secretsync instead.
 func CopySecretBetweenNamespaces(secretName, srcNamespace, dstNamespace string) error {
 	// Get secret from source namespace
 	cmd := exec.Command("kubectl", "get", "secret", "-n", srcNamespace, secretName, "-o", "json")
@@ -248,7 +252,14 @@ func CopySecretBetweenNamespaces(secretName, srcNamespace, dstNamespace string)
 		delete(metadata, "resourceVersion")
 		delete(metadata, "uid")
 		delete(metadata, "creationTimestamp")
+		delete(metadata, "managedFields")
 		metadata["namespace"] = dstNamespace
+
+		// Client-side apply keeps the applied manifest, values included, in
+		// this annotation; do not carry it over or create it
+		if annotations, ok := metadata["annotations"].(map[string]interface{}); ok {
+			delete(annotations, "kubectl.kubernetes.io/last-applied-configuration")
+		}
 	}
 
 	// Convert back to JSON
@@ -258,7 +269,7 @@ func CopySecretBetweenNamespaces(secretName, srcNamespace, dstNamespace string)
 	}
 
 	// Apply to destination namespace
-	cmd = exec.Command("kubectl", "apply", "-f", "-")
+	cmd = exec.Command("kubectl", "apply", "--server-side", "--field-manager=wild-central", "-f", "-")
 	cmd.Stdin = strings.NewReader(string(secretJSON))
 	if output, err := cmd.CombinedOutput(); err != nil {
 		return fmt.Errorf("failed to apply secret to %s: %w\nOutput: %s", dstNamespace, err, string(output))
@@ -298,3 +309,17 @@ func GetTalosVersion() (string, error) {
 
 	return strings.TrimSpace(string(output)), nil
 }
+
+// ClusterReachable reports whether the cluster's API server answers its
+// readiness check within a few seconds. A missing kubeconfig means unreachable.
+func ClusterReachable(kubeconfigPath string) bool {
+	if kubeconfigPath == "" {
+		return false
+	}
+	if _, err := os.Stat(kubeconfigPath); err != nil {
+		return false
+	}
+
+	cmd := exec.Command("kubectl", "--kubeconfig", kubeconfigPath, "--request-timeout=5s", "get", "--raw", "/readyz")
+	return cmd.Run() == nil
+}
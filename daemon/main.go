@@ -10,6 +10,10 @@ import (
 	"github.com/gorilla/mux"
 
 	v1 "github.com/wild-cloud/wild-central/daemon/internal/api/v1"
+	"github.com/wild-cloud/wild-central/daemon/internal/instance"
+	"github.com/wild-cloud/wild-central/daemon/internal/migrations"
+	"github.com/wild-cloud/wild-central/daemon/internal/secrets"
+	"github.com/wild-cloud/wild-central/daemon/internal/storage"
 )
 
 var startTime time.Time
@@ -30,6 +34,45 @@ func main() {
 		log.Fatal("WILD_DIRECTORY environment variable is required")
 	}
 
+	// Secrets files handed to scripts of a previous run are no longer in use
+	if err := secrets.SweepRuntimeDir(dataDir); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+
+	// Bring the data directory layout up to date before anything reads it
+	migrated, err := migrations.NewManager(dataDir).Run()
+	if err != nil {
+		log.Fatalf("Failed to migrate data directory: %v", err)
+	}
+	if len(migrated.Applied) > 0 {
+		log.Printf("Migrated data directory from version %d to %d (backup: %s)", migrated.From, migrated.To, migrated.BackupPath)
+	}
+
+	// Unlock secrets encryption if a key file or passphrase is configured
+	keys, err := secrets.LoadKeys(dataDir)
+	if err != nil {
+		log.Fatalf("Failed to unlock secrets: %v", err)
+	}
+	secrets.Unlock(keys...)
+
+	if _, err := secrets.RevealAllowed(); err != nil {
+		log.Fatalf("Invalid secrets reveal setting: %v", err)
+	}
+
+	if _, err := storage.LockTimeout(); err != nil {
+		log.Fatalf("Invalid lock timeout: %v", err)
+	}
+
+	// Remove deleted instances whose trash retention has ended
+	if _, err := instance.TrashRetention(); err != nil {
+		log.Fatalf("Invalid trash retention: %v", err)
+	}
+	if purged, err := instance.NewManager(dataDir).PurgeExpiredTrash(); err != nil {
+		log.Printf("Warning: failed to purge expired trash: %v", err)
+	} else if len(purged) > 0 {
+		log.Printf("Purged %d expired instance(s) from trash", len(purged))
+	}
+
 	// Create API handler with all dependencies
 	api, err := v1.NewAPI(dataDir, directoryPath)
 	if err != nil {
@@ -62,6 +105,9 @@ func main() {
 	log.Printf("Starting wild-central daemon on %s", addr)
 	log.Printf("Data directory: %s", dataDir)
 	log.Printf("Wild Cloud Directory: %s", directoryPath)
+	if key := secrets.ActiveKey(); key != nil {
+		log.Printf("Secrets encryption enabled (key %s)", key.ID)
+	}
 
 	if err := http.ListenAndServe(addr, router); err != nil {
 		log.Fatal("Server failed to start:", err)
@@ -0,0 +1,31 @@
+#!/bin/bash
+# Rotation hook for apps.ghost.dbPassword.
+# Changes the Ghost database user's password in MySQL before Ghost is restarted
+# with the new value. Runs again with the values swapped if the rotation fails.
+set -e
+set -o pipefail
+
+# The daemon writes the old and new values to stdin, one per line
+read -r OLD_VALUE
+read -r NEW_VALUE
+
+CONFIG_FILE="${WILD_CENTRAL_DATA}/instances/${WILD_INSTANCE}/config.yaml"
+DB_USER=$(yq '.apps.ghost.dbUser' "$CONFIG_FILE")
+ROOT_PASSWORD=$(yq '.apps.mysql.rootPassword' "${WILD_SECRETS_FILE}")
+
+if [ -z "$DB_USER" ] || [ "$DB_USER" = "null" ]; then
+    echo "❌ ERROR: apps.ghost.dbUser is not configured"
+    exit 1
+fi
+if [ -z "$ROOT_PASSWORD" ] || [ "$ROOT_PASSWORD" = "null" ]; then
+    echo "❌ ERROR: apps.mysql.rootPassword secret not found"
+    exit 1
+fi
+
+echo "🔐 Updating MySQL password for user ${DB_USER}..."
+kubectl exec -i -n mysql statefulset/mysql -- \
+  env MYSQL_PWD="${ROOT_PASSWORD}" mysql -u root <<SQL
+ALTER USER '${DB_USER}'@'%' IDENTIFIED BY '${NEW_VALUE}';
+FLUSH PRIVILEGES;
+SQL
+echo "✅ MySQL password updated"
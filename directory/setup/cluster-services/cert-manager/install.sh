@@ -62,23 +62,8 @@ kubectl wait --for=condition=Available deployment/cert-manager -n cert-manager -
 kubectl wait --for=condition=Available deployment/cert-manager-cainjector -n cert-manager --timeout=120s
 kubectl wait --for=condition=Available deployment/cert-manager-webhook -n cert-manager --timeout=120s
 
-# Create Cloudflare API token secret
-# Read token from Wild Central secrets file
-echo "🔐 Creating Cloudflare API token secret..."
-SECRETS_FILE="${WILD_CENTRAL_DATA}/instances/${WILD_INSTANCE}/secrets.yaml"
-CLOUDFLARE_API_TOKEN=$(yq '.cloudflare.token' "$SECRETS_FILE" 2>/dev/null)
-
-CLOUDFLARE_API_TOKEN=$(echo "$CLOUDFLARE_API_TOKEN")
-if [ -z "$CLOUDFLARE_API_TOKEN" ] || [ "$CLOUDFLARE_API_TOKEN" = "null" ]; then
-    echo "❌ ERROR: Cloudflare API token not found"
-    echo "💡 Please set: wild secret set cloudflare.token YOUR_TOKEN"
-    exit 1
-fi
-
-kubectl create secret generic cloudflare-api-token \
-  --namespace cert-manager \
-  --from-literal=api-token="${CLOUDFLARE_API_TOKEN}" \
-  --dry-run=client -o yaml | kubectl apply -f -
+# The cloudflare-api-token secret is synced by the daemon from cloudflare.token
+# (see kubernetesSecrets in wild-manifest.yaml) before this script runs
 
 # Ensure webhook is fully operational
 echo "🔍 Verifying cert-manager webhook is fully operational..."
@@ -34,14 +34,18 @@ get_config_file() {
 get_secret() {
     local path="$1"
     local secrets_file="$(get_secrets_file)"
-
-    if [ ! -f "$secrets_file" ]; then
+    local value
+
+    # Prefer decrypted secrets passed in by the daemon
+    if [ -n "${WILD_SECRETS_FILE}" ]; then
+        value=$(yq ".$path" "${WILD_SECRETS_FILE}" 2>/dev/null)
+    elif [ -f "$secrets_file" ]; then
+        value=$(yq ".$path" "$secrets_file" 2>/dev/null)
+    else
         echo ""
         return 1
     fi
 
-    local value=$(yq ".$path" "$secrets_file" 2>/dev/null)
-
     # Remove quotes and return empty string if null
     value=$(echo "$value" | tr -d '"')
     if [ "$value" = "null" ]; then
@@ -47,20 +47,8 @@ fi
 echo "🚀 Deploying ExternalDNS..."
 kubectl apply -k ${EXTERNALDNS_DIR}/kustomize
 
-# Setup Cloudflare API token secret
-echo "🔐 Creating Cloudflare API token secret..."
-SECRETS_FILE="${WILD_CENTRAL_DATA}/instances/${WILD_INSTANCE}/secrets.yaml"
-CLOUDFLARE_API_TOKEN=$(yq '.cloudflare.token' "$SECRETS_FILE" 2>/dev/null | tr -d '"')
-
-if [ -z "$CLOUDFLARE_API_TOKEN" ] || [ "$CLOUDFLARE_API_TOKEN" = "null" ]; then
-    echo "❌ ERROR: Cloudflare API token not found."
-    echo "💡 Please set: wild secret set cloudflare.token YOUR_TOKEN"
-    exit 1
-fi
-kubectl create secret generic cloudflare-api-token \
-  --namespace externaldns \
-  --from-literal=api-token="${CLOUDFLARE_API_TOKEN}" \
-  --dry-run=client -o yaml | kubectl apply -f -
+# The cloudflare-api-token secret is synced by the daemon from cloudflare.token
+# (see kubernetesSecrets in wild-manifest.yaml) before this script runs
 
 # Wait for ExternalDNS to be ready
 echo "⏳ Waiting for Cloudflare ExternalDNS to be ready..."